@@ -0,0 +1,343 @@
+// loadgen is a load-testing harness for the portal. It serves synthetic manifests
+// with configurable response latencies for crawl tests, fetches them with the
+// crawler, and generates search load against a running portal.
+//
+//	loadgen --mode=serve --manifests=10000 --latency=normal:150ms:50ms --urls=urls.txt
+//	loadgen --mode=crawl --urls=urls.txt --concurrency=50
+//	loadgen --mode=search --target=http://localhost:9000 --concurrency=20 --duration=1m
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/models"
+	flag "github.com/spf13/pflag"
+)
+
+var lo = log.New(os.Stderr, "", log.Ldate|log.Ltime)
+
+// Words that synthetic manifests and search queries are made of.
+var words = []string{"fast", "secure", "web", "framework", "database", "parser", "compiler",
+	"library", "cli", "network", "graphics", "crypto", "editor", "kernel", "cloud", "data",
+	"stream", "search", "queue", "cache", "browser", "terminal", "audio", "video", "test"}
+
+func main() {
+	f := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	f.String("mode", "serve", "serve = serves synthetic manifests | crawl = fetches manifest URLs with the crawler | search = generates search load against a portal")
+	f.String("address", ":8090", "address to serve synthetic manifests on (serve)")
+	f.String("root-url", "http://localhost:8090", "root URL of the synthetic manifests written to --urls (serve)")
+	f.Int("manifests", 1000, "number of synthetic manifests (serve)")
+	f.Int("projects", 3, "number of projects per synthetic manifest (serve)")
+	f.String("latency", "fixed:0", "response latency distribution: fixed:d | uniform:min:max | normal:mean:stddev | exp:mean (serve)")
+	f.Float64("error-rate", 0, "fraction (0-1) of requests that fail with a 503 (serve)")
+	f.String("urls", "urls.txt", "file the synthetic manifest URLs are written to (serve) or read from (crawl)")
+	f.String("target", "http://localhost:9000", "root URL of the portal (search)")
+	f.Int("concurrency", 10, "number of concurrent requests (crawl, search)")
+	f.Duration("duration", time.Minute, "duration to generate load for (search)")
+	f.Duration("timeout", time.Second*10, "request timeout (crawl, search)")
+	if err := f.Parse(os.Args[1:]); err != nil {
+		lo.Fatalf("error parsing flags: %v", err)
+	}
+
+	var (
+		mode, _    = f.GetString("mode")
+		urls, _    = f.GetString("urls")
+		conc, _    = f.GetInt("concurrency")
+		timeout, _ = f.GetDuration("timeout")
+	)
+
+	switch mode {
+	case "serve":
+		var (
+			addr, _     = f.GetString("address")
+			rootURL, _  = f.GetString("root-url")
+			num, _      = f.GetInt("manifests")
+			projects, _ = f.GetInt("projects")
+			latency, _  = f.GetString("latency")
+			errRate, _  = f.GetFloat64("error-rate")
+		)
+
+		dist, err := parseLatency(latency)
+		if err != nil {
+			lo.Fatalf("invalid --latency: %v", err)
+		}
+		if err := serve(addr, strings.TrimRight(rootURL, "/"), num, projects, dist, errRate, urls); err != nil {
+			lo.Fatalf("error serving: %v", err)
+		}
+	case "crawl":
+		if err := crawlURLs(urls, conc, timeout); err != nil {
+			lo.Fatalf("error crawling: %v", err)
+		}
+	case "search":
+		var (
+			target, _ = f.GetString("target")
+			dur, _    = f.GetDuration("duration")
+		)
+		runSearch(strings.TrimRight(target, "/"), conc, dur, timeout)
+	default:
+		lo.Fatalf("unknown --mode: %s", mode)
+	}
+}
+
+// latencyDist returns a random response latency.
+type latencyDist func() time.Duration
+
+// parseLatency parses a latency distribution spec, eg: normal:150ms:50ms.
+func parseLatency(s string) (latencyDist, error) {
+	parts := strings.Split(s, ":")
+
+	args := make([]time.Duration, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		d, err := time.ParseDuration(p)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, d)
+	}
+
+	switch {
+	case parts[0] == "fixed" && len(args) == 1:
+		return func() time.Duration { return args[0] }, nil
+	case parts[0] == "uniform" && len(args) == 2 && args[1] >= args[0]:
+		return func() time.Duration {
+			return args[0] + time.Duration(rand.Int63n(int64(args[1]-args[0])+1))
+		}, nil
+	case parts[0] == "normal" && len(args) == 2:
+		return func() time.Duration {
+			return max(0, args[0]+time.Duration(rand.NormFloat64()*float64(args[1])))
+		}, nil
+	case parts[0] == "exp" && len(args) == 1:
+		return func() time.Duration {
+			return time.Duration(rand.ExpFloat64() * float64(args[0]))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown distribution: %s", s)
+}
+
+// synthManifest generates a synthetic manifest. The same n always generates the same manifest.
+func synthManifest(rootURL string, n, projects int) ([]byte, error) {
+	r := rand.New(rand.NewSource(int64(n)))
+	word := func() string { return words[r.Intn(len(words))] }
+
+	base := fmt.Sprintf("%s/m/%d", rootURL, n)
+	m := v1.Manifest{
+		Version: "v1.0.0",
+		Entity: v1.Entity{
+			Type:        "individual",
+			Role:        "owner",
+			Name:        fmt.Sprintf("Entity %d", n),
+			Email:       fmt.Sprintf("entity%d@example.com", n),
+			Description: fmt.Sprintf("A %s %s maintainer.", word(), word()),
+			WebpageURL:  v1.URL{URL: base},
+		},
+		Funding: v1.Funding{
+			Channels: v1.Channels{{GUID: "bank", Type: "bank", Address: fmt.Sprintf("IBAN %d", n), Description: "Bank transfer"}},
+			Plans: v1.Plans{{GUID: "monthly", Status: "active", Name: "Monthly", Description: "Monthly support",
+				Amount: float64(100 + r.Intn(10000)), Currency: "USD", Frequency: "monthly", Channels: []string{"bank"}}},
+			History: v1.History{},
+		},
+	}
+
+	for p := 0; p < projects; p++ {
+		name := fmt.Sprintf("%s-%s-%d-%d", word(), word(), n, p)
+		m.Projects = append(m.Projects, v1.Project{
+			GUID:          name,
+			Name:          name,
+			Description:   fmt.Sprintf("A %s %s %s for %s %s.", word(), word(), word(), word(), word()),
+			WebpageURL:    v1.URL{URL: base + "/" + name},
+			RepositoryURL: v1.URL{URL: base + "/" + name + "/repo"},
+			Licenses:      []string{"spdx:MIT"},
+			Tags:          []string{word(), word()},
+		})
+	}
+
+	return json.Marshal(m)
+}
+
+// serve serves synthetic manifests at /m/{n}/funding.json with the given latency
+// distribution and error rate, and writes their URLs to urlsFile.
+func serve(addr, rootURL string, num, projects int, dist latencyDist, errRate float64, urlsFile string) error {
+	f, err := os.Create(urlsFile)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for n := 0; n < num; n++ {
+		fmt.Fprintf(w, "%s/m/%d/funding.json\n", rootURL, n)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /m/{n}/funding.json", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.PathValue("n"))
+		if err != nil || n < 0 || n >= num {
+			http.NotFound(w, r)
+			return
+		}
+
+		time.Sleep(dist())
+		if errRate > 0 && rand.Float64() < errRate {
+			http.Error(w, "synthetic error", http.StatusServiceUnavailable)
+			return
+		}
+
+		b, err := synthManifest(rootURL, n, projects)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+
+	lo.Printf("serving %d synthetic manifests on %s. URLs written to %s", num, addr, urlsFile)
+	return http.ListenAndServe(addr, mux)
+}
+
+// rawSchema only decodes manifests so that crawl runs measure fetching and not validation.
+type rawSchema struct{}
+
+func (rawSchema) Validate(m models.ManifestData) (models.ManifestData, error) { return m, nil }
+
+func (rawSchema) ParseManifest(_ context.Context, b []byte, u string, _ bool) (models.ManifestData, error) {
+	var m v1.Manifest
+	if err := m.UnmarshalJSON(b); err != nil {
+		return models.ManifestData{}, err
+	}
+	return models.ManifestData{Manifest: m}, nil
+}
+
+// crawlURLs fetches the manifest URLs in a file with the crawler and reports the
+// throughput and latencies.
+func crawlURLs(urlsFile string, conc int, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	b, err := os.ReadFile(urlsFile)
+	if err != nil {
+		return err
+	}
+	urls := strings.Fields(string(b))
+
+	c := crawl.New(&crawl.Opt{
+		HTTP: common.HTTPOpt{
+			ReqTimeout: timeout,
+			Retries:    1,
+			MaxBytes:   1024 * 1024,
+			UserAgent:  "funding-manifest-loadgen",
+		},
+	}, rawSchema{}, &crawl.Callbacks{}, nil, log.New(io.Discard, "", 0))
+
+	var (
+		st    stats
+		start = time.Now()
+	)
+	for r := range c.FetchAll(ctx, urls, conc) {
+		st.add(r.Result.Duration, r.Err == nil)
+	}
+
+	st.report(time.Since(start))
+	return nil
+}
+
+// runSearch generates random search queries against a portal for the given duration.
+func runSearch(target string, conc int, dur, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), dur)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	var (
+		hc    = &http.Client{Timeout: timeout}
+		st    stats
+		wg    sync.WaitGroup
+		start = time.Now()
+	)
+	for n := 0; n < conc; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				q := url.Values{}
+				q.Set("q", words[rand.Intn(len(words))])
+				q.Set("type", []string{"project", "entity"}[rand.Intn(2)])
+
+				t := time.Now()
+				resp, err := hc.Get(target + "/search?" + q.Encode())
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				st.add(time.Since(t), err == nil && resp.StatusCode == http.StatusOK)
+			}
+		}()
+	}
+	wg.Wait()
+
+	st.report(time.Since(start))
+}
+
+// stats records the outcome and latency of requests.
+type stats struct {
+	errs      atomic.Int64
+	latencies []time.Duration
+	mu        sync.Mutex
+}
+
+func (s *stats) add(d time.Duration, ok bool) {
+	if !ok {
+		s.errs.Add(1)
+	}
+
+	s.mu.Lock()
+	s.latencies = append(s.latencies, d)
+	s.mu.Unlock()
+}
+
+func (s *stats) report(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.latencies)
+	if n == 0 {
+		lo.Println("no requests made")
+		return
+	}
+	slices.Sort(s.latencies)
+
+	pc := func(p float64) time.Duration {
+		return s.latencies[int(math.Ceil(p*float64(n)))-1]
+	}
+
+	lo.Printf("requests: %d, errors: %d, elapsed: %s, throughput: %.1f req/s",
+		n, s.errs.Load(), elapsed.Round(time.Millisecond), float64(n)/elapsed.Seconds())
+	lo.Printf("latency p50: %s, p95: %s, p99: %s, max: %s",
+		pc(0.5), pc(0.95), pc(0.99), s.latencies[n-1])
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// forgeTokens are the optional API tokens of forges (github, gitlab)
+// for higher API rate limits.
+type forgeTokens map[string]string
+
+// refreshRepoActivity fetches the activity metrics (last commit, contributors, open
+// issues) of the project repositories that are due for refreshing from their forges'
+// APIs and caches them.
+func refreshRepoActivity(ctx context.Context, app *App, tokens forgeTokens, maxAge string, batchSize int) int {
+	total := 0
+	for ctx.Err() == nil {
+		repos, err := app.core.GetReposForActivity(maxAge, batchSize)
+		if err != nil || len(repos) == 0 {
+			break
+		}
+
+		for _, r := range repos {
+			a, err := fetchRepoActivity(ctx, app, tokens, r)
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				app.lo.Printf("error fetching repo activity: %s: %v", r, err)
+				_ = app.core.UpsertRepoActivity(models.RepoActivity{RepositoryURL: r}, err.Error())
+				continue
+			}
+
+			if err := app.core.UpsertRepoActivity(a, ""); err != nil {
+				return total
+			}
+			total++
+		}
+	}
+
+	app.lo.Printf("refreshed activity of %d repositories", total)
+	return total
+}
+
+func fetchRepoActivity(ctx context.Context, app *App, tokens forgeTokens, repo string) (models.RepoActivity, error) {
+	forge, metaURL, contribURL, ok := core.ActivityAPI(repo)
+	if !ok {
+		return models.RepoActivity{}, fmt.Errorf("unsupported repository host")
+	}
+
+	hdr := http.Header{}
+	if t := tokens[forge]; t != "" {
+		if forge == "gitlab" {
+			hdr.Set("PRIVATE-TOKEN", t)
+		} else {
+			hdr.Set("Authorization", "Bearer "+t)
+		}
+	}
+
+	// Repository metadata.
+	u, _ := url.Parse(metaURL)
+	resp, err := app.crawl.Fetch(ctx, u, crawl.WithHeaders(hdr))
+	if err != nil {
+		return models.RepoActivity{}, err
+	}
+
+	out := models.RepoActivity{RepositoryURL: repo}
+	out.LastCommitAt, out.OpenIssues, err = core.ParseRepoActivity(forge, resp.Body)
+	if err != nil {
+		return models.RepoActivity{}, err
+	}
+
+	// Contributors.
+	u, _ = url.Parse(contribURL)
+	resp, err = app.crawl.Fetch(ctx, u, crawl.WithHeaders(hdr))
+	if err != nil {
+		return models.RepoActivity{}, fmt.Errorf("error fetching contributors: %v", err)
+	}
+
+	var items []json.RawMessage
+	_ = json.Unmarshal(resp.Body, &items)
+	out.Contributors = core.CountFromPagination(resp.Header.Get("X-Total"), resp.Header.Get("Link"), len(items))
+
+	return out, nil
+}
+
+// handleGetRepoActivity returns the cached activity metrics of a repository.
+func handleGetRepoActivity(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		repo = strings.TrimSpace(c.QueryParam("url"))
+	)
+
+	if repo == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "url is required")
+	}
+
+	out, err := app.core.GetRepoActivity([]string{repo})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching activity")
+	}
+	if len(out) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "activity not found")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out[0]})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Number of days of usage stats returned by the usage endpoint.
+const apiUsageDays = 30
+
+// endpointUsage is the aggregated usage of an endpoint.
+type endpointUsage struct {
+	Endpoint  string  `json:"endpoint"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// apiKeyFromRequest returns the API key in the X-API-Key or the Authorization: Bearer header.
+func apiKeyFromRequest(c echo.Context) string {
+	if k := c.Request().Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+
+	return strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+}
+
+// quotaReset returns the time at which daily quotas reset.
+func quotaReset(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// handleAPIKey is a middleware that meters API requests made with an API key. Requests
+// over the key's daily quota are rejected, and every response carries the X-RateLimit-*
+// headers. Anonymous API requests are not affected.
+func handleAPIKey(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := apiKeyFromRequest(c)
+		if !strings.HasPrefix(key, "pk_") || !strings.HasPrefix(c.Path(), "/api/") {
+			return next(c)
+		}
+
+		app := c.Get("app").(*App)
+		k, err := app.core.GetAPIKey(key)
+		if err != nil {
+			if err == core.ErrNotFound {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "error checking API key")
+		}
+
+		remaining := k.DailyQuota - k.RequestsToday - 1
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		h := c.Response().Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(k.DailyQuota))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(quotaReset(time.Now()).Unix(), 10))
+
+		if k.RequestsToday >= k.DailyQuota {
+			h.Set("Retry-After", strconv.Itoa(int(time.Until(quotaReset(time.Now())).Seconds())))
+			return echo.NewHTTPError(http.StatusTooManyRequests, "daily API quota exceeded")
+		}
+
+		k.RequestsToday++
+		c.Set("apiKey", k)
+		err = next(c)
+
+		// Record the request against the route (and not the full URI) to group the stats.
+		status := c.Response().Status
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			status = he.Code
+		} else if err != nil {
+			status = http.StatusInternalServerError
+		}
+		_ = app.core.RecordAPIKeyUsage(k.ID, c.Path(), status >= 400)
+
+		return err
+	}
+}
+
+// handleGetAPIUsage returns the usage stats and the remaining quota of the API key
+// the request is made with.
+func handleGetAPIUsage(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	k, ok := c.Get("apiKey").(models.APIKey)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "an API key is required (X-API-Key header)")
+	}
+
+	days, err := app.core.GetAPIKeyUsage(k.ID, apiUsageDays)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching usage")
+	}
+
+	// Aggregate per endpoint.
+	eps := map[string]*endpointUsage{}
+	for _, d := range days {
+		e, ok := eps[d.Endpoint]
+		if !ok {
+			e = &endpointUsage{Endpoint: d.Endpoint}
+			eps[d.Endpoint] = e
+		}
+		e.Requests += d.Requests
+		e.Errors += d.Errors
+	}
+
+	endpoints := make([]endpointUsage, 0, len(eps))
+	for _, e := range eps {
+		if e.Requests > 0 {
+			e.ErrorRate = float64(e.Errors) / float64(e.Requests)
+		}
+		endpoints = append(endpoints, *e)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Requests > endpoints[j].Requests
+	})
+
+	out := struct {
+		Name          string               `json:"name"`
+		DailyQuota    int                  `json:"daily_quota"`
+		RequestsToday int                  `json:"requests_today"`
+		Remaining     int                  `json:"remaining"`
+		Reset         time.Time            `json:"reset"`
+		Endpoints     []endpointUsage      `json:"endpoints"`
+		Days          []models.APIKeyUsage `json:"days"`
+	}{
+		Name:          k.Name,
+		DailyQuota:    k.DailyQuota,
+		RequestsToday: k.RequestsToday,
+		Remaining:     max(k.DailyQuota-k.RequestsToday, 0),
+		Reset:         quotaReset(time.Now()),
+		Endpoints:     endpoints,
+		Days:          days,
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleAPISubmission accepts a manifest submission made with an API key (eg: by a
+// partner's integration). It goes through the regular submission pipeline and is
+// attributed to the key.
+func handleAPISubmission(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	k, ok := c.Get("apiKey").(models.APIKey)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "an API key is required (X-API-Key header)")
+	}
+
+	res := submitManifest(c.Request().Context(), app, c.FormValue("url"), submitOpt{
+		noRelay:   c.FormValue("no_relay") != "",
+		pin:       c.FormValue("pin"),
+		preview:   c.FormValue("preview") != "",
+		intake:    core.IntakeAPI,
+		intakeRef: k.Name,
+	})
+	if res.retry {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, res.errMessage)
+	}
+
+	out := struct {
+		Message    string `json:"message"`
+		Error      string `json:"error"`
+		PreviewURL string `json:"preview_url,omitempty"`
+		RequestID  string `json:"request_id"`
+	}{res.message, res.errMessage, res.previewURL, crawl.RequestID(c.Request().Context())}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+func handleGetAPIKeys(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	out, err := app.core.GetAPIKeys()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching API keys")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleCreateAPIKey creates an API key. The plain text key is only returned once.
+func handleCreateAPIKey(c echo.Context) error {
+	var (
+		app      = c.Get("app").(*App)
+		name     = strings.TrimSpace(c.FormValue("name"))
+		quota, _ = strconv.Atoi(c.FormValue("daily_quota"))
+	)
+
+	if name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
+	}
+	if quota < 1 {
+		quota = app.consts.APIDailyQuota
+	}
+
+	k, key, err := app.core.CreateAPIKey(name, quota)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error creating API key")
+	}
+
+	out := struct {
+		models.APIKey
+		Key string `json:"key"`
+	}{k, key}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+func handleDeleteAPIKey(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	if err := app.core.DeleteAPIKey(id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error deleting API key")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
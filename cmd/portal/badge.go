@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/labstack/echo/v4"
+)
+
+// Validation statuses of manifest badges.
+const (
+	badgeValid    = "valid"
+	badgeWarnings = "warnings"
+	badgeFailing  = "failing"
+)
+
+var badgeColors = map[string]string{
+	badgeValid:    "#4c1",
+	badgeWarnings: "#dfb317",
+	badgeFailing:  "#e05d44",
+}
+
+// manifestBadges serves SVG badges of the live validation (conformance) status of
+// any funding.json URL, listed or not, for authors to embed in READMEs. Statuses
+// are cached and revalidated when they expire. Badge images are requested by
+// image proxies (eg: GitHub's) from a handful of IPs, so only revalidations
+// are rate limited per client IP.
+type manifestBadges struct {
+	ttl        time.Duration
+	maxItems   int
+	ratePerMin int
+
+	items map[string]badgeItem
+	hits  map[string]proxyHits
+	mu    sync.Mutex
+}
+
+type badgeItem struct {
+	status    string
+	checkedAt time.Time
+}
+
+func initManifestBadges(ttl time.Duration, maxItems, ratePerMin int) *manifestBadges {
+	return &manifestBadges{
+		ttl:        ttl,
+		maxItems:   maxItems,
+		ratePerMin: ratePerMin,
+		items:      make(map[string]badgeItem),
+		hits:       make(map[string]proxyHits),
+	}
+}
+
+// allow records a revalidation by a client and returns false if it's over the rate limit.
+func (b *manifestBadges) allow(ip string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	h, ok := b.hits[ip]
+	if !ok || now.Sub(h.start) > time.Minute {
+		// Purge expired windows so that the map doesn't grow unbounded.
+		if len(b.hits) > 10000 {
+			for k, v := range b.hits {
+				if now.Sub(v.start) > time.Minute {
+					delete(b.hits, k)
+				}
+			}
+		}
+		h = proxyHits{start: now}
+	}
+
+	h.n++
+	b.hits[ip] = h
+
+	return h.n <= b.ratePerMin
+}
+
+// get returns the cached status of a URL and whether it's still fresh.
+func (b *manifestBadges) get(u string) (badgeItem, bool, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	it, ok := b.items[u]
+	return it, ok, ok && time.Since(it.checkedAt) <= b.ttl
+}
+
+// set caches a status. If the cache is full, expired entries are purged, and
+// if there are none, an arbitrary entry is evicted.
+func (b *manifestBadges) set(u, status string) badgeItem {
+	it := badgeItem{status: status, checkedAt: time.Now()}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.items[u]; !ok && len(b.items) >= b.maxItems {
+		for k, v := range b.items {
+			if time.Since(v.checkedAt) > b.ttl {
+				delete(b.items, k)
+			}
+		}
+		for k := range b.items {
+			if len(b.items) < b.maxItems {
+				break
+			}
+			delete(b.items, k)
+		}
+	}
+	b.items[u] = it
+
+	return it
+}
+
+// handleGetBadge returns an SVG badge of the validation status (valid, warnings,
+// failing) of a funding.json URL. Badges of stale statuses are served as-is to
+// clients that are over the rate limit.
+func handleGetBadge(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.badges == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "badges are disabled")
+	}
+
+	u, err := common.IsURL("url", strings.TrimSpace(c.QueryParam("url")), v1.MaxURLLen)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	it, ok, fresh := app.badges.get(u.String())
+	if !fresh {
+		if !app.badges.allow(c.RealIP()) {
+			if !ok {
+				c.Response().Header().Set("Retry-After", "60")
+				return echo.NewHTTPError(http.StatusTooManyRequests, "too many requests. Retry later.")
+			}
+		} else {
+			r := checkConformance(c.Request().Context(), app, u)
+
+			status := badgeValid
+			if r.Summary.Failed > 0 {
+				status = badgeFailing
+			} else if r.Summary.Warnings > 0 {
+				status = badgeWarnings
+			}
+			it = app.badges.set(u.String(), status)
+		}
+	}
+
+	// Cache for the rest of the status's lifetime.
+	maxAge := max(int((app.badges.ttl - time.Since(it.checkedAt)).Seconds()), 0)
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age="+strconv.Itoa(maxAge))
+
+	return c.Blob(http.StatusOK, "image/svg+xml", makeBadge("funding.json", it.status, badgeColors[it.status]))
+}
+
+// makeBadge returns a flat two-part SVG badge. Widths are approximated from
+// the number of characters as the label and message are short ASCII strings.
+func makeBadge(label, msg, color string) []byte {
+	var (
+		lw = len(label)*7 + 10
+		mw = len(msg)*7 + 10
+	)
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="14">%[4]s</text><text x="%[8]d" y="14">%[5]s</text></g></svg>`,
+		lw+mw, lw, mw, label, msg, color, lw/2, lw+mw/2))
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/knadh/koanf/v2"
+)
+
+// configDefaults are the defaults applied to options that are not set in the config.
+// Options without defaults (DB credentials, URLs etc.) are required.
+var configDefaults = map[string]interface{}{
+	"app.address":                 ":9000",
+	"app.template_dir":            "site",
+	"app.maintenance":             false,
+	"app.maintenance_retry_after": "5m",
+	"app.log_format":              "text",
+
+	"data_files.spdx":       "data/spdx.json",
+	"data_files.languages":  "data/languages.json",
+	"data_files.currencies": "data/currencies.json",
+
+	"site.home_num_tags":                        25,
+	"site.home_num_projects":                    20,
+	"site.enable_captcha":                       false,
+	"site.captcha_complexity":                   50000,
+	"site.status_num_runs":                      30,
+	"site.preview_cache_size":                   1000,
+	"site.api_daily_quota":                      10000,
+	"site.backpressure.max_pending_submissions": 20,
+	"site.backpressure.max_queue_depth":         5000,
+	"site.backpressure.max_db_latency":          "500ms",
+	"site.backpressure.retry_after":             "30s",
+	"site.velocity.max_per_domain":              10,
+	"site.velocity.max_per_address":             3,
+	"site.denylist.enabled":                     true,
+	"site.live.max_subscribers":                 500,
+	"site.live.poll_interval":                   "5s",
+	"site.email_intake.enabled":                 false,
+	"site.email_intake.max_urls":                5,
+	"site.email_intake.smtp_host":               "localhost",
+	"site.email_intake.smtp_port":               25,
+
+	"crawl.manifest_uri":          "/funding.json",
+	"crawl.wellknown_uri":         "/.well-known/funding-manifest-urls",
+	"crawl.workers":               100,
+	"crawl.adaptive_concurrency":  false,
+	"crawl.min_workers":           10,
+	"crawl.target_latency":        "2s",
+	"crawl.manifest_age":          "5 DAYS",
+	"crawl.batch_size":            10000,
+	"crawl.queue":                 "postgres",
+	"crawl.queue_lease":           "30m",
+	"crawl.queue_retry_wait":      "5m",
+	"crawl.queue_max_attempts":    3,
+	"crawl.skip_ratelimited_host": true,
+	"crawl.check_provenance":      true,
+	"crawl.partial_projects":      false,
+	"crawl.max_crawl_errors":      5,
+	"crawl.gone_after":            3,
+	"crawl.error_retention":       "90 DAYS",
+	"crawl.fetch_favicons":        true,
+	"crawl.favicon_max_bytes":     50000,
+	"crawl.fetch_opengraph":       false,
+	"crawl.mirror":                false,
+	"crawl.force_revalidate":      false,
+	"crawl.respect_robots":        true,
+	"crawl.robots_ttl":            "24h",
+	"crawl.max_host_conns":        100,
+	"crawl.host_rps":              5.0,
+	"crawl.host_concurrency":      4,
+	"crawl.schedule_min_interval": "6h",
+	"crawl.schedule_max_interval": "168h",
+	"crawl.schedule_factor":       1.5,
+	"crawl.schedule_tick":         "1m",
+	"crawl.breaker_threshold":     10,
+	"crawl.breaker_cooldown":      "10m",
+	"crawl.dns_cache_size":        10000,
+	"crawl.dns_cache_ttl":         "5m",
+	"crawl.retries":               2,
+	"crawl.retry_wait":            "1s",
+	"crawl.retry_multiplier":      2.0,
+	"crawl.retry_jitter":          0.2,
+	"crawl.retry_max_wait":        "30s",
+	"crawl.req_timeout":           "3s",
+	"crawl.max_bytes":             320000,
+	"crawl.wellknown_max_bytes":   20000,
+	"crawl.useragent":             "funding-manifest-bot",
+	"crawl.submit_req_timeout":    "3s",
+	"crawl.submit_max_bytes":      100000,
+	"crawl.submit_dedupe_ttl":     "10m",
+	"crawl.forge_hosts":           []string{"github.com", "gitlab.com", "codeberg.org", "bitbucket.org", "git.sr.ht"},
+	"crawl.disallowed_domains":    []string{},
+	"crawl.allowed_schemes":       []string{"https"},
+	"crawl.allow_private_addrs":   false,
+	"crawl.max_redirects":         10,
+	"crawl.windows":               []string{},
+	"crawl.blackouts":             []string{},
+	"crawl.timezone":              "UTC",
+	"crawl.proxy_url":             "",
+	"crawl.host_proxies":          map[string]interface{}{},
+	"crawl.cross_host_redirects":  true,
+	"crawl.force_http2":           false,
+	"crawl.disable_http2":         false,
+	"crawl.tls_min_version":       "",
+	"crawl.tls_skip_verify":       false,
+	"crawl.disable_keepalives":    false,
+	"crawl.idle_conn_timeout":     "0s",
+	"crawl.host_headers":          []interface{}{},
+	"crawl.metrics_addr":          "",
+	"site.velocity.shared_hosts":  []string{"github.com", "gitlab.com", "codeberg.org", "bitbucket.org", "git.sr.ht"},
+
+	"db.port": 5432,
+
+	"export.dir":    "export",
+	"export.format": "parquet",
+
+	"snapshot.dir": "snapshot",
+
+	"scorecard.api_url":    "https://api.securityscorecards.dev",
+	"scorecard.max_age":    "7 DAYS",
+	"scorecard.batch_size": 500,
+
+	"relay.enabled":       false,
+	"relay.upstreams":     []string{},
+	"relay.accept_tokens": []string{},
+	"relay.timeout":       "10s",
+
+	"telemetry.enabled":  false,
+	"telemetry.url":      "",
+	"telemetry.interval": "24h",
+	"telemetry.timeout":  "10s",
+	"telemetry.accept":   false,
+	"telemetry.max_age":  "30 DAYS",
+
+	"webhooks.enabled":          false,
+	"webhooks.max_per_manifest": 5,
+	"webhooks.max_failures":     20,
+	"webhooks.timeout":          "5s",
+	"webhooks.poll_interval":    "30s",
+
+	"compliance.enabled":                  false,
+	"compliance.exclude_channel_types":    []string{},
+	"compliance.exclude_domains":          []string{},
+	"compliance.exclude_address_patterns": []string{},
+
+	"payment_alerts.enabled":        true,
+	"payment_alerts.require_review": false,
+	"payment_alerts.poll_interval":  "1m",
+
+	"consistency.enabled":  false,
+	"consistency.repair":   true,
+	"consistency.interval": "6h",
+
+	"federation.enabled":   false,
+	"federation.timeout":   "5s",
+	"federation.cache_ttl": "1h",
+
+	"proxy.enabled":    false,
+	"proxy.cache_ttl":  "15m",
+	"proxy.cache_size": 5000,
+	"proxy.rate_limit": 30,
+
+	"badge.enabled":    false,
+	"badge.cache_ttl":  "1h",
+	"badge.cache_size": 10000,
+	"badge.rate_limit": 10,
+
+	"grpc.enabled": false,
+	"grpc.address": ":9090",
+
+	"activity.max_age":    "3 DAYS",
+	"activity.batch_size": 500,
+
+	"translation.url":        "",
+	"translation.api_key":    "",
+	"translation.locales":    []string{},
+	"translation.timeout":    "10s",
+	"translation.batch_size": 100,
+
+	"url_safety.enabled":   false,
+	"url_safety.provider":  "list",
+	"url_safety.list_file": "",
+	"url_safety.api_key":   "",
+	"url_safety.timeout":   "5s",
+	"url_safety.action":    "flag",
+
+	"search.per_page":          20,
+	"search.max_groups":        6,
+	"search.results_per_group": 4,
+
+	"tenants": []interface{}{},
+}
+
+// configError is a validation error on a config option.
+type configError struct {
+	Key string
+	Msg string
+}
+
+func (e configError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Msg)
+}
+
+// configErrors is the list of all validation errors in a config.
+type configErrors []configError
+
+func (e configErrors) Error() string {
+	s := make([]string, len(e))
+	for n, err := range e {
+		s[n] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// configValidator validates the options in a config, collecting all errors.
+type configValidator struct {
+	ko   *koanf.Koanf
+	errs configErrors
+}
+
+func (v *configValidator) fail(key, msg string, a ...interface{}) {
+	v.errs = append(v.errs, configError{Key: key, Msg: fmt.Sprintf(msg, a...)})
+}
+
+func (v *configValidator) required(keys ...string) {
+	for _, k := range keys {
+		if strings.TrimSpace(v.ko.String(k)) == "" {
+			v.fail(k, "is required")
+		}
+	}
+}
+
+func (v *configValidator) duration(key string, min time.Duration) {
+	d, err := time.ParseDuration(v.ko.String(key))
+	if err != nil {
+		v.fail(key, "invalid duration %q (eg: 500ms, 3s, 5m)", v.ko.String(key))
+		return
+	}
+	if d < min {
+		v.fail(key, "should be >= %s", min)
+	}
+}
+
+func (v *configValidator) intRange(key string, min, max int64) {
+	n, err := strconv.ParseInt(v.ko.String(key), 10, 64)
+	if err != nil {
+		v.fail(key, "invalid number %q", v.ko.String(key))
+		return
+	}
+	if n < min || (max > 0 && n > max) {
+		if max > 0 {
+			v.fail(key, "should be between %d and %d", min, max)
+		} else {
+			v.fail(key, "should be >= %d", min)
+		}
+	}
+}
+
+func (v *configValidator) url(key string) {
+	u, err := url.Parse(v.ko.String(key))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.fail(key, "invalid URL %q", v.ko.String(key))
+	}
+}
+
+// initConfigDefaults applies the defaults to the options that are not set.
+func initConfigDefaults(ko *koanf.Koanf) {
+	for k, v := range configDefaults {
+		if !ko.Exists(k) {
+			ko.Set(k, v)
+		}
+	}
+}
+
+// validateConfig validates all the options in the config and returns
+// configErrors with every invalid option.
+func validateConfig(ko *koanf.Koanf) error {
+	v := &configValidator{ko: ko}
+
+	// app.
+	v.required("app.address", "app.root_url", "app.template_dir", "app.admin_username", "app.admin_password")
+	v.url("app.root_url")
+	v.duration("app.maintenance_retry_after", time.Second)
+	if f := ko.String("app.log_format"); f != "text" && f != "json" {
+		v.fail("app.log_format", "should be text or json")
+	}
+
+	v.required("data_files.spdx", "data_files.languages", "data_files.currencies")
+
+	// site.
+	v.intRange("site.home_num_tags", 0, 1000)
+	v.intRange("site.home_num_projects", 0, 1000)
+	v.intRange("site.status_num_runs", 1, 1000)
+	v.intRange("site.preview_cache_size", 1, 0)
+	v.intRange("site.api_daily_quota", 1, 0)
+	if ko.Bool("site.enable_captcha") {
+		v.intRange("site.captcha_complexity", 1000, 0)
+	}
+	v.intRange("site.backpressure.max_pending_submissions", 0, 0)
+	v.intRange("site.backpressure.max_queue_depth", 0, 0)
+	v.duration("site.backpressure.max_db_latency", 0)
+	v.duration("site.backpressure.retry_after", time.Second)
+	v.intRange("site.velocity.max_per_domain", 0, 0)
+	v.intRange("site.velocity.max_per_address", 0, 0)
+	v.intRange("site.live.max_subscribers", 1, 0)
+	v.duration("site.live.poll_interval", time.Second)
+
+	if ko.Bool("site.email_intake.enabled") {
+		v.required("site.email_intake.token")
+		v.intRange("site.email_intake.max_urls", 1, 100)
+	}
+
+	// The SMTP settings send intake replies and webhook confirmations.
+	if ko.Bool("site.email_intake.enabled") || ko.Bool("webhooks.enabled") {
+		v.required("site.email_intake.from", "site.email_intake.smtp_host")
+		v.intRange("site.email_intake.smtp_port", 1, 65535)
+		if _, err := mail.ParseAddress(ko.String("site.email_intake.from")); err != nil {
+			v.fail("site.email_intake.from", "invalid e-mail address: %v", err)
+		}
+		if (ko.String("site.email_intake.smtp_username") == "") != (ko.String("site.email_intake.smtp_password") == "") {
+			v.fail("site.email_intake.smtp_username", "smtp_username and smtp_password should both be set")
+		}
+	}
+
+	// crawl.
+	v.required("crawl.manifest_uri", "crawl.wellknown_uri", "crawl.manifest_age", "crawl.error_retention", "crawl.useragent")
+	for _, k := range []string{"crawl.manifest_uri", "crawl.wellknown_uri"} {
+		if !strings.HasPrefix(ko.String(k), "/") {
+			v.fail(k, "should start with /")
+		}
+	}
+	v.intRange("crawl.workers", 1, 10000)
+	if ko.Bool("crawl.adaptive_concurrency") {
+		v.intRange("crawl.min_workers", 1, int64(ko.Int("crawl.workers")))
+		v.duration("crawl.target_latency", 0)
+	}
+	v.intRange("crawl.batch_size", 1, 0)
+	switch ko.String("crawl.queue") {
+	case "postgres":
+		v.duration("crawl.queue_lease", time.Minute)
+		v.duration("crawl.queue_retry_wait", 0)
+		v.intRange("crawl.queue_max_attempts", 1, 0)
+	case "memory":
+	default:
+		v.fail("crawl.queue", "should be postgres or memory")
+	}
+	v.intRange("crawl.max_crawl_errors", 1, 0)
+	v.intRange("crawl.gone_after", 0, 0)
+	v.intRange("crawl.max_host_conns", 1, 0)
+	v.intRange("crawl.host_concurrency", 0, 0)
+	v.duration("crawl.schedule_min_interval", time.Minute)
+	v.duration("crawl.schedule_max_interval", ko.Duration("crawl.schedule_min_interval"))
+	v.duration("crawl.schedule_tick", time.Second)
+	if ko.Float64("crawl.schedule_factor") < 1 {
+		v.fail("crawl.schedule_factor", "should be >= 1")
+	}
+	v.intRange("crawl.breaker_threshold", 0, 0)
+	if ko.Int("crawl.breaker_threshold") > 0 {
+		v.duration("crawl.breaker_cooldown", time.Second)
+	}
+	v.intRange("crawl.dns_cache_size", 0, 0)
+	if ko.Int("crawl.dns_cache_size") > 0 {
+		v.duration("crawl.dns_cache_ttl", time.Second)
+	}
+	if ko.Float64("crawl.host_rps") < 0 {
+		v.fail("crawl.host_rps", "should be >= 0")
+	}
+	v.intRange("crawl.retries", 1, 10)
+	v.duration("crawl.retry_wait", time.Millisecond)
+	v.duration("crawl.retry_max_wait", 0)
+	if ko.Float64("crawl.retry_multiplier") < 1 {
+		v.fail("crawl.retry_multiplier", "should be >= 1")
+	}
+	if j := ko.Float64("crawl.retry_jitter"); j < 0 || j >= 1 {
+		v.fail("crawl.retry_jitter", "should be >= 0 and < 1")
+	}
+	v.duration("crawl.req_timeout", time.Millisecond)
+	v.intRange("crawl.max_bytes", 1, 0)
+	v.intRange("crawl.wellknown_max_bytes", 1, 0)
+	v.duration("crawl.submit_req_timeout", time.Millisecond)
+	v.intRange("crawl.submit_max_bytes", 1, 0)
+	v.duration("crawl.submit_dedupe_ttl", time.Second)
+	v.intRange("crawl.max_redirects", -1, 50)
+	for _, w := range ko.Strings("crawl.windows") {
+		if _, err := crawl.ParseWindow(w); err != nil {
+			v.fail("crawl.windows", "%v", err)
+		}
+	}
+	for _, b := range ko.Strings("crawl.blackouts") {
+		if _, err := crawl.ParseBlackout(b); err != nil {
+			v.fail("crawl.blackouts", "%v", err)
+		}
+	}
+	if _, err := time.LoadLocation(ko.String("crawl.timezone")); err != nil {
+		v.fail("crawl.timezone", "invalid timezone: %v", err)
+	}
+	if _, err := crawl.NewProxy(ko.String("crawl.proxy_url"), ko.StringMap("crawl.host_proxies")); err != nil {
+		v.fail("crawl.proxy_url", "%v", err)
+	}
+	if ko.Bool("crawl.force_http2") && ko.Bool("crawl.disable_http2") {
+		v.fail("crawl.force_http2", "can't be enabled along with crawl.disable_http2")
+	}
+	if _, err := crawl.ParseTLSVersion(ko.String("crawl.tls_min_version")); err != nil {
+		v.fail("crawl.tls_min_version", "should be empty, 1.0, 1.1, 1.2, or 1.3")
+	}
+	v.duration("crawl.idle_conn_timeout", 0)
+	if _, err := initTenants(ko); err != nil {
+		v.fail("tenants", "%v", err)
+	}
+	if err := crawl.ValidateHostHeaders(initHostHeaders(ko)); err != nil {
+		v.fail("crawl.host_headers", "%v", err)
+	}
+	if len(ko.Strings("crawl.allowed_schemes")) == 0 {
+		v.fail("crawl.allowed_schemes", "should have at least one scheme")
+	}
+	if ko.Bool("crawl.respect_robots") {
+		v.duration("crawl.robots_ttl", time.Minute)
+	}
+	if ko.Bool("crawl.fetch_favicons") {
+		v.intRange("crawl.favicon_max_bytes", 1, 0)
+	}
+
+	v.required("export.dir")
+	if f := ko.String("export.format"); f != "parquet" && f != "csv" {
+		v.fail("export.format", "should be parquet or csv")
+	}
+	v.required("snapshot.dir")
+
+	v.required("scorecard.max_age")
+	v.url("scorecard.api_url")
+	v.intRange("scorecard.batch_size", 1, 0)
+
+	if ko.Bool("telemetry.enabled") {
+		v.url("telemetry.url")
+		v.duration("telemetry.interval", time.Hour)
+		v.duration("telemetry.timeout", time.Second)
+	}
+	if ko.Bool("telemetry.accept") {
+		v.required("telemetry.max_age")
+	}
+
+	if ko.Bool("relay.enabled") {
+		v.duration("relay.timeout", time.Second)
+		for _, u := range ko.Strings("relay.upstreams") {
+			if p, err := url.Parse(u); err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+				v.fail("relay.upstreams", "invalid URL %q", u)
+			}
+		}
+		if len(ko.Strings("relay.upstreams")) > 0 {
+			v.required("relay.token")
+		}
+		for _, t := range ko.Strings("relay.accept_tokens") {
+			if len(t) < 16 {
+				v.fail("relay.accept_tokens", "tokens should be at least 16 characters")
+				break
+			}
+		}
+	}
+
+	if ko.Bool("webhooks.enabled") {
+		v.intRange("webhooks.max_per_manifest", 1, 100)
+		v.intRange("webhooks.max_failures", 1, 0)
+		v.duration("webhooks.timeout", time.Second)
+		v.duration("webhooks.poll_interval", time.Second)
+	}
+
+	if ko.Bool("compliance.enabled") {
+		for _, p := range ko.Strings("compliance.exclude_address_patterns") {
+			if _, err := regexp.Compile(p); err != nil {
+				v.fail("compliance.exclude_address_patterns", "invalid pattern %q: %v", p, err)
+			}
+		}
+	}
+
+	if ko.Bool("consistency.enabled") {
+		v.duration("consistency.interval", time.Minute)
+	}
+	if ko.Bool("payment_alerts.enabled") {
+		v.duration("payment_alerts.poll_interval", time.Second)
+	}
+
+	if ko.Bool("federation.enabled") {
+		v.duration("federation.timeout", time.Second)
+		v.duration("federation.cache_ttl", time.Second)
+	}
+
+	if ko.Bool("proxy.enabled") {
+		v.duration("proxy.cache_ttl", time.Second)
+		v.intRange("proxy.cache_size", 1, 0)
+		v.intRange("proxy.rate_limit", 1, 0)
+	}
+
+	if ko.Bool("badge.enabled") {
+		v.duration("badge.cache_ttl", time.Minute)
+		v.intRange("badge.cache_size", 1, 0)
+		v.intRange("badge.rate_limit", 1, 0)
+	}
+
+	if ko.Bool("grpc.enabled") {
+		v.required("grpc.address")
+	}
+
+	v.required("activity.max_age")
+	v.intRange("activity.batch_size", 1, 0)
+
+	if ko.String("translation.url") != "" {
+		v.url("translation.url")
+		v.duration("translation.timeout", time.Second)
+		v.intRange("translation.batch_size", 1, 0)
+		if len(ko.Strings("translation.locales")) == 0 {
+			v.fail("translation.locales", "should have at least one locale")
+		}
+	}
+
+	if ko.Bool("url_safety.enabled") {
+		switch ko.String("url_safety.provider") {
+		case "list":
+			v.required("url_safety.list_file")
+		case "safebrowsing":
+			v.required("url_safety.api_key")
+			v.duration("url_safety.timeout", time.Second)
+		default:
+			v.fail("url_safety.provider", "should be list or safebrowsing")
+		}
+		if a := ko.String("url_safety.action"); a != crawl.UnsafeFlag && a != crawl.UnsafeQuarantine {
+			v.fail("url_safety.action", "should be %s or %s", crawl.UnsafeFlag, crawl.UnsafeQuarantine)
+		}
+	}
+
+	// db.
+	v.required("db.host", "db.user", "db.db")
+	v.intRange("db.port", 1, 65535)
+
+	// search.
+	v.required("search.root_url", "search.api_key")
+	v.url("search.root_url")
+	v.intRange("search.per_page", 1, 250)
+	v.intRange("search.max_groups", 1, 0)
+	v.intRange("search.results_per_group", 1, 0)
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+
+	return nil
+}
+
+// envPrefix is the prefix of environment variables that override config options.
+// Sections are separated by double underscores. eg: PORTAL_DB__PASSWORD = db.password,
+// PORTAL_SITE__EMAIL_INTAKE__TOKEN = site.email_intake.token.
+const envPrefix = "PORTAL_"
+
+// loadEnvConfig loads config options from the environment variables
+// and returns the keys that were loaded. List values are comma separated.
+func loadEnvConfig(ko *koanf.Koanf, environ []string) []string {
+	var keys []string
+	for _, e := range environ {
+		k, val, ok := strings.Cut(e, "=")
+		if !ok || !strings.HasPrefix(k, envPrefix) {
+			continue
+		}
+
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(k, envPrefix), "__", "."))
+		if key == "" {
+			continue
+		}
+
+		var v interface{} = val
+		switch ko.Get(key).(type) {
+		case []interface{}, []string:
+			v = splitEnvList(val)
+		default:
+			if d, ok := configDefaults[key]; ok {
+				if _, ok := d.([]string); ok {
+					v = splitEnvList(val)
+				}
+			}
+		}
+
+		ko.Set(key, v)
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func splitEnvList(s string) []string {
+	out := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/mod/semver"
+)
+
+// conformance collects the checks of a conformance report.
+type conformance struct {
+	checks []models.ConformanceCheck
+
+	// Skip the (network) provenance check, eg: for drafts that aren't published yet.
+	noProvenance bool
+}
+
+func (c *conformance) add(id, ref string, err error) bool {
+	if err != nil {
+		c.fail(id, ref, err.Error())
+		return false
+	}
+
+	c.checks = append(c.checks, models.ConformanceCheck{ID: id, Status: models.CheckPass, Ref: ref})
+	return true
+}
+
+func (c *conformance) fail(id, ref, msg string) {
+	c.checks = append(c.checks, models.ConformanceCheck{ID: id, Status: models.CheckFail, Ref: ref, Message: msg})
+}
+
+func (c *conformance) warn(id, ref, msg string) {
+	c.checks = append(c.checks, models.ConformanceCheck{ID: id, Status: models.CheckWarn, Ref: ref, Message: msg})
+}
+
+func (c *conformance) skip(id, ref, msg string) {
+	c.checks = append(c.checks, models.ConformanceCheck{ID: id, Status: models.CheckSkip, Ref: ref, Message: msg})
+}
+
+// report returns the final report. The order and IDs of checks are stable
+// so that CI tools can rely on them.
+func (c *conformance) report(u string) models.ConformanceReport {
+	out := models.ConformanceReport{
+		URL:         u,
+		SpecVersion: v1.CurrentVersion,
+		Checks:      c.checks,
+	}
+
+	for _, ch := range c.checks {
+		switch ch.Status {
+		case models.CheckPass:
+			out.Summary.Passed++
+		case models.CheckFail:
+			out.Summary.Failed++
+		case models.CheckWarn:
+			out.Summary.Warnings++
+		case models.CheckSkip:
+			out.Summary.Skipped++
+		}
+	}
+	out.Conformant = out.Summary.Failed == 0
+
+	return out
+}
+
+type conformanceCheck struct {
+	id  string
+	ref string
+}
+
+// Sequence of checks run on the manifest body. Checks after a failed
+// check that they depend on are skipped.
+var conformanceChecks = []conformanceCheck{
+	{"json", "manifest"},
+	{"version", "version"},
+	{"urls", "url"},
+	{"entity", "entity"},
+	{"projects", "projects"},
+	{"repositories", "projects[].repositories"},
+	{"channels", "funding.channels"},
+	{"plans", "funding.plans"},
+	{"history", "funding.history"},
+	{"compliance", "manifest"},
+	{"provenance", "wellKnown"},
+	{"extensions", "extensions"},
+}
+
+// Conformance runs the spec conformance checks on a manifest body. Unlike
+// ParseManifest which stops at the first error, every section is checked.
+func (s *Schema) Conformance(ctx context.Context, c *conformance, b []byte, manifestURL string) {
+	var m v1.Manifest
+	if err := m.UnmarshalJSON(b); !c.add("json", "manifest", err) {
+		c.skipRest("json")
+		return
+	}
+
+	// Version.
+	if !semver.IsValid(m.Version) || semver.Major(m.Version) != v1.MajorVersion {
+		c.fail("version", "version", fmt.Sprintf("version should be %s.x.x (current version is %s)", v1.MajorVersion, v1.CurrentVersion))
+	} else if semver.Compare(m.Version, v1.CurrentVersion) > 0 {
+		c.warn("version", "version", fmt.Sprintf("version %s is newer than the supported version %s", m.Version, v1.CurrentVersion))
+	} else {
+		c.add("version", "version", nil)
+	}
+
+	// URLs. Every other section depends on the parsed URLs.
+	m.URL = v1.URL{URL: manifestURL}
+	err := parseConformanceURL("manifest URL", &m.URL)
+	if err == nil {
+		err = parseConformanceURL("entity.webpageUrl", &m.Entity.WebpageURL)
+	}
+	for n := 0; n < len(m.Projects) && err == nil; n++ {
+		if err = parseConformanceURL(fmt.Sprintf("projects[%d].webpageUrl", n), &m.Projects[n].WebpageURL); err == nil {
+			err = parseConformanceURL(fmt.Sprintf("projects[%d].repositoryUrl", n), &m.Projects[n].RepositoryURL)
+		}
+	}
+	if !c.add("urls", "url", err) {
+		c.skipRest("urls")
+		return
+	}
+
+	// Entity.
+	c.add("entity", "entity", func() error {
+		_, err := s.schema.ValidateEntity(m.Entity, m.URL.URLobj)
+		return err
+	}())
+
+	// Projects.
+	c.add("projects", "projects", func() error {
+		if err := common.InRange[int]("projects", len(m.Projects), 1, 30); err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(m.Projects))
+		for n, o := range m.Projects {
+			if _, err := s.schema.ValidateProject(o, n, m.URL.URLobj); err != nil {
+				return err
+			}
+			ids = append(ids, o.GUID)
+		}
+
+		return checkUniqueIDs("projects[].guid", ids)
+	}())
+
+	// Additional repositories of projects.
+	repos, err := core.ParseRepositories(b)
+	c.add("repositories", "projects[].repositories", err)
+
+	// Funding channels.
+	chIDs := make(map[string]struct{})
+	c.add("channels", "funding.channels", func() error {
+		if err := common.InRange[int]("funding.channels", len(m.Funding.Channels), 1, 10); err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(m.Funding.Channels))
+		for n, o := range m.Funding.Channels {
+			if _, err := s.schema.ValidateChannel(o, n); err != nil {
+				return err
+			}
+			chIDs[o.GUID] = struct{}{}
+			ids = append(ids, o.GUID)
+		}
+
+		return checkUniqueIDs("funding.channels[].guid", ids)
+	}())
+
+	// Funding plans.
+	c.add("plans", "funding.plans", func() error {
+		if err := common.InRange[int]("funding.plans", len(m.Funding.Plans), 1, 10); err != nil {
+			return err
+		}
+
+		for n, o := range m.Funding.Plans {
+			if _, err := s.schema.ValidatePlan(o, n, chIDs); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}())
+
+	// History.
+	c.add("history", "funding.history", func() error {
+		if err := common.InRange[int]("funding.history", len(m.Funding.History), 0, 50); err != nil {
+			return err
+		}
+
+		for n, o := range m.Funding.History {
+			if _, err := s.schema.ValidateHistory(o, n); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}())
+
+	// The instance's compliance rules. The manifest may be valid, but not listable here.
+	c.add("compliance", "manifest", core.CheckCompliance(m, s.compliance))
+
+	// Provenance of URLs on other domains.
+	if c.noProvenance {
+		c.skip("provenance", "wellKnown", "skipped for drafts")
+	} else {
+		c.add("provenance", "wellKnown", s.checkManifestProvenance(ctx, m, repos))
+	}
+
+	// Experimental extensions never fail a manifest, but the ones left out are warned about.
+	if _, warns, err := core.ParseExtensions(b); err == nil && len(warns) > 0 {
+		c.warn("extensions", "extensions", strings.Join(warns, "; "))
+	} else {
+		c.add("extensions", "extensions", err)
+	}
+}
+
+// skipRest marks all the manifest checks after the given check as skipped.
+// If the check isn't a manifest check (eg: fetch), all of them are skipped.
+func (c *conformance) skipRest(after string) {
+	found := !slices.ContainsFunc(conformanceChecks, func(ch conformanceCheck) bool {
+		return ch.id == after
+	})
+	for _, ch := range conformanceChecks {
+		if found {
+			c.skip(ch.id, ch.ref, fmt.Sprintf("skipped as the %s check failed", after))
+		}
+		if ch.id == after {
+			found = true
+		}
+	}
+}
+
+// handleGetConformance fetches the manifest at the given URL and returns a
+// machine-readable report of its conformance to the spec.
+func handleGetConformance(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		mURL = strings.TrimSpace(c.QueryParam("url"))
+	)
+
+	u, err := common.IsURL("url", mURL, v1.MaxURLLen)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, okResp{checkConformance(c.Request().Context(), app, u)})
+}
+
+// checkConformance fetches the manifest at the given URL and runs the conformance checks on it.
+func checkConformance(ctx context.Context, app *App, u *url.URL) models.ConformanceReport {
+	out := &conformance{}
+
+	// Location of the manifest.
+	if u.Scheme != "https" {
+		out.warn("https", "url", "manifest URL should be served over https")
+	} else {
+		out.add("https", "url", nil)
+	}
+
+	if !strings.HasSuffix(u.Path, app.consts.ManifestURI) {
+		out.fail("path", "url", fmt.Sprintf("URL must end in %s", app.consts.ManifestURI))
+	} else {
+		out.add("path", "url", nil)
+	}
+
+	// Fetch the manifest with the stricter submission limits.
+	resp, err := app.crawl.Fetch(ctx, u,
+		crawl.WithTimeout(app.consts.SubmitReqTimeout),
+		crawl.WithMaxBytes(app.consts.SubmitMaxBytes),
+		crawl.IgnoreRobots())
+	if !out.add("fetch", "url", err) {
+		out.skip("content_type", "url", "skipped as the fetch check failed")
+		out.skipRest("fetch")
+		return out.report(u.String())
+	}
+
+	if ct := resp.Header.Get("Content-Type"); !strings.Contains(ct, "json") && !strings.HasPrefix(ct, "text/plain") {
+		out.warn("content_type", "url", fmt.Sprintf("unexpected Content-Type %q. Should be application/json", ct))
+	} else {
+		out.add("content_type", "url", nil)
+	}
+
+	app.schema.Conformance(ctx, out, resp.Body, u.String())
+
+	return out.report(u.String())
+}
+
+// parseConformanceURL parses the URL and the optional wellKnown URL of a v1.URL.
+func parseConformanceURL(tag string, u *v1.URL) error {
+	p, err := common.IsURL(tag, u.URL, v1.MaxURLLen)
+	if err != nil {
+		return err
+	}
+	u.URLobj = p
+
+	if u.WellKnown != "" {
+		w, err := common.IsURL(tag, u.WellKnown, v1.MaxURLLen)
+		if err != nil {
+			return err
+		}
+		u.WellKnownObj = w
+	}
+
+	return nil
+}
+
+// checkUniqueIDs returns an error if there are duplicate IDs.
+func checkUniqueIDs(tag string, ids []string) error {
+	s := slices.Clone(ids)
+	slices.Sort(s)
+	if len(slices.Compact(s)) != len(ids) {
+		return fmt.Errorf("%s must be unique", tag)
+	}
+
+	return nil
+}
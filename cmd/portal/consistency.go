@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// consistency periodically cross-checks the search index and the preview card cache
+// against the DB, the source of truth, for drift that otherwise goes unnoticed until
+// a manual sync-search: missing entries (active manifests that aren't indexed),
+// extra entries (of manifests that are no longer active), and stale entries (out of
+// date with the DB). Discrepancies are optionally repaired.
+//
+// As the index is read before the DB, manifests updated during a check may show up
+// as drift. Repairing them only re-indexes them again, which is harmless.
+type consistency struct {
+	repair bool
+
+	// Serialises checks, eg: a manual run during a scheduled one.
+	checking sync.Mutex
+
+	last    consistencyReport
+	runs    int64
+	drift   int64
+	repairs int64
+	errors  int64
+	mu      sync.Mutex
+}
+
+// consistencyReport is the result of a consistency check.
+type consistencyReport struct {
+	StartedAt time.Time `json:"started_at"`
+	Duration  float64   `json:"duration"`
+	Manifests int       `json:"manifests"`
+
+	// Manifests with missing, extra, and stale search index entries.
+	Missing int `json:"missing"`
+	Extra   int `json:"extra"`
+	Stale   int `json:"stale"`
+
+	// Extra and stale preview cards.
+	CacheExtra int `json:"cache_extra"`
+	CacheStale int `json:"cache_stale"`
+
+	Repaired int    `json:"repaired"`
+	Error    string `json:"error,omitempty"`
+}
+
+// indexedManifest is a manifest's entries in the search index.
+type indexedManifest struct {
+	entityID  string
+	updatedAt int64
+
+	// Project ID => updated_at.
+	projects map[string]int64
+}
+
+func initConsistency(repair bool) *consistency {
+	return &consistency{repair: repair}
+}
+
+// run checks for drift at every interval. It blocks forever.
+func (cs *consistency) run(app *App, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		cs.check(app)
+	}
+}
+
+// check cross-checks the search index, and if the site is running, the preview
+// card cache against the DB, and records and logs the report.
+func (cs *consistency) check(app *App) consistencyReport {
+	cs.checking.Lock()
+	defer cs.checking.Unlock()
+
+	r := consistencyReport{StartedAt: time.Now()}
+	if err := cs.checkIndex(app, &r); err != nil {
+		r.Error = err.Error()
+		app.lo.Printf("error checking search index consistency: %v", err)
+	}
+	r.Duration = time.Since(r.StartedAt).Seconds()
+
+	if r.Missing+r.Extra+r.Stale+r.CacheExtra+r.CacheStale > 0 {
+		app.lo.Printf("consistency: %d manifests: %d missing, %d extra, %d stale in search, %d extra, %d stale cards, %d repaired",
+			r.Manifests, r.Missing, r.Extra, r.Stale, r.CacheExtra, r.CacheStale, r.Repaired)
+	}
+
+	cs.mu.Lock()
+	cs.last = r
+	cs.runs++
+	cs.drift += int64(r.Missing + r.Extra + r.Stale + r.CacheExtra + r.CacheStale)
+	cs.repairs += int64(r.Repaired)
+	if r.Error != "" {
+		cs.errors++
+	}
+	cs.mu.Unlock()
+
+	return r
+}
+
+func (cs *consistency) checkIndex(app *App, r *consistencyReport) error {
+	cats, err := app.core.GetTagCategoryMap()
+	if err != nil {
+		return fmt.Errorf("error fetching tag categories: %v", err)
+	}
+
+	// Read the index before the DB so that manifests that are added to the DB
+	// in between aren't reported as missing.
+	idx, err := getIndexedManifests(app)
+	if err != nil {
+		return err
+	}
+
+	var cards map[int]string
+	if app.cards != nil {
+		cards = app.cards.Versions()
+	}
+
+	lastID := 0
+	for {
+		items, err := app.core.GetManifests(lastID, 1000)
+		if err != nil {
+			return fmt.Errorf("error fetching manifests: %v", err)
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, m := range items {
+			r.Manifests++
+
+			im, ok := idx[m.ID]
+			delete(idx, m.ID)
+
+			// Repair drifted entries by re-indexing the manifest.
+			missing, stale := diffIndexed(m, im, ok)
+			if missing {
+				r.Missing++
+			} else if stale {
+				r.Stale++
+			}
+			if (missing || stale) && cs.repair {
+				tenants, _ := app.core.GetManifestTenants(m.ID)
+				updateSearchRecord(m, m.Status, cats, manifestScorecards(app.core, m), tenants, app.search)
+				r.Repaired++
+			}
+
+			// Cards are versioned by the manifest's update time.
+			if v, ok := cards[m.ID]; ok {
+				delete(cards, m.ID)
+				if v != m.UpdatedAt.String() {
+					r.CacheStale++
+					if cs.repair {
+						app.cards.Delete(m.ID)
+						r.Repaired++
+					}
+				}
+			}
+		}
+
+		lastID = items[len(items)-1].ID
+	}
+
+	// Whatever is left belongs to manifests that are no longer active.
+	for id := range idx {
+		r.Extra++
+		if cs.repair && app.search.Delete(id) == nil {
+			r.Repaired++
+		}
+	}
+	for id := range cards {
+		r.CacheExtra++
+		if cs.repair {
+			app.cards.Delete(id)
+			r.Repaired++
+		}
+	}
+
+	return nil
+}
+
+// getIndexedManifests returns the entries in the search index by manifest ID.
+func getIndexedManifests(app *App) (map[int]*indexedManifest, error) {
+	ents, err := app.search.GetIndexedEntities()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching indexed entities: %v", err)
+	}
+	prjs, err := app.search.GetIndexedProjects()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching indexed projects: %v", err)
+	}
+
+	out := make(map[int]*indexedManifest, len(ents))
+	get := func(id int) *indexedManifest {
+		im, ok := out[id]
+		if !ok {
+			im = &indexedManifest{projects: map[string]int64{}}
+			out[id] = im
+		}
+		return im
+	}
+
+	for _, e := range ents {
+		im := get(e.ManifestID)
+		im.entityID = e.ID
+		im.updatedAt = e.UpdatedAt
+	}
+	for _, p := range prjs {
+		get(p.ManifestID).projects[p.ID] = p.UpdatedAt
+	}
+
+	return out, nil
+}
+
+// diffIndexed compares an active manifest with its entries in the index (as
+// indexed by updateSearchRecord). missing is set if it has no entity or lacks
+// projects, and stale if the entries are out of date or it has extra projects.
+func diffIndexed(m models.ManifestData, im *indexedManifest, ok bool) (missing bool, stale bool) {
+	if !ok || im.entityID == "" {
+		return true, false
+	}
+
+	updated := m.CreatedAt.Unix()
+	if im.entityID != m.GUID || im.updatedAt != updated || len(im.projects) != len(m.Manifest.Projects) {
+		stale = true
+	}
+	for _, p := range m.Manifest.Projects {
+		u, ok := im.projects[m.GUID+"/"+p.GUID]
+		if !ok {
+			return true, stale
+		}
+		if u != updated {
+			stale = true
+		}
+	}
+
+	return false, stale
+}
+
+// write writes the metrics of the checks in the Prometheus text exposition format.
+func (cs *consistency) write(w io.Writer) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP portal_consistency_runs_total Consistency checks run.")
+	fmt.Fprintln(w, "# TYPE portal_consistency_runs_total counter")
+	fmt.Fprintf(w, "portal_consistency_runs_total %d\n", cs.runs)
+
+	fmt.Fprintln(w, "# HELP portal_consistency_errors_total Consistency checks that failed.")
+	fmt.Fprintln(w, "# TYPE portal_consistency_errors_total counter")
+	fmt.Fprintf(w, "portal_consistency_errors_total %d\n", cs.errors)
+
+	fmt.Fprintln(w, "# HELP portal_consistency_drift_total Drifted entries found across all checks.")
+	fmt.Fprintln(w, "# TYPE portal_consistency_drift_total counter")
+	fmt.Fprintf(w, "portal_consistency_drift_total %d\n", cs.drift)
+
+	fmt.Fprintln(w, "# HELP portal_consistency_repairs_total Drifted entries repaired across all checks.")
+	fmt.Fprintln(w, "# TYPE portal_consistency_repairs_total counter")
+	fmt.Fprintf(w, "portal_consistency_repairs_total %d\n", cs.repairs)
+
+	r := cs.last
+	fmt.Fprintln(w, "# HELP portal_consistency_drift Drifted entries found by the last check by kind.")
+	fmt.Fprintln(w, "# TYPE portal_consistency_drift gauge")
+	for _, d := range []struct {
+		kind string
+		n    int
+	}{
+		{"missing", r.Missing}, {"extra", r.Extra}, {"stale", r.Stale},
+		{"cache_extra", r.CacheExtra}, {"cache_stale", r.CacheStale},
+	} {
+		fmt.Fprintf(w, "portal_consistency_drift{kind=%q} %d\n", d.kind, d.n)
+	}
+
+	fmt.Fprintln(w, "# HELP portal_consistency_last_run_timestamp_seconds Start time of the last check.")
+	fmt.Fprintln(w, "# TYPE portal_consistency_last_run_timestamp_seconds gauge")
+	fmt.Fprintf(w, "portal_consistency_last_run_timestamp_seconds %d\n", r.StartedAt.Unix())
+
+	fmt.Fprintln(w, "# HELP portal_consistency_last_run_duration_seconds Duration of the last check.")
+	fmt.Fprintln(w, "# TYPE portal_consistency_last_run_duration_seconds gauge")
+	fmt.Fprintf(w, "portal_consistency_last_run_duration_seconds %g\n", r.Duration)
+}
+
+// handleGetConsistency returns the report of the last consistency check.
+func handleGetConsistency(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.consistency == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "consistency checks are disabled")
+	}
+
+	app.consistency.mu.Lock()
+	out := app.consistency.last
+	app.consistency.mu.Unlock()
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetConsistencyMetrics returns the metrics of the consistency checks for scraping.
+func handleGetConsistencyMetrics(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.consistency == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "consistency checks are disabled")
+	}
+
+	c.Response().Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+	app.consistency.write(c.Response())
+
+	return nil
+}
+
+// handleRunConsistency runs a consistency check and returns its report.
+func handleRunConsistency(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.consistency == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "consistency checks are disabled")
+	}
+
+	return c.JSON(http.StatusOK, okResp{app.consistency.check(app)})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// submitDeduper makes manifest submissions idempotent. A URL that is already being
+// processed is not fetched again, and the result of a submission is remembered
+// against its idempotency key (a per-form token or the Idempotency-Key header) so
+// that a repeated submission (eg: double-click) gets the original response.
+type submitDeduper struct {
+	ttl time.Duration
+
+	inflight map[string]struct{}
+	results  map[string]submitResult
+	mu       sync.Mutex
+}
+
+type submitResult struct {
+	code int
+	page Page
+	at   time.Time
+}
+
+func newSubmitDeduper(ttl time.Duration) *submitDeduper {
+	return &submitDeduper{
+		ttl:      ttl,
+		inflight: make(map[string]struct{}),
+		results:  make(map[string]submitResult),
+	}
+}
+
+// begin marks a URL as being processed. It returns false if the URL is already
+// being processed by another request.
+func (s *submitDeduper) begin(url string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.inflight[url]; ok {
+		return false
+	}
+	s.inflight[url] = struct{}{}
+
+	return true
+}
+
+// end marks a URL as processed.
+func (s *submitDeduper) end(url string) {
+	s.mu.Lock()
+	delete(s.inflight, url)
+	s.mu.Unlock()
+}
+
+// get returns the remembered result of a submission by its idempotency key.
+func (s *submitDeduper) get(key string) (submitResult, bool) {
+	if key == "" {
+		return submitResult{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.results[key]
+	if !ok || time.Since(r.at) > s.ttl {
+		return submitResult{}, false
+	}
+
+	return r, true
+}
+
+// set remembers the result of a submission against its idempotency key.
+// Expired results are purged on every write.
+func (s *submitDeduper) set(key string, code int, p Page) {
+	if key == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, r := range s.results {
+		if now.Sub(r.at) > s.ttl {
+			delete(s.results, k)
+		}
+	}
+
+	s.results[key] = submitResult{code: code, page: p, at: now}
+}
+
+// makeIdempotencyKey returns a random key to be embedded in a submission form.
+func makeIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// paymentAddresses returns the non-empty payment addresses of a manifest's funding channels.
+func paymentAddresses(m models.ManifestData) []string {
+	var out []string
+	for _, ch := range m.Manifest.Funding.Channels {
+		if a := strings.TrimSpace(ch.Address); a != "" {
+			out = append(out, a)
+		}
+	}
+
+	return out
+}
+
+// checkDenylist checks the payment addresses of a new listing against the denylist
+// and returns a moderation note if any of them are on it.
+func checkDenylist(app *App, m models.ManifestData) (string, error) {
+	if !app.consts.EnableDenylist {
+		return "", nil
+	}
+
+	res, err := app.core.GetDenylisted(paymentAddresses(m))
+	if err != nil {
+		return "", err
+	}
+	if len(res) == 0 {
+		return "", nil
+	}
+
+	var notes []string
+	for _, e := range res {
+		n := e.Address
+		if e.Reason != "" {
+			n += " (" + e.Reason + ")"
+		}
+		notes = append(notes, n)
+	}
+
+	return fmt.Sprintf("Held for moderation: payment address(es) on the denylist: %s", strings.Join(notes, ", ")), nil
+}
+
+// handleGetDenylist exports the payment address denylist in the exchange format.
+func handleGetDenylist(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	out, err := app.core.GetDenylist()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching denylist")
+	}
+
+	return c.JSON(http.StatusOK, models.Denylist{Version: models.DenylistVersion, Addresses: out})
+}
+
+// handleImportDenylist imports a denylist in the exchange format. Existing
+// addresses are updated. ?source= sets the source of entries that don't have one.
+func handleImportDenylist(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	var l models.Denylist
+	if err := c.Bind(&l); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid denylist: %v", err))
+	}
+	if l.Version != models.DenylistVersion {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unsupported denylist version. Should be %s", models.DenylistVersion))
+	}
+
+	n, err := app.core.ImportDenylist(l.Addresses, c.QueryParam("source"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error importing denylist")
+	}
+
+	return c.JSON(http.StatusOK, okResp{n})
+}
+
+func handleDeleteDenylist(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		addr = strings.TrimSpace(c.Param("address"))
+	)
+
+	if err := app.core.DeleteDenylist(addr); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error deleting from denylist")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
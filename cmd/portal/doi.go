@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// resolveCitations resolves the DOIs of projects (project GUID => DOI) with DataCite
+// and returns their citations. DOIs that can't be resolved are returned unverified.
+func (s *Schema) resolveCitations(ctx context.Context, dois map[string]string) map[string]models.Citation {
+	out := make(map[string]models.Citation, len(dois))
+	for guid, doi := range dois {
+		out[guid] = models.Citation{DOI: doi}
+		if s.crawl == nil {
+			continue
+		}
+
+		u, err := url.Parse(core.DataCiteURL + url.PathEscape(doi))
+		if err != nil {
+			continue
+		}
+
+		resp, err := s.crawl.Fetch(ctx, u)
+		if err != nil {
+			lo.Printf("error resolving DOI: %s: %v", doi, err)
+			continue
+		}
+
+		c, err := core.ParseDataCite(resp.Body)
+		if err != nil {
+			lo.Printf("error resolving DOI: %s: %v", doi, err)
+			continue
+		}
+		out[guid] = c
+	}
+
+	return out
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/labstack/echo/v4"
+)
+
+// Max size of an inbound e-mail message.
+const maxEmailBytes = 1 << 20
+
+var reEmailURL = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+
+// emailIntake accepts manifest submissions via e-mail. Messages received on the
+// inbound address are relayed to the portal as raw RFC 5322 messages (a feature
+// offered by most mail providers and MTAs), and the results are replied to the
+// sender over SMTP.
+type emailIntake struct {
+	token   string
+	maxURLs int
+
+	from     *mail.Address
+	smtpAddr string
+	smtpAuth smtp.Auth
+}
+
+// inboundEmail is a parsed inbound submission e-mail.
+type inboundEmail struct {
+	from      string
+	subject   string
+	messageID string
+	urls      []string
+
+	// The message is an automated one (auto-reply, bounce).
+	auto bool
+}
+
+// parseInboundEmail parses a raw e-mail message and extracts the manifest URLs
+// (URLs ending in manifestURI) from its plain text body.
+func parseInboundEmail(r io.Reader, manifestURI string, maxURLs int) (inboundEmail, error) {
+	msg, err := mail.ReadMessage(io.LimitReader(r, maxEmailBytes))
+	if err != nil {
+		return inboundEmail{}, fmt.Errorf("error parsing e-mail: %v", err)
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return inboundEmail{}, fmt.Errorf("invalid From address: %v", err)
+	}
+
+	// Decode single part bodies. Parts of multipart bodies are decoded by the multipart reader.
+	var r2 io.Reader = msg.Body
+	switch strings.ToLower(msg.Header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		r2 = quotedprintable.NewReader(msg.Body)
+	case "base64":
+		r2 = base64.NewDecoder(base64.StdEncoding, msg.Body)
+	}
+
+	body, err := emailText(msg.Header.Get("Content-Type"), r2)
+	if err != nil {
+		return inboundEmail{}, err
+	}
+
+	out := inboundEmail{
+		from:      from.Address,
+		subject:   msg.Header.Get("Subject"),
+		messageID: msg.Header.Get("Message-ID"),
+	}
+	if h := msg.Header.Get("Auto-Submitted"); h != "" && h != "no" {
+		out.auto = true
+	}
+	for _, u := range reEmailURL.FindAllString(string(body), -1) {
+		u = strings.TrimRight(u, ".,;:")
+		if !strings.HasSuffix(u, manifestURI) || slices.Contains(out.urls, u) {
+			continue
+		}
+
+		if len(out.urls) >= maxURLs {
+			break
+		}
+		out.urls = append(out.urls, u)
+	}
+
+	return out, nil
+}
+
+// emailText returns the plain text body of a message, picking the
+// first text/plain part of multipart messages.
+func emailText(contentType string, body io.Reader) ([]byte, error) {
+	typ, params, err := mime.ParseMediaType(contentType)
+	if err != nil || contentType == "" {
+		return io.ReadAll(body)
+	}
+
+	if !strings.HasPrefix(typ, "multipart/") {
+		return io.ReadAll(body)
+	}
+
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading e-mail body: %v", err)
+		}
+
+		// Nested multipart/alternative.
+		ct := p.Header.Get("Content-Type")
+		if strings.HasPrefix(ct, "multipart/") {
+			if b, err := emailText(ct, p); err == nil && len(b) > 0 {
+				return b, nil
+			}
+			continue
+		}
+
+		if ct == "" || strings.HasPrefix(ct, "text/plain") {
+			return io.ReadAll(p)
+		}
+	}
+
+	return nil, errors.New("no text/plain part found in e-mail")
+}
+
+// reply e-mails the results of the submissions to the sender.
+func (e *emailIntake) reply(m inboundEmail, results []string, reqID string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", m.from)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Re: "+strings.TrimPrefix(m.subject, "Re: ")))
+	if m.messageID != "" {
+		fmt.Fprintf(&b, "In-Reply-To: %s\r\nReferences: %s\r\n", m.messageID, m.messageID)
+	}
+	b.WriteString("Auto-Submitted: auto-replied\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	if len(results) == 0 {
+		b.WriteString("No funding manifest URLs were found in your e-mail.\r\n")
+	} else {
+		b.WriteString("Results of your funding manifest submission(s):\r\n\r\n")
+		for _, r := range results {
+			b.WriteString(r + "\r\n\r\n")
+		}
+		fmt.Fprintf(&b, "Request ID (for reporting issues): %s\r\n", reqID)
+	}
+
+	return smtp.SendMail(e.smtpAddr, e.smtpAuth, e.from.Address, []string{m.from}, b.Bytes())
+}
+
+// send e-mails an automated plain text message, eg: confirmation links.
+func (e *emailIntake) send(to, subject, body string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	b.WriteString("Auto-Submitted: auto-generated\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return smtp.SendMail(e.smtpAddr, e.smtpAuth, e.from.Address, []string{to}, b.Bytes())
+}
+
+// handleInboundEmail accepts a raw inbound e-mail message relayed by the mail
+// server, submits the manifest URLs found in it, and replies with the results.
+func handleInboundEmail(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.email == nil || app.email.token == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "e-mail intake is disabled")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(c.QueryParam("token")), []byte(app.email.token)) != 1 {
+		return echo.NewHTTPError(http.StatusForbidden, "invalid token")
+	}
+
+	m, err := parseInboundEmail(c.Request().Body, app.consts.ManifestURI, app.email.maxURLs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Ignore automated messages (auto-replies, bounces) to avoid mail loops.
+	if m.auto {
+		return c.JSON(http.StatusOK, okResp{true})
+	}
+
+	// Submissions are attributed to the sender's domain and not the address.
+	_, domain, _ := strings.Cut(m.from, "@")
+
+	results := make([]string, 0, len(m.urls))
+	for _, u := range m.urls {
+		res := submitManifest(c.Request().Context(), app, u, submitOpt{intake: core.IntakeEmail, intakeRef: strings.ToLower(domain)})
+
+		msg := res.errMessage
+		if msg == "" {
+			msg = res.message
+		}
+		if msg == "success" {
+			msg = "Submitted successfully. It will be listed after review."
+		}
+		results = append(results, fmt.Sprintf("%s\r\n  %s", u, msg))
+	}
+
+	reqID := crawl.RequestID(c.Request().Context())
+	app.lo.Printf("e-mail submission from %s: %d URL(s) request_id=%s", m.from, len(m.urls), reqID)
+	if err := app.email.reply(m, results, reqID); err != nil {
+		app.lo.Printf("error replying to e-mail submission: %s: %v", m.from, err)
+	}
+
+	return c.JSON(http.StatusOK, okResp{results})
+}
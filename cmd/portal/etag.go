@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// makeETag returns a weak ETag derived from the given parts (eg: manifest hashes).
+// The build version is included so that responses change when the server does.
+func makeETag(parts ...string) string {
+	h := sha256.New()
+	h.Write([]byte(buildString))
+	for _, p := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(p))
+	}
+
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// manifestETag returns the ETag parts of a manifest. Besides the hash of its contents,
+// the fields that are updated independently of the contents are included.
+func manifestETag(m models.ManifestData) string {
+	return strings.Join([]string{
+		strconv.Itoa(m.ID),
+		m.Hash,
+		m.Status,
+		m.Verification,
+		strconv.FormatInt(m.UpdatedAt.UnixNano(), 10),
+	}, ":")
+}
+
+// checkETag sets the ETag header on the response and returns true if the request's
+// If-None-Match matches it, in which case the handler should respond with notModified().
+func checkETag(c echo.Context, etag string) bool {
+	c.Response().Header().Set("ETag", etag)
+
+	inm := c.Request().Header.Get("If-None-Match")
+	if inm == "" {
+		return false
+	}
+
+	// Weak comparison (RFC 9110 13.1.2).
+	tag := strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(inm, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func notModified(c echo.Context) error {
+	return c.NoContent(http.StatusNotModified)
+}
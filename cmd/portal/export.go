@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/parquet"
+)
+
+// Number of rows in a row group of exported parquet files.
+const exportRowGroupSize = 100000
+
+// tableWriter writes the rows of an exported table to a file.
+type tableWriter interface {
+	write(row []interface{}) error
+	close() error
+}
+
+// exportTables writes the analytics-ready tables as parquet or CSV (with headers)
+// files to the given directory. Files are written atomically so that a reader
+// never sees a partial export.
+func exportTables(app *App, dir, format string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, t := range core.ExportTables {
+		n, err := exportTable(app, t, format, filepath.Join(dir, t+"."+format))
+		if err != nil {
+			return fmt.Errorf("error exporting %s: %v", t, err)
+		}
+		app.lo.Printf("exported %d rows of %s", n, t)
+	}
+
+	return nil
+}
+
+func exportTable(app *App, table, format, fPath string) (int, error) {
+	f, err := os.CreateTemp(filepath.Dir(fPath), "."+table+"-*."+format)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var (
+		w tableWriter
+		n = 0
+	)
+	if err := app.core.ExportTable(table, func(cols []*sql.ColumnType) error {
+		var err error
+		w, err = newTableWriter(f, format, cols)
+		return err
+	}, func(row []interface{}) error {
+		n++
+		return w.write(row)
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := w.close(); err != nil {
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+
+	return n, os.Rename(f.Name(), fPath)
+}
+
+func newTableWriter(f io.Writer, format string, cols []*sql.ColumnType) (tableWriter, error) {
+	if format == "csv" {
+		w := &csvTable{w: csv.NewWriter(f)}
+		names := make([]string, 0, len(cols))
+		for _, c := range cols {
+			names = append(names, c.Name())
+		}
+		return w, w.w.Write(names)
+	}
+
+	pc := make([]parquet.Column, 0, len(cols))
+	for _, c := range cols {
+		pc = append(pc, parquet.Column{Name: c.Name(), Type: parquetType(c.DatabaseTypeName())})
+	}
+	w, err := parquet.NewWriter(f, pc, exportRowGroupSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parquetTable{w: w}, nil
+}
+
+// parquetType returns the parquet column type of a Postgres type.
+func parquetType(dbType string) parquet.Type {
+	switch dbType {
+	case "INT2", "INT4", "INT8":
+		return parquet.Int64
+	case "FLOAT4", "FLOAT8", "NUMERIC":
+		return parquet.Double
+	case "BOOL":
+		return parquet.Bool
+	case "DATE", "TIMESTAMP", "TIMESTAMPTZ":
+		return parquet.Timestamp
+	}
+
+	return parquet.String
+}
+
+type parquetTable struct {
+	w *parquet.Writer
+}
+
+func (t *parquetTable) write(row []interface{}) error {
+	return t.w.Write(row)
+}
+
+func (t *parquetTable) close() error {
+	return t.w.Close()
+}
+
+type csvTable struct {
+	w   *csv.Writer
+	rec []string
+}
+
+func (t *csvTable) write(row []interface{}) error {
+	t.rec = t.rec[:0]
+	for _, v := range row {
+		t.rec = append(t.rec, csvValue(v))
+	}
+
+	return t.w.Write(t.rec)
+}
+
+func (t *csvTable) close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// csvValue formats a DB value for CSV in a form that DuckDB and BigQuery
+// can infer types from.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Header set on federated lookups so that instances don't forward them again.
+const federatedHeader = "X-Portal-Federated"
+
+// federation resolves lookups that miss on this instance with the authoritative
+// instance of the manifest's domain, if the domain publishes one with a _portal
+// TXT record (_portal.example.com TXT "v=portal1; url=https://portal.example.com")
+// or a _portal._tcp SRV record.
+type federation struct {
+	self     string
+	ttl      time.Duration
+	resolver *net.Resolver
+	hc       *http.Client
+
+	// Discovered instances by domain. Misses are cached too.
+	cache map[string]fedInstance
+	mu    sync.Mutex
+}
+
+type fedInstance struct {
+	url     string
+	expires time.Time
+}
+
+func initFederation(self string, timeout, ttl time.Duration) *federation {
+	return &federation{
+		self:     strings.TrimRight(self, "/"),
+		ttl:      ttl,
+		resolver: net.DefaultResolver,
+		hc:       &http.Client{Timeout: timeout},
+		cache:    make(map[string]fedInstance),
+	}
+}
+
+// discover returns the root URL of the authoritative instance of a host by looking up
+// the _portal records of the host and its parent domains. It's empty if there's none.
+func (f *federation) discover(ctx context.Context, host string) string {
+	for _, d := range core.DiscoveryDomains(host) {
+		f.mu.Lock()
+		c, ok := f.cache[d]
+		f.mu.Unlock()
+
+		if !ok || time.Now().After(c.expires) {
+			c = fedInstance{url: f.resolve(ctx, d), expires: time.Now().Add(f.ttl)}
+
+			f.mu.Lock()
+			f.cache[d] = c
+			f.mu.Unlock()
+		}
+
+		if c.url != "" {
+			return c.url
+		}
+	}
+
+	return ""
+}
+
+// resolve looks up the _portal TXT and SRV records of a domain.
+func (f *federation) resolve(ctx context.Context, domain string) string {
+	if txt, err := f.resolver.LookupTXT(ctx, "_portal."+domain); err == nil {
+		if u := core.ParsePortalTXT(txt); u != "" {
+			return u
+		}
+	}
+
+	_, srv, err := f.resolver.LookupSRV(ctx, "portal", "tcp", domain)
+	if err != nil || len(srv) == 0 {
+		return ""
+	}
+
+	// Records are sorted by priority and randomized by weight.
+	host := strings.TrimSuffix(srv[0].Target, ".")
+	if host == "" {
+		return ""
+	}
+	if srv[0].Port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(int(srv[0].Port)))
+	}
+
+	return "https://" + host
+}
+
+// lookup looks up a manifest URL on another instance.
+func (f *federation) lookup(ctx context.Context, instance, manifestURL string) (models.ManifestStatus, error) {
+	var out models.ManifestStatus
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, instance+"/api/v1/lookup?url="+url.QueryEscape(manifestURL), nil)
+	if err != nil {
+		return out, err
+	}
+	req.Header.Set(federatedHeader, f.self)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+
+	resp, err := f.hc.Do(req)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return out, core.ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return out, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var r struct {
+		Data models.ManifestLookup `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 100000)).Decode(&r); err != nil {
+		return out, err
+	}
+	if r.Data.Status == "" {
+		return out, core.ErrNotFound
+	}
+
+	return r.Data.ManifestStatus, nil
+}
+
+// errFederatedLookup is returned by lookupManifest when the lookup on the
+// authoritative instance of a manifest's domain fails.
+var errFederatedLookup = errors.New("error looking up manifest on the authoritative instance")
+
+// lookupManifest looks up the listing of a manifest URL, resolving aliases of moved
+// manifests. On a miss, the authoritative instance of the manifest's domain (discovered
+// via DNS) is queried, if federation is enabled and the lookup wasn't forwarded by
+// another instance. The instance is set in the result on errFederatedLookup.
+func lookupManifest(ctx context.Context, app *App, u *url.URL, forwarded bool) (models.ManifestLookup, error) {
+	st, err := app.core.GetManifestStatus(u.String())
+	if err != nil {
+		return models.ManifestLookup{}, err
+	}
+	if st.Status != "" {
+		return models.ManifestLookup{ManifestStatus: st, Instance: app.consts.RootURL}, nil
+	}
+
+	// Don't forward lookups that were forwarded by another instance.
+	if app.fed == nil || forwarded {
+		return models.ManifestLookup{}, core.ErrNotFound
+	}
+
+	inst := app.fed.discover(ctx, u.Hostname())
+	if inst == "" || inst == app.fed.self {
+		return models.ManifestLookup{}, core.ErrNotFound
+	}
+
+	st, err = app.fed.lookup(ctx, inst, u.String())
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return models.ManifestLookup{}, core.ErrNotFound
+		}
+
+		app.lo.Printf("error looking up manifest on %s: %s: %v", inst, u.String(), err)
+		return models.ManifestLookup{Instance: inst}, errFederatedLookup
+	}
+
+	return models.ManifestLookup{ManifestStatus: st, Instance: inst, Federated: true}, nil
+}
+
+// handleLookupManifest looks up the listing of a manifest by its URL, resolving aliases
+// of moved manifests. On a miss, the authoritative instance of the manifest's domain
+// (discovered via DNS) is queried, if federation is enabled.
+func handleLookupManifest(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	u, err := common.IsURL("url", strings.TrimSpace(c.QueryParam("url")), v1.MaxURLLen)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	out, err := lookupManifest(c.Request().Context(), app, u, c.Request().Header.Get(federatedHeader) != "")
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		if errors.Is(err, errFederatedLookup) {
+			return echo.NewHTTPError(http.StatusBadGateway, "error looking up manifest on "+out.Instance)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error looking up manifest")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
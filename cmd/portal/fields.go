@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// Max number of fields in a ?fields= selection.
+const maxFields = 50
+
+// fieldSet is a sparse fieldset (?fields=) that API responses are pruned to. Keys are
+// field names, and nested fields are selected with dotted paths, eg: entity.name,
+// projects.name, funding.channels. A field without sub-fields is kept whole.
+type fieldSet map[string]fieldSet
+
+// parseFields parses a comma separated list of (dotted) field names. It returns nil
+// if there are no fields.
+func parseFields(s string) fieldSet {
+	var out fieldSet
+	for n, f := range strings.Split(s, ",") {
+		if n >= maxFields {
+			break
+		}
+
+		keys := strings.Split(strings.TrimSpace(f), ".")
+		if slices.Contains(keys, "") {
+			continue
+		}
+		if out == nil {
+			out = fieldSet{}
+		}
+
+		cur := out
+		for n, k := range keys {
+			sub, ok := cur[k]
+			if ok && sub == nil {
+				// Already selected whole.
+				break
+			}
+			if n == len(keys)-1 {
+				cur[k] = nil
+				break
+			}
+
+			if !ok {
+				sub = fieldSet{}
+				cur[k] = sub
+			}
+			cur = sub
+		}
+	}
+
+	return out
+}
+
+// apply prunes a JSON response to the fields. Responses wrapped in {"data": ...}
+// (okResp) have their data pruned.
+func (fs fieldSet) apply(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	if m, ok := v.(map[string]interface{}); ok {
+		if data, ok := m["data"]; ok && len(m) == 1 {
+			return json.Marshal(okResp{fs.prune(data)})
+		}
+	}
+
+	return json.Marshal(fs.prune(v))
+}
+
+// prune returns the value with only the selected fields of objects. Arrays are
+// pruned item by item.
+func (fs fieldSet) prune(v interface{}) interface{} {
+	if fs == nil {
+		return v
+	}
+
+	switch o := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(fs))
+		for k, sub := range fs {
+			if val, ok := o[k]; ok {
+				out[k] = sub.prune(val)
+			}
+		}
+		return out
+	case []interface{}:
+		for n, item := range o {
+			o[n] = fs.prune(item)
+		}
+		return o
+	}
+
+	return v
+}
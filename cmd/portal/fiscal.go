@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Max number of members in a fiscal host's member list.
+const maxFiscalMembers = 5000
+
+// refreshFiscalHost fetches a fiscal host's member list and its detached signature
+// (members_url + ".sig"), verifies the signature against the host's public key, and
+// grants delegated verification to the listed manifests. The list is plain text with
+// one manifest URL per line. Empty lines and lines starting with # are ignored.
+func refreshFiscalHost(ctx context.Context, app *App, h models.FiscalHost) (int, error) {
+	urls, err := fetchFiscalMembers(ctx, app, h)
+	if err != nil {
+		app.lo.Printf("error refreshing fiscal host members: %s: %v", h.GUID, err)
+		_ = app.core.SetFiscalHostError(h.ID, err.Error())
+		return 0, err
+	}
+
+	if err := app.core.SetFiscalHostMembers(h.ID, urls); err != nil {
+		return 0, err
+	}
+
+	// Re-index the members in search.
+	for _, u := range urls {
+		st, err := app.core.GetManifestStatus(u)
+		if err != nil || st.ID == 0 {
+			continue
+		}
+		if m, err := app.core.GetManifest(st.ID, ""); err == nil {
+			app.crawl.Callbacks.OnManifestUpdate(m, m.Status)
+		}
+	}
+
+	return len(urls), nil
+}
+
+func fetchFiscalMembers(ctx context.Context, app *App, h models.FiscalHost) ([]string, error) {
+	if h.Verification != core.VerificationAdmin {
+		return nil, core.ErrNotAdminVerified
+	}
+
+	pub, err := core.ParsePublicKey(h.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(h.MembersURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid members URL: %v", err)
+	}
+	su, err := url.Parse(h.MembersURL + ".sig")
+	if err != nil {
+		return nil, fmt.Errorf("invalid members URL: %v", err)
+	}
+
+	// Fetch the list and the signature.
+	list, err := app.crawl.Fetch(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching member list: %v", err)
+	}
+	sr, err := app.crawl.Fetch(ctx, su)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching member list signature: %v", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sr.Body)))
+	if err != nil {
+		return nil, errors.New("invalid member list signature. Should be base64 encoded")
+	}
+	if !ed25519.Verify(pub, list.Body, sig) {
+		return nil, errors.New("member list signature verification failed")
+	}
+
+	var out []string
+	for n, l := range strings.Split(string(list.Body), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		if _, err := common.IsURL(fmt.Sprintf("line %d", n+1), l, v1.MaxURLLen); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+
+		if len(out) > maxFiscalMembers {
+			return nil, fmt.Errorf("too many members. Max is %d", maxFiscalMembers)
+		}
+	}
+
+	return out, nil
+}
+
+// refreshFiscalHosts refreshes the member lists of all fiscal hosts.
+func refreshFiscalHosts(ctx context.Context, app *App) {
+	hosts, err := app.core.GetFiscalHosts()
+	if err != nil {
+		return
+	}
+
+	for _, h := range hosts {
+		if ctx.Err() != nil {
+			return
+		}
+		if n, err := refreshFiscalHost(ctx, app, h); err == nil {
+			app.lo.Printf("refreshed fiscal host %s: %d members", h.GUID, n)
+		}
+	}
+}
+
+func handleGetFiscalHosts(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	out, err := app.core.GetFiscalHosts()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching fiscal hosts")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleUpsertFiscalHost registers an admin-verified manifest as a fiscal host.
+func handleUpsertFiscalHost(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	u, err := common.IsURL("members_url", c.FormValue("members_url"), v1.MaxURLLen)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	hostID, err := app.core.UpsertFiscalHost(id, strings.TrimSpace(c.FormValue("public_key")), u.String())
+	if err != nil {
+		if err == core.ErrNotAdminVerified || err == core.ErrInvalidPublicKey {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error saving fiscal host")
+	}
+
+	return c.JSON(http.StatusOK, okResp{hostID})
+}
+
+func handleDeleteFiscalHost(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	if err := app.core.DeleteFiscalHost(id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error deleting fiscal host")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// handleRefreshFiscalHost fetches and applies a fiscal host's signed member list.
+func handleRefreshFiscalHost(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	h, err := app.core.GetFiscalHost(id)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "fiscal host not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching fiscal host")
+	}
+
+	n, err := refreshFiscalHost(c.Request().Context(), app, h)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, okResp{n})
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/grpc/portalv1"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/floss-fund/portal/internal/search"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer serves the read API (search, lookup, manifests, and the change feed)
+// over gRPC, mirroring the public REST endpoints. The service is defined in
+// proto/portal/v1/portal.proto.
+type grpcServer struct {
+	portalv1.UnimplementedPortalServer
+
+	app *App
+}
+
+// serveGRPC serves the gRPC API on the given address. It blocks forever.
+func serveGRPC(app *App, address string) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		lo.Fatalf("error starting gRPC server: %v", err)
+	}
+
+	srv := grpc.NewServer()
+	portalv1.RegisterPortalServer(srv, &grpcServer{app: app})
+
+	lo.Printf("starting gRPC server on %s", address)
+	if err := srv.Serve(l); err != nil {
+		lo.Fatalf("error starting gRPC server: %v", err)
+	}
+}
+
+// SearchProjects searches projects (GET /search).
+func (s *grpcServer) SearchProjects(ctx context.Context, req *portalv1.SearchRequest) (*portalv1.SearchProjectsResponse, error) {
+	q, page, err := grpcSearchQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	query := search.ProjectQuery{Query: q, Field: req.Field, Page: page, MinScorecard: req.MinScore}
+	query.Licenses = append([]string{}, req.License...)
+	query.Categories = req.Category
+	if core.IsVerificationLevel(req.Verified) {
+		query.Verification = req.Verified
+	}
+
+	res, total, err := s.app.search.SearchProjects(query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error searching")
+	}
+
+	out := &portalv1.SearchProjectsResponse{Total: int32(total), Page: int32(page)}
+	for _, p := range res {
+		out.Results = append(out.Results, &portalv1.Project{
+			Id:                p.ID,
+			ManifestId:        int32(p.ManifestID),
+			ManifestGuid:      p.ManifestGUID,
+			EntityName:        p.EntityName,
+			EntityType:        p.EntityType,
+			EntityNumProjects: int32(p.EntityNumProjects),
+			Name:              p.Name,
+			Description:       p.Description,
+			WebpageUrl:        p.WebpageURL,
+			RepositoryUrl:     p.RepositoryURL,
+			Licenses:          p.Licenses,
+			Tags:              p.Tags,
+			Categories:        p.Categories,
+			Verification:      p.Verification,
+			UpdatedAt:         p.UpdatedAt,
+			Scorecard:         p.Scorecard,
+		})
+	}
+
+	return out, nil
+}
+
+// SearchEntities searches entities (GET /search?type=entity).
+func (s *grpcServer) SearchEntities(ctx context.Context, req *portalv1.SearchRequest) (*portalv1.SearchEntitiesResponse, error) {
+	q, page, err := grpcSearchQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, total, err := s.app.search.SearchEntities(search.EntityQuery{Query: q, Field: req.Field, Page: page})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error searching")
+	}
+
+	out := &portalv1.SearchEntitiesResponse{Total: int32(total), Page: int32(page)}
+	for _, e := range res {
+		out.Results = append(out.Results, &portalv1.Entity{
+			Id:           e.ID,
+			ManifestId:   int32(e.ManifestID),
+			ManifestGuid: e.ManifestGUID,
+			Type:         e.Type,
+			Role:         e.Role,
+			Name:         e.Name,
+			Description:  e.Description,
+			WebpageUrl:   e.WebpageURL,
+			NumProjects:  int32(e.NumProjects),
+			UpdatedAt:    e.UpdatedAt,
+		})
+	}
+
+	return out, nil
+}
+
+// GetManifest returns a manifest by its GUID (GET /api/entities/:guid).
+func (s *grpcServer) GetManifest(ctx context.Context, req *portalv1.GetManifestRequest) (*portalv1.ManifestData, error) {
+	m, err := s.app.core.GetManifest(0, strings.TrimSpace(req.Guid))
+	if err != nil {
+		if err == core.ErrNotFound {
+			return nil, status.Error(codes.NotFound, "entity not found")
+		}
+		return nil, status.Error(codes.Internal, "error fetching entity")
+	}
+
+	return grpcManifest(m), nil
+}
+
+// LookupManifest looks up the listing of a manifest by its URL (GET /api/v1/lookup).
+func (s *grpcServer) LookupManifest(ctx context.Context, req *portalv1.LookupManifestRequest) (*portalv1.ManifestLookup, error) {
+	u, err := common.IsURL("url", strings.TrimSpace(req.Url), v1.MaxURLLen)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	l, err := lookupManifest(ctx, s.app, u, false)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "manifest not found")
+		}
+		if errors.Is(err, errFederatedLookup) {
+			return nil, status.Error(codes.Unavailable, "error looking up manifest on "+l.Instance)
+		}
+		return nil, status.Error(codes.Internal, "error looking up manifest")
+	}
+
+	return &portalv1.ManifestLookup{
+		Status: &portalv1.ManifestStatus{
+			Id:           int32(l.ID),
+			Guid:         l.GUID,
+			Url:          l.URL,
+			Status:       l.Status,
+			CrawlErrors:  int32(l.CrawlErrors),
+			CrawlMessage: l.CrawlMessage,
+			UpdatedAt:    timestamppb.New(l.UpdatedAt),
+		},
+		Instance:  l.Instance,
+		Federated: l.Federated,
+	}, nil
+}
+
+// GetChanges streams the change feed of listings after a cursor (GET /api/v1/changes).
+// With follow, the stream stays open and changes are sent as they happen.
+func (s *grpcServer) GetChanges(req *portalv1.GetChangesRequest, stream portalv1.Portal_GetChangesServer) error {
+	var (
+		app    = s.app
+		limit  = int(req.Limit)
+		cursor = req.Since
+	)
+	if limit < 1 || limit > maxChanges {
+		limit = maxChanges
+	}
+
+	// Subscribe to the live feed before catching up so that no changes are missed in between.
+	// Live events only signal new changes, which are read from the cursor, so that changes
+	// dropped for slow subscribers aren't lost.
+	var live chan liveEvent
+	if req.Follow {
+		ch, ok := app.live.subscribe()
+		if !ok {
+			return status.Error(codes.ResourceExhausted, "too many subscribers. Retry later.")
+		}
+		defer app.live.unsubscribe(ch)
+		live = ch
+	}
+
+	// send sends the changes after the cursor, in batches of limit with follow.
+	send := func() error {
+		for {
+			changes, err := app.core.GetChanges(cursor, limit, "")
+			if err != nil {
+				return status.Error(codes.Internal, "error fetching changes")
+			}
+
+			for _, ch := range changes {
+				if err := stream.Send(grpcChange(ch)); err != nil {
+					return err
+				}
+				cursor = ch.ID
+			}
+
+			if !req.Follow || len(changes) < limit {
+				return nil
+			}
+		}
+	}
+
+	if err := send(); err != nil || !req.Follow {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+
+		case e := <-live:
+			if e.ID <= cursor {
+				continue
+			}
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcSearchQuery validates the query and page of a search request.
+func grpcSearchQuery(req *portalv1.SearchRequest) (string, int, error) {
+	q := strings.TrimSpace(req.Q)
+	if q == "" || len(q) > 128 {
+		return "", 0, status.Error(codes.InvalidArgument, "invalid query")
+	}
+
+	page := int(req.Page)
+	if page < 1 {
+		page = 1
+	}
+
+	return q, page, nil
+}
+
+func grpcManifest(m models.ManifestData) *portalv1.ManifestData {
+	e := m.Manifest.Entity
+	out := &portalv1.ManifestData{
+		Id:           int32(m.ID),
+		Guid:         m.GUID,
+		Version:      m.Version,
+		Url:          m.URL,
+		Status:       m.Status,
+		Verification: m.Verification,
+		Entity: &portalv1.ManifestEntity{
+			Type:        e.Type,
+			Role:        e.Role,
+			Name:        e.Name,
+			Email:       e.Email,
+			Phone:       e.Phone,
+			Description: e.Description,
+			WebpageUrl:  grpcURL(e.WebpageURL),
+		},
+		Funding:   &portalv1.Funding{},
+		CreatedAt: timestamppb.New(m.CreatedAt),
+		UpdatedAt: timestamppb.New(m.UpdatedAt),
+	}
+
+	for _, p := range m.Manifest.Projects {
+		out.Projects = append(out.Projects, &portalv1.ManifestProject{
+			Guid:          p.GUID,
+			Name:          p.Name,
+			Description:   p.Description,
+			WebpageUrl:    grpcURL(p.WebpageURL),
+			RepositoryUrl: grpcURL(p.RepositoryURL),
+			Licenses:      p.Licenses,
+			Tags:          p.Tags,
+		})
+	}
+
+	f := m.Manifest.Funding
+	for _, c := range f.Channels {
+		out.Funding.Channels = append(out.Funding.Channels, &portalv1.Channel{
+			Guid:        c.GUID,
+			Type:        c.Type,
+			Address:     c.Address,
+			Description: c.Description,
+		})
+	}
+	for _, p := range f.Plans {
+		out.Funding.Plans = append(out.Funding.Plans, &portalv1.Plan{
+			Guid:        p.GUID,
+			Status:      p.Status,
+			Name:        p.Name,
+			Description: p.Description,
+			Amount:      p.Amount,
+			Currency:    p.Currency,
+			Frequency:   p.Frequency,
+			Channels:    p.Channels,
+		})
+	}
+	for _, h := range f.History {
+		out.Funding.History = append(out.Funding.History, &portalv1.HistoryItem{
+			Year:        int32(h.Year),
+			Income:      h.Income,
+			Expenses:    h.Expenses,
+			Taxes:       h.Taxes,
+			Currency:    h.Currency,
+			Description: h.Description,
+		})
+	}
+
+	return out
+}
+
+func grpcURL(u v1.URL) *portalv1.URL {
+	return &portalv1.URL{Url: u.URL, WellKnown: u.WellKnown}
+}
+
+func grpcChange(ch models.ManifestChange) *portalv1.ManifestChange {
+	return &portalv1.ManifestChange{
+		Id:         ch.ID,
+		ManifestId: int32(ch.ManifestID),
+		Guid:       ch.GUID,
+		Url:        ch.URL,
+		Event:      ch.Event,
+		Hash:       ch.Hash,
+		CreatedAt:  timestamppb.New(ch.CreatedAt),
+	}
+}
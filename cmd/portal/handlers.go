@@ -2,11 +2,20 @@ package main
 
 import (
 	"crypto/subtle"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"path"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/altcha-org/altcha-lib-go"
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
 	"github.com/knadh/koanf/v2"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -14,26 +23,79 @@ import (
 
 const (
 	isAuthed = "is_authed"
+
+	// Max number of changes returned in one change feed request.
+	maxChanges = 1000
+
+	// Max number of listings a budget is allocated across.
+	maxMatches = 500
+
+	// Number of related projects shown for a project.
+	numRelated = 5
 )
 
 func initHandlers(ko *koanf.Koanf, srv *echo.Echo) {
 	g := srv.Group("")
 	g.GET("/", handleIndexPage)
 	g.GET("/submit", handleSubmitPage)
-	g.POST("/submit", handleSubmitPage)
+	g.POST("/submit", handleSubmitPage, handleMaintenance, handleShedLoad)
+	g.POST("/api/intake/email", handleInboundEmail, handleMaintenance, handleShedLoad)
+	g.POST("/api/v1/relay", handleRelaySubmission, handleMaintenance, handleShedLoad)
+	g.POST("/api/v1/submit", handleAPISubmission, handleMaintenance, handleShedLoad)
 	g.GET("/validate", handleValidatePage)
 	g.POST("/validate", handleValidatePage)
 	g.GET("/search", handleSearchPage)
+	g.GET("/status", handleStatusPage)
+	g.GET("/categories", handleCategoriesPage)
+	g.GET("/category/:id", handleCategoryPage)
 	g.GET("/view/funding", handleManifestPage)
 	g.GET("/view/projects", handleManifestPage)
 	g.GET("/view/project", handleManifestPage)
 	g.GET("/view/*", handleManifestPage)
+	g.GET("/preview/*", handleManifestPage)
+	g.GET("/webhooks/confirm", handleWebhookConfirmPage)
+	g.POST("/webhooks/confirm", handleWebhookConfirmPage, handleMaintenance)
 
 	g.POST("/api/validate", handleValidateManifest)
 	g.GET("/api/tags", handleGetTags)
+	g.GET("/api/categories", handleGetCategories)
 	g.GET("/api/captcha", handleGenerateCaptcha)
+	g.GET("/api/status", handleGetStatus)
+	g.GET("/api/entities/*", handleGetEntity)
+	g.GET("/api/v1/directory", handleGetDirectory)
+	g.GET("/api/v1/context.jsonld", handleGetJSONLDContext)
+	g.GET("/api/v1/lookup", handleLookupManifest)
+	g.POST("/api/v1/lookup/bulk", handleBulkLookup)
+	g.OPTIONS("/api/v1/lookup/bulk", handleBulkLookupPreflight)
+	g.GET("/api/v1/proxy", handleProxyManifest)
+	g.GET("/api/v1/changes", handleGetChanges)
+	g.GET("/api/v1/live", handleLiveFeed)
+	g.GET("/api/v1/match", handleMatchFunding)
+	g.GET("/api/v1/trend/*", handleGetFundingTrend)
+	g.GET("/api/v1/funding-gaps", handleGetFundingGaps)
+	g.GET("/api/v1/related/*", handleGetRelatedProjects)
+	g.GET("/api/v1/conformance", handleGetConformance)
+	g.GET("/api/v1/badge", handleGetBadge)
+	g.POST("/api/v1/wizard", handleManifestWizard)
+	g.POST("/api/v1/webhooks", handleCreateWebhook, handleMaintenance)
+	g.GET("/api/v1/webhooks", handleGetWebhook)
+	g.DELETE("/api/v1/webhooks", handleDeleteWebhook, handleMaintenance)
+	g.GET("/api/v1/security/*", handleGetSecurityContact)
+	g.GET("/api/v1/deprecations/*", handleGetDeprecations)
+	g.GET("/api/v1/extensions/*", handleGetExtensions)
+	g.GET("/api/v1/fund/*", handleGetFundingLinks)
+	g.GET("/api/v1/mirror/*", handleGetManifestMirror)
+	g.GET("/api/v1/manifests/:id", handleGetManifestVersion)
+	g.GET("/api/v1/scorecard", handleGetScorecard)
+	g.GET("/api/v1/activity", handleGetRepoActivity)
+	g.GET("/api/v1/translations/*", handleGetTranslations)
+	g.GET("/api/v1/usage", handleGetAPIUsage)
+	g.POST("/api/v1/telemetry", handleTelemetryReport, handleMaintenance)
+	g.GET("/api/v1/telemetry", handleGetTelemetry)
+	g.GET("/favicon/:id", handleGetFavicon)
+	g.GET("/card/*", handleManifestCard)
 
-	g.POST("/report/:mguid", handleReport)
+	g.POST("/report/:mguid", handleReport, handleMaintenance)
 	g.GET("/report/:mguid", handleReport)
 
 	// Static files.
@@ -41,9 +103,49 @@ func initHandlers(ko *koanf.Koanf, srv *echo.Echo) {
 
 	// Private, authenticated endpoints.
 	a := srv.Group("", middleware.BasicAuth(basicAuth))
+	a.GET("/api/maintenance", handleGetMaintenance)
+	a.PUT("/api/maintenance", handleSetMaintenance)
 	a.GET("/api/manifests/:id", handleGetManifest)
-	a.DELETE("/api/manifests/:id", handleDeleteManifest)
-	a.PUT("/api/manifests/:id/status", handleUpdateManifestStatus)
+	a.DELETE("/api/manifests/:id", handleDeleteManifest, handleMaintenance)
+	a.POST("/api/manifests/bulk", handleBulkModeration, handleMaintenance)
+	a.PUT("/api/manifests/:id/status", handleUpdateManifestStatus, handleMaintenance)
+	a.PUT("/api/manifests/:id/url", handleMoveManifest, handleMaintenance)
+	a.PUT("/api/manifests/:id/verification", handleUpdateManifestVerification, handleMaintenance)
+	a.PUT("/api/manifests/:id/pin", handleUpdateManifestPin, handleMaintenance)
+	a.GET("/api/manifests/:id/fetch-limits", handleGetManifestFetchLimits)
+	a.PUT("/api/manifests/:id/fetch-limits", handleUpdateManifestFetchLimits, handleMaintenance)
+	a.GET("/api/manifests/:id/aliases", handleGetManifestAliases)
+	a.GET("/api/manifests/:id/intake", handleGetManifestIntake)
+	a.GET("/api/manifests/:id/trace", handleGetManifestTrace)
+	a.GET("/api/manifests/:id/liveness", handleGetManifestLiveness)
+	a.PUT("/api/manifests/:id/trace", handleArmManifestTrace, handleMaintenance)
+	a.GET("/api/manifests/:id/linked", handleGetLinkedManifests)
+	a.PUT("/api/manifests/:id/parent", handleLinkManifest, handleMaintenance)
+	a.DELETE("/api/manifests/:id/parent", handleUnlinkManifest, handleMaintenance)
+	a.PUT("/api/categories/tags/:tag", handleSetTagCategory, handleMaintenance)
+	a.DELETE("/api/categories/tags/:tag", handleDeleteTagCategory, handleMaintenance)
+	a.GET("/api/fiscal-hosts", handleGetFiscalHosts)
+	a.PUT("/api/manifests/:id/fiscal-host", handleUpsertFiscalHost, handleMaintenance)
+	a.DELETE("/api/fiscal-hosts/:id", handleDeleteFiscalHost, handleMaintenance)
+	a.POST("/api/fiscal-hosts/:id/refresh", handleRefreshFiscalHost, handleMaintenance)
+	a.GET("/api/crawl-errors/domains", handleGetCrawlErrorDomains)
+	a.GET("/api/crawl-errors/trends", handleGetCrawlErrorTrends)
+	a.GET("/api/intake/stats", handleGetIntakeStats)
+	a.GET("/api/crawl-breakers", handleGetCrawlBreakers)
+	a.GET("/api/host-downtime", handleGetHostDowntime)
+	a.GET("/api/tenants", handleGetTenants)
+	a.GET("/api/consistency", handleGetConsistency)
+	a.GET("/api/consistency/metrics", handleGetConsistencyMetrics)
+	a.POST("/api/consistency/run", handleRunConsistency, handleMaintenance)
+	a.GET("/api/tenant/manifests", handleGetTenantManifests)
+	a.PUT("/api/tenant/manifests/:id", handleAddTenantManifest, handleMaintenance)
+	a.DELETE("/api/tenant/manifests/:id", handleDeleteTenantManifest, handleMaintenance)
+	a.GET("/api/keys", handleGetAPIKeys)
+	a.POST("/api/keys", handleCreateAPIKey, handleMaintenance)
+	a.DELETE("/api/keys/:id", handleDeleteAPIKey, handleMaintenance)
+	a.GET("/api/denylist", handleGetDenylist)
+	a.POST("/api/denylist", handleImportDenylist, handleMaintenance)
+	a.DELETE("/api/denylist/:address", handleDeleteDenylist, handleMaintenance)
 
 	// 404 pages.
 	srv.RouteNotFound("/api/*", func(c echo.Context) error {
@@ -92,19 +194,887 @@ func handleUpdateManifestStatus(c echo.Context) error {
 		status = c.FormValue("status")
 	)
 
+	if err := setManifestStatus(app, id, status); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// setManifestStatus updates the status of a manifest and its search record.
+func setManifestStatus(app *App, id int, status string) error {
 	// Update the status in the DB.
 	if err := app.core.UpdateManifestStatus(id, status); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
 
 	// Delete it from search if the status isn't active.
 	if m, err := app.core.GetManifest(id, ""); err == nil {
 		app.crawl.Callbacks.OnManifestUpdate(m, status)
+
+		// Relay accepted manifests to upstream instances.
+		if status == core.ManifestStatusActive && app.relay != nil {
+			app.relay.forward(app, m)
+		}
+	}
+
+	return nil
+}
+
+// handleMoveManifest records an owner-declared move of a manifest to a new URL.
+func handleMoveManifest(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	u, err := common.IsURL("url", c.FormValue("url"), v1.MaxURLLen)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := app.core.MoveManifest(id, u.String(), core.AliasDeclared); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error moving manifest")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+func handleGetManifestAliases(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	out, err := app.core.GetManifestAliases(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching aliases")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetManifestIntake returns the channel a manifest was submitted through.
+func handleGetManifestIntake(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	out, err := app.core.GetManifestIntake(id)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching intake")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleArmManifestTrace requests a capture of the full requests and responses
+// (headers and bodies) of the next crawl of a manifest.
+func handleArmManifestTrace(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	if err := app.core.ArmManifestTrace(id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error requesting trace")
 	}
 
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
+// handleGetManifestTrace returns the captured trace of a manifest's crawl.
+func handleGetManifestTrace(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	out, err := app.core.GetManifestTrace(id)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "no trace has been requested for the manifest")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching trace")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetManifestLiveness returns the availability of a manifest recorded by the last liveness sweep.
+func handleGetManifestLiveness(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	out, err := app.core.GetManifestLiveness(id)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest hasn't been swept yet")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching liveness")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetEntity returns a manifest along with the other manifests of the
+// same entity linked to it.
+func handleGetEntity(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		guid = strings.TrimSuffix(c.Param("*"), "/")
+	)
+
+	m, err := app.core.GetManifest(0, guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching entity")
+	}
+	if !isListed(app, c, m.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+	}
+
+	linked, err := app.core.GetLinkedManifests(m.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching linked manifests")
+	}
+	linked = slices.DeleteFunc(linked, func(l models.ManifestData) bool {
+		return !isListed(app, c, l.ID)
+	})
+
+	tags := []string{manifestETag(m)}
+	for _, l := range linked {
+		tags = append(tags, manifestETag(l))
+	}
+	format := negotiateFormat(c)
+	if checkETag(c, makeETag(append(tags, format)...)) {
+		return notModified(c)
+	}
+
+	// The manifest as a linked data document, with the linked manifests in it.
+	if format == formatJSONLD {
+		out, err := manifestJSONLD(app.consts.RootURL, m)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "error generating JSON-LD")
+		}
+
+		ls := make([]map[string]interface{}, 0, len(linked))
+		for _, l := range linked {
+			if o, err := manifestJSONLD(app.consts.RootURL, l); err == nil {
+				delete(o, "@context")
+				ls = append(ls, o)
+			}
+		}
+		out["linked"] = ls
+
+		c.Response().Header().Set(echo.HeaderContentType, mimeJSONLD)
+		return c.JSON(http.StatusOK, out)
+	}
+
+	if format == formatText || format == formatGemini {
+		return entityText(m, linked, format == formatGemini).render(c, http.StatusOK)
+	}
+
+	out := struct {
+		Manifest models.ManifestData   `json:"manifest"`
+		Linked   []models.ManifestData `json:"linked"`
+	}{m, linked}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetSecurityContact returns the security contact of a manifest.
+func handleGetSecurityContact(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		guid = strings.TrimSuffix(c.Param("*"), "/")
+	)
+
+	m, err := app.core.GetManifest(0, guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+	}
+	if !isListed(app, c, m.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+	}
+
+	if checkETag(c, makeETag("security", manifestETag(m))) {
+		return notModified(c)
+	}
+
+	out, err := core.GetSecurityContact(m)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error reading security contact")
+	}
+	if out == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest has no security contact")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetDeprecations returns the deprecated plans and channels of a manifest
+// along with their migration hints.
+func handleGetDeprecations(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		guid = strings.TrimSuffix(c.Param("*"), "/")
+	)
+
+	m, err := app.core.GetManifest(0, guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+	}
+	if !isListed(app, c, m.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+	}
+
+	if checkETag(c, makeETag("deprecations", manifestETag(m))) {
+		return notModified(c)
+	}
+
+	out, err := core.GetDeprecations(m)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error reading deprecations")
+	}
+	if out == nil {
+		out = &models.Deprecations{}
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetExtensions returns the experimental (x-) extensions of a manifest and its projects.
+func handleGetExtensions(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		guid = strings.TrimSuffix(c.Param("*"), "/")
+	)
+
+	m, err := app.core.GetManifest(0, guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+	}
+	if !isListed(app, c, m.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+	}
+
+	if checkETag(c, makeETag("extensions", manifestETag(m))) {
+		return notModified(c)
+	}
+
+	out, err := core.GetExtensions(m)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error reading extensions")
+	}
+	if out == nil {
+		out = &models.Extensions{}
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetManifestVersion returns a manifest by its ID. With ?as_of (a date or
+// an RFC3339 timestamp), it returns the version of the manifest that was listed
+// at the time (the end of the day for dates) from its recorded versions.
+func handleGetManifestVersion(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+		asOf  = c.QueryParam("as_of")
+	)
+	if id < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid manifest ID")
+	}
+	if !isListed(app, c, id) {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+	}
+
+	if asOf == "" {
+		m, err := app.core.GetManifest(id, "")
+		if err != nil {
+			if err == core.ErrNotFound {
+				return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+		}
+
+		return c.JSON(http.StatusOK, okResp{m})
+	}
+
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		d, err := time.Parse("2006-01-02", asOf)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid as_of. Should be a date (YYYY-MM-DD) or an RFC3339 timestamp")
+		}
+		at = d.AddDate(0, 0, 1)
+	}
+
+	out, err := app.core.GetManifestVersion(id, at)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest was not listed at the time")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest version")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetFundingLinks returns links to fund the active plans of a manifest through
+// their channels, with the amount and frequency prefilled on known payment providers.
+// ?plan and ?channel filter the links, and ?redirect=true redirects to the first one
+// for "fund this plan" buttons.
+func handleGetFundingLinks(c echo.Context) error {
+	var (
+		app     = c.Get("app").(*App)
+		guid    = strings.TrimSuffix(c.Param("*"), "/")
+		plan    = c.QueryParam("plan")
+		channel = c.QueryParam("channel")
+	)
+
+	m, err := app.core.GetManifest(0, guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+	}
+	if !isListed(app, c, m.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+	}
+
+	dep, err := core.GetDeprecations(m)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error reading deprecations")
+	}
+
+	out := []models.DeepLink{}
+	for _, l := range core.FundingDeepLinks(m.Manifest.Funding, dep) {
+		if (plan == "" || l.Plan == plan) && (channel == "" || l.Channel == channel) {
+			out = append(out, l)
+		}
+	}
+
+	if c.QueryParam("redirect") == "true" {
+		if len(out) == 0 {
+			return echo.NewHTTPError(http.StatusNotFound, "no funding link for the plan")
+		}
+		return c.Redirect(http.StatusFound, out[0].URL)
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetManifestMirror returns the mirrored copy of a manifest, labeled with its original
+// URL and fetch time, so that funding data is available when the origin is down.
+// ?raw=true returns the manifest as-is with the labels in headers.
+func handleGetManifestMirror(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		guid = strings.TrimSuffix(c.Param("*"), "/")
+	)
+
+	if !app.consts.EnableMirror {
+		return echo.NewHTTPError(http.StatusNotFound, "mirroring is disabled")
+	}
+
+	m, err := app.core.GetManifestMirror(guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "mirror not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching mirror")
+	}
+
+	if checkETag(c, makeETag("mirror", c.QueryParam("raw"), m.Hash, m.FetchedAt.String())) {
+		return notModified(c)
+	}
+
+	if ok, _ := strconv.ParseBool(c.QueryParam("raw")); ok {
+		h := c.Response().Header()
+		h.Set("X-Mirror-Source", m.URL)
+		h.Set("X-Mirror-Fetched-At", m.FetchedAt.UTC().Format(time.RFC3339))
+		h.Set("X-Mirror-SHA256", m.Hash)
+		h.Set("X-Content-Type-Options", "nosniff")
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, m.Body)
+	}
+
+	out := struct {
+		models.ManifestMirror
+		Mirror   bool            `json:"mirror"`
+		Manifest json.RawMessage `json:"manifest"`
+	}{m, true, json.RawMessage(m.Body)}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetChanges returns the change feed of listings after a cursor (?since=).
+// Consumers pass the returned cursor in subsequent requests to get further changes.
+// On a tenant sub-portal, the feed only has the changes of the tenant's listings.
+func handleGetChanges(c echo.Context) error {
+	var (
+		app      = c.Get("app").(*App)
+		since, _ = strconv.ParseInt(c.QueryParam("since"), 10, 64)
+		limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	)
+
+	if limit < 1 || limit > maxChanges {
+		limit = maxChanges
+	}
+
+	changes, err := app.core.GetChanges(since, limit, tenantSlug(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching changes")
+	}
+
+	out := struct {
+		Changes []models.ManifestChange `json:"changes"`
+		Cursor  int64                   `json:"cursor"`
+	}{changes, since}
+	if len(changes) > 0 {
+		out.Cursor = changes[len(changes)-1].ID
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleMatchFunding suggests an allocation of a budget across listings
+// that match the given licenses, tags, and entity types.
+func handleMatchFunding(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+		qp  = c.QueryParams()
+	)
+
+	budget, _ := strconv.ParseFloat(qp.Get("budget"), 64)
+	if budget <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid budget")
+	}
+
+	cur := strings.ToUpper(strings.TrimSpace(qp.Get("currency")))
+	if len(cur) != 3 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid currency")
+	}
+
+	q := models.FundingMatchQuery{
+		Budget:      budget,
+		Currency:    cur,
+		Licenses:    qp["license"],
+		Tags:        qp["tag"],
+		EntityTypes: qp["entity_type"],
+		Tenant:      tenantSlug(c),
+		Limit:       maxMatches,
+	}
+	q.MaxPerRecipient, _ = strconv.ParseFloat(qp.Get("max_per_recipient"), 64)
+	if n, _ := strconv.Atoi(qp.Get("limit")); n > 0 && n < maxMatches {
+		q.Limit = n
+	}
+
+	out, err := app.core.MatchFunding(q)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error matching funding")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetFundingTrend returns the time series of the yearly funding goal and
+// received amounts of a manifest (and thereby, its projects) per currency.
+func handleGetFundingTrend(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		guid = strings.TrimSuffix(c.Param("*"), "/")
+	)
+
+	m, err := app.core.GetManifest(0, guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+	}
+	if !isListed(app, c, m.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+	}
+
+	snaps, err := app.core.GetFundingSnapshots(m.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching funding trend")
+	}
+
+	// Group the series by currency.
+	out := make(map[string][]models.FundingSnapshot)
+	for _, s := range snaps {
+		out[s.Currency] = append(out[s.Currency], s)
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetFundingGaps returns the funding goals versus the verified income of
+// active manifests per ?by=category (or tag) and ?currency, biggest gaps first.
+func handleGetFundingGaps(c echo.Context) error {
+	var (
+		app      = c.Get("app").(*App)
+		by       = c.QueryParam("by")
+		limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	)
+
+	switch by {
+	case "":
+		by = "category"
+	case "category", "tag":
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "by should be category or tag")
+	}
+	if limit < 1 || limit > 1000 {
+		limit = 50
+	}
+
+	out, err := app.core.GetFundingGaps(by, strings.ToUpper(c.QueryParam("currency")), limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching funding gaps")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+func handleGetCategories(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	out, err := app.core.GetCategories()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching categories")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleUpdateManifestVerification sets the verification level of a manifest (eg: admin-verified).
+func handleUpdateManifestVerification(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	if err := app.core.UpdateManifestVerification(id, c.FormValue("verification")); err != nil {
+		if err == core.ErrInvalidVerification {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error updating verification")
+	}
+
+	// Re-index the manifest in search.
+	if m, err := app.core.GetManifest(id, ""); err == nil {
+		app.crawl.Callbacks.OnManifestUpdate(m, m.Status)
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// handleUpdateManifestPin pins a manifest to the SHA-256 hash of its contents, eg: when
+// the maintainer has legitimately updated a pinned manifest. An empty hash unpins it.
+func handleUpdateManifestPin(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+		hash  = c.FormValue("hash")
+	)
+
+	if hash != "" {
+		h, err := core.ParseContentHash(hash)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		hash = h
+	}
+
+	if err := app.core.SetManifestPin(id, "", hash); err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error updating pin")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+func handleGetManifestFetchLimits(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	out, err := app.core.GetManifestFetchLimits(id)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching fetch limits")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleUpdateManifestFetchLimits overrides the crawler's global fetch limits (max_bytes,
+// timeout_ms, attempts) for a manifest, eg: a large fiscal host manifest. Limits that
+// are null or left out use the global ones.
+func handleUpdateManifestFetchLimits(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	var l models.FetchLimits
+	if err := c.Bind(&l); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+	}
+	if err := core.ValidateFetchLimits(l); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := app.core.SetManifestFetchLimits(id, l); err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error updating fetch limits")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// handleSetTagCategory maps a tag to a category. Existing search records
+// pick up the change when they're re-indexed (eg: sync-search).
+func handleSetTagCategory(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+		tag = strings.ToLower(strings.TrimSpace(c.Param("tag")))
+	)
+
+	if tag == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid tag")
+	}
+
+	if err := app.core.SetTagCategory(tag, c.FormValue("category")); err != nil {
+		if err == core.ErrInvalidCategory {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error setting tag category")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+func handleDeleteTagCategory(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+		tag = strings.ToLower(strings.TrimSpace(c.Param("tag")))
+	)
+
+	if err := app.core.DeleteTagCategory(tag); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error deleting tag category")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// handleGetRelatedProjects returns the related projects of a project.
+// The URI is $manifestGUID/$projectGUID.
+func handleGetRelatedProjects(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		path = strings.TrimSuffix(c.Param("*"), "/")
+		i    = strings.LastIndex(path, "/")
+	)
+
+	if i == -1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid project guid")
+	}
+
+	out, err := app.core.GetRelatedProjects(path[:i], path[i+1:], numRelated, tenantSlug(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching related projects")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+func handleGetLinkedManifests(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	out, err := app.core.GetLinkedManifests(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching linked manifests")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleLinkManifest links a manifest to a parent manifest of the same entity.
+func handleLinkManifest(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		id, _       = strconv.Atoi(c.Param("id"))
+		parentID, _ = strconv.Atoi(c.FormValue("parent_id"))
+	)
+
+	if parentID < 1 || parentID == id {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid parent_id")
+	}
+
+	if err := app.core.LinkManifest(id, parentID); err != nil {
+		if err == core.ErrLinked {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error linking manifest")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+func handleUnlinkManifest(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	if err := app.core.UnlinkManifest(id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error unlinking manifest")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+func handleGetFavicon(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	f, err := app.core.GetFavicon(id)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "favicon not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching favicon")
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=86400")
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	return c.Blob(http.StatusOK, f.ContentType, f.Body)
+}
+
+func handleGetStatus(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+	)
+
+	out, err := app.core.GetCrawlStatus(app.consts.StatusNumRuns)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching crawl status")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetCrawlErrorDomains returns the hosts with the most crawl errors
+// in the last ?days=30 days.
+func handleGetCrawlErrorDomains(c echo.Context) error {
+	var (
+		app      = c.Get("app").(*App)
+		days     = crawlErrorDays(c)
+		limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	)
+
+	if limit < 1 || limit > 1000 {
+		limit = 50
+	}
+
+	out, err := app.core.GetCrawlErrorDomains(strconv.Itoa(days)+" DAYS", limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching crawl errors")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetCrawlBreakers returns the circuit breaker state of hosts that
+// requests made by the instance have recently failed on.
+func handleGetCrawlBreakers(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	return c.JSON(http.StatusOK, okResp{app.crawl.BreakerState()})
+}
+
+// handleGetHostDowntime returns the hosts that are down as per the last
+// liveness sweep, whose scheduled recrawls are deferred.
+func handleGetHostDowntime(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	out, err := app.core.GetHostDowntime()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching host downtime")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetCrawlErrorTrends returns the daily number of crawl errors per
+// error class in the last ?days=30 days.
+func handleGetCrawlErrorTrends(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	out, err := app.core.GetCrawlErrorTrends(strconv.Itoa(crawlErrorDays(c)) + " DAYS")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching crawl errors")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetIntakeStats returns the number of manifests submitted in the last ?days=30
+// days per submission channel, reference (eg: API key), and status.
+func handleGetIntakeStats(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	out, err := app.core.GetIntakeStats(strconv.Itoa(crawlErrorDays(c)) + " DAYS")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching intake stats")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+func crawlErrorDays(c echo.Context) int {
+	days, _ := strconv.Atoi(c.QueryParam("days"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	return days
+}
+
 func handleGenerateCaptcha(c echo.Context) error {
 	var (
 		app = c.Get("app").(*App)
@@ -133,5 +1103,6 @@ func basicAuth(username, password string, c echo.Context) (bool, error) {
 		return true, nil
 	}
 
-	return false, nil
+	// Tenant admins can only manage their tenant's listings.
+	return isTenantAdmin(username, password, c), nil
 }
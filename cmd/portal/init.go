@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/base64"
@@ -13,9 +14,12 @@ import (
 	"io/ioutil"
 	"log"
 	mrand "math/rand"
+	"net/mail"
+	"net/smtp"
 	"os"
 	"path"
 	"reflect"
+	"regexp"
 	"strings"
 	"time"
 	"unicode"
@@ -42,6 +46,23 @@ import (
 
 type Schema struct {
 	schema *v1.Schema
+
+	// Code forge hosts for computing verification levels.
+	forgeHosts []string
+
+	// URI of .well-known lists for the provenance of a project's additional repositories.
+	wellKnownURI string
+
+	// The instance's compliance rules that manifests have to meet to be listed.
+	compliance core.ComplianceRules
+
+	// Accept manifests with the projects that fail provenance checks left out
+	// instead of rejecting them.
+	partialProjects bool
+
+	// The crawler is used to fetch .well-known lists for provenance
+	// checks once it's initialized.
+	crawl *crawl.Crawl
 }
 
 func initConfig() {
@@ -54,15 +75,16 @@ func initConfig() {
 		os.Exit(0)
 	}
 
-	f.String("mode", "site", "site = runs the public portal | crawl = runs the background crawler")
+	f.String("mode", "site", "site = runs the public portal | crawl = runs the background crawler | schedule = continuously re-crawls manifests at adaptive intervals | sweep = checks the liveness of manifest URLs (HEAD only) | sync-search = re-indexes search | consistency = cross-checks the search index against the DB and repairs drift | related = computes related projects | export = exports analytics tables as Parquet or CSV | snapshot = exports all instance data to snapshot.dir | restore = replaces all instance data with the snapshot in snapshot.dir | scorecard = refreshes OpenSSF Scorecard results | activity = refreshes repository activity metrics | translate = machine translates descriptions")
 	f.Bool("new-config", false, "generate a new sample config.toml file.")
 	f.StringSlice("config", []string{"config.toml"},
 		"path to one or more config files (will be merged in order)")
+	f.String("secrets", "", "path to an optional config file with secrets (merged after --config)")
 	f.Bool("install", false, "run first time DB installation")
 	f.Bool("install-db", true, "run installation on PostgresDB")
 	f.Bool("install-search", true, "run installation on TypeSense search")
 	f.Bool("upgrade", false, "upgrade database to the current version")
-	f.Bool("yes", false, "assume 'yes' to prompts during --install/upgrade")
+	f.Bool("yes", false, "assume 'yes' to prompts during --install/upgrade/restore")
 	f.Bool("version", false, "current version of the build")
 
 	if err := f.Parse(os.Args[1:]); err != nil {
@@ -84,20 +106,41 @@ func initConfig() {
 		os.Exit(0)
 	}
 
-	// Load config files.
+	// Load config in the order of precedence (lowest first): config files, secrets file,
+	// environment variables, commandline flags, and finally, defaults for unset options.
 	cFiles, _ := f.GetStringSlice("config")
-	for _, f := range cFiles {
-		lo.Printf("reading config: %s", f)
+	if s, _ := f.GetString("secrets"); s != "" {
+		cFiles = append(cFiles, s)
+	}
+	for _, c := range cFiles {
+		// The default config file is optional so that the config
+		// can be entirely supplied via the environment.
+		if _, err := os.Stat(c); os.IsNotExist(err) && !f.Changed("config") {
+			lo.Printf("%s not found. Using the environment and defaults", c)
+			continue
+		}
 
-		if err := ko.Load(file.Provider(f), toml.Parser()); err != nil {
+		lo.Printf("reading config: %s", c)
+		if err := ko.Load(file.Provider(c), toml.Parser()); err != nil {
 			fmt.Printf("error reading config: %v", err)
 			os.Exit(1)
 		}
 	}
 
+	if keys := loadEnvConfig(ko, os.Environ()); len(keys) > 0 {
+		lo.Printf("config from environment: %s", strings.Join(keys, ", "))
+	}
+
 	if err := ko.Load(posflag.Provider(f, ".", ko), nil); err != nil {
 		lo.Fatalf("error loading config: %v", err)
 	}
+
+	// Apply defaults and validate the config upfront instead of failing at first use.
+	initConfigDefaults(ko)
+	if err := validateConfig(ko); err != nil {
+		fmt.Printf("invalid config:\n%v\n", err)
+		os.Exit(1)
+	}
 }
 
 func initConstants(ko *koanf.Koanf) Consts {
@@ -109,8 +152,17 @@ func initConstants(ko *koanf.Koanf) Consts {
 		WellKnownURI:      ko.MustString("crawl.wellknown_uri"),
 		DisallowedDomains: ko.Strings("crawl.disallowed_domains"),
 		EnableCaptcha:     ko.Bool("site.enable_captcha"),
+		EnableDenylist:    ko.Bool("site.denylist.enabled"),
+		EnableMirror:      ko.Bool("crawl.mirror"),
+		DurableQueue:      ko.String("crawl.queue") == "postgres",
+		APIDailyQuota:     ko.MustInt("site.api_daily_quota"),
+		SubmitReqTimeout:  ko.MustDuration("crawl.submit_req_timeout"),
+		SubmitMaxBytes:    ko.MustInt64("crawl.submit_max_bytes"),
 		HomeNumTags:       ko.MustInt("site.home_num_tags"),
 		HomeNumProjects:   ko.MustInt("site.home_num_projects"),
+		StatusNumRuns:     ko.MustInt("site.status_num_runs"),
+		AcceptTelemetry:   ko.Bool("telemetry.accept"),
+		TelemetryMaxAge:   ko.String("telemetry.max_age"),
 	}
 
 	if c.EnableCaptcha {
@@ -191,6 +243,18 @@ func initHTTPServer(app *App, ko *koanf.Koanf) *echo.Echo {
 		}
 	})
 
+	srv.Use(handleRequestID)
+	srv.Use(handleTenant)
+
+	// Meter API requests made with API keys.
+	srv.Use(handleAPIKey)
+	srv.Use(handleNegotiate)
+
+	// Access logs are only emitted in the json log format for log pipelines.
+	if ko.String("app.log_format") == "json" {
+		srv.Use(handleAccessLog(newLogger("http")))
+	}
+
 	initHandlers(ko, srv)
 
 	return srv
@@ -216,28 +280,191 @@ func initCore(fs stuffbin.FileSystem, db *sqlx.DB) *core.Core {
 
 	opt := core.Opt{}
 
-	return core.New(&q, opt, lo)
+	return core.New(&q, db, opt, newLogger("core"))
+}
+
+// initHostHeaders returns the extra request headers and auth per host ([[crawl.host_headers]]).
+func initHostHeaders(ko *koanf.Koanf) []crawl.HostHeaders {
+	var out []crawl.HostHeaders
+	for _, k := range ko.Slices("crawl.host_headers") {
+		out = append(out, crawl.HostHeaders{
+			Host:     k.String("host"),
+			Headers:  k.StringMap("headers"),
+			Username: k.String("username"),
+			Password: k.String("password"),
+			Token:    k.String("token"),
+		})
+	}
+
+	return out
 }
 
 func initCrawl(sc crawl.Schema, co *core.Core, s *search.Search, ko *koanf.Koanf) *crawl.Crawl {
+	// Validated on startup.
+	tlsMin, _ := crawl.ParseTLSVersion(ko.String("crawl.tls_min_version"))
+
 	opt := crawl.Opt{
-		Workers:         ko.MustInt("crawl.workers"),
-		ManifestAge:     ko.MustString("crawl.manifest_age"),
-		BatchSize:       ko.MustInt("crawl.batch_size"),
-		CheckProvenance: ko.Bool("crawl.check_provenance"),
-		MaxCrawlErrors:  ko.MustInt("crawl.max_crawl_errors"),
+		Workers:           ko.MustInt("crawl.workers"),
+		ManifestAge:       ko.MustString("crawl.manifest_age"),
+		BatchSize:         ko.MustInt("crawl.batch_size"),
+		CheckProvenance:   ko.Bool("crawl.check_provenance"),
+		MaxCrawlErrors:    ko.MustInt("crawl.max_crawl_errors"),
+		GoneAfter:         ko.Int("crawl.gone_after"),
+		WellKnownMaxBytes: ko.Int64("crawl.wellknown_max_bytes"),
+		FetchFavicons:     ko.Bool("crawl.fetch_favicons"),
+		FaviconMaxBytes:   ko.Int64("crawl.favicon_max_bytes"),
+		FetchOpenGraph:    ko.Bool("crawl.fetch_opengraph"),
+		Mirror:            ko.Bool("crawl.mirror"),
+		ForceRevalidate:   ko.Bool("crawl.force_revalidate"),
+		RespectRobots:     ko.Bool("crawl.respect_robots"),
+		RobotsTTL:         ko.Duration("crawl.robots_ttl"),
+
+		AdaptiveConcurrency: ko.Bool("crawl.adaptive_concurrency"),
+		MinWorkers:          ko.Int("crawl.min_workers"),
+		TargetLatency:       ko.Duration("crawl.target_latency"),
+
+		HostRPS:         ko.Float64("crawl.host_rps"),
+		HostConcurrency: ko.Int("crawl.host_concurrency"),
+
+		BreakerThreshold: ko.Int("crawl.breaker_threshold"),
+		BreakerCooldown:  ko.Duration("crawl.breaker_cooldown"),
+
+		DNSCacheSize: ko.Int("crawl.dns_cache_size"),
+		DNSCacheTTL:  ko.Duration("crawl.dns_cache_ttl"),
+
+		AllowedSchemes:    ko.Strings("crawl.allowed_schemes"),
+		AllowPrivateAddrs: ko.Bool("crawl.allow_private_addrs"),
+
+		MaxRedirects:            ko.Int("crawl.max_redirects"),
+		AllowCrossHostRedirects: ko.Bool("crawl.cross_host_redirects"),
+
+		ProxyURL:    ko.String("crawl.proxy_url"),
+		HostProxies: ko.StringMap("crawl.host_proxies"),
+
+		Transport: crawl.TransportOpt{
+			ForceHTTP2:        ko.Bool("crawl.force_http2"),
+			DisableHTTP2:      ko.Bool("crawl.disable_http2"),
+			TLSMinVersion:     tlsMin,
+			TLSSkipVerify:     ko.Bool("crawl.tls_skip_verify"),
+			DisableKeepAlives: ko.Bool("crawl.disable_keepalives"),
+			IdleConnTimeout:   ko.Duration("crawl.idle_conn_timeout"),
+			HostHeaders:       initHostHeaders(ko),
+		},
 
 		HTTP: initHTTPOpt(),
+		Backoff: crawl.Backoff{
+			Base:       ko.MustDuration("crawl.retry_wait"),
+			Multiplier: ko.Float64("crawl.retry_multiplier"),
+			Jitter:     ko.Float64("crawl.retry_jitter"),
+			Max:        ko.Duration("crawl.retry_max_wait"),
+		},
+	}
+
+	// Persist crawls in the DB so that they resume after restarts.
+	if ko.String("crawl.queue") == "postgres" {
+		opt.Queue = co
+		opt.QueueLease = ko.MustDuration("crawl.queue_lease")
+		opt.QueueRetryWait = ko.Duration("crawl.queue_retry_wait")
+		opt.QueueMaxAttempts = ko.Int("crawl.queue_max_attempts")
+	}
+
+	// Check the links in crawled manifests for known malicious destinations.
+	if ko.Bool("url_safety.enabled") {
+		switch ko.String("url_safety.provider") {
+		case "safebrowsing":
+			opt.URLChecker = initSafeBrowsing(ko.String("url_safety.api_key"), ko.MustDuration("url_safety.timeout"))
+		default:
+			l, err := loadURLList(ko.String("url_safety.list_file"))
+			if err != nil {
+				lo.Fatalf("error loading url_safety.list_file: %v", err)
+			}
+			opt.URLChecker = l
+		}
+		opt.UnsafeURLAction = ko.String("url_safety.action")
+	}
+
+	// Expose the crawler's request metrics for scraping.
+	if addr := ko.String("crawl.metrics_addr"); addr != "" {
+		m := newCrawlMetrics()
+		opt.Metrics = m
+		go serveMetrics(addr, m)
 	}
 
 	// When the crawler updates manifests, fire the callback to search results.
 	cb := &crawl.Callbacks{
 		OnManifestUpdate: func(m models.ManifestData, status string) {
-			updateSearchRecord(m, status, s)
+			cats, _ := co.GetTagCategoryMap()
+			tenants, _ := co.GetManifestTenants(m.ID)
+			updateSearchRecord(m, status, cats, manifestScorecards(co, m), tenants, s)
+		},
+
+		// Tombstone manifests that are gone: disable them and take them off search.
+		OnManifestGone: func(j models.ManifestJob, err *crawl.GoneError) {
+			if co.UpdateManifestStatus(j.ID, core.ManifestStatusDisabled) != nil {
+				return
+			}
+			co.UpdateManifestStatusMessage(j.URL, err.Error())
+			_ = s.Delete(j.ID)
 		},
 	}
 
-	return crawl.New(&opt, sc, cb, co, lo)
+	return crawl.New(&opt, sc, cb, co, newLogger("crawl"))
+}
+
+func initLoadShedder(ko *koanf.Koanf) *loadShedder {
+	return &loadShedder{
+		maxPending:   ko.Int64("site.backpressure.max_pending_submissions"),
+		maxQueue:     ko.Int("site.backpressure.max_queue_depth"),
+		maxDBLatency: ko.Duration("site.backpressure.max_db_latency"),
+		retryAfter:   ko.MustDuration("site.backpressure.retry_after"),
+	}
+}
+
+func initVelocityLimits(ko *koanf.Koanf) *velocityLimits {
+	v := &velocityLimits{
+		maxPerDomain:  ko.Int("site.velocity.max_per_domain"),
+		maxPerAddress: ko.Int("site.velocity.max_per_address"),
+	}
+	if v.maxPerDomain == 0 && v.maxPerAddress == 0 {
+		return nil
+	}
+
+	for _, h := range ko.Strings("site.velocity.shared_hosts") {
+		v.sharedHosts = append(v.sharedHosts, strings.ToLower(h))
+	}
+
+	return v
+}
+
+// initEmailIntake returns the mailer. Without intake (webhooks only), it has no
+// token and inbound e-mails are rejected.
+func initEmailIntake(ko *koanf.Koanf, intake bool) *emailIntake {
+	var token string
+	if intake {
+		token = ko.MustString("site.email_intake.token")
+		if len(token) < 16 {
+			lo.Fatal("site.email_intake.token should be at least 16 characters")
+		}
+	}
+
+	host := ko.MustString("site.email_intake.smtp_host")
+	var auth smtp.Auth
+	if u := ko.String("site.email_intake.smtp_username"); u != "" {
+		auth = smtp.PlainAuth("", u, ko.String("site.email_intake.smtp_password"), host)
+	}
+
+	from, err := mail.ParseAddress(ko.MustString("site.email_intake.from"))
+	if err != nil {
+		lo.Fatalf("invalid site.email_intake.from: %v", err)
+	}
+
+	return &emailIntake{
+		token:    token,
+		maxURLs:  ko.MustInt("site.email_intake.max_urls"),
+		from:     from,
+		smtpAddr: fmt.Sprintf("%s:%d", host, ko.MustInt("site.email_intake.smtp_port")),
+		smtpAuth: auth,
+	}
 }
 
 func initPaginator(ko *koanf.Koanf) *paginator.Paginator {
@@ -248,7 +475,7 @@ func initPaginator(ko *koanf.Koanf) *paginator.Paginator {
 	return paginator.New(pgOpt)
 }
 
-func initSchema(ko *koanf.Koanf) crawl.Schema {
+func initSchema(ko *koanf.Koanf) *Schema {
 	// SPDX license index.
 	licenses := make(map[string]string)
 	if b, err := os.ReadFile(ko.MustString("data_files.spdx")); err != nil {
@@ -296,12 +523,31 @@ func initSchema(ko *koanf.Koanf) crawl.Schema {
 		Licenses:             licenses,
 		ProgrammingLanguages: langs,
 		Currencies:           currencies,
-	}, initHTTPOpt(), lo)
+	}, initHTTPOpt(), newLogger("schema"))
 
 	// Since the portal has it's own models.Manifest (with additional fields),
 	// have to use a simple abstraction to pass the underlying v1 schema to the
 	// schema validator.
-	return &Schema{schema: sc}
+	var forges []string
+	for _, h := range ko.Strings("crawl.forge_hosts") {
+		forges = append(forges, strings.ToLower(h))
+	}
+
+	// Compliance rules.
+	var rules core.ComplianceRules
+	if ko.Bool("compliance.enabled") {
+		rules.ChannelTypes = ko.Strings("compliance.exclude_channel_types")
+		rules.Domains = ko.Strings("compliance.exclude_domains")
+		for _, p := range ko.Strings("compliance.exclude_address_patterns") {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				lo.Fatalf("error compiling compliance.exclude_address_patterns: %s: %v", p, err)
+			}
+			rules.AddressPatterns = append(rules.AddressPatterns, re)
+		}
+	}
+
+	return &Schema{schema: sc, forgeHosts: forges, wellKnownURI: ko.MustString("crawl.wellknown_uri"), compliance: rules, partialProjects: ko.Bool("crawl.partial_projects")}
 }
 
 func initHTTPOpt() common.HTTPOpt {
@@ -324,7 +570,7 @@ func initSearch(ko *koanf.Koanf) *search.Search {
 		HTTP: initHTTPOpt(),
 	}
 
-	return search.New(opt, lo)
+	return search.New(opt, newLogger("search"))
 }
 
 func initSiteTemplates(dirPath string) *template.Template {
@@ -415,10 +661,142 @@ func (s *Schema) Validate(m models.ManifestData) (models.ManifestData, error) {
 	return m, nil
 }
 
-func (s *Schema) ParseManifest(b []byte, manifestURL string, checkProvenance bool) (models.ManifestData, error) {
-	schemaManifest, err := s.schema.ParseManifest(b, manifestURL, checkProvenance)
+func (s *Schema) ParseManifest(ctx context.Context, b []byte, manifestURL string, checkProvenance bool) (models.ManifestData, error) {
+	schemaManifest, err := s.schema.ParseManifest(b, manifestURL, false)
+	if err != nil {
+		return models.ManifestData{}, err
+	}
+
+	// The manifest may be valid, but not listable on this instance.
+	if err := core.CheckCompliance(schemaManifest, s.compliance); err != nil {
+		return models.ManifestData{}, err
+	}
+
+	repos, err := core.ParseRepositories(b)
+	if err != nil {
+		return models.ManifestData{}, err
+	}
+
+	// Establish the provenance of all URLs mentioned in the manifest.
+	var rejected []models.RejectedProject
+	if checkProvenance {
+		if s.partialProjects {
+			schemaManifest, rejected, err = s.checkPartialProvenance(ctx, schemaManifest, repos)
+		} else {
+			err = s.checkManifestProvenance(ctx, schemaManifest, repos)
+		}
+		if err != nil {
+			return models.ManifestData{}, err
+		}
+	}
+
+	// Portal specific fields that are not in the schema.
+	sec, err := core.ParseSecurityContact(b, schemaManifest.URL.URLobj, schemaManifest.Entity.WebpageURL.URLobj)
+	if err != nil {
+		return models.ManifestData{}, err
+	}
+
+	// DOIs of projects are resolved along with the other network checks.
+	dois, err := core.ParseDOIs(b)
+	if err != nil {
+		return models.ManifestData{}, err
+	}
+	var cites map[string]models.Citation
+	if len(dois) > 0 {
+		if checkProvenance {
+			cites = s.resolveCitations(ctx, dois)
+		} else {
+			cites = make(map[string]models.Citation, len(dois))
+			for guid, doi := range dois {
+				cites[guid] = models.Citation{DOI: doi}
+			}
+		}
+	}
+
+	deps, err := core.ParseDeprecations(b)
+	if err != nil {
+		return models.ManifestData{}, err
+	}
+
+	// Extensions that are left out are only warned about in conformance reports.
+	ext, _, err := core.ParseExtensions(b)
+	if err != nil {
+		return models.ManifestData{}, err
+	}
+
+	meta, err := json.Marshal(models.ManifestMeta{Security: sec, Citations: cites, Deprecations: deps, Repositories: repos, Rejected: rejected, Extensions: ext})
 	if err != nil {
 		return models.ManifestData{}, err
 	}
-	return models.ManifestData{Manifest: schemaManifest}, nil
+
+	return models.ManifestData{
+		Manifest:     schemaManifest,
+		Meta:         meta,
+		Verification: core.VerificationLevel(schemaManifest, checkProvenance, s.forgeHosts),
+	}, nil
+}
+
+// checkProvenance checks the .well-known provenance of a URL in a manifest.
+func (s *Schema) checkProvenance(ctx context.Context, u v1.URL, manifest v1.URL) error {
+	if s.crawl == nil {
+		return s.schema.CheckProvenance(u, manifest)
+	}
+
+	return s.crawl.CheckProvenance(ctx, u, manifest)
+}
+
+// checkManifestProvenance checks the provenance of all URLs in a manifest.
+func (s *Schema) checkManifestProvenance(ctx context.Context, m v1.Manifest, repos map[string][]models.Repository) error {
+	if err := s.checkProvenance(ctx, m.Entity.WebpageURL, m.URL); err != nil {
+		return err
+	}
+
+	for n, o := range m.Projects {
+		if err := s.checkProjectProvenance(ctx, n, o, m.URL, repos[o.GUID]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkProjectProvenance checks the provenance of the URLs of a project, including
+// its additional repositories.
+func (s *Schema) checkProjectProvenance(ctx context.Context, n int, o v1.Project, manifest v1.URL, repos []models.Repository) error {
+	if err := s.checkProvenance(ctx, o.WebpageURL, manifest); err != nil {
+		return err
+	}
+	if err := s.checkProvenance(ctx, o.RepositoryURL, manifest); err != nil {
+		return err
+	}
+
+	for i, r := range repos {
+		u, err := core.RepositoryURL(fmt.Sprintf("projects[%d].repositories[%d]", n, i), r, manifest.URLobj, s.wellKnownURI)
+		if err != nil {
+			return err
+		}
+		if err := s.checkProvenance(ctx, u, manifest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkPartialProvenance checks the provenance of all URLs in a manifest and
+// leaves out the projects whose URLs fail the checks. The manifest is rejected
+// if the entity's URL or all the projects fail.
+func (s *Schema) checkPartialProvenance(ctx context.Context, m v1.Manifest, repos map[string][]models.Repository) (v1.Manifest, []models.RejectedProject, error) {
+	if err := s.checkProvenance(ctx, m.Entity.WebpageURL, m.URL); err != nil {
+		return m, nil, err
+	}
+
+	errs := make(map[string]error)
+	for n, o := range m.Projects {
+		if err := s.checkProjectProvenance(ctx, n, o, m.URL, repos[o.GUID]); err != nil {
+			errs[o.GUID] = err
+		}
+	}
+
+	return core.RejectProjects(m, errs)
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// liveEvent is a change to a listing broadcast to live feed subscribers.
+type liveEvent struct {
+	models.ManifestChange
+
+	// Name of the entity. Empty for deletions.
+	Name string `json:"name"`
+}
+
+// liveFeed polls the change feed (which also captures changes made by the
+// crawler running as a separate process) and broadcasts new changes to
+// server-sent event (SSE) subscribers.
+type liveFeed struct {
+	maxSubs int
+	subs    map[chan liveEvent]struct{}
+	mu      sync.RWMutex
+}
+
+const livePingInterval = time.Second * 30
+
+func newLiveFeed(maxSubs int) *liveFeed {
+	return &liveFeed{
+		maxSubs: maxSubs,
+		subs:    make(map[chan liveEvent]struct{}),
+	}
+}
+
+// run polls for changes after the most recent one at the given interval
+// and broadcasts them. It blocks forever.
+func (l *liveFeed) run(co *core.Core, interval time.Duration) {
+	cursor, _ := co.GetLastChangeID()
+
+	for {
+		time.Sleep(interval)
+
+		// No subscribers. Keep moving the cursor without fetching changes.
+		if l.numSubs() == 0 {
+			if id, err := co.GetLastChangeID(); err == nil {
+				cursor = id
+			}
+			continue
+		}
+
+		changes, err := co.GetChanges(cursor, maxChanges, "")
+		if err != nil {
+			continue
+		}
+
+		for _, ch := range changes {
+			l.broadcast(makeLiveEvent(co, ch))
+			cursor = ch.ID
+		}
+	}
+}
+
+// subscribe adds a subscriber. It returns false if there are too many subscribers.
+func (l *liveFeed) subscribe() (chan liveEvent, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.subs) >= l.maxSubs {
+		return nil, false
+	}
+
+	ch := make(chan liveEvent, 100)
+	l.subs[ch] = struct{}{}
+
+	return ch, true
+}
+
+func (l *liveFeed) unsubscribe(ch chan liveEvent) {
+	l.mu.Lock()
+	delete(l.subs, ch)
+	l.mu.Unlock()
+}
+
+func (l *liveFeed) numSubs() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.subs)
+}
+
+// broadcast sends an event to all subscribers. Events are dropped for
+// subscribers that are too slow to keep up.
+func (l *liveFeed) broadcast(e liveEvent) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for ch := range l.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// handleLiveFeed streams changes to listings as server-sent events.
+// Clients that reconnect with Last-Event-ID get the changes they missed.
+// On a tenant sub-portal, only the changes of the tenant's listings are streamed.
+func handleLiveFeed(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	ch, ok := app.live.subscribe()
+	if !ok {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "too many subscribers. Retry later.")
+	}
+	defer app.live.unsubscribe(ch)
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	// Replay missed changes.
+	if id, _ := strconv.ParseInt(c.Request().Header.Get("Last-Event-ID"), 10, 64); id > 0 {
+		changes, _ := app.core.GetChanges(id, maxChanges, tenantSlug(c))
+		for _, ch := range changes {
+			if err := writeLiveEvent(w, makeLiveEvent(app.core, ch)); err != nil {
+				return nil
+			}
+		}
+	}
+
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+
+		case e := <-ch:
+			if !isListed(app, c, e.ManifestID) {
+				continue
+			}
+			if err := writeLiveEvent(w, e); err != nil {
+				return nil
+			}
+
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			w.Flush()
+		}
+	}
+}
+
+func makeLiveEvent(co *core.Core, ch models.ManifestChange) liveEvent {
+	e := liveEvent{ManifestChange: ch}
+	if ch.Event != "delete" {
+		if m, err := co.GetManifest(ch.ManifestID, ""); err == nil {
+			e.Name = m.Manifest.Entity.Name
+		}
+	}
+
+	return e
+}
+
+func writeLiveEvent(w *echo.Response, e liveEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Event, b); err != nil {
+		return err
+	}
+	w.Flush()
+
+	return nil
+}
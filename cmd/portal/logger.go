@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/labstack/echo/v4"
+)
+
+// Fields that are lifted out of log messages (key=value) into their own
+// JSON fields so that log pipelines can index them.
+var reLogField = regexp.MustCompile(`\s*\b(request_id|manifest_url|duration|status|method|path)=(\S+)`)
+
+// jsonLogWriter is a log.Logger writer that emits every log line as a JSON object.
+type jsonLogWriter struct {
+	component string
+	out       io.Writer
+	mu        *sync.Mutex
+}
+
+// Serializes writes from all component loggers to stderr.
+var logMu sync.Mutex
+
+// newLogger returns a logger for a component (app, crawl, http, webhooks etc.).
+// In the json log format, every line is a JSON object with time, component,
+// msg, and the known key=value fields in the message.
+func newLogger(component string) *log.Logger {
+	if ko.String("app.log_format") != "json" {
+		return log.New(os.Stderr, "", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+
+	return log.New(&jsonLogWriter{component: component, out: os.Stderr, mu: &logMu}, "", 0)
+}
+
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+
+	l := map[string]string{
+		"time":      time.Now().Format(time.RFC3339Nano),
+		"component": w.component,
+	}
+	for _, m := range reLogField.FindAllStringSubmatch(msg, -1) {
+		l[m[1]] = m[2]
+	}
+	l["msg"] = strings.TrimSpace(reLogField.ReplaceAllString(msg, ""))
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.out.Write(append(b, '\n')); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Request IDs accepted from the X-Request-ID header of incoming requests.
+var reRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// handleRequestID assigns every request an ID (the X-Request-ID header set by an
+// upstream proxy, or a new one) that's returned in the X-Request-ID response header
+// and carried by the request context so that submissions can be traced across
+// logs, crawls, and crawl errors.
+func handleRequestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Request().Header.Get(echo.HeaderXRequestID)
+		if !reRequestID.MatchString(id) {
+			id = crawl.NewRequestID()
+		}
+
+		c.Response().Header().Set(echo.HeaderXRequestID, id)
+		c.SetRequest(c.Request().WithContext(crawl.WithRequestID(c.Request().Context(), id)))
+		return next(c)
+	}
+}
+
+// handleAccessLog logs every HTTP request with its duration and request ID.
+func handleAccessLog(lo *log.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			req := c.Request()
+			lo.Printf("%s method=%s path=%s status=%d duration=%s request_id=%s",
+				c.RealIP(), req.Method, req.URL.Path, c.Response().Status, time.Since(start), crawl.RequestID(req.Context()))
+
+			// The error is already handled above.
+			return nil
+		}
+	}
+}
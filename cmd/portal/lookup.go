@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Max number of URLs in one bulk lookup request.
+const maxBulkLookup = 100
+
+// handleBulkLookup returns compact funding summaries of the listings that a batch of
+// repository or website URLs (eg: GitHub pages open in a browser) belong to. Only URLs
+// that match a listing are returned. It's CORS enabled for browser extensions.
+func handleBulkLookup(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	c.Response().Header().Set(echo.HeaderAccessControlAllowOrigin, "*")
+
+	var in struct {
+		URLs []string `json:"urls" form:"urls"`
+	}
+	if err := c.Bind(&in); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid input: %v", err))
+	}
+	if len(in.URLs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "urls is empty")
+	}
+	if len(in.URLs) > maxBulkLookup {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a maximum of %d URLs can be looked up at once", maxBulkLookup))
+	}
+
+	// Normalize the URLs to the keys they're matched with. Multiple URLs
+	// (eg: a repository's issues and pulls pages) may map to the same key.
+	var (
+		keys   = make([]string, 0, len(in.URLs))
+		byKey  = make(map[string][]string, len(in.URLs))
+		forges = app.schema.forgeHosts
+	)
+	for _, u := range in.URLs {
+		k, ok := core.NormalizeLookupURL(u, forges)
+		if !ok {
+			continue
+		}
+
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, k)
+		}
+		byKey[k] = append(byKey[k], u)
+	}
+
+	res, err := app.core.GetFundingSummaries(keys)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error looking up URLs")
+	}
+
+	out := make([]models.FundingSummary, 0, len(res))
+	for _, s := range res {
+		s.FundingURL = fmt.Sprintf("%s/view/funding/%s", app.consts.RootURL, s.ManifestGUID)
+		for _, u := range byKey[s.URL] {
+			s.URL = u
+			out = append(out, s)
+		}
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleBulkLookupPreflight responds to CORS preflight requests of the bulk lookup.
+func handleBulkLookupPreflight(c echo.Context) error {
+	h := c.Response().Header()
+	h.Set(echo.HeaderAccessControlAllowOrigin, "*")
+	h.Set(echo.HeaderAccessControlAllowMethods, http.MethodPost)
+	h.Set(echo.HeaderAccessControlAllowHeaders, echo.HeaderContentType)
+	h.Set(echo.HeaderAccessControlMaxAge, "86400")
+
+	return c.NoContent(http.StatusNoContent)
+}
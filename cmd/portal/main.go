@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/floss-fund/portal/internal/core"
 	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/preview"
 	"github.com/floss-fund/portal/internal/search"
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/koanf/v2"
@@ -32,19 +37,55 @@ type Consts struct {
 	CaptchaComplexity int64  `json:"site.captcha_complexity"`
 	CaptchaKey        string `json:"-"`
 
+	// Flag submissions with payment addresses on the shared denylist.
+	EnableDenylist bool `json:"site.denylist.enabled"`
+
+	// Serve mirrored copies of manifests.
+	EnableMirror bool `json:"crawl.mirror"`
+
+	// Crawls lease jobs from the durable queue in the DB (crawl.queue = "postgres").
+	DurableQueue bool `json:"crawl.queue"`
+
+	// Default daily request quota of new API keys.
+	APIDailyQuota int `json:"site.api_daily_quota"`
+
+	SubmitReqTimeout time.Duration `json:"crawl.submit_req_timeout"`
+	SubmitMaxBytes   int64         `json:"crawl.submit_max_bytes"`
+
+	// Accept telemetry reports of other instances (central instance).
+	AcceptTelemetry bool   `json:"telemetry.accept"`
+	TelemetryMaxAge string `json:"telemetry.max_age"`
+
 	HomeNumTags     int `json:"site.home_num_tags"`
 	HomeNumProjects int `json:"site.home_num_projects"`
+	StatusNumRuns   int `json:"site.status_num_runs"`
 }
 
 // App contains the "global" components that are passed around, especially through HTTP handlers.
 type App struct {
-	consts  Consts
-	siteTpl *template.Template
-	core    *core.Core
-	search  *search.Search
-	crawl   *crawl.Crawl
-	schema  crawl.Schema
-	pg      *paginator.Paginator
+	consts   Consts
+	siteTpl  *template.Template
+	core     *core.Core
+	search   *search.Search
+	crawl    *crawl.Crawl
+	schema   *Schema
+	pg       *paginator.Paginator
+	cards    *preview.Cache
+	shed     *loadShedder
+	submits  *submitDeduper
+	live     *liveFeed
+	email    *emailIntake
+	velocity *velocityLimits
+	maint    *maintenance
+	relay    *relay
+	webhooks *webhooks
+	fed      *federation
+	proxy    *manifestProxy
+	badges   *manifestBadges
+	payments *paymentAlerts
+	tenants  tenants
+
+	consistency *consistency
 
 	db *sqlx.DB
 	fs stuffbin.FileSystem
@@ -58,6 +99,7 @@ var (
 
 func main() {
 	initConfig()
+	lo = newLogger("app")
 
 	// Connect to the DB.
 	db := initDB(ko.MustString("db.host"),
@@ -99,16 +141,190 @@ func main() {
 	app.schema = initSchema(ko)
 	app.search = initSearch(ko)
 	app.crawl = initCrawl(app.schema, app.core, app.search, ko)
+	app.schema.crawl = app.crawl
 	app.pg = initPaginator(ko)
+	app.cards = preview.NewCache(ko.MustInt("site.preview_cache_size"))
+	app.submits = newSubmitDeduper(ko.MustDuration("crawl.submit_dedupe_ttl"))
+	app.velocity = initVelocityLimits(ko)
+	app.maint = &maintenance{retryAfter: ko.MustDuration("app.maintenance_retry_after")}
+	app.maint.on.Store(ko.Bool("app.maintenance"))
+
+	// Batch modes (crawl, sweep etc.) stop gracefully on SIGINT and SIGTERM,
+	// aborting in-flight requests.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Run the crawl mode.
 	switch ko.String("mode") {
 	case "crawl":
-		app.crawl.Crawl()
+		if app.maint.enabled() {
+			lo.Println("maintenance mode is enabled. Not crawling.")
+			return
+		}
+
+		ctx, cancel, ok := withCrawlWindow(ctx, initCrawlSchedule(ko))
+		if !ok {
+			lo.Println("outside the crawl windows or in a blackout period. Not crawling.")
+			return
+		}
+		defer cancel()
+
+		_ = app.core.PruneCrawlErrors(ko.MustString("crawl.error_retention"))
+		app.crawl.Crawl(ctx)
+
+		// Apply the signed member lists of fiscal hosts.
+		refreshFiscalHosts(ctx, app)
+		return
+	case "schedule":
+		if app.maint.enabled() {
+			lo.Println("maintenance mode is enabled. Not crawling.")
+			return
+		}
+		runScheduler(ctx, app, ko)
+		return
+	case "sweep":
+		ctx, cancel, ok := withCrawlWindow(ctx, initCrawlSchedule(ko))
+		if !ok {
+			lo.Println("outside the crawl windows or in a blackout period. Not sweeping.")
+			return
+		}
+		defer cancel()
+
+		if err := app.crawl.Sweep(ctx); err != nil {
+			lo.Fatalf("error running liveness sweep: %v", err)
+		}
 		return
 	case "sync-search":
 		syncSearch(app.core, app.search, lo)
 		return
+	case "consistency":
+		// The card cache is only checked on the site.
+		app.cards = nil
+		r := initConsistency(ko.Bool("consistency.repair")).check(app)
+		if r.Error != "" {
+			lo.Fatalf("error checking consistency: %s", r.Error)
+		}
+		lo.Printf("checked %d manifests: %d missing, %d extra, %d stale, %d repaired", r.Manifests, r.Missing, r.Extra, r.Stale, r.Repaired)
+		return
+	case "related":
+		n, err := app.core.UpdateRelatedProjects()
+		if err != nil {
+			lo.Fatalf("error computing related projects: %v", err)
+		}
+		lo.Printf("computed related projects for %d projects", n)
+		return
+	case "scorecard":
+		refreshScorecards(ctx, app, ko.MustString("scorecard.api_url"), ko.MustString("scorecard.max_age"), ko.MustInt("scorecard.batch_size"))
+
+		// Re-index the scores in search.
+		syncSearch(app.core, app.search, lo)
+		return
+	case "activity":
+		refreshRepoActivity(ctx, app, forgeTokens{
+			"github": ko.String("activity.github_token"),
+			"gitlab": ko.String("activity.gitlab_token"),
+		}, ko.MustString("activity.max_age"), ko.MustInt("activity.batch_size"))
+		return
+	case "translate":
+		if ko.String("translation.url") == "" {
+			lo.Fatal("translation.url is not set")
+		}
+		refreshTranslations(ctx, app, initTranslator(ko.String("translation.url"), ko.String("translation.api_key"), ko.MustDuration("translation.timeout")),
+			ko.Strings("translation.locales"), ko.MustInt("translation.batch_size"))
+		return
+	case "export":
+		if err := exportTables(app, ko.MustString("export.dir"), ko.MustString("export.format")); err != nil {
+			lo.Fatalf("error exporting: %v", err)
+		}
+		return
+	case "snapshot":
+		if err := writeSnapshot(app, ko.MustString("snapshot.dir")); err != nil {
+			lo.Fatalf("error writing snapshot: %v", err)
+		}
+		return
+	case "restore":
+		if err := restoreSnapshot(app, ko.MustString("snapshot.dir"), !ko.Bool("yes")); err != nil {
+			lo.Fatalf("error restoring snapshot: %v", err)
+		}
+
+		// Re-index the restored listings.
+		syncSearch(app.core, app.search, lo)
+		return
+	}
+
+	// Restore the default signal handling for the site.
+	stop()
+
+	// Start measuring the load for shedding submissions.
+	app.shed = initLoadShedder(ko)
+	go app.shed.watchDB(db, time.Second*5)
+	go app.shed.watchQueue(app.core.CountCrawlJobs, time.Second*5)
+
+	// Start broadcasting changes to live feed subscribers.
+	app.live = newLiveFeed(ko.MustInt("site.live.max_subscribers"))
+	go app.live.run(app.core, ko.MustDuration("site.live.poll_interval"))
+
+	// Relay accepted manifests upstream and/or accept relayed manifests.
+	if ko.Bool("relay.enabled") {
+		app.relay = initRelay(ko.Strings("relay.upstreams"), ko.String("relay.token"),
+			ko.Strings("relay.accept_tokens"), ko.MustString("app.root_url"), ko.MustDuration("relay.timeout"))
+	}
+
+	// Accept submissions via e-mail. The SMTP settings are also used to e-mail webhook confirmations.
+	if ko.Bool("site.email_intake.enabled") || ko.Bool("webhooks.enabled") {
+		app.email = initEmailIntake(ko, ko.Bool("site.email_intake.enabled"))
+	}
+
+	// Deliver events to the webhooks registered by maintainers. Confirmations are e-mailed.
+	if ko.Bool("webhooks.enabled") {
+		app.webhooks = initWebhooks(ko.MustInt("webhooks.max_per_manifest"), ko.MustInt("webhooks.max_failures"), ko.MustDuration("webhooks.timeout"), ko.Bool("crawl.allow_private_addrs"))
+		go app.webhooks.run(app, ko.MustDuration("webhooks.poll_interval"), newLogger("webhooks"))
+	}
+
+	// Alert on changes to the payment details of listings.
+	if ko.Bool("payment_alerts.enabled") {
+		app.payments = initPaymentAlerts(ko.Bool("payment_alerts.require_review"))
+		go app.payments.run(app, ko.MustDuration("payment_alerts.poll_interval"))
+	}
+
+	// Resolve lookup misses with the authoritative instances of domains.
+	if ko.Bool("federation.enabled") {
+		app.fed = initFederation(ko.MustString("app.root_url"), ko.MustDuration("federation.timeout"), ko.MustDuration("federation.cache_ttl"))
+	}
+
+	// Fetch and validate arbitrary manifest URLs for clients like browser extensions.
+	if ko.Bool("proxy.enabled") {
+		app.proxy = initManifestProxy(ko.MustDuration("proxy.cache_ttl"), ko.MustInt("proxy.cache_size"),
+			ko.MustInt("proxy.rate_limit"), ko.Bool("crawl.check_provenance"))
+	}
+
+	// Validation status badges of manifest URLs.
+	if ko.Bool("badge.enabled") {
+		app.badges = initManifestBadges(ko.MustDuration("badge.cache_ttl"), ko.MustInt("badge.cache_size"), ko.MustInt("badge.rate_limit"))
+	}
+
+	// Cross-check the search index and caches against the DB for drift.
+	if ko.Bool("consistency.enabled") {
+		app.consistency = initConsistency(ko.Bool("consistency.repair"))
+		go app.consistency.run(app, ko.MustDuration("consistency.interval"))
+	}
+
+	// Report anonymous aggregate stats to the central instance (opt-in).
+	if ko.Bool("telemetry.enabled") {
+		t := initTelemetry(ko.MustString("telemetry.url"), versionString, ko.MustDuration("telemetry.timeout"))
+		go t.run(app, ko.MustDuration("telemetry.interval"))
+	}
+
+	// Tenant sub-portals served on their own domains.
+	tn, err := initTenants(ko)
+	if err != nil {
+		lo.Fatalf("error loading tenants: %v", err)
+	}
+	app.tenants = tn
+
+	// Serve the read API over gRPC.
+	if ko.Bool("grpc.enabled") {
+		go serveGRPC(app, ko.MustString("grpc.address"))
 	}
 
 	// Initialize the echo HTTP server.
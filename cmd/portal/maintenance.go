@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maintenance is the read-only maintenance mode during which writes (submissions,
+// reports, recrawl triggers, admin changes, outgoing notifications) are disabled while
+// search and the read APIs continue to be served. It can be toggled at runtime by admins.
+type maintenance struct {
+	on         atomic.Bool
+	retryAfter time.Duration
+}
+
+const maintenanceMsg = "The directory is in read-only maintenance mode. Please retry in a while."
+
+func (m *maintenance) enabled() bool {
+	return m != nil && m.on.Load()
+}
+
+// handleMaintenance is a middleware that rejects write requests in maintenance mode.
+// It's added to every route that writes (except the one that toggles the mode), and
+// lets GETs through.
+func handleMaintenance(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		app := c.Get("app").(*App)
+		if !app.maint.enabled() || c.Request().Method == http.MethodGet {
+			return next(c)
+		}
+
+		c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int(app.maint.retryAfter.Seconds())))
+		if strings.HasPrefix(c.Path(), "/api/") {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, maintenanceMsg)
+		}
+
+		return errPage(c, http.StatusServiceUnavailable, "", "Maintenance", maintenanceMsg)
+	}
+}
+
+func handleGetMaintenance(c echo.Context) error {
+	app := c.Get("app").(*App)
+	return c.JSON(http.StatusOK, okResp{app.maint.enabled()})
+}
+
+// handleSetMaintenance toggles the maintenance mode (?enabled=true|false).
+func handleSetMaintenance(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	on, err := strconv.ParseBool(c.FormValue("enabled"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid value for enabled")
+	}
+
+	app.maint.on.Store(on)
+	app.lo.Printf("maintenance mode: %v", on)
+
+	return c.JSON(http.StatusOK, okResp{on})
+}
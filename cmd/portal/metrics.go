@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/floss-fund/portal/internal/crawl"
+)
+
+// Upper bounds (seconds) of the crawler's request latency histogram buckets.
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// crawlMetrics collects the crawler's request metrics (crawl.Metrics) and
+// exposes them in the Prometheus text format.
+type crawlMetrics struct {
+	phases map[string]*phaseMetrics
+	mu     sync.Mutex
+}
+
+type phaseMetrics struct {
+	// Requests by status code class.
+	requests map[string]int64
+	retries  int64
+	bytes    int64
+
+	// Latency histogram: cumulative counts by bucket, and the sum and count.
+	buckets []int64
+	sum     float64
+	count   int64
+}
+
+func newCrawlMetrics() *crawlMetrics {
+	return &crawlMetrics{phases: make(map[string]*phaseMetrics)}
+}
+
+// ObserveRequest implements crawl.Metrics.
+func (m *crawlMetrics) ObserveRequest(phase string, statusCode int, bytes int64, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.phase(phase)
+	p.requests[crawl.StatusClass(statusCode)]++
+	p.bytes += bytes
+
+	s := d.Seconds()
+	for n, b := range latencyBuckets {
+		if s <= b {
+			p.buckets[n]++
+		}
+	}
+	p.sum += s
+	p.count++
+}
+
+// ObserveRetry implements crawl.Metrics.
+func (m *crawlMetrics) ObserveRetry(phase string) {
+	m.mu.Lock()
+	m.phase(phase).retries++
+	m.mu.Unlock()
+}
+
+func (m *crawlMetrics) phase(phase string) *phaseMetrics {
+	p, ok := m.phases[phase]
+	if !ok {
+		p = &phaseMetrics{requests: make(map[string]int64), buckets: make([]int64, len(latencyBuckets))}
+		m.phases[phase] = p
+	}
+
+	return p
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (m *crawlMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	phases := make([]string, 0, len(m.phases))
+	for p := range m.phases {
+		phases = append(phases, p)
+	}
+	slices.Sort(phases)
+
+	fmt.Fprintln(w, "# HELP portal_crawl_requests_total Crawler requests by phase and status code class.")
+	fmt.Fprintln(w, "# TYPE portal_crawl_requests_total counter")
+	for _, ph := range phases {
+		p := m.phases[ph]
+
+		classes := make([]string, 0, len(p.requests))
+		for c := range p.requests {
+			classes = append(classes, c)
+		}
+		slices.Sort(classes)
+
+		for _, c := range classes {
+			fmt.Fprintf(w, "portal_crawl_requests_total{phase=%q,class=%q} %d\n", ph, c, p.requests[c])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP portal_crawl_retries_total Crawler request retries by phase.")
+	fmt.Fprintln(w, "# TYPE portal_crawl_retries_total counter")
+	for _, ph := range phases {
+		fmt.Fprintf(w, "portal_crawl_retries_total{phase=%q} %d\n", ph, m.phases[ph].retries)
+	}
+
+	fmt.Fprintln(w, "# HELP portal_crawl_bytes_total Response body bytes fetched by the crawler by phase.")
+	fmt.Fprintln(w, "# TYPE portal_crawl_bytes_total counter")
+	for _, ph := range phases {
+		fmt.Fprintf(w, "portal_crawl_bytes_total{phase=%q} %d\n", ph, m.phases[ph].bytes)
+	}
+
+	fmt.Fprintln(w, "# HELP portal_crawl_request_duration_seconds Crawler request latencies by phase.")
+	fmt.Fprintln(w, "# TYPE portal_crawl_request_duration_seconds histogram")
+	for _, ph := range phases {
+		p := m.phases[ph]
+		for n, b := range latencyBuckets {
+			fmt.Fprintf(w, "portal_crawl_request_duration_seconds_bucket{phase=%q,le=%q} %d\n", ph, strconv.FormatFloat(b, 'g', -1, 64), p.buckets[n])
+		}
+		fmt.Fprintf(w, "portal_crawl_request_duration_seconds_bucket{phase=%q,le=\"+Inf\"} %d\n", ph, p.count)
+		fmt.Fprintf(w, "portal_crawl_request_duration_seconds_sum{phase=%q} %g\n", ph, p.sum)
+		fmt.Fprintf(w, "portal_crawl_request_duration_seconds_count{phase=%q} %d\n", ph, p.count)
+	}
+}
+
+// serveMetrics serves the metrics at /metrics on the given address for scraping.
+// It's independent of the app's HTTP server so that batch modes (crawl, schedule)
+// that don't run it can be scraped too.
+func serveMetrics(addr string, m *crawlMetrics) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.write(w)
+	})
+
+	lo.Printf("serving crawl metrics on %s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		lo.Printf("error serving crawl metrics: %v", err)
+	}
+}
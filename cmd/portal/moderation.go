@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Bulk moderation actions.
+const (
+	modApprove   = "approve"
+	modReject    = "reject"
+	modBlocklist = "blocklist"
+	modRecrawl   = "recrawl"
+)
+
+// Statuses that manifests are set to by the bulk moderation actions.
+var modStatuses = map[string]string{
+	modApprove:   core.ManifestStatusActive,
+	modReject:    core.ManifestStatusDisabled,
+	modBlocklist: core.ManifestStatusBlocked,
+}
+
+// Max manifests acted on by a bulk moderation request.
+const maxModerationItems = 1000
+
+type moderationResult struct {
+	Action    string                    `json:"action"`
+	DryRun    bool                      `json:"dry_run"`
+	Matched   int                       `json:"matched"`
+	Applied   int                       `json:"applied"`
+	Manifests []models.ModerationTarget `json:"manifests"`
+}
+
+// handleBulkModeration applies a moderation action (approve, reject, blocklist, recrawl)
+// to the manifests matching a filter, eg: a wave of spam submissions from a domain.
+// With dry_run, the matching manifests are returned without acting on them.
+func handleBulkModeration(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	var req struct {
+		Action string                  `json:"action"`
+		Filter models.ModerationFilter `json:"filter"`
+		DryRun bool                    `json:"dry_run"`
+		Limit  int                     `json:"limit"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+	}
+
+	if _, ok := modStatuses[req.Action]; !ok && req.Action != modRecrawl {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("action should be one of %s, %s, %s, %s", modApprove, modReject, modBlocklist, modRecrawl))
+	}
+
+	// Recrawls are queued for the crawls, which only read the queue when it's durable.
+	if req.Action == modRecrawl && !app.consts.DurableQueue {
+		return echo.NewHTTPError(http.StatusBadRequest, `recrawl requires the durable crawl queue (crawl.queue = "postgres")`)
+	}
+
+	// A filter is required so that a malformed request doesn't act on every manifest.
+	f := req.Filter
+	if f.Domain == "" && f.CreatedFrom == nil && f.CreatedTo == nil && f.RelaySource == "" && f.ErrorClass == "" && f.Status == "" && f.Intake == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "at least one filter is required")
+	}
+	if req.Limit < 1 || req.Limit > maxModerationItems {
+		req.Limit = maxModerationItems
+	}
+
+	items, err := app.core.GetModerationTargets(f, req.Limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifests")
+	}
+
+	out := moderationResult{Action: req.Action, DryRun: req.DryRun, Matched: len(items), Manifests: items}
+	if req.DryRun || len(items) == 0 {
+		return c.JSON(http.StatusOK, okResp{out})
+	}
+
+	if req.Action == modRecrawl {
+		ids := make([]int, 0, len(items))
+		for _, m := range items {
+			ids = append(ids, m.ID)
+		}
+
+		// Crawls pick up admin requested recrawls ahead of the regular ones.
+		if err := app.core.RecrawlManifests(ids, int(crawl.PriorityInteractive)); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "error scheduling recrawls")
+		}
+		out.Applied = len(ids)
+
+		return c.JSON(http.StatusOK, okResp{out})
+	}
+
+	status := modStatuses[req.Action]
+	for _, m := range items {
+		if err := setManifestStatus(app, m.ID, status); err != nil {
+			continue
+		}
+		out.Applied++
+	}
+	app.lo.Printf("bulk moderation: %s: %d of %d manifests", req.Action, out.Applied, out.Matched)
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// Response formats of the API.
+const (
+	formatJSON   = "json"
+	formatJSONLD = "jsonld"
+	formatYAML   = "yaml"
+	formatText   = "text"
+	formatGemini = "gemini"
+
+	mimeJSONLD = "application/ld+json"
+	mimeYAML   = "application/yaml"
+	mimeGemini = "text/gemini"
+)
+
+// Media types (Accept) of the formats.
+var formatTypes = map[string]string{
+	echo.MIMEApplicationJSON: formatJSON,
+	mimeJSONLD:               formatJSONLD,
+	mimeYAML:                 formatYAML,
+	"application/x-yaml":     formatYAML,
+	"text/yaml":              formatYAML,
+	echo.MIMETextPlain:       formatText,
+	mimeGemini:               formatGemini,
+}
+
+// negotiateFormat returns the response format for a request. ?format= takes precedence
+// over the media type with the highest q-value in the Accept header. Defaults to JSON.
+func negotiateFormat(c echo.Context) string {
+	switch f := c.QueryParam("format"); f {
+	case formatJSON, formatJSONLD, formatYAML, formatText, formatGemini:
+		return f
+	}
+
+	var (
+		out   = formatJSON
+		bestQ = 0.0
+	)
+	for _, a := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(a))
+		if err != nil {
+			continue
+		}
+
+		f, ok := formatTypes[typ]
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			out, bestQ = f, q
+		}
+	}
+
+	return out
+}
+
+// handleNegotiate is a middleware that serves the JSON responses of API endpoints
+// as YAML when it's negotiated, and prunes them to the fields selected with ?fields=.
+// JSON-LD is served by the endpoints that support it.
+func handleNegotiate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !strings.HasPrefix(c.Path(), "/api/") {
+			return next(c)
+		}
+
+		res := c.Response()
+		res.Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+		var (
+			isYAML = negotiateFormat(c) == formatYAML
+			fields = parseFields(c.QueryParam("fields"))
+		)
+		if !isYAML && fields == nil {
+			return next(c)
+		}
+
+		w := &yamlWriter{ResponseWriter: res.Writer}
+		res.Writer = w
+		defer func() { res.Writer = w.ResponseWriter }()
+
+		// Render errors while the response is being captured so that they're converted too.
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		if !w.capture {
+			return nil
+		}
+
+		b := w.buf.Bytes()
+
+		// Errors are not pruned.
+		if fields != nil && w.status < http.StatusMultipleChoices {
+			if p, err := fields.apply(b); err == nil {
+				b = p
+			}
+		}
+		if isYAML {
+			if y, err := jsonToYAML(b); err == nil {
+				b = y
+				res.Header().Set(echo.HeaderContentType, mimeYAML+"; charset=utf-8")
+			}
+		}
+		res.Header().Del(echo.HeaderContentLength)
+
+		w.ResponseWriter.WriteHeader(w.status)
+		_, err := w.ResponseWriter.Write(b)
+		return err
+	}
+}
+
+// yamlWriter captures JSON responses for converting them to YAML or pruning
+// their fields. Other responses (eg: event streams) are written through.
+type yamlWriter struct {
+	http.ResponseWriter
+
+	buf     bytes.Buffer
+	capture bool
+	status  int
+}
+
+func (w *yamlWriter) WriteHeader(code int) {
+	if strings.HasPrefix(w.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		w.capture = true
+		w.status = code
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *yamlWriter) Write(b []byte) (int, error) {
+	if w.capture {
+		return w.buf.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *yamlWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.capture {
+		f.Flush()
+	}
+}
+
+// jsonToYAML converts a JSON document to YAML retaining the order of keys.
+func jsonToYAML(b []byte) ([]byte, error) {
+	// JSON is valid YAML. Parse it and drop the JSON (flow, quoted) styles.
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+
+	var reset func(*yaml.Node)
+	reset = func(n *yaml.Node) {
+		n.Style = 0
+		for _, c := range n.Content {
+			reset(c)
+		}
+	}
+	reset(&n)
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&n); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// manifestJSONLD returns a manifest as a JSON-LD document, identified by its URL,
+// that uses the portal's context to map its terms to schema.org.
+func manifestJSONLD(rootURL string, m models.ManifestData) (map[string]interface{}, error) {
+	b, err := m.Manifest.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{})
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+
+	out["@context"] = rootURL + "/api/v1/context.jsonld"
+	out["@id"] = m.URL
+	out["@type"] = "FundingManifest"
+	out["guid"] = m.GUID
+	out["verification"] = m.Verification
+	out["updatedAt"] = m.UpdatedAt
+
+	return out, nil
+}
+
+// jsonLDContext maps the terms of manifests to schema.org and the portal's vocabulary.
+func jsonLDContext(rootURL string) map[string]interface{} {
+	id := func(term string) map[string]string {
+		return map[string]string{"@id": term, "@type": "@id"}
+	}
+
+	return map[string]interface{}{
+		"@context": map[string]interface{}{
+			"@vocab":  rootURL + "/ns#",
+			"schema":  "https://schema.org/",
+			"xsd":     "http://www.w3.org/2001/XMLSchema#",
+			"version": "schema:schemaVersion",
+
+			"entity":      "schema:funder",
+			"name":        "schema:name",
+			"description": "schema:description",
+			"email":       "schema:email",
+			"phone":       "schema:telephone",
+			"role":        "schema:roleName",
+			"webpageUrl":  "schema:url",
+			"url":         id("schema:url"),
+			"wellKnown":   id("wellKnown"),
+
+			"projects":      "schema:subjectOf",
+			"repositoryUrl": "schema:codeRepository",
+			"licenses":      "schema:license",
+			"tags":          "schema:keywords",
+
+			"funding":   "schema:funding",
+			"plans":     "schema:offers",
+			"amount":    map[string]string{"@id": "schema:price", "@type": "xsd:decimal"},
+			"currency":  "schema:priceCurrency",
+			"frequency": "schema:billingDuration",
+			"channels":  "schema:acceptedPaymentMethod",
+			"address":   "schema:identifier",
+			"history":   "schema:annualReport",
+			"year":      map[string]string{"@id": "schema:temporalCoverage", "@type": "xsd:gYear"},
+			"income":    map[string]string{"@id": "schema:totalRevenue", "@type": "xsd:decimal"},
+			"expenses":  map[string]string{"@id": "schema:expenses", "@type": "xsd:decimal"},
+			"updatedAt": map[string]string{"@id": "schema:dateModified", "@type": "xsd:dateTime"},
+		},
+	}
+}
+
+// handleGetJSONLDContext returns the JSON-LD context of manifests.
+func handleGetJSONLDContext(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	c.Response().Header().Set(echo.HeaderContentType, mimeJSONLD)
+	return c.JSON(http.StatusOK, jsonLDContext(app.consts.RootURL))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// paymentAlerts watches for changes to the payment details (funding channel types
+// and addresses) of active manifests, which are recorded by the DB when the crawler
+// updates manifests. As payment details are the prime target of hijacking, changes
+// are logged, flagged on the listing, and e-mailed to the manifest's entity, and
+// optionally, the listing is sent back for review.
+type paymentAlerts struct {
+	requireReview bool
+}
+
+func initPaymentAlerts(requireReview bool) *paymentAlerts {
+	return &paymentAlerts{requireReview: requireReview}
+}
+
+// run polls the recorded payment changes and processes them. Changes are claimed in
+// the DB so that ones recorded while the site is down are alerted when it's back, and
+// are alerted only once across site instances. As alerts are outgoing notifications,
+// they're paused in maintenance mode. It blocks forever.
+func (p *paymentAlerts) run(app *App, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		if app.maint.enabled() {
+			continue
+		}
+
+		changes, err := app.core.ClaimPaymentChanges(maxChanges)
+		if err != nil {
+			continue
+		}
+
+		for _, ch := range changes {
+			p.process(app, ch)
+		}
+	}
+}
+
+func (p *paymentAlerts) process(app *App, ch models.PaymentChange) {
+	diff, err := core.DiffChannels(ch.OldChannels, ch.NewChannels)
+	if err != nil {
+		app.lo.Printf("error diffing payment channels: %s: %v", ch.URL, err)
+		return
+	}
+
+	// Only descriptions or the order of channels changed.
+	if len(diff) == 0 {
+		return
+	}
+
+	desc := core.DescribeChannelChanges(diff)
+	app.lo.Printf("ALERT: payment details of manifest %d (%s) changed:\n%s", ch.ManifestID, ch.URL, desc)
+
+	msg := fmt.Sprintf("Payment details changed on %s", ch.CreatedAt.Format(time.RFC3339))
+	if p.requireReview {
+		msg += ". Sent back for review"
+	}
+	app.core.UpdateManifestStatusMessage(ch.URL, msg)
+
+	m, err := app.core.GetManifest(ch.ManifestID, "")
+	if err != nil {
+		return
+	}
+
+	// Take the listing down until an admin reviews the change.
+	if p.requireReview && m.Status == core.ManifestStatusActive {
+		if err := app.core.UpdateManifestStatus(m.ID, core.ManifestStatusPending); err == nil {
+			app.crawl.Callbacks.OnManifestUpdate(m, core.ManifestStatusPending)
+		}
+	}
+
+	if app.webhooks != nil {
+		app.webhooks.deliver(app.core, models.WebhookEvent{
+			Event:      models.WebhookEventPaymentChange,
+			ManifestID: ch.ManifestID,
+			GUID:       ch.GUID,
+			URL:        ch.URL,
+			Message:    desc,
+			CreatedAt:  ch.CreatedAt,
+		}, app.lo)
+	}
+
+	if app.email == nil || m.Manifest.Entity.Email == "" {
+		return
+	}
+
+	body := fmt.Sprintf("The payment details in the funding manifest %s listed on %s have changed:\n\n%s\n"+
+		"If you made these changes, no action is needed.", ch.URL, app.consts.RootURL, desc)
+	if p.requireReview {
+		body += " The listing will be restored once the changes are reviewed."
+	}
+	body += "\n\nIf you didn't make them, the manifest (or its hosting) may have been compromised. " +
+		"Secure it, revert the changes, and contact the directory admins.\n"
+
+	if err := app.email.send(m.Manifest.Entity.Email, "Payment details changed: "+m.GUID, body); err != nil {
+		app.lo.Printf("error e-mailing payment change alert: %s: %v", ch.URL, err)
+	}
+}
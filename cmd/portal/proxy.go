@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/labstack/echo/v4"
+)
+
+// manifestProxy fetches, validates, and caches arbitrary funding.json URLs so that
+// clients like browser extensions can display funding info without running into
+// CORS issues or re-implementing validation. Requests are rate limited per client IP.
+type manifestProxy struct {
+	ttl             time.Duration
+	maxItems        int
+	ratePerMin      int
+	checkProvenance bool
+
+	items map[string]proxyItem
+	hits  map[string]proxyHits
+	mu    sync.Mutex
+}
+
+type proxyItem struct {
+	out  models.ProxiedManifest
+	etag string
+}
+
+// proxyHits is the number of requests of a client in the current minute.
+type proxyHits struct {
+	n     int
+	start time.Time
+}
+
+func initManifestProxy(ttl time.Duration, maxItems, ratePerMin int, checkProvenance bool) *manifestProxy {
+	return &manifestProxy{
+		ttl:             ttl,
+		maxItems:        maxItems,
+		ratePerMin:      ratePerMin,
+		checkProvenance: checkProvenance,
+		items:           make(map[string]proxyItem),
+		hits:            make(map[string]proxyHits),
+	}
+}
+
+// allow records a request from a client and returns false if it's over the rate limit.
+func (p *manifestProxy) allow(ip string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	h, ok := p.hits[ip]
+	if !ok || now.Sub(h.start) > time.Minute {
+		// Purge expired windows so that the map doesn't grow unbounded.
+		if len(p.hits) > 10000 {
+			for k, v := range p.hits {
+				if now.Sub(v.start) > time.Minute {
+					delete(p.hits, k)
+				}
+			}
+		}
+		h = proxyHits{start: now}
+	}
+
+	h.n++
+	p.hits[ip] = h
+
+	return h.n <= p.ratePerMin
+}
+
+// get returns a cached result that hasn't expired.
+func (p *manifestProxy) get(u string) (proxyItem, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	it, ok := p.items[u]
+	if !ok || time.Since(it.out.FetchedAt) > p.ttl {
+		return proxyItem{}, false
+	}
+
+	return it, true
+}
+
+// set caches a result. If the cache is full, expired entries are purged, and
+// if there are none, an arbitrary entry is evicted.
+func (p *manifestProxy) set(u string, out models.ProxiedManifest) proxyItem {
+	h := sha256.Sum256(append([]byte(out.Error+out.Verification), out.Manifest...))
+	it := proxyItem{out: out, etag: `W/"` + hex.EncodeToString(h[:16]) + `"`}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.items[u]; !ok && len(p.items) >= p.maxItems {
+		for k, v := range p.items {
+			if time.Since(v.out.FetchedAt) > p.ttl {
+				delete(p.items, k)
+			}
+		}
+		for k := range p.items {
+			if len(p.items) < p.maxItems {
+				break
+			}
+			delete(p.items, k)
+		}
+	}
+	p.items[u] = it
+
+	return it
+}
+
+// handleProxyManifest fetches, validates, and returns a funding.json URL. Invalid
+// manifests are returned with valid=false and the validation error. Results,
+// including invalid ones, are cached.
+func handleProxyManifest(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.proxy == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest proxy is disabled")
+	}
+
+	c.Response().Header().Set(echo.HeaderAccessControlAllowOrigin, "*")
+
+	u, err := common.IsURL("url", strings.TrimSpace(c.QueryParam("url")), v1.MaxURLLen)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if !strings.HasSuffix(u.Path, app.consts.ManifestURI) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("URL must end in %s", app.consts.ManifestURI))
+	}
+
+	if !app.proxy.allow(c.RealIP()) {
+		c.Response().Header().Set("Retry-After", "60")
+		return echo.NewHTTPError(http.StatusTooManyRequests, "too many requests. Retry later.")
+	}
+
+	it, ok := app.proxy.get(u.String())
+	if !ok {
+		out := models.ProxiedManifest{URL: u.String(), FetchedAt: time.Now()}
+
+		// Fetch the manifest with the stricter submission limits.
+		resp, err := app.crawl.Fetch(c.Request().Context(), u,
+			crawl.WithTimeout(app.consts.SubmitReqTimeout),
+			crawl.WithMaxBytes(app.consts.SubmitMaxBytes),
+			crawl.IgnoreRobots())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("error fetching manifest: %v", err))
+		}
+
+		if m, err := app.schema.ParseManifest(c.Request().Context(), resp.Body, u.String(), app.proxy.checkProvenance); err != nil {
+			out.Error = err.Error()
+		} else if b, err := m.Manifest.MarshalJSON(); err != nil {
+			out.Error = err.Error()
+		} else {
+			out.Valid = true
+			out.Verification = m.Verification
+			out.Manifest = types.JSONText(b)
+		}
+
+		it = app.proxy.set(u.String(), out)
+	}
+
+	// Cache for the rest of the entry's lifetime.
+	age := time.Since(it.out.FetchedAt)
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age="+strconv.Itoa(int((app.proxy.ttl-age).Seconds())))
+	c.Response().Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+	if checkETag(c, it.etag) {
+		return notModified(c)
+	}
+
+	return c.JSON(http.StatusOK, okResp{it.out})
+}
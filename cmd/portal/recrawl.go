@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl/scheduler"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/knadh/koanf/v2"
+)
+
+// scheduleStore persists the re-crawl schedule in the DB.
+type scheduleStore struct {
+	co *core.Core
+}
+
+func (s *scheduleStore) Due(ctx context.Context, now time.Time, limit int) ([]scheduler.Entry, error) {
+	due, err := s.co.GetDueCrawls(now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]scheduler.Entry, 0, len(due))
+	for _, d := range due {
+		out = append(out, scheduler.Entry{
+			Job:      d.ManifestJob,
+			Interval: time.Duration(d.IntervalSecs) * time.Second,
+		})
+	}
+
+	return out, nil
+}
+
+func (s *scheduleStore) Save(ctx context.Context, e scheduler.Entry) error {
+	return s.co.UpsertCrawlSchedule(e.Job.ID, e.Interval, e.NextAt)
+}
+
+// runScheduler re-crawls manifests at adaptive intervals until ctx is cancelled.
+// Crawls are paused outside the crawl windows.
+func runScheduler(ctx context.Context, app *App, ko *koanf.Koanf) {
+	win := initCrawlSchedule(ko)
+
+	s := scheduler.New(scheduler.Opt{
+		MinInterval: ko.MustDuration("crawl.schedule_min_interval"),
+		MaxInterval: ko.MustDuration("crawl.schedule_max_interval"),
+		Factor:      ko.Float64("crawl.schedule_factor"),
+		Tick:        ko.MustDuration("crawl.schedule_tick"),
+		BatchSize:   ko.MustInt("crawl.batch_size"),
+		Workers:     ko.MustInt("crawl.workers"),
+		Paused: func(t time.Time) bool {
+			ok, _ := win.Allowed(t)
+			return !ok
+		},
+	}, &scheduleStore{co: app.core}, func(ctx context.Context, j models.ManifestJob) (bool, error) {
+		if j.URLobj == nil {
+			return false, errors.New("invalid manifest URL")
+		}
+
+		return app.crawl.CrawlManifest(ctx, j)
+	}, newLogger("scheduler"))
+
+	lo.Println("starting the crawl scheduler")
+	s.Run(ctx)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// relay forwards manifests accepted (approved) on this instance to upstream portal
+// instances, eg: a topical community portal feeding a global directory. Submitters
+// can opt out of relaying on the submission form.
+type relay struct {
+	// Upstream instances' root URLs and the token issued by them to this instance.
+	upstreams []string
+	token     string
+
+	// Tokens issued to downstream instances that are allowed to relay to this instance.
+	acceptTokens []string
+
+	// This instance's root URL that's sent upstream for attribution.
+	source string
+
+	hc *http.Client
+}
+
+// relayResp is the response of an upstream's relay endpoint.
+type relayResp struct {
+	Data struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	} `json:"data"`
+	Message string `json:"message"`
+}
+
+// forward relays an accepted manifest to all the upstreams in the background.
+func (r *relay) forward(app *App, m models.ManifestData) {
+	if len(r.upstreams) == 0 {
+		return
+	}
+
+	rl, err := app.core.GetManifestRelay(m.ID)
+	if err != nil || rl.OptOut {
+		return
+	}
+
+	for _, u := range r.upstreams {
+		go func(up string) {
+			if err := r.post(up, m.Manifest.URL.URL); err != nil {
+				app.lo.Printf("error relaying manifest: %s: %s: %v", up, m.Manifest.URL.URL, err)
+				return
+			}
+			app.lo.Printf("relayed manifest to %s: %s", up, m.Manifest.URL.URL)
+		}(u)
+	}
+}
+
+func (r *relay) post(upstream, manifestURL string) error {
+	form := url.Values{}
+	form.Set("url", manifestURL)
+	form.Set("source", r.source)
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(upstream, "/")+"/api/v1/relay", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+r.token)
+
+	resp, err := r.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var out relayResp
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 10000)).Decode(&out)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upstream responded with %d: %s", resp.StatusCode, out.Message)
+	}
+	if out.Data.Error != "" {
+		return fmt.Errorf("upstream: %s", out.Data.Error)
+	}
+
+	return nil
+}
+
+// handleRelaySubmission accepts a manifest submission relayed by an authorized
+// downstream instance. The submission goes through the regular submission pipeline
+// (and moderation) and is attributed to the downstream instance.
+func handleRelaySubmission(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.relay == nil || len(app.relay.acceptTokens) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "relaying is disabled")
+	}
+
+	token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	ok := false
+	for _, t := range app.relay.acceptTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			ok = true
+		}
+	}
+	if !ok {
+		return echo.NewHTTPError(http.StatusForbidden, "invalid token")
+	}
+
+	source := strings.TrimSpace(c.FormValue("source"))
+	if source == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "source is required")
+	}
+
+	res := submitManifest(c.Request().Context(), app, c.FormValue("url"), submitOpt{source: source, intake: core.IntakeRelay, intakeRef: source})
+	if res.retry {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, res.errMessage)
+	}
+
+	out := struct {
+		Message   string `json:"message"`
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}{res.message, res.errMessage, crawl.RequestID(c.Request().Context())}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+func initRelay(upstreams []string, token string, acceptTokens []string, source string, timeout time.Duration) *relay {
+	return &relay{
+		upstreams:    upstreams,
+		token:        token,
+		acceptTokens: acceptTokens,
+		source:       source,
+		hc:           &http.Client{Timeout: timeout},
+	}
+}
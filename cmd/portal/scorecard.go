@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// refreshScorecards fetches the OpenSSF Scorecard results of the project repositories
+// that are due for (re)scoring and caches them. Repositories that aren't scored by
+// Scorecard are recorded with the error so that they're retried only after max_age.
+func refreshScorecards(ctx context.Context, app *App, apiURL, maxAge string, batchSize int) int {
+	total := 0
+	for ctx.Err() == nil {
+		repos, err := app.core.GetReposForScorecard(maxAge, batchSize)
+		if err != nil || len(repos) == 0 {
+			break
+		}
+
+		for _, r := range repos {
+			s, err := fetchScorecard(ctx, app, apiURL, r)
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				app.lo.Printf("error fetching scorecard: %s: %v", r, err)
+				_ = app.core.UpsertScorecard(models.Scorecard{RepositoryURL: r}, err.Error())
+				continue
+			}
+
+			if err := app.core.UpsertScorecard(s, ""); err != nil {
+				return total
+			}
+			total++
+		}
+	}
+
+	app.lo.Printf("refreshed %d scorecards", total)
+	return total
+}
+
+func fetchScorecard(ctx context.Context, app *App, apiURL, repo string) (models.Scorecard, error) {
+	p, ok := core.ScorecardPath(repo)
+	if !ok {
+		return models.Scorecard{}, fmt.Errorf("unsupported repository host")
+	}
+
+	u, err := url.Parse(strings.TrimRight(apiURL, "/") + p)
+	if err != nil {
+		return models.Scorecard{}, err
+	}
+
+	resp, err := app.crawl.Fetch(ctx, u)
+	if err != nil {
+		return models.Scorecard{}, err
+	}
+
+	return core.ParseScorecard(repo, resp.Body)
+}
+
+// handleGetScorecard returns the cached Scorecard result of a repository.
+func handleGetScorecard(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		repo = strings.TrimSpace(c.QueryParam("url"))
+	)
+
+	if repo == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "url is required")
+	}
+
+	out, err := app.core.GetScorecards([]string{repo})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching scorecard")
+	}
+	if len(out) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "scorecard not found")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out[0]})
+}
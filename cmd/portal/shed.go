@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// loadShedder rejects new submissions when the instance is under load (too many
+// in-flight submissions, a deep crawl queue, or a slow DB) so that reads continue
+// to be served. A threshold of 0 disables it.
+type loadShedder struct {
+	maxPending   int64
+	maxQueue     int
+	maxDBLatency time.Duration
+	retryAfter   time.Duration
+
+	pending   atomic.Int64
+	dbLatency atomic.Int64
+
+	// Depth of the crawl queue. As crawls run in separate processes, it's
+	// read from the durable queue in the DB that they share.
+	queueDepth atomic.Int64
+}
+
+// watchQueue periodically reads the depth of the crawl queue.
+func (l *loadShedder) watchQueue(depth func() (int, error), interval time.Duration) {
+	if l.maxQueue == 0 {
+		return
+	}
+
+	for {
+		l.checkQueue(depth)
+		time.Sleep(interval)
+	}
+}
+
+// checkQueue reads and stores the depth of the crawl queue. On errors,
+// the last known depth is retained.
+func (l *loadShedder) checkQueue(depth func() (int, error)) {
+	if n, err := depth(); err == nil {
+		l.queueDepth.Store(int64(n))
+	}
+}
+
+// watchDB periodically measures the DB's latency with a ping.
+func (l *loadShedder) watchDB(db *sqlx.DB, interval time.Duration) {
+	if l.maxDBLatency == 0 {
+		return
+	}
+
+	for {
+		start := time.Now()
+
+		ctx, cancel := context.WithTimeout(context.Background(), l.maxDBLatency*2)
+		if err := db.PingContext(ctx); err != nil {
+			// Treat an unreachable DB as maximally slow.
+			l.dbLatency.Store(int64(l.maxDBLatency * 2))
+		} else {
+			l.dbLatency.Store(int64(time.Since(start)))
+		}
+		cancel()
+
+		time.Sleep(interval)
+	}
+}
+
+// isOverloaded checks the thresholds and returns the reason if one is crossed.
+func (l *loadShedder) isOverloaded() (bool, string) {
+	if l.maxPending > 0 && l.pending.Load() >= l.maxPending {
+		return true, "too many pending submissions"
+	}
+	if l.maxQueue > 0 && l.queueDepth.Load() >= int64(l.maxQueue) {
+		return true, "crawl queue is full"
+	}
+	if l.maxDBLatency > 0 && time.Duration(l.dbLatency.Load()) > l.maxDBLatency {
+		return true, "database is slow"
+	}
+
+	return false, ""
+}
+
+const shedMsg = "The directory is receiving too many submissions right now. Please retry in a while."
+
+// handleShedLoad is a middleware that sheds submissions (POST) when the instance is overloaded.
+func handleShedLoad(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Method != http.MethodPost {
+			return next(c)
+		}
+
+		var (
+			app = c.Get("app").(*App)
+			l   = app.shed
+		)
+
+		if ok, reason := l.isOverloaded(); ok {
+			app.lo.Printf("shedding submission: %s", reason)
+
+			c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int(l.retryAfter.Seconds())))
+			if strings.HasPrefix(c.Path(), "/api/") {
+				return echo.NewHTTPError(http.StatusServiceUnavailable, shedMsg)
+			}
+
+			return errPage(c, http.StatusServiceUnavailable, "", "Busy", shedMsg)
+		}
+
+		l.pending.Add(1)
+		defer l.pending.Add(-1)
+
+		return next(c)
+	}
+}
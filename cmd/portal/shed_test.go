@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShedQueueDepth(t *testing.T) {
+	var (
+		l     = &loadShedder{maxQueue: 100}
+		depth = 0
+		err   error
+	)
+	src := func() (int, error) { return depth, err }
+
+	l.checkQueue(src)
+	ok, _ := l.isOverloaded()
+	assert.False(t, ok)
+
+	// The crawl processes filled the shared queue.
+	depth = 150
+	l.checkQueue(src)
+	ok, reason := l.isOverloaded()
+	assert.True(t, ok)
+	assert.Equal(t, "crawl queue is full", reason)
+
+	// Errors retain the last known depth.
+	depth, err = 0, errors.New("db error")
+	l.checkQueue(src)
+	ok, _ = l.isOverloaded()
+	assert.True(t, ok)
+
+	// Drained.
+	err = nil
+	l.checkQueue(src)
+	ok, _ = l.isOverloaded()
+	assert.False(t, ok)
+}
+
+func TestShedLoadAPI(t *testing.T) {
+	l := &loadShedder{maxQueue: 100, retryAfter: time.Second * 30}
+	l.checkQueue(func() (int, error) { return 150, nil })
+
+	app := &App{shed: l, lo: log.New(io.Discard, "", 0)}
+	next := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	// API clients get a 503 error response and not the HTML error page.
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/api/v1/submit", nil), rec)
+	c.SetPath("/api/v1/submit")
+	c.Set("app", app)
+
+	err := handleShedLoad(next)(c)
+	var he *echo.HTTPError
+	if assert.ErrorAs(t, err, &he) {
+		assert.Equal(t, http.StatusServiceUnavailable, he.Code)
+	}
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+
+	// Reads aren't shed.
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/submit", nil), httptest.NewRecorder())
+	c.SetPath("/api/v1/submit")
+	c.Set("app", app)
+	assert.NoError(t, handleShedLoad(next)(c))
+}
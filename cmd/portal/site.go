@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,13 +11,16 @@ import (
 	"net/url"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/altcha-org/altcha-lib-go"
 	"github.com/floss-fund/go-funding-json/common"
 	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
 	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl"
 	"github.com/floss-fund/portal/internal/models"
+	"github.com/floss-fund/portal/internal/preview"
 	"github.com/floss-fund/portal/internal/search"
 	"github.com/labstack/echo/v4"
 )
@@ -33,11 +37,14 @@ type tplRenderer struct {
 }
 
 type Query struct {
-	Query   string   `query:"q"`
-	Type    string   `query:"type"`
-	Field   string   `query:"field"`
-	License []string `query:"license"`
-	Page    int      `query:"page"`
+	Query    string   `query:"q"`
+	Type     string   `query:"type"`
+	Field    string   `query:"field"`
+	License  []string `query:"license"`
+	Category []string `query:"category"`
+	Verified string   `query:"verified"`
+	MinScore float64  `query:"min_score"`
+	Page     int      `query:"page"`
 }
 
 // tplData is the data container that is injected
@@ -46,6 +53,9 @@ type tplData struct {
 	RootURL  string
 	AssetVer string
 	Data     interface{}
+
+	// Tenant sub-portal the page is rendered for, if any.
+	Tenant *tenant
 }
 
 type Tab struct {
@@ -58,11 +68,22 @@ type Tab struct {
 type Page struct {
 	Title         string
 	Description   string
+	Image         string
 	Heading       string
 	Tabs          []Tab
 	EnableCaptcha bool
+	EnableRelay   bool
 	ErrMessage    string
 	Message       string
+
+	// ID of the request for tracing a reported error.
+	RequestID string
+
+	// Token embedded in forms to make submissions idempotent.
+	IdempotencyKey string
+
+	// Private preview URL of a manifest submitted for preview.
+	PreviewURL string
 }
 
 var (
@@ -77,7 +98,7 @@ func handleIndexPage(c echo.Context) error {
 
 	// Get top tags.
 	tags, _ := app.core.GetTopTags(app.consts.HomeNumTags)
-	projects, _ := app.search.GetRecentProjects(app.consts.HomeNumProjects)
+	projects, _ := app.search.GetRecentProjects(app.consts.HomeNumProjects, tenantSlug(c))
 
 	out := struct {
 		Page
@@ -93,6 +114,30 @@ func handleIndexPage(c echo.Context) error {
 	return c.Render(http.StatusOK, "index", out)
 }
 
+func handleStatusPage(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+	)
+
+	st, err := app.core.GetCrawlStatus(app.consts.StatusNumRuns)
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching crawl status.")
+	}
+
+	out := struct {
+		Page
+		Status     models.CrawlStatus
+		SuccessPct string
+	}{}
+	out.Title = "Crawler status"
+	out.Heading = "Crawler status"
+	out.Description = "Health of the directory's manifest crawler over recent runs"
+	out.Status = st
+	out.SuccessPct = fmt.Sprintf("%.1f", st.SuccessRate*100)
+
+	return c.Render(http.StatusOK, "status", out)
+}
+
 func handleGetTags(c echo.Context) error {
 	var (
 		app = c.Get("app").(*App)
@@ -134,8 +179,9 @@ func handleValidatePage(c echo.Context) error {
 			return c.Render(http.StatusBadRequest, "validate", out)
 		}
 
-		if _, err := app.schema.ParseManifest([]byte(body), mUrl, false); err != nil {
+		if _, err := app.schema.ParseManifest(c.Request().Context(), []byte(body), mUrl, false); err != nil {
 			out.ErrMessage = err.Error()
+			out.RequestID = crawl.RequestID(c.Request().Context())
 			return c.Render(http.StatusBadRequest, "validate", out)
 		}
 	}
@@ -154,6 +200,7 @@ func handleSubmitPage(c echo.Context) error {
 		Title:         "Submit funding manifest",
 		Heading:       "Submit",
 		EnableCaptcha: app.consts.EnableCaptcha,
+		EnableRelay:   app.relay != nil && len(app.relay.upstreams) > 0,
 		Tabs: []Tab{
 			{
 				ID:       "submit",
@@ -171,9 +218,23 @@ func handleSubmitPage(c echo.Context) error {
 
 	// Render the page.
 	if c.Request().Method == http.MethodGet {
+		out.IdempotencyKey = makeIdempotencyKey()
+		if app.maint.enabled() {
+			out.ErrMessage = maintenanceMsg
+		}
 		return c.Render(http.StatusOK, "submit", out)
 	}
 
+	// If this is a repeat of an earlier submission (eg: double-click), return its result.
+	key := c.Request().Header.Get("Idempotency-Key")
+	if key == "" {
+		key = c.FormValue("idempotency_key")
+	}
+	if r, ok := app.submits.get(key); ok {
+		return c.Render(r.code, "submit", r.page)
+	}
+	out.IdempotencyKey = makeIdempotencyKey()
+
 	// Process submission.
 	// Is Captcha enabled?
 	if app.consts.EnableCaptcha {
@@ -183,10 +244,65 @@ func handleSubmitPage(c echo.Context) error {
 		}
 	}
 
+	// Remember the result against the idempotency key unless it's a
+	// transient error that's worth retrying.
+	res := submitManifest(c.Request().Context(), app, mURL, submitOpt{
+		noRelay: c.FormValue("no_relay") != "",
+		pin:     c.FormValue("pin"),
+		preview: c.FormValue("preview") != "",
+		intake:  core.IntakeWeb,
+	})
+	out.Message, out.ErrMessage, out.PreviewURL = res.message, res.errMessage, res.previewURL
+	out.RequestID = crawl.RequestID(c.Request().Context())
+	if !res.retry {
+		app.submits.set(key, res.code, out)
+	}
+
+	return c.Render(res.code, "submit", out)
+}
+
+// submission is the result of a manifest submission.
+type submission struct {
+	code       int
+	message    string
+	errMessage string
+
+	// The error is transient (eg: DB error) and the submission can be retried.
+	retry bool
+
+	// Private preview URL of a manifest submitted for preview.
+	previewURL string
+}
+
+// submitOpt represents the options of a submission.
+type submitOpt struct {
+	// The submitter opted out of relaying the manifest to upstream instances.
+	noRelay bool
+
+	// The downstream instance the submission was relayed from.
+	source string
+
+	// Channel (core.Intake*) the submission came through and a reference
+	// within it for attribution (eg: the API key's name).
+	intake    string
+	intakeRef string
+
+	// Optional SHA-256 hash of the manifest's contents to pin the listing to.
+	pin string
+
+	// Save the manifest in preview (visible only at a private URL) instead of
+	// submitting it for review. Submitting a manifest in preview again without
+	// it submits it for review.
+	preview bool
+}
+
+// submitManifest validates a submitted manifest URL, fetches and validates the
+// manifest, and adds it to the database for review. This is the pipeline shared
+// by all submission channels (web form, API, e-mail, relay).
+func submitManifest(ctx context.Context, app *App, mURL string, o submitOpt) submission {
 	u, err := common.IsURL("url", mURL, v1.MaxURLLen)
 	if err != nil {
-		out.ErrMessage = err.Error()
-		return c.Render(http.StatusBadRequest, "submit", out)
+		return submission{code: http.StatusBadRequest, errMessage: err.Error()}
 	}
 
 	// Remove any ?query params and #hash fragments
@@ -196,53 +312,126 @@ func handleSubmitPage(c echo.Context) error {
 	// Check if the domain is disallowed.
 	for _, pattern := range app.consts.DisallowedDomains {
 		if matchHostname(u.Host, pattern) {
-			out.ErrMessage = fmt.Sprintf("The host %s (CDN URL) is not allowed. Please use a fully qualified domain or a path like github.com/user/project...", pattern)
-			return c.Render(http.StatusBadRequest, "submit", out)
+			return submission{code: http.StatusBadRequest,
+				errMessage: fmt.Sprintf("The host %s (CDN URL) is not allowed. Please use a fully qualified domain or a path like github.com/user/project...", pattern)}
 		}
 	}
 
 	if !strings.HasSuffix(u.Path, app.consts.ManifestURI) {
-		out.ErrMessage = fmt.Sprintf("URL must end in %s", app.consts.ManifestURI)
-		return c.Render(http.StatusBadRequest, "submit", out)
+		return submission{code: http.StatusBadRequest, errMessage: fmt.Sprintf("URL must end in %s", app.consts.ManifestURI)}
+	}
+
+	pin := ""
+	if o.pin != "" {
+		if pin, err = core.ParseContentHash(o.pin); err != nil {
+			return submission{code: http.StatusBadRequest, errMessage: err.Error()}
+		}
 	}
 
+	// If the same URL is already being processed by another request, don't fetch it again.
+	if !app.submits.begin(u.String()) {
+		return submission{code: http.StatusOK, message: "This manifest is already being processed. Check back in a bit.", retry: true}
+	}
+	defer app.submits.end(u.String())
+
 	// See if the manifest is already in the database.
-	if status, err := app.core.GetManifestStatus(u.String()); err != nil {
-		out.ErrMessage = "Error checking manifest status. Retry later."
-		return c.Render(http.StatusBadRequest, "submit", out)
-	} else if status != "" {
-		switch status {
+	if st, err := app.core.GetManifestStatus(u.String()); err != nil {
+		return submission{code: http.StatusBadRequest, errMessage: "Error checking manifest status. Retry later.", retry: true}
+	} else if st.URL != "" && st.URL != u.String() {
+		// The URL is an alias of a manifest that has moved.
+		return submission{code: http.StatusOK, errMessage: fmt.Sprintf("This manifest has moved to %s", st.URL)}
+	} else if st.Status != "" {
+		msg := ""
+		switch st.Status {
 		case core.ManifestStatusActive:
-			out.ErrMessage = "Manifest is already active."
+			msg = "Manifest is already active."
 		case core.ManifestStatusPending:
-			out.ErrMessage = "Manifest is already submitted and is pending review."
+			msg = "Manifest is already submitted and is pending review."
 		case core.ManifestStatusBlocked:
-			out.ErrMessage = "Manifest URL is blocked and cannot be submitted at this time."
+			msg = "Manifest URL is blocked and cannot be submitted at this time."
 		}
 
-		if out.ErrMessage != "" {
-			return c.Render(http.StatusOK, "submit", out)
+		if msg != "" {
+			if st.CrawlErrors > 0 && st.CrawlMessage != nil {
+				msg += fmt.Sprintf(" The last crawl failed (%d error(s)): %s", st.CrawlErrors, *st.CrawlMessage)
+			}
+			return submission{code: http.StatusOK, errMessage: msg}
 		}
 	}
 
-	// Fetch and validate the manifest.
-	m, err := app.crawl.FetchManifest(u)
+	// Fetch and validate the manifest with the stricter submission limits.
+	res, err := app.crawl.FetchManifest(ctx, u,
+		crawl.WithTimeout(app.consts.SubmitReqTimeout),
+		crawl.WithMaxBytes(app.consts.SubmitMaxBytes),
+		crawl.IgnoreRobots())
 	if err != nil {
-		out.ErrMessage = err.Error()
-		return c.Render(http.StatusBadRequest, "submit", out)
+		app.lo.Printf("error fetching submitted manifest: %v manifest_url=%s request_id=%s", err, u.String(), crawl.RequestID(ctx))
+
+		// A cancelled request (eg: the client went away) is worth retrying.
+		return submission{code: http.StatusBadRequest, errMessage: err.Error(), retry: ctx.Err() != nil}
 	}
+	if pin != "" && res.Hash != pin {
+		return submission{code: http.StatusBadRequest, errMessage: fmt.Sprintf("The SHA-256 hash of the manifest's contents (%s) doesn't match the pinned hash.", res.Hash)}
+	}
+	m := res.Manifest
 
 	// Add it to the database.
 	m.GUID = core.MakeGUID(m.Manifest.URL.URLobj)
 	m.GUID = strings.TrimSuffix(m.GUID, app.consts.ManifestURI)
 
-	if err := app.core.UpsertManifest(m, core.ManifestStatusPending); err != nil {
-		out.ErrMessage = "Error saving manifest to database. Retry later."
-		return c.Render(http.StatusBadRequest, "submit", out)
+	// Check the new listing against the anti-abuse velocity limits and the payment address denylist.
+	var notes []string
+	for _, fn := range []func(*App, models.ManifestData) (string, error){checkVelocity, checkDenylist} {
+		n, err := fn(app, m)
+		if err != nil {
+			return submission{code: http.StatusBadRequest, errMessage: "Error checking manifest. Retry later.", retry: true}
+		}
+		if n != "" {
+			notes = append(notes, n)
+		}
+	}
+	if o.source != "" {
+		notes = append(notes, "Relayed from "+o.source)
+	}
+	note := strings.Join(notes, ". ")
+
+	status := core.ManifestStatusPending
+	if o.preview {
+		status = core.ManifestStatusPreview
+	}
+
+	// Flag the listing for moderation.
+	if note != "" {
+		app.lo.Printf("%s manifest_url=%s request_id=%s", note, m.Manifest.URL.URL, crawl.RequestID(ctx))
+	}
+
+	// The manifest and its submission options are saved together so that a retry
+	// after a failure isn't rejected as already submitted with the options lost.
+	// Crawls of the manifest are traced with the submission's request ID.
+	token, err := app.core.SubmitManifest(m, status, core.SubmitOpt{
+		NoRelay:       o.noRelay,
+		RelaySource:   o.source,
+		Intake:        o.intake,
+		IntakeRef:     o.intakeRef,
+		RequestID:     crawl.RequestID(ctx),
+		Pin:           pin,
+		StatusMessage: note,
+		Preview:       o.preview,
+	})
+	if err != nil {
+		return submission{code: http.StatusBadRequest, errMessage: "Error saving manifest to database. Retry later.", retry: true}
+	}
+
+	if res.RawURL != "" {
+		app.core.InsertManifestAlias(m.Manifest.URL.URL, res.RawURL, core.AliasRaw)
+	}
+
+	// The preview URL is only shown once. Every preview submission generates a new one.
+	if o.preview {
+		return submission{code: http.StatusOK, message: "preview", previewURL: fmt.Sprintf("%s/preview/%s", app.consts.RootURL, token)}
 	}
 
-	out.Message = "success"
-	return c.Render(http.StatusOK, "submit", out)
+	return submission{code: http.StatusOK, message: "success"}
 }
 
 func handleValidateManifest(c echo.Context) error {
@@ -252,7 +441,7 @@ func handleValidateManifest(c echo.Context) error {
 		body = c.FormValue("body")
 	)
 
-	m, err := app.schema.ParseManifest([]byte(body), mUrl, false)
+	m, err := app.schema.ParseManifest(c.Request().Context(), []byte(body), mUrl, false)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
@@ -280,11 +469,29 @@ func handleManifestPage(c echo.Context) error {
 		// Project guid.
 		pGuid = ""
 
+		// Preview token of a manifest in preview.
+		preview = ""
+
 		// Template response.
 		out = struct {
 			Page
 			Manifest models.ManifestData
 			Project  v1.Project
+
+			// Other manifests of the entity linked to this one, and
+			// the parent manifest this one is linked to (if any).
+			Linked []models.ManifestData
+			Parent models.ManifestData
+
+			Related      []models.RelatedProject
+			Citation     *models.Citation
+			Activity     *models.RepoActivity
+			Deprecations *models.Deprecations
+			Repositories []models.Repository
+			Translations []models.Translation
+			Rejected     []models.RejectedProject
+
+			Preview bool
 		}{}
 	)
 
@@ -323,6 +530,21 @@ func handleManifestPage(c echo.Context) error {
 		tpl = "history"
 		out.Title = "Financial history of projects by %s"
 		out.Description = "Financial and funding history of projects by %s"
+	} else if strings.HasPrefix(mGuid, "/preview/") {
+		// Private preview of a manifest that's not public yet: /preview/<token>[/projects|funding|history].
+		var page string
+		preview, page, _ = strings.Cut(strings.TrimSuffix(strings.TrimPrefix(mGuid, "/preview/"), "/"), "/")
+		switch page {
+		case "":
+			tpl = "entity"
+		case "projects", "funding", "history":
+			tpl = page
+		default:
+			return errPage(c, http.StatusNotFound, "", "Page not found", "Page not found.")
+		}
+		out.Preview = true
+		out.Title = "Preview of %s"
+		out.Description = "Preview of the listing of %s"
 	} else {
 		// Main entity page.
 		tpl = "entity"
@@ -332,13 +554,24 @@ func handleManifestPage(c echo.Context) error {
 	}
 
 	// Get the manifest.
-	m, err := app.core.GetManifest(0, mGuid)
+	var (
+		m   models.ManifestData
+		err error
+	)
+	if preview != "" {
+		m, err = app.core.GetPreviewManifest(preview)
+	} else {
+		m, err = app.core.GetManifest(0, mGuid)
+	}
 	if err != nil {
 		if err == core.ErrNotFound {
 			return errPage(c, http.StatusNotFound, "", "Manifest not found", err.Error())
 		}
 		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching manifest.")
 	}
+	if preview == "" && !isListed(app, c, m.ID) {
+		return errPage(c, http.StatusNotFound, "", "Manifest not found", "Manifest not found.")
+	}
 
 	// If it's a single project's page, get the project.
 	var prj v1.Project
@@ -350,12 +583,58 @@ func handleManifestPage(c echo.Context) error {
 			return errPage(c, http.StatusNotFound, "", "Project not found", "Project not found.")
 		}
 		prj = m.Manifest.Projects[idx]
+		out.Related, _ = app.core.GetRelatedProjects(m.GUID, prj.GUID, numRelated, tenantSlug(c))
+		out.Citation, _ = core.GetCitation(m, prj.GUID)
+		out.Repositories, _ = core.GetRepositories(m, prj.GUID)
+		if a, err := app.core.GetRepoActivity([]string{prj.RepositoryURL.URL}); err == nil && len(a) > 0 {
+			out.Activity = &a[0]
+		}
+		if t, err := app.core.GetTranslations(m.ID, ""); err == nil {
+			out.Translations = projectTranslations(t, prj.GUID)
+		}
 		out.Title = prj.Name + "by %s"
 		out.Description = abbrev(prj.Description, 200)
 	}
 
+	if tpl == "funding" {
+		out.Deprecations, _ = core.GetDeprecations(m)
+	}
+	if tpl == "projects" {
+		out.Rejected, _ = core.GetRejectedProjects(m)
+	}
+
+	// Get the other manifests of the entity to aggregate their projects and plans.
+	linked, _ := app.core.GetLinkedManifests(m.ID)
+	if pid, _ := app.core.GetManifestParent(m.ID); pid > 0 {
+		out.Parent, _ = app.core.GetManifest(pid, "")
+	}
+
+	// Project pages also show related projects that change independently
+	// of the manifests, so only the other pages are conditional. Previews
+	// are never cached.
+	if preview != "" {
+		c.Response().Header().Set("Cache-Control", "no-store")
+		c.Response().Header().Set("X-Robots-Tag", "noindex")
+	} else if pGuid == "" {
+		tags := []string{c.Request().URL.Path, manifestETag(m), manifestETag(out.Parent)}
+		for _, l := range linked {
+			tags = append(tags, manifestETag(l))
+		}
+		if checkETag(c, makeETag(tags...)) {
+			return notModified(c)
+		}
+	}
+
+	nProjects, nPlans := len(m.Manifest.Projects), len(m.Manifest.Funding.Plans)
+	for _, l := range linked {
+		nProjects += len(l.Manifest.Projects)
+		nPlans += len(l.Manifest.Funding.Plans)
+	}
+
 	out.Manifest = m
 	out.Project = prj
+	out.Linked = linked
+	out.Image = fmt.Sprintf("%s/card/%s", app.consts.RootURL, m.GUID)
 	out.Title = fmt.Sprintf(out.Title, m.Manifest.Entity.Name)
 	out.Description = fmt.Sprintf(out.Description, m.Manifest.Entity.Name)
 	out.Heading = m.Manifest.Entity.Name
@@ -368,14 +647,14 @@ func handleManifestPage(c echo.Context) error {
 		},
 		{
 			ID:       "projects",
-			Label:    fmt.Sprintf("Projects (%d)", len(m.Manifest.Projects)),
+			Label:    fmt.Sprintf("Projects (%d)", nProjects),
 			Selected: tpl == "projects",
 			URL:      fmt.Sprintf("%s/view/projects/%s", app.consts.RootURL, m.GUID),
 		},
 		{
 			ID:       "funding",
 			Selected: tpl == "funding",
-			Label:    fmt.Sprintf("Funding plans (%d)", len(m.Manifest.Funding.Plans)),
+			Label:    fmt.Sprintf("Funding plans (%d)", nPlans),
 			URL:      fmt.Sprintf("%s/view/funding/%s", app.consts.RootURL, m.GUID),
 		},
 		{
@@ -386,6 +665,14 @@ func handleManifestPage(c echo.Context) error {
 		},
 	}
 
+	// Tabs of previews stay on the preview URL.
+	if preview != "" {
+		for n, t := range out.Tabs {
+			out.Tabs[n].URL = fmt.Sprintf("%s/preview/%s/%s", app.consts.RootURL, preview, t.ID)
+		}
+		out.Tabs[0].URL = fmt.Sprintf("%s/preview/%s", app.consts.RootURL, preview)
+	}
+
 	// If the view is for a single project, add a tab for that too.
 	if pGuid != "" {
 		out.Title = fmt.Sprintf("%s by %s - Funding", prj.Name, m.Entity.Name)
@@ -404,6 +691,44 @@ func handleManifestPage(c echo.Context) error {
 	return c.Render(http.StatusOK, tpl, out)
 }
 
+// handleManifestCard renders the social preview card image of a manifest.
+func handleManifestCard(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		mGuid = strings.TrimPrefix(c.Request().URL.Path, "/card/")
+	)
+
+	m, err := app.core.GetManifest(0, mGuid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+	}
+	if !isListed(app, c, m.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=3600")
+	if checkETag(c, makeETag("card", manifestETag(m))) {
+		return notModified(c)
+	}
+
+	// The cached card is invalidated whenever the manifest is updated.
+	ver := m.UpdatedAt.String()
+	b, ok := app.cards.Get(m.ID, ver)
+	if !ok {
+		b, err = preview.Render(preview.NewCard(m))
+		if err != nil {
+			app.lo.Printf("error rendering preview card: %s: %v", m.GUID, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "error rendering card")
+		}
+		app.cards.Set(m.ID, ver, b)
+	}
+
+	return c.Blob(http.StatusOK, "image/png", b)
+}
+
 func handleSearchPage(c echo.Context) error {
 	var (
 		app = c.Get("app").(*App)
@@ -428,7 +753,7 @@ func handleSearchPage(c echo.Context) error {
 	)
 	switch q.Type {
 	case "entity":
-		query := search.EntityQuery{Query: q.Query, Field: q.Field, Page: q.Page}
+		query := search.EntityQuery{Query: q.Query, Field: q.Field, Page: q.Page, Tenant: tenantSlug(c)}
 
 		o, num, err := app.search.SearchEntities(query)
 		if err != nil {
@@ -437,12 +762,17 @@ func handleSearchPage(c echo.Context) error {
 		results = o
 		total = num
 	case "project":
-		query := search.ProjectQuery{Query: q.Query, Field: q.Field, Page: q.Page}
+		query := search.ProjectQuery{Query: q.Query, Field: q.Field, Page: q.Page, Tenant: tenantSlug(c)}
 		query.Licenses = []string{}
 
 		for _, l := range c.QueryParams()["license"] {
 			query.Licenses = append(query.Licenses, l)
 		}
+		query.Categories = q.Category
+		if core.IsVerificationLevel(q.Verified) {
+			query.Verification = q.Verified
+		}
+		query.MinScorecard = q.MinScore
 
 		o, num, err := app.search.SearchProjects(query)
 		if err != nil {
@@ -470,6 +800,15 @@ func handleSearchPage(c echo.Context) error {
 	qp.Set("q", q.Query)
 	qp.Set("type", q.Type)
 	qp.Set("field", q.Field)
+	for _, c := range q.Category {
+		qp.Add("category", c)
+	}
+	if q.Verified != "" {
+		qp.Set("verified", q.Verified)
+	}
+	if q.MinScore > 0 {
+		qp.Set("min_score", strconv.FormatFloat(q.MinScore, 'f', -1, 64))
+	}
 
 	out.Pagination = template.HTML(pg.HTML("", qp))
 	out.Title = "Search"
@@ -525,11 +864,21 @@ func handleReport(c echo.Context) error {
 
 // Render executes and renders a template for echo.
 func (t *tplRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
-	return t.tpl.ExecuteTemplate(w, name, tplData{
+	out := tplData{
 		RootURL:  t.RootURL,
 		AssetVer: t.AssetVer,
 		Data:     data,
-	})
+	}
+
+	// Tenant sub-portals have their own root URLs and branding.
+	if c != nil {
+		if tn := getTenant(c); tn != nil {
+			out.RootURL = tn.RootURL
+			out.Tenant = tn
+		}
+	}
+
+	return t.tpl.ExecuteTemplate(w, name, out)
 }
 
 func errPage(c echo.Context, code int, tpl, title, message string) error {
@@ -578,3 +927,64 @@ func matchHostname(host, pattern string) bool {
 
 	return false
 }
+
+func handleCategoriesPage(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	cats, err := app.core.GetCategories()
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching categories.")
+	}
+
+	out := struct {
+		Page
+		Categories []models.Category
+	}{}
+	out.Title = "Browse projects by category"
+	out.Heading = "Categories"
+	out.Categories = cats
+
+	return c.Render(http.StatusOK, "categories", out)
+}
+
+// handleCategoryPage lists the projects in a category.
+func handleCategoryPage(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	cat, ok := core.GetCategory(c.Param("id"))
+	if !ok {
+		return errPage(c, http.StatusNotFound, "", "Category not found", "Category not found.")
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	q := search.ProjectQuery{Query: "*", Page: page, Tenant: tenantSlug(c)}
+	q.Categories = []string{cat.ID}
+
+	res, total, err := app.search.SearchProjects(q)
+	if err != nil {
+		return errPage(c, http.StatusBadRequest, "", "Error", "An internal error occurred while searching.")
+	}
+
+	pg := app.pg.NewFromURL(c.Request().URL.Query())
+	pg.SetTotal(total)
+
+	out := struct {
+		Page
+		Pagination template.HTML
+		Q          Query
+		Total      int
+		Results    interface{}
+	}{}
+	out.Pagination = template.HTML(pg.HTML("", url.Values{}))
+	out.Title = fmt.Sprintf("%s - Projects seeking funding", cat.Name)
+	out.Heading = cat.Name
+	out.Q = Query{Type: "project"}
+	out.Total = total
+	out.Results = res
+
+	return c.Render(http.StatusOK, "search", out)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// snapshotTable is a table in an instance snapshot. Tables are listed in the order
+// of their foreign key dependencies so that they can be restored in that order.
+type snapshotTable struct {
+	name string
+
+	// Whether the table has a SERIAL id whose sequence is reset after restore.
+	serial bool
+}
+
+var snapshotTables = []snapshotTable{
+	{"manifests", true},
+	{"entities", true},
+	{"projects", true},
+	{"settings", false},
+	{"reports", true},
+	{"favicons", false},
+	{"manifest_mirrors", false},
+	{"scorecards", false},
+	{"repo_activity", false},
+	{"translations", false},
+	{"api_keys", true},
+	{"api_key_usage", false},
+	{"crawl_runs", true},
+	{"manifest_liveness", false},
+	{"manifest_traces", false},
+	{"crawl_errors", true},
+	{"manifest_aliases", true},
+	{"entity_links", false},
+	{"manifest_changes", true},
+	{"funding_snapshots", true},
+	{"manifest_versions", true},
+	{"tag_categories", false},
+	{"related_projects", false},
+	{"payment_denylist", false},
+	{"fiscal_hosts", true},
+	{"fiscal_host_members", false},
+	{"manifest_webhooks", true},
+	{"payment_changes", true},
+	{"telemetry_reports", false},
+	{"tenant_manifests", false},
+}
+
+// Number of rows inserted in one statement on restore.
+const snapshotBatchSize = 500
+
+// snapshotMeta describes a snapshot and is written to snapshot.json.
+type snapshotMeta struct {
+	Version   string         `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Tables    map[string]int `json:"tables"`
+}
+
+// writeSnapshot exports all instance data (manifests, history, moderation state,
+// API keys etc.) to the given directory as one JSON lines file (one row per line)
+// per table, along with snapshot.json. The export runs in a single read-only
+// transaction so that the snapshot is consistent.
+func writeSnapshot(app *App, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	ver, err := getLastMigrationVersion(app.db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := app.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`); err != nil {
+		return err
+	}
+
+	meta := snapshotMeta{Version: ver, CreatedAt: time.Now(), Tables: make(map[string]int, len(snapshotTables))}
+	for _, t := range snapshotTables {
+		n, err := writeSnapshotTable(tx, t, filepath.Join(dir, t.name+".jsonl"))
+		if err != nil {
+			return fmt.Errorf("error exporting %s: %v", t.name, err)
+		}
+		meta.Tables[t.name] = n
+		app.lo.Printf("exported %d rows of %s", n, t.name)
+	}
+
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, "snapshot.json"), b, 0644)
+}
+
+func writeSnapshotTable(tx *sqlx.Tx, t snapshotTable, fPath string) (int, error) {
+	f, err := os.CreateTemp(filepath.Dir(fPath), "."+t.name+"-*.jsonl")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// Table names are from the fixed list above.
+	rows, err := tx.Query(fmt.Sprintf(`SELECT ROW_TO_JSON(t)::TEXT FROM %s t`, t.name))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var (
+		w = bufio.NewWriter(f)
+		n = 0
+	)
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return 0, err
+		}
+		if _, err := w.WriteString(row + "\n"); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+
+	return n, os.Rename(f.Name(), fPath)
+}
+
+// restoreSnapshot replaces all instance data with a snapshot written by writeSnapshot.
+// The database should be installed (--install) or upgraded to the same version as
+// the snapshot. The restore runs in a single transaction. Triggers (eg: change history)
+// are disabled during the restore as the history is restored from the snapshot.
+func restoreSnapshot(app *App, dir string, prompt bool) error {
+	b, err := os.ReadFile(filepath.Join(dir, "snapshot.json"))
+	if err != nil {
+		return err
+	}
+
+	var meta snapshotMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return fmt.Errorf("error reading snapshot.json: %v", err)
+	}
+
+	ver, err := getLastMigrationVersion(app.db)
+	if err != nil {
+		return err
+	}
+	if ver != meta.Version {
+		return fmt.Errorf("snapshot is of version %s but the database is of version %s. Install or upgrade to the same version first", meta.Version, ver)
+	}
+
+	if prompt {
+		var ok string
+		fmt.Printf("** IMPORTANT: This will wipe all existing data and restore the snapshot from %s (%s).\n", dir, meta.CreatedAt.Format(time.RFC3339))
+		fmt.Print("continue (y/n)?  ")
+		if _, err := fmt.Scanf("%s", &ok); err != nil {
+			return fmt.Errorf("error reading value from terminal: %v", err)
+		}
+		if strings.ToLower(ok) != "y" {
+			fmt.Println("restore cancelled")
+			return nil
+		}
+	}
+
+	tx, err := app.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	names := make([]string, 0, len(snapshotTables))
+	for _, t := range snapshotTables {
+		names = append(names, t.name)
+	}
+	if _, err := tx.Exec(`TRUNCATE ` + strings.Join(names, ", ") + ` RESTART IDENTITY CASCADE`); err != nil {
+		return err
+	}
+
+	for _, t := range snapshotTables {
+		n, err := restoreSnapshotTable(tx, t, filepath.Join(dir, t.name+".jsonl"))
+		if err != nil {
+			return fmt.Errorf("error restoring %s: %v", t.name, err)
+		}
+		if exp, ok := meta.Tables[t.name]; ok && exp != n {
+			return fmt.Errorf("error restoring %s: expected %d rows, got %d", t.name, exp, n)
+		}
+		app.lo.Printf("restored %d rows of %s", n, t.name)
+	}
+
+	return tx.Commit()
+}
+
+func restoreSnapshotTable(tx *sqlx.Tx, t snapshotTable, fPath string) (int, error) {
+	f, err := os.Open(fPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s DISABLE TRIGGER USER`, t.name)); err != nil {
+		return 0, err
+	}
+
+	var (
+		q     = fmt.Sprintf(`INSERT INTO %[1]s SELECT * FROM JSON_POPULATE_RECORDSET(NULL::%[1]s, $1::JSON)`, t.name)
+		batch = make([]json.RawMessage, 0, snapshotBatchSize)
+		n     = 0
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		b, err := json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(q, string(b)); err != nil {
+			return err
+		}
+		n += len(batch)
+		batch = batch[:0]
+
+		return nil
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64*1024*1024)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		batch = append(batch, json.RawMessage(append([]byte(nil), line...)))
+		if len(batch) >= snapshotBatchSize {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ENABLE TRIGGER USER`, t.name)); err != nil {
+		return 0, err
+	}
+
+	// Continue the id sequence after the restored ids.
+	if t.serial {
+		if _, err := tx.Exec(fmt.Sprintf(`SELECT SETVAL(PG_GET_SERIAL_SEQUENCE('%[1]s', 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM %[1]s`, t.name)); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
@@ -13,6 +13,12 @@ func syncSearch(c *core.Core, s *search.Search, lo *log.Logger) {
 		lastID = 0
 		total  = 0
 	)
+
+	cats, err := c.GetTagCategoryMap()
+	if err != nil {
+		lo.Fatalf("error fetching tag categories: %v", err)
+	}
+
 	for {
 		items, err := c.GetManifests(lastID, 1000)
 		if err != nil {
@@ -25,7 +31,8 @@ func syncSearch(c *core.Core, s *search.Search, lo *log.Logger) {
 		// Update each record to the search backend.
 		for _, item := range items {
 			item := item
-			updateSearchRecord(item, item.Status, s)
+			tenants, _ := c.GetManifestTenants(item.ID)
+			updateSearchRecord(item, item.Status, cats, manifestScorecards(c, item), tenants, s)
 		}
 
 		lastID = items[len(items)-1].ID
@@ -35,7 +42,22 @@ func syncSearch(c *core.Core, s *search.Search, lo *log.Logger) {
 	lo.Printf("synced %d items", total)
 }
 
-func updateSearchRecord(m models.ManifestData, status string, s *search.Search) {
+// manifestScorecards returns the Scorecard scores of the repositories of a manifest's projects.
+func manifestScorecards(c *core.Core, m models.ManifestData) map[string]float64 {
+	repos := make([]string, 0, len(m.Manifest.Projects))
+	for _, p := range m.Manifest.Projects {
+		repos = append(repos, p.RepositoryURL.URL)
+	}
+
+	out, _ := c.GetScorecardMap(repos)
+	return out
+}
+
+// updateSearchRecord re-indexes a manifest's entity and projects. Projects are
+// categorised by their tags as per the given tag => category mapping and carry
+// the Scorecard scores of their repositories from the given repo => score map.
+// tenants are the slugs of the tenant sub-portals the manifest is listed on.
+func updateSearchRecord(m models.ManifestData, status string, cats map[string]string, scores map[string]float64, tenants []string, s *search.Search) {
 	// Delete all search data (entity, projects) on the manifest.
 	_ = s.Delete(m.ID)
 
@@ -51,6 +73,7 @@ func updateSearchRecord(m models.ManifestData, status string, s *search.Search)
 			Description:  m.Manifest.Entity.Description,
 			WebpageURL:   m.Manifest.Entity.WebpageURL.URL,
 			NumProjects:  len(m.Manifest.Projects),
+			Tenants:      tenants,
 			UpdatedAt:    m.CreatedAt.Unix(),
 		})
 
@@ -68,6 +91,10 @@ func updateSearchRecord(m models.ManifestData, status string, s *search.Search)
 				Description:       p.Description,
 				Licenses:          p.Licenses,
 				Tags:              p.Tags,
+				Categories:        core.TagsToCategories(p.Tags, cats),
+				Verification:      m.Verification,
+				Scorecard:         scores[p.RepositoryURL.URL],
+				Tenants:           tenants,
 				UpdatedAt:         m.CreatedAt.Unix(),
 			})
 		}
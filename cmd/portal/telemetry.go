@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Random instance IDs generated by core.GetInstanceID.
+var reInstanceID = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// telemetry reports the instance's anonymous aggregate stats (number of listings
+// and projects, and the version) to a central instance. It's opt-in.
+type telemetry struct {
+	url     string
+	version string
+	hc      *http.Client
+}
+
+func initTelemetry(url, version string, timeout time.Duration) *telemetry {
+	return &telemetry{
+		url:     url,
+		version: version,
+		hc:      &http.Client{Timeout: timeout},
+	}
+}
+
+// run reports the stats every interval.
+func (t *telemetry) run(app *App, interval time.Duration) {
+	for {
+		if err := t.report(app); err != nil {
+			app.lo.Printf("error reporting telemetry: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// report posts the stats to the central instance.
+func (t *telemetry) report(app *App) error {
+	id, err := app.core.GetInstanceID()
+	if err != nil {
+		return err
+	}
+
+	r, err := app.core.GetInstanceStats()
+	if err != nil {
+		return err
+	}
+	r.InstanceID = id
+	r.Version = t.version
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.hc.Post(t.url, echo.MIMEApplicationJSON, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned %d: %s", t.url, resp.StatusCode, abbrev(string(body), 200))
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// handleTelemetryReport accepts the stats reported by an instance on the central instance.
+func handleTelemetryReport(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if !app.consts.AcceptTelemetry {
+		return echo.NewHTTPError(http.StatusNotFound, "telemetry is disabled")
+	}
+
+	var r models.TelemetryReport
+	if err := json.NewDecoder(io.LimitReader(c.Request().Body, 1024)).Decode(&r); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid report")
+	}
+	if !reInstanceID.MatchString(r.InstanceID) || len(r.Version) > 64 || r.Manifests < 0 || r.Projects < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid report")
+	}
+
+	if err := app.core.UpsertTelemetryReport(r); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error saving report")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// handleGetTelemetry returns the overall footprint of the instances that have
+// reported their stats recently, and the breakdown by version.
+func handleGetTelemetry(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if !app.consts.AcceptTelemetry {
+		return echo.NewHTTPError(http.StatusNotFound, "telemetry is disabled")
+	}
+
+	vers, err := app.core.GetTelemetryVersions(app.consts.TelemetryMaxAge)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching telemetry")
+	}
+
+	out := struct {
+		Instances int                       `json:"instances"`
+		Manifests int                       `json:"manifests"`
+		Projects  int                       `json:"projects"`
+		Versions  []models.TelemetryVersion `json:"versions"`
+	}{Versions: vers}
+	for _, v := range vers {
+		out.Instances += v.Instances
+		out.Manifests += v.Manifests
+		out.Projects += v.Projects
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
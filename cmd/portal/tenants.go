@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/knadh/koanf/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// Key of the request's tenant (*tenant) in the echo context.
+const ctxTenant = "tenant"
+
+// Max manifests returned per request of a tenant's listings.
+const maxTenantManifests = 1000
+
+var reTenantSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// tenant is a hosted sub-portal, eg: the topical directory of a per-language foundation,
+// with its own listings, branding, admins, and domain on a shared deployment.
+type tenant struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Domain      string `json:"domain"`
+	RootURL     string `json:"root_url"`
+	LogoURL     string `json:"logo_url"`
+
+	adminUsername []byte
+	adminPassword []byte
+}
+
+// tenants are the tenant sub-portals by their domains.
+type tenants map[string]*tenant
+
+// initTenants loads and validates the tenant sub-portals ([[tenants]]).
+func initTenants(ko *koanf.Koanf) (tenants, error) {
+	var (
+		out   = tenants{}
+		slugs = map[string]bool{}
+	)
+	for _, k := range ko.Slices("tenants") {
+		t := &tenant{
+			Slug:          k.String("slug"),
+			Name:          k.String("name"),
+			Description:   k.String("description"),
+			Domain:        strings.ToLower(k.String("domain")),
+			RootURL:       strings.TrimSuffix(k.String("root_url"), "/"),
+			LogoURL:       k.String("logo_url"),
+			adminUsername: []byte(k.String("admin_username")),
+			adminPassword: []byte(k.String("admin_password")),
+		}
+
+		if !reTenantSlug.MatchString(t.Slug) {
+			return nil, fmt.Errorf("invalid tenant slug %q: should be lowercase alphanumeric and hyphens, up to 32 chars", t.Slug)
+		}
+		if slugs[t.Slug] {
+			return nil, fmt.Errorf("%s: duplicate tenant slug", t.Slug)
+		}
+		if t.Name == "" {
+			return nil, fmt.Errorf("%s: name is required", t.Slug)
+		}
+		if t.Domain == "" || strings.ContainsAny(t.Domain, "/:@ ") {
+			return nil, fmt.Errorf("%s: invalid domain %q", t.Slug, t.Domain)
+		}
+		if _, ok := out[t.Domain]; ok {
+			return nil, fmt.Errorf("%s: duplicate tenant domain %s", t.Slug, t.Domain)
+		}
+		if u, err := url.Parse(t.RootURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%s: invalid root_url %q", t.Slug, t.RootURL)
+		}
+		if (len(t.adminUsername) == 0) != (len(t.adminPassword) == 0) {
+			return nil, fmt.Errorf("%s: both admin_username and admin_password are required", t.Slug)
+		}
+
+		slugs[t.Slug] = true
+		out[t.Domain] = t
+	}
+
+	return out, nil
+}
+
+// forHost returns the tenant of a request's Host, if any.
+func (ts tenants) forHost(host string) *tenant {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return ts[strings.ToLower(host)]
+}
+
+// bySlug returns the tenant with a slug, if any.
+func (ts tenants) bySlug(slug string) *tenant {
+	for _, t := range ts {
+		if t.Slug == slug {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// handleTenant is a middleware that resolves the tenant sub-portal of a request by its Host.
+func handleTenant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		app := c.Get("app").(*App)
+		if t := app.tenants.forHost(c.Request().Host); t != nil {
+			c.Set(ctxTenant, t)
+		}
+
+		return next(c)
+	}
+}
+
+// getTenant returns the tenant sub-portal of a request, or nil for the main portal.
+func getTenant(c echo.Context) *tenant {
+	t, _ := c.Get(ctxTenant).(*tenant)
+	return t
+}
+
+// tenantSlug returns the slug of the tenant sub-portal of a request, or an empty
+// string for the main portal, which lists all manifests.
+func tenantSlug(c echo.Context) string {
+	if t := getTenant(c); t != nil {
+		return t.Slug
+	}
+
+	return ""
+}
+
+// isTenantAdmin checks the credentials of the admins of the request's tenant. They
+// can only manage the tenant's listings (/api/tenant/*).
+func isTenantAdmin(username, password string, c echo.Context) bool {
+	t := getTenant(c)
+	if t == nil || len(t.adminUsername) == 0 || !strings.HasPrefix(c.Path(), "/api/tenant/") {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(username), t.adminUsername) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), t.adminPassword) == 1
+}
+
+// isListed checks whether a manifest is listed on the request's tenant sub-portal.
+// All manifests are listed on the main portal.
+func isListed(app *App, c echo.Context, manifestID int) bool {
+	t := getTenant(c)
+	if t == nil {
+		return true
+	}
+
+	ts, err := app.core.GetManifestTenants(manifestID)
+	return err == nil && slices.Contains(ts, t.Slug)
+}
+
+// adminTenant returns the tenant whose listings an admin request manages: the
+// tenant of the domain, or for the main portal's admins, ?tenant=slug.
+func adminTenant(c echo.Context) (*tenant, error) {
+	if t := getTenant(c); t != nil {
+		return t, nil
+	}
+
+	app := c.Get("app").(*App)
+	if t := app.tenants.bySlug(c.QueryParam("tenant")); t != nil {
+		return t, nil
+	}
+
+	return nil, echo.NewHTTPError(http.StatusBadRequest, "unknown tenant")
+}
+
+// handleGetTenants returns the tenant sub-portals.
+func handleGetTenants(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	out := make([]*tenant, 0, len(app.tenants))
+	for _, t := range app.tenants {
+		out = append(out, t)
+	}
+	slices.SortFunc(out, func(a, b *tenant) int { return strings.Compare(a.Slug, b.Slug) })
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleGetTenantManifests returns the manifests listed on a tenant sub-portal
+// after ?offset_id=, up to ?limit=.
+func handleGetTenantManifests(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	t, err := adminTenant(c)
+	if err != nil {
+		return err
+	}
+
+	offsetID, _ := strconv.Atoi(c.QueryParam("offset_id"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit < 1 || limit > maxTenantManifests {
+		limit = maxTenantManifests
+	}
+
+	out, err := app.core.GetTenantManifests(t.Slug, offsetID, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching tenant manifests")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleAddTenantManifest lists a manifest on a tenant sub-portal.
+func handleAddTenantManifest(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	t, err := adminTenant(c)
+	if err != nil {
+		return err
+	}
+
+	if err := app.core.AddTenantManifest(t.Slug, id); err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error adding tenant manifest")
+	}
+	reindexManifest(app, id)
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// handleDeleteTenantManifest removes a manifest from the listings of a tenant sub-portal.
+func handleDeleteTenantManifest(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	t, err := adminTenant(c)
+	if err != nil {
+		return err
+	}
+
+	if err := app.core.DeleteTenantManifest(t.Slug, id); err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest is not listed on the tenant")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error deleting tenant manifest")
+	}
+	reindexManifest(app, id)
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// reindexManifest re-indexes a manifest in search, eg: after its tenants change.
+func reindexManifest(app *App, id int) {
+	if m, err := app.core.GetManifest(id, ""); err == nil {
+		app.crawl.Callbacks.OnManifestUpdate(m, m.Status)
+	}
+}
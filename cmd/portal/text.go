@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/floss-fund/portal/internal/search"
+	"github.com/labstack/echo/v4"
+)
+
+// Search highlights that are dropped from text output.
+var reMarkTags = regexp.MustCompile(`</?mark>`)
+
+// textDoc builds a compact document for text-only clients (eg: terminals, screen
+// readers, and Gemini browsers) as either plain text or gemtext.
+type textDoc struct {
+	gemini bool
+	b      strings.Builder
+}
+
+func (d *textDoc) heading(level int, s string) {
+	if d.b.Len() > 0 {
+		d.b.WriteString("\n")
+	}
+	if d.gemini {
+		fmt.Fprintf(&d.b, "%s %s\n", strings.Repeat("#", level), s)
+		return
+	}
+
+	d.b.WriteString(s + "\n")
+	if level == 1 {
+		d.b.WriteString(strings.Repeat("=", len([]rune(s))) + "\n")
+	}
+}
+
+func (d *textDoc) para(s string) {
+	if s = strings.TrimSpace(reMarkTags.ReplaceAllString(s, "")); s != "" {
+		d.b.WriteString(s + "\n")
+	}
+}
+
+func (d *textDoc) item(s string) {
+	if d.gemini {
+		d.b.WriteString("* " + s + "\n")
+		return
+	}
+	d.b.WriteString("- " + s + "\n")
+}
+
+// link adds a link line. Gemtext links are on their own lines and can't be in list items.
+func (d *textDoc) link(u, label string) {
+	if u == "" {
+		return
+	}
+	if d.gemini {
+		fmt.Fprintf(&d.b, "=> %s %s\n", u, label)
+		return
+	}
+	fmt.Fprintf(&d.b, "  %s: %s\n", label, u)
+}
+
+// render writes the document with the negotiated media type.
+func (d *textDoc) render(c echo.Context, code int) error {
+	typ := echo.MIMETextPlainCharsetUTF8
+	if d.gemini {
+		typ = mimeGemini + "; charset=utf-8"
+	}
+
+	return c.Blob(code, typ, []byte(d.b.String()))
+}
+
+// entityText renders a manifest's entity, projects, and funding plans and channels
+// (and those of its linked manifests) as text.
+func entityText(m models.ManifestData, linked []models.ManifestData, gemini bool) *textDoc {
+	d := &textDoc{gemini: gemini}
+
+	e := m.Manifest.Entity
+	d.heading(1, e.Name)
+	d.para(fmt.Sprintf("%s (%s). Verification: %s", e.Type, e.Role, m.Verification))
+	d.para(e.Description)
+	d.link(e.WebpageURL.URL, "Website")
+
+	for _, mf := range append([]models.ManifestData{m}, linked...) {
+		for _, p := range mf.Manifest.Projects {
+			d.heading(2, p.Name)
+			d.para(p.Description)
+			if len(p.Licenses) > 0 {
+				d.para("Licenses: " + strings.Join(p.Licenses, ", "))
+			}
+			d.link(p.WebpageURL.URL, "Website")
+			d.link(p.RepositoryURL.URL, "Repository")
+		}
+
+		f := mf.Manifest.Funding
+		if len(f.Plans) > 0 {
+			d.heading(2, "Funding plans")
+			for _, p := range f.Plans {
+				if p.Status != "active" {
+					continue
+				}
+
+				s := p.Name
+				if p.Amount > 0 {
+					s += fmt.Sprintf(": %s %s", strconv.FormatFloat(p.Amount, 'f', -1, 64), p.Currency)
+				}
+				if p.Frequency != "" {
+					s += " (" + p.Frequency + ")"
+				}
+				d.item(s)
+			}
+		}
+
+		if len(f.Channels) > 0 {
+			d.heading(2, "Funding channels")
+			for _, ch := range f.Channels {
+				s := ch.Type
+				if ch.Description != "" {
+					s += ": " + ch.Description
+				}
+				d.item(s)
+
+				// Addresses that are URLs (eg: payment pages) are links.
+				if u, err := url.Parse(ch.Address); err == nil && (u.Scheme == "https" || u.Scheme == "http") {
+					d.link(ch.Address, ch.Type)
+				} else if ch.Address != "" {
+					d.para("  " + ch.Address)
+				}
+			}
+		}
+	}
+
+	d.heading(3, "Source")
+	d.link(m.URL, "funding.json")
+
+	return d
+}
+
+// handleGetDirectory returns the recently updated projects, or those matching
+// a search ?q, with their entities. Text-only clients can ask for plain text or
+// gemtext, which links to the entity pages in the same format.
+func handleGetDirectory(c echo.Context) error {
+	var (
+		app    = c.Get("app").(*App)
+		q      = strings.TrimSpace(c.QueryParam("q"))
+		format = negotiateFormat(c)
+	)
+
+	var (
+		res search.Projects
+		err error
+	)
+	if q == "" {
+		res, err = app.search.GetRecentProjects(app.consts.HomeNumProjects, tenantSlug(c))
+	} else {
+		page, _ := strconv.Atoi(c.QueryParam("page"))
+		res, _, err = app.search.SearchProjects(search.ProjectQuery{Query: q, Page: max(page, 1), Tenant: tenantSlug(c)})
+	}
+	if err != nil {
+		app.lo.Printf("error fetching directory: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching projects")
+	}
+
+	if format != formatText && format != formatGemini {
+		return c.JSON(http.StatusOK, okResp{res})
+	}
+
+	d := &textDoc{gemini: format == formatGemini}
+	if q == "" {
+		d.heading(1, "Recently updated projects")
+	} else {
+		d.heading(1, fmt.Sprintf("Projects matching %q", q))
+	}
+	if len(res) == 0 {
+		d.para("No projects found.")
+	}
+
+	for _, p := range res {
+		d.heading(2, reMarkTags.ReplaceAllString(p.Name, ""))
+		d.para(p.Description)
+		d.link(fmt.Sprintf("%s/api/entities/%s?format=%s", app.consts.RootURL, p.ManifestGUID, format), "Funding: "+p.EntityName)
+		d.link(p.RepositoryURL, "Repository")
+	}
+
+	return d.render(c, http.StatusOK)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// translator sends descriptions to a LibreTranslate compatible translation backend.
+type translator struct {
+	url    string
+	apiKey string
+	hc     *http.Client
+}
+
+func initTranslator(url, apiKey string, timeout time.Duration) *translator {
+	return &translator{
+		url:    strings.TrimRight(url, "/"),
+		apiKey: apiKey,
+		hc:     &http.Client{Timeout: timeout},
+	}
+}
+
+// translate translates text (of any language) to the given locale.
+func (t *translator) translate(ctx context.Context, text, locale string) (string, error) {
+	b, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  "auto",
+		"target":  locale,
+		"format":  "text",
+		"api_key": t.apiKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url+"/translate", bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translation backend returned %d: %s", resp.StatusCode, abbrev(string(body), 200))
+	}
+
+	var out struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("error parsing translation response: %v", err)
+	}
+
+	return out.TranslatedText, nil
+}
+
+// refreshTranslations translates the descriptions of active manifests (entity and
+// projects) that are new or have changed to the given locales. As the backend is
+// down for all descriptions when it's down, the run stops at the first error.
+func refreshTranslations(ctx context.Context, app *App, t *translator, locales []string, batchSize int) int {
+	total := 0
+	for ctx.Err() == nil {
+		jobs, err := app.core.GetForTranslation(locales, batchSize)
+		if err != nil || len(jobs) == 0 {
+			break
+		}
+
+		for _, j := range jobs {
+			text, err := t.translate(ctx, j.Description, j.Locale)
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				app.lo.Printf("error translating description: %d: %s: %s: %v", j.ManifestID, j.GUID, j.Locale, err)
+				app.lo.Printf("translated %d descriptions", total)
+				return total
+			}
+
+			if err := app.core.UpsertTranslation(j, text); err != nil {
+				return total
+			}
+			total++
+		}
+	}
+
+	app.lo.Printf("translated %d descriptions", total)
+	return total
+}
+
+// projectTranslations returns the translations of a project's description.
+func projectTranslations(all []models.Translation, guid string) []models.Translation {
+	var out []models.Translation
+	for _, t := range all {
+		if t.Kind == core.TranslationProject && t.GUID == guid {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+// handleGetTranslations returns the machine translations of a manifest's entity and
+// project descriptions, optionally of a single ?locale.
+func handleGetTranslations(c echo.Context) error {
+	var (
+		app  = c.Get("app").(*App)
+		guid = strings.TrimSuffix(c.Param("*"), "/")
+	)
+
+	m, err := app.core.GetManifest(0, guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+	}
+	if !isListed(app, c, m.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+	}
+
+	out, err := app.core.GetTranslations(m.ID, c.QueryParam("locale"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching translations")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
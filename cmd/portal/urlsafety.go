@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// urlList is a local list of known malicious domains and URL prefixes that the
+// links in manifests are checked against (crawl.URLChecker). A domain entry
+// matches its subdomains too.
+type urlList struct {
+	domains  map[string]bool
+	prefixes []string
+}
+
+// loadURLList loads a list file with one domain (eg: evil.example) or URL prefix
+// (eg: https://example.com/phish/) per line. Blank lines and # comments are ignored.
+func loadURLList(path string) (*urlList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := &urlList{domains: make(map[string]bool)}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		if strings.Contains(l, "://") {
+			out.prefixes = append(out.prefixes, strings.ToLower(l))
+		} else {
+			out.domains[strings.ToLower(strings.TrimSuffix(l, "."))] = true
+		}
+	}
+
+	return out, sc.Err()
+}
+
+// CheckURLs implements crawl.URLChecker.
+func (l *urlList) CheckURLs(ctx context.Context, urls []string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, raw := range urls {
+		if l.isListed(raw) {
+			out[raw] = "listed"
+		}
+	}
+
+	return out, nil
+}
+
+func (l *urlList) isListed(raw string) bool {
+	lower := strings.ToLower(raw)
+	for _, p := range l.prefixes {
+		if strings.HasPrefix(lower, p) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(lower)
+	if err != nil {
+		return false
+	}
+
+	// The host and its parent domains.
+	for h := strings.TrimSuffix(u.Hostname(), "."); h != ""; {
+		if l.domains[h] {
+			return true
+		}
+
+		_, p, ok := strings.Cut(h, ".")
+		if !ok {
+			break
+		}
+		h = p
+	}
+
+	return false
+}
+
+// safeBrowsing checks URLs with the Google Safe Browsing Lookup API (v4).
+type safeBrowsing struct {
+	apiKey string
+	hc     *http.Client
+}
+
+// Max URLs per Safe Browsing lookup.
+const safeBrowsingBatch = 500
+
+func initSafeBrowsing(apiKey string, timeout time.Duration) *safeBrowsing {
+	return &safeBrowsing{apiKey: apiKey, hc: &http.Client{Timeout: timeout}}
+}
+
+// CheckURLs implements crawl.URLChecker.
+func (s *safeBrowsing) CheckURLs(ctx context.Context, urls []string) (map[string]string, error) {
+	out := make(map[string]string)
+	for len(urls) > 0 {
+		n := min(len(urls), safeBrowsingBatch)
+		if err := s.lookup(ctx, urls[:n], out); err != nil {
+			return nil, err
+		}
+		urls = urls[n:]
+	}
+
+	return out, nil
+}
+
+func (s *safeBrowsing) lookup(ctx context.Context, urls []string, out map[string]string) error {
+	type entry struct {
+		URL string `json:"url"`
+	}
+
+	entries := make([]entry, 0, len(urls))
+	for _, u := range urls {
+		entries = append(entries, entry{URL: u})
+	}
+
+	var req struct {
+		Client struct {
+			ClientID      string `json:"clientId"`
+			ClientVersion string `json:"clientVersion"`
+		} `json:"client"`
+		ThreatInfo struct {
+			ThreatTypes      []string `json:"threatTypes"`
+			PlatformTypes    []string `json:"platformTypes"`
+			ThreatEntryTypes []string `json:"threatEntryTypes"`
+			ThreatEntries    []entry  `json:"threatEntries"`
+		} `json:"threatInfo"`
+	}
+	req.Client.ClientID = "funding-portal"
+	req.Client.ClientVersion = versionString
+	req.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	req.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	req.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	req.ThreatInfo.ThreatEntries = entries
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		"https://safebrowsing.googleapis.com/v4/threatMatches:find?key="+url.QueryEscape(s.apiKey), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.hc.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("safe browsing returned %d: %s", resp.StatusCode, abbrev(string(body), 200))
+	}
+
+	var res struct {
+		Matches []struct {
+			ThreatType string `json:"threatType"`
+			Threat     entry  `json:"threat"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return fmt.Errorf("error parsing safe browsing response: %v", err)
+	}
+
+	for _, m := range res.Matches {
+		out[m.Threat.URL] = m.ThreatType
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// velocityLimits caps the number of new listings per day that may share one
+// domain or one payment address. Listings over the limits are still accepted,
+// but are flagged for moderation. This blunts spam campaigns that reuse the
+// same infrastructure to create many listings.
+type velocityLimits struct {
+	maxPerDomain  int
+	maxPerAddress int
+
+	// Code hosting and similar shared hosts where the first path segment
+	// (user or org) is treated as the domain. eg: github.com/user
+	sharedHosts []string
+}
+
+// velocityDomain returns the domain of a manifest URL for velocity checks.
+func (v *velocityLimits) velocityDomain(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	if !slices.Contains(v.sharedHosts, host) {
+		return host
+	}
+
+	if user, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/"); user != "" {
+		return host + "/" + user
+	}
+
+	return host
+}
+
+// checkVelocity checks a new listing against the velocity limits and returns a
+// moderation note if any of them are exceeded. An empty note means it's within limits.
+func checkVelocity(app *App, m models.ManifestData) (string, error) {
+	v := app.velocity
+	if v == nil || m.Manifest.URL.URLobj == nil {
+		return "", nil
+	}
+
+	res, err := app.core.GetSubmissionVelocity(v.velocityDomain(m.Manifest.URL.URLobj), paymentAddresses(m), m.Manifest.URL.URL)
+	if err != nil {
+		return "", err
+	}
+
+	var notes []string
+	if v.maxPerDomain > 0 && res.DomainCount >= v.maxPerDomain {
+		notes = append(notes, fmt.Sprintf("%d new listings on %s in the last day", res.DomainCount, res.Domain))
+	}
+	if v.maxPerAddress > 0 && res.AddressCount >= v.maxPerAddress {
+		notes = append(notes, fmt.Sprintf("%d new listings with the same payment address in the last day", res.AddressCount))
+	}
+	if len(notes) == 0 {
+		return "", nil
+	}
+
+	return "Held for moderation: velocity limit exceeded: " + strings.Join(notes, ", "), nil
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// webhooks delivers events of manifests (update, delist, provenance-lost) to the
+// webhooks registered by their maintainers. Maintainers register webhooks with the
+// self-service API and confirm them with the link e-mailed to the manifest's entity.
+type webhooks struct {
+	maxPerManifest int
+	maxFailures    int
+
+	hc *http.Client
+}
+
+// Header with the HMAC-SHA256 signature of webhook payloads.
+const webhookSigHeader = "X-Portal-Signature"
+
+// initWebhooks initializes webhook deliveries. As webhook URLs are supplied by
+// maintainers, deliveries are guarded like crawls against reaching internal
+// services (SSRF), and redirects aren't followed.
+func initWebhooks(maxPerManifest, maxFailures int, timeout time.Duration, allowPrivate bool) *webhooks {
+	g := &crawl.Guard{
+		Schemes:      []string{"https"},
+		AllowPrivate: allowPrivate,
+		MaxRedirects: -1,
+	}
+
+	return &webhooks{
+		maxPerManifest: maxPerManifest,
+		maxFailures:    maxFailures,
+		hc:             g.Client(timeout),
+	}
+}
+
+// Keys of the stored cursors of the events that have been delivered.
+const (
+	cursorWebhookChanges = "webhooks.change_cursor"
+	cursorWebhookErrors  = "webhooks.error_cursor"
+)
+
+// run polls the change feed and provenance crawl errors (which are recorded by the
+// crawler running as a separate process) and delivers them. Deliveries are paused
+// in maintenance mode. It blocks forever.
+//
+// The cursors are stored in the DB so that events recorded while the site is down
+// are delivered when it's back. Every batch is claimed by advancing its cursor
+// before it's delivered so that multiple site instances don't deliver duplicates.
+func (w *webhooks) run(app *App, interval time.Duration, lo *log.Logger) {
+	co := app.core
+
+	// Start from the latest events when webhooks are first enabled. The cursors
+	// can't fall back to 0, which would deliver every past event, so retry until
+	// they're initialized.
+	for {
+		err := initCursor(co, cursorWebhookChanges, co.GetLastChangeID)
+		if err == nil {
+			err = initCursor(co, cursorWebhookErrors, co.GetLastCrawlErrorID)
+		}
+		if err == nil {
+			break
+		}
+
+		lo.Printf("error initializing webhook cursors: %v", err)
+		time.Sleep(interval)
+	}
+
+	for {
+		time.Sleep(interval)
+
+		// Events are picked up from the cursors once maintenance is over.
+		if app.maint.enabled() {
+			continue
+		}
+
+		if cur, err := co.GetCursor(cursorWebhookChanges, 0); err == nil {
+			changes, err := co.GetChanges(cur, maxChanges, "")
+			if err == nil && len(changes) > 0 {
+				if ok, _ := co.ClaimCursor(cursorWebhookChanges, cur, changes[len(changes)-1].ID); ok {
+					w.deliverChanges(co, changes, lo)
+				}
+			}
+		}
+
+		if cur, err := co.GetCursor(cursorWebhookErrors, 0); err == nil {
+			errs, err := co.GetProvenanceErrors(cur, maxChanges)
+			if err == nil && len(errs) > 0 {
+				if ok, _ := co.ClaimCursor(cursorWebhookErrors, cur, errs[len(errs)-1].ID); ok {
+					w.deliverErrors(co, errs, lo)
+				}
+			}
+		}
+	}
+}
+
+// initCursor creates the cursor at the ID of the latest event if it doesn't exist.
+func initCursor(co *core.Core, key string, last func() (int64, error)) error {
+	id, err := last()
+	if err != nil {
+		return err
+	}
+
+	_, err = co.GetCursor(key, id)
+	return err
+}
+
+// deliverChanges delivers listing updates and delists from the change feed.
+func (w *webhooks) deliverChanges(co *core.Core, changes []models.ManifestChange, lo *log.Logger) {
+	for _, ch := range changes {
+		ev := ""
+		switch ch.Event {
+		case "update":
+			ev = models.WebhookEventUpdate
+		case "delete":
+			ev = models.WebhookEventDelist
+		default:
+			continue
+		}
+
+		w.deliver(co, models.WebhookEvent{
+			Event:      ev,
+			ManifestID: ch.ManifestID,
+			GUID:       ch.GUID,
+			URL:        ch.URL,
+			CreatedAt:  ch.CreatedAt,
+		}, lo)
+	}
+}
+
+// deliverErrors delivers provenance crawl errors.
+func (w *webhooks) deliverErrors(co *core.Core, errs []models.ProvenanceError, lo *log.Logger) {
+	for _, e := range errs {
+		w.deliver(co, models.WebhookEvent{
+			Event:      models.WebhookEventProvenanceLost,
+			ManifestID: e.ManifestID,
+			GUID:       e.GUID,
+			URL:        e.URL,
+			Message:    e.Message,
+			CreatedAt:  e.CreatedAt,
+		}, lo)
+	}
+}
+
+// deliver posts an event to the manifest's webhooks subscribed to it.
+func (w *webhooks) deliver(co *core.Core, e models.WebhookEvent, lo *log.Logger) {
+	hooks, err := co.GetEventWebhooks(e.ManifestID, e.Event)
+	if err != nil || len(hooks) == 0 {
+		return
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	for _, h := range hooks {
+		start := time.Now()
+		status, err := w.post(h, e.Event, b)
+		if err != nil {
+			lo.Printf("error delivering webhook: %d: %s: %v manifest_url=%s duration=%s", h.ID, h.URL, err, e.URL, time.Since(start))
+		}
+
+		co.UpdateWebhookDelivery(h.ID, status, err == nil, w.maxFailures)
+	}
+}
+
+func (w *webhooks) post(h models.Webhook, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Portal-Event", event)
+	req.Header.Set(webhookSigHeader, "sha256="+core.SignWebhook(h.Secret, body))
+
+	resp, err := w.hc.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 10000))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// handleCreateWebhook registers a webhook for a verified manifest. The webhook
+// is delivered to only after it's confirmed with the link that's e-mailed to the
+// manifest's entity, which also carries the token for managing the webhook.
+func handleCreateWebhook(c echo.Context) error {
+	var (
+		app     = c.Get("app").(*App)
+		guid    = strings.TrimSpace(c.FormValue("guid"))
+		hookURL = strings.TrimSpace(c.FormValue("url"))
+		events  = splitEnvList(c.FormValue("events"))
+	)
+
+	if app.webhooks == nil || app.email == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "webhooks are disabled")
+	}
+
+	u, err := common.IsURL("url", hookURL, v1.MaxURLLen)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if u.Scheme != "https" {
+		return echo.NewHTTPError(http.StatusBadRequest, "url should be an https URL")
+	}
+
+	if len(events) == 0 {
+		events = core.WebhookEvents
+	}
+	for _, e := range events {
+		if !slices.Contains(core.WebhookEvents, e) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown event %q. Should be one of %s", e, strings.Join(core.WebhookEvents, ", ")))
+		}
+	}
+
+	m, err := app.core.GetManifest(0, guid)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "manifest not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching manifest")
+	}
+
+	// Only maintainers of verified listings can register webhooks.
+	if m.Status != core.ManifestStatusActive || m.Verification == core.VerificationUnverified {
+		return echo.NewHTTPError(http.StatusForbidden, "webhooks can only be registered for active, verified manifests")
+	}
+	if m.Manifest.Entity.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "the manifest has no entity e-mail to send the confirmation to")
+	}
+
+	if n, err := app.core.CountWebhooks(m.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error registering webhook")
+	} else if n >= app.webhooks.maxPerManifest {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a manifest can have at most %d webhooks", app.webhooks.maxPerManifest))
+	}
+
+	h, token, err := app.core.CreateWebhook(m.ID, u.String(), events)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error registering webhook")
+	}
+
+	link := app.consts.RootURL + "/webhooks/confirm?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("A webhook was registered for the funding manifest %s on %s\n\n"+
+		"URL: %s\nEvents: %s\n\n"+
+		"If you requested it, confirm it on the page at the link below. Keep the link private. "+
+		"Its token is for managing the webhook (X-Webhook-Token header).\n\n%s\n\n"+
+		"If you didn't request it, ignore this e-mail.\n",
+		m.Manifest.URL.URL, app.consts.RootURL, h.URL, strings.Join(events, ", "), link)
+
+	if err := app.email.send(m.Manifest.Entity.Email, "Confirm webhook for "+m.GUID, body); err != nil {
+		app.lo.Printf("error e-mailing webhook confirmation: %d: %v", h.ID, err)
+		app.core.DeleteWebhook(token)
+		return echo.NewHTTPError(http.StatusInternalServerError, "error sending the confirmation e-mail")
+	}
+
+	return c.JSON(http.StatusOK, okResp{"A confirmation link has been e-mailed to the manifest's entity e-mail."})
+}
+
+// handleWebhookConfirmPage renders the page that the e-mailed confirmation link opens.
+// The link only shows the webhook, and it's confirmed (and its signing secret shown)
+// by POSTing the form on the page, so that e-mail link scanners that follow the link
+// don't confirm webhooks that the entity never approved.
+func handleWebhookConfirmPage(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		token = c.FormValue("token")
+	)
+
+	if app.webhooks == nil {
+		return errPage(c, http.StatusNotFound, "", "Not found", "Webhooks are disabled.")
+	}
+
+	out := struct {
+		Page
+		Token     string
+		Webhook   models.Webhook
+		Confirmed bool
+	}{}
+	out.Title = "Confirm webhook"
+	out.Heading = "Confirm webhook"
+	out.Token = token
+
+	var err error
+	if c.Request().Method == http.MethodGet {
+		out.Webhook, err = app.core.GetWebhook(token)
+	} else {
+		out.Webhook, err = app.core.ConfirmWebhook(token)
+		out.Confirmed = true
+	}
+	if err != nil {
+		if err == core.ErrNotFound {
+			return errPage(c, http.StatusNotFound, "", "Not found", "Webhook not found. It may have been deleted.")
+		}
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error confirming webhook.")
+	}
+
+	return c.Render(http.StatusOK, "webhook", out)
+}
+
+// handleGetWebhook returns the webhook of the management token in the X-Webhook-Token header.
+func handleGetWebhook(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.webhooks == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "webhooks are disabled")
+	}
+
+	out, err := app.core.GetWebhook(c.Request().Header.Get("X-Webhook-Token"))
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "webhook not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching webhook")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleDeleteWebhook deletes the webhook of the management token in the X-Webhook-Token header.
+func handleDeleteWebhook(c echo.Context) error {
+	app := c.Get("app").(*App)
+	if app.webhooks == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "webhooks are disabled")
+	}
+
+	if err := app.core.DeleteWebhook(c.Request().Header.Get("X-Webhook-Token")); err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "webhook not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "error deleting webhook")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/knadh/koanf/v2"
+)
+
+// initCrawlSchedule returns the windows and blackouts that batch crawls are restricted to.
+// The config is validated upfront.
+func initCrawlSchedule(ko *koanf.Koanf) crawl.Schedule {
+	loc, _ := time.LoadLocation(ko.String("crawl.timezone"))
+
+	s := crawl.Schedule{Location: loc}
+	for _, w := range ko.Strings("crawl.windows") {
+		win, _ := crawl.ParseWindow(w)
+		s.Windows = append(s.Windows, win)
+	}
+	for _, b := range ko.Strings("crawl.blackouts") {
+		bl, _ := crawl.ParseBlackout(b)
+		s.Blackouts = append(s.Blackouts, bl)
+	}
+
+	return s
+}
+
+// withCrawlWindow returns a context that's cancelled when the current crawl window
+// ends (or the next blackout starts) so that a crawl doesn't run over it. The bool
+// is false if crawls can't run now.
+func withCrawlWindow(ctx context.Context, s crawl.Schedule) (context.Context, context.CancelFunc, bool) {
+	ok, until := s.Allowed(time.Now())
+	if !ok {
+		return ctx, func() {}, false
+	}
+	if until.IsZero() {
+		return ctx, func() {}, true
+	}
+
+	lo.Printf("crawl window ends at %s", until.Format(time.RFC3339))
+	ctx, cancel := context.WithDeadline(ctx, until)
+	return ctx, cancel, true
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/labstack/echo/v4"
+)
+
+// wizardInput is the structured form input of the manifest wizard. url is
+// where the maintainer intends to publish the manifest. All sections are
+// optional so that the wizard can validate the form as it is filled in.
+type wizardInput struct {
+	URL      string           `json:"url"`
+	Entity   v1.Entity        `json:"entity"`
+	Projects []v1.Project     `json:"projects"`
+	Channels []v1.Channel     `json:"channels"`
+	Plans    []v1.Plan        `json:"plans"`
+	History  []v1.HistoryItem `json:"history"`
+}
+
+// manifest fills in the defaults that can be inferred (GUIDs from names, plan status,
+// plan channels) and returns a manifest.
+func (w wizardInput) manifest() v1.Manifest {
+	m := v1.Manifest{
+		Version:  v1.CurrentVersion,
+		Entity:   w.Entity,
+		Projects: append(v1.Projects{}, w.Projects...),
+		Funding: v1.Funding{
+			Channels: append(v1.Channels{}, w.Channels...),
+			Plans:    append(v1.Plans{}, w.Plans...),
+			History:  append(v1.History{}, w.History...),
+		},
+	}
+
+	// Projects.
+	taken := make(map[string]bool)
+	for _, o := range m.Projects {
+		taken[o.GUID] = true
+	}
+	for n, o := range m.Projects {
+		if o.GUID == "" {
+			m.Projects[n].GUID = core.MakeID(o.Name, fmt.Sprintf("project-%d", n+1), taken)
+		}
+	}
+
+	// Channels. Plans without channels accept all of them.
+	taken = make(map[string]bool)
+	for _, o := range m.Funding.Channels {
+		taken[o.GUID] = true
+	}
+	chIDs := make([]string, 0, len(m.Funding.Channels))
+	for n, o := range m.Funding.Channels {
+		if o.GUID == "" {
+			m.Funding.Channels[n].GUID = core.MakeID(o.Type, fmt.Sprintf("channel-%d", n+1), taken)
+		}
+		chIDs = append(chIDs, m.Funding.Channels[n].GUID)
+	}
+
+	// Plans.
+	taken = make(map[string]bool)
+	for _, o := range m.Funding.Plans {
+		taken[o.GUID] = true
+	}
+	for n, o := range m.Funding.Plans {
+		p := &m.Funding.Plans[n]
+		if o.GUID == "" {
+			p.GUID = core.MakeID(o.Name, fmt.Sprintf("plan-%d", n+1), taken)
+		}
+		if o.Status == "" {
+			p.Status = "active"
+		}
+		if len(o.Channels) == 0 {
+			p.Channels = chIDs
+		}
+		p.Currency = strings.ToUpper(o.Currency)
+	}
+
+	for n, o := range m.Funding.History {
+		m.Funding.History[n].Currency = strings.ToUpper(o.Currency)
+	}
+
+	return m
+}
+
+// handleManifestWizard generates a funding.json from structured form input and
+// validates it. Every section is validated and reported so that a web wizard can
+// show the maintainer what's left to fix at each step. The generated manifest is
+// returned even if it's not yet valid.
+func handleManifestWizard(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	var in wizardInput
+	if err := c.Bind(&in); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid input: %v", err))
+	}
+
+	u, err := common.IsURL("url", strings.TrimSpace(in.URL), v1.MaxURLLen)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	b, err := json.MarshalIndent(in.manifest(), "", "  ")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error generating manifest: %v", err))
+	}
+
+	// Validate the manifest. The provenance of URLs is checked once it's published.
+	out := &conformance{noProvenance: true}
+	if !strings.HasSuffix(u.Path, app.consts.ManifestURI) {
+		out.fail("path", "url", fmt.Sprintf("URL must end in %s", app.consts.ManifestURI))
+	} else {
+		out.add("path", "url", nil)
+	}
+	app.schema.Conformance(c.Request().Context(), out, b, u.String())
+
+	return c.JSON(http.StatusOK, okResp{models.ManifestDraft{
+		Manifest: types.JSONText(b),
+		Report:   out.report(u.String()),
+	}})
+}
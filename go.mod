@@ -5,6 +5,7 @@ go 1.22.5
 require (
 	github.com/Masterminds/sprig v2.22.0+incompatible
 	github.com/altcha-org/altcha-lib-go v0.1.3
+	github.com/andybalholm/brotli v1.2.0
 	github.com/floss-fund/go-funding-json v0.4.1
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/knadh/goyesql/v2 v2.2.0
@@ -21,6 +22,9 @@ require (
 	github.com/stretchr/testify v1.9.0
 	github.com/zerodha/easyjson v1.0.1
 	golang.org/x/mod v0.20.0
+	google.golang.org/grpc v1.65.0
+	google.golang.org/protobuf v1.34.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -43,13 +47,13 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
-	golang.org/x/crypto v0.14.0 // indirect
-	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/sys v0.13.0 // indirect
-	golang.org/x/text v0.13.0 // indirect
+	golang.org/x/crypto v0.23.0 // indirect
+	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240528184218-531527333157 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 replace github.com/imdario/mergo => github.com/imdario/mergo v0.3.8
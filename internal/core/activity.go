@@ -0,0 +1,157 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/lib/pq"
+)
+
+// Freshness of a repository going by its last commit.
+const (
+	FreshnessActive    = "active"
+	FreshnessStale     = "stale"
+	FreshnessAbandoned = "abandoned"
+	FreshnessUnknown   = "unknown"
+)
+
+// Repositories with no commits within these durations are stale and abandoned respectively.
+const (
+	staleAfter     = time.Hour * 24 * 180
+	abandonedAfter = time.Hour * 24 * 730
+)
+
+// Forge API endpoints used for activity metrics.
+const (
+	GitHubAPIURL = "https://api.github.com"
+	GitLabAPIURL = "https://gitlab.com/api/v4"
+)
+
+var reLinkLastPage = regexp.MustCompile(`[?&]page=(\d+)[^>]*>;\s*rel="last"`)
+
+// ActivityAPI returns the forge API URLs for fetching the metadata (last activity,
+// open issues) and the contributors (paginated with one item per page so that the
+// number of pages is the count) of a repository, and the forge's name.
+func ActivityAPI(repo string) (forge, meta, contributors string, ok bool) {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return "", "", "", false
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false
+	}
+	path := parts[0] + "/" + strings.TrimSuffix(parts[1], ".git")
+
+	switch strings.ToLower(u.Hostname()) {
+	case "github.com":
+		base := GitHubAPIURL + "/repos/" + path
+		return "github", base, base + "/contributors?per_page=1&anon=1", true
+	case "gitlab.com":
+		base := GitLabAPIURL + "/projects/" + url.PathEscape(path)
+		return "gitlab", base, base + "/repository/contributors?per_page=1", true
+	}
+
+	return "", "", "", false
+}
+
+// ParseRepoActivity parses the repository metadata response of a forge API.
+func ParseRepoActivity(forge string, b []byte) (lastCommit *time.Time, openIssues int, err error) {
+	var raw struct {
+		// GitHub.
+		PushedAt        *time.Time `json:"pushed_at"`
+		OpenIssuesCount int        `json:"open_issues_count"`
+
+		// GitLab.
+		LastActivityAt *time.Time `json:"last_activity_at"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, 0, fmt.Errorf("error parsing %s response: %v", forge, err)
+	}
+
+	if forge == "gitlab" {
+		return raw.LastActivityAt, raw.OpenIssuesCount, nil
+	}
+	return raw.PushedAt, raw.OpenIssuesCount, nil
+}
+
+// CountFromPagination returns the total number of items of a paginated response
+// requested with one item per page, going by the GitLab X-Total header or the
+// last page in the GitHub Link header. numItems is the number of items on the page.
+func CountFromPagination(total, link string, numItems int) int {
+	if n, err := strconv.Atoi(total); err == nil {
+		return n
+	}
+	if m := reLinkLastPage.FindStringSubmatch(link); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+
+	return numItems
+}
+
+// Freshness returns the freshness of a repository going by its last commit.
+func Freshness(lastCommit *time.Time, now time.Time) string {
+	if lastCommit == nil {
+		return FreshnessUnknown
+	}
+
+	switch age := now.Sub(*lastCommit); {
+	case age >= abandonedAfter:
+		return FreshnessAbandoned
+	case age >= staleAfter:
+		return FreshnessStale
+	}
+
+	return FreshnessActive
+}
+
+// GetReposForActivity returns the repository URLs whose activity metrics are due for refreshing.
+func (d *Core) GetReposForActivity(age string, limit int) ([]string, error) {
+	var out []string
+	if err := d.q.GetActivityRepos.Select(&out, age, limit); err != nil {
+		d.log.Printf("error fetching repos for activity: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// UpsertRepoActivity inserts or updates the activity metrics of a repository. If
+// lastErr is set, the last known metrics of the repository are retained.
+func (d *Core) UpsertRepoActivity(a models.RepoActivity, lastErr string) error {
+	if _, err := d.q.UpsertRepoActivity.Exec(a.RepositoryURL, a.LastCommitAt, a.Contributors, a.OpenIssues, lastErr); err != nil {
+		d.log.Printf("error upserting repo activity: %s: %v", a.RepositoryURL, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetRepoActivity returns the activity metrics of the given repository URLs
+// with their freshness.
+func (d *Core) GetRepoActivity(repos []string) ([]models.RepoActivity, error) {
+	out := []models.RepoActivity{}
+	if len(repos) == 0 {
+		return out, nil
+	}
+
+	if err := d.q.GetRepoActivity.Select(&out, pq.Array(repos)); err != nil {
+		d.log.Printf("error fetching repo activity: %v", err)
+		return nil, err
+	}
+
+	now := time.Now()
+	for n := range out {
+		out[n].Freshness = Freshness(out[n].LastCommitAt, now)
+	}
+
+	return out, nil
+}
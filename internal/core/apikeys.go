@@ -0,0 +1,105 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Prefix of API keys to make them identifiable (eg: by secret scanners).
+const apiKeyPrefix = "pk_"
+
+// MakeAPIKey returns a new random API key and its hash that's stored in the DB.
+func MakeAPIKey() (string, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	key := apiKeyPrefix + hex.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the SHA-256 (hex) of an API key.
+func HashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateAPIKey creates a new API key and returns it along with the plain text key
+// which is not stored and can't be retrieved later.
+func (d *Core) CreateAPIKey(name string, dailyQuota int) (models.APIKey, string, error) {
+	key, hash, err := MakeAPIKey()
+	if err != nil {
+		d.log.Printf("error generating API key: %v", err)
+		return models.APIKey{}, "", err
+	}
+
+	var out models.APIKey
+	if err := d.q.InsertAPIKey.Get(&out, name, hash, dailyQuota); err != nil {
+		d.log.Printf("error creating API key: %v", err)
+		return models.APIKey{}, "", err
+	}
+
+	return out, key, nil
+}
+
+// GetAPIKeys returns all the API keys with their usage today.
+func (d *Core) GetAPIKeys() ([]models.APIKey, error) {
+	out := []models.APIKey{}
+	if err := d.q.GetAPIKeys.Select(&out); err != nil {
+		d.log.Printf("error fetching API keys: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetAPIKey returns an enabled API key by its plain text key along with its usage today.
+func (d *Core) GetAPIKey(key string) (models.APIKey, error) {
+	var out models.APIKey
+	if err := d.q.GetAPIKey.Get(&out, HashAPIKey(key)); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching API key: %v", err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// DeleteAPIKey deletes an API key and its usage stats.
+func (d *Core) DeleteAPIKey(id int) error {
+	if _, err := d.q.DeleteAPIKey.Exec(id); err != nil {
+		d.log.Printf("error deleting API key: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// RecordAPIKeyUsage increments the day's request (and error) count of an API key on an endpoint.
+func (d *Core) RecordAPIKeyUsage(id int, endpoint string, isErr bool) error {
+	if _, err := d.q.RecordAPIKeyUsage.Exec(id, endpoint, isErr); err != nil {
+		d.log.Printf("error recording API key usage: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAPIKeyUsage returns the daily usage of an API key per endpoint for the last n days.
+func (d *Core) GetAPIKeyUsage(id, days int) ([]models.APIKeyUsage, error) {
+	out := []models.APIKeyUsage{}
+	if err := d.q.GetAPIKeyUsage.Select(&out, id, days); err != nil {
+		d.log.Printf("error fetching API key usage: %d: %v", id, err)
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Categories is the fixed taxonomy of top-level categories. Tags are mapped
+// to categories by admins.
+var Categories = []models.Category{
+	{ID: "dev-tools", Name: "Developer tools"},
+	{ID: "languages", Name: "Programming languages"},
+	{ID: "libraries", Name: "Libraries and frameworks"},
+	{ID: "infrastructure", Name: "Infrastructure and cloud"},
+	{ID: "databases", Name: "Databases"},
+	{ID: "security", Name: "Security and privacy"},
+	{ID: "networking", Name: "Networking"},
+	{ID: "os", Name: "Operating systems"},
+	{ID: "desktop", Name: "Desktop applications"},
+	{ID: "mobile", Name: "Mobile"},
+	{ID: "web", Name: "Web"},
+	{ID: "science", Name: "Science and research"},
+	{ID: "data", Name: "Data and AI"},
+	{ID: "media", Name: "Media and graphics"},
+	{ID: "games", Name: "Games"},
+	{ID: "education", Name: "Education"},
+	{ID: "communication", Name: "Communication"},
+	{ID: "finance", Name: "Finance"},
+	{ID: "hardware", Name: "Hardware and embedded"},
+	{ID: "other", Name: "Other"},
+}
+
+var ErrInvalidCategory = errors.New("unknown category")
+
+// GetCategory returns a category from the taxonomy.
+func GetCategory(id string) (models.Category, bool) {
+	i := slices.IndexFunc(Categories, func(c models.Category) bool {
+		return c.ID == id
+	})
+	if i < 0 {
+		return models.Category{}, false
+	}
+
+	return Categories[i], true
+}
+
+// GetCategories returns the taxonomy with the tags mapped to each category.
+func (d *Core) GetCategories() ([]models.Category, error) {
+	tags, err := d.GetTagCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	out := slices.Clone(Categories)
+	for n, c := range out {
+		out[n].Tags = []string{}
+		for _, t := range tags {
+			if t.Category == c.ID {
+				out[n].Tags = append(out[n].Tags, t.Tag)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// GetTagCategories returns all tag to category mappings.
+func (d *Core) GetTagCategories() ([]models.TagCategory, error) {
+	out := []models.TagCategory{}
+	if err := d.q.GetTagCategories.Select(&out); err != nil {
+		d.log.Printf("error fetching tag categories: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetTagCategoryMap returns the tag to category mappings as a map.
+func (d *Core) GetTagCategoryMap() (map[string]string, error) {
+	tags, err := d.GetTagCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(tags))
+	for _, t := range tags {
+		out[t.Tag] = t.Category
+	}
+
+	return out, nil
+}
+
+// SetTagCategory maps a tag to a category in the taxonomy.
+func (d *Core) SetTagCategory(tag, category string) error {
+	if _, ok := GetCategory(category); !ok {
+		return ErrInvalidCategory
+	}
+
+	if _, err := d.q.UpsertTagCategory.Exec(tag, category); err != nil {
+		d.log.Printf("error setting tag category: %s: %v", tag, err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteTagCategory removes a tag's category mapping.
+func (d *Core) DeleteTagCategory(tag string) error {
+	if _, err := d.q.DeleteTagCategory.Exec(tag); err != nil {
+		d.log.Printf("error deleting tag category: %s: %v", tag, err)
+		return err
+	}
+
+	return nil
+}
+
+// TagsToCategories returns the distinct categories of the given tags as per the mapping.
+func TagsToCategories(tags []string, mapping map[string]string) []string {
+	out := []string{}
+	for _, t := range tags {
+		if c, ok := mapping[t]; ok && !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+)
+
+// ComplianceRules are the legal/regulatory constraints of an instance that
+// manifests have to meet to be listed on it.
+type ComplianceRules struct {
+	// Funding channel types (eg: cash) that can't be listed.
+	ChannelTypes []string
+
+	// Domain suffixes (eg: a sanctioned region's TLD, .example) that the manifest,
+	// entity webpage and e-mail, and project URLs can't be on.
+	Domains []string
+
+	// Patterns that funding channel addresses can't match.
+	AddressPatterns []*regexp.Regexp
+}
+
+// ComplianceError is returned for manifests that don't meet an instance's compliance rules.
+type ComplianceError struct {
+	Reason string
+}
+
+func (e *ComplianceError) Error() string {
+	return "not listed on this instance because " + e.Reason
+}
+
+// CheckCompliance checks a manifest against compliance rules.
+func CheckCompliance(m v1.Manifest, r ComplianceRules) error {
+	for _, c := range m.Funding.Channels {
+		if slices.Contains(r.ChannelTypes, c.Type) {
+			return &ComplianceError{Reason: fmt.Sprintf("the funding channel type %s (%s) isn't allowed", c.Type, c.GUID)}
+		}
+
+		for _, re := range r.AddressPatterns {
+			if re.MatchString(c.Address) {
+				return &ComplianceError{Reason: fmt.Sprintf("the address of the funding channel %s isn't allowed", c.GUID)}
+			}
+		}
+	}
+
+	if len(r.Domains) == 0 {
+		return nil
+	}
+
+	type place struct{ what, host string }
+	hosts := []place{
+		{"the manifest URL", hostOf(m.URL.URL)},
+		{"the entity webpage", hostOf(m.Entity.WebpageURL.URL)},
+	}
+	if _, domain, ok := strings.Cut(m.Entity.Email, "@"); ok {
+		hosts = append(hosts, place{"the entity e-mail", strings.ToLower(domain)})
+	}
+	for _, p := range m.Projects {
+		hosts = append(hosts,
+			place{fmt.Sprintf("the project %s webpage", p.GUID), hostOf(p.WebpageURL.URL)},
+			place{fmt.Sprintf("the project %s repository", p.GUID), hostOf(p.RepositoryURL.URL)})
+	}
+
+	for _, h := range hosts {
+		for _, d := range r.Domains {
+			d = strings.ToLower(strings.TrimPrefix(d, "."))
+			if h.host != "" && (h.host == d || strings.HasSuffix(h.host, "."+d)) {
+				return &ComplianceError{Reason: fmt.Sprintf("%s is on a domain (%s) that isn't allowed", h.what, d)}
+			}
+		}
+	}
+
+	return nil
+}
+
+func hostOf(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(p.Hostname())
+}
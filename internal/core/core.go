@@ -1,26 +1,36 @@
 package core
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"path"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/floss-fund/go-funding-json/common"
 	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
 	"github.com/floss-fund/portal/internal/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
 )
 
 const maxURISize = 40
 const maxURLLen = 200
 
-var reGithub = regexp.MustCompile(`^(https://github\.com/([^/]+))/([^/]+)/(blob|raw)/([^/]+)`)
+var (
+	reGithub = regexp.MustCompile(`^(https://github\.com/([^/]+))/([^/]+)/(blob|raw)/([^/]+)`)
+	reNonID  = regexp.MustCompile(`[^a-z0-9]+`)
+)
 
 type Opt struct {
 }
@@ -31,6 +41,20 @@ const (
 	ManifestStatusExpiring = "expiring"
 	ManifestStatusDisabled = "disabled"
 	ManifestStatusBlocked  = "blocked"
+	ManifestStatusPreview  = "preview"
+
+	// Reasons for a manifest's move to a new URL.
+	AliasRedirect = "redirect"
+	AliasDeclared = "declared"
+
+	// Raw content URL of a manifest submitted as a blob URL (eg: GitHub).
+	AliasRaw = "raw"
+
+	// Channels manifests are submitted through.
+	IntakeWeb   = "web"
+	IntakeAPI   = "api"
+	IntakeEmail = "email"
+	IntakeRelay = "relay"
 )
 
 // Queries contains prepared DB queries.
@@ -39,15 +63,131 @@ type Queries struct {
 	GetManifests         *sqlx.Stmt `query:"get-manifests"`
 	GetManifestStatus    *sqlx.Stmt `query:"get-manifest-status"`
 	GetForCrawling       *sqlx.Stmt `query:"get-for-crawling"`
+	EnqueueCrawlJobs     *sqlx.Stmt `query:"enqueue-crawl-jobs"`
+	LeaseCrawlJobs       *sqlx.Stmt `query:"lease-crawl-jobs"`
+	AckCrawlJob          *sqlx.Stmt `query:"ack-crawl-job"`
+	RetryCrawlJob        *sqlx.Stmt `query:"retry-crawl-job"`
+	ReleaseCrawlJob      *sqlx.Stmt `query:"release-crawl-job"`
+	CountCrawlJobs       *sqlx.Stmt `query:"count-crawl-jobs"`
+	UpdateManifestETag   *sqlx.Stmt `query:"update-manifest-etag"`
+	UpdateBodyHash       *sqlx.Stmt `query:"update-manifest-body-hash"`
+	UpdateGoneStreak     *sqlx.Stmt `query:"update-manifest-gone-streak"`
+	UpdatePreview        *sqlx.Stmt `query:"update-manifest-preview"`
+	UpdateFetchLimits    *sqlx.Stmt `query:"update-manifest-fetch-limits"`
+	GetFetchLimits       *sqlx.Stmt `query:"get-manifest-fetch-limits"`
+	UpdateManifestPin    *sqlx.Stmt `query:"update-manifest-pin"`
+	UpdateManifestReqID  *sqlx.Stmt `query:"update-manifest-request-id"`
+	GetForSweep          *sqlx.Stmt `query:"get-for-sweep"`
+	GetDueCrawls         *sqlx.Stmt `query:"get-due-crawls"`
+	UpsertCrawlSchedule  *sqlx.Stmt `query:"upsert-crawl-schedule"`
+	UpdateLiveness       *sqlx.Stmt `query:"update-manifest-liveness"`
+	GetLiveness          *sqlx.Stmt `query:"get-manifest-liveness"`
+	UpdateHostDowntime   *sqlx.Stmt `query:"update-host-downtime"`
+	GetHostDowntime      *sqlx.Stmt `query:"get-host-downtime"`
+	AddTenantManifest    *sqlx.Stmt `query:"add-tenant-manifest"`
+	DeleteTenantManifest *sqlx.Stmt `query:"delete-tenant-manifest"`
+	GetTenantManifests   *sqlx.Stmt `query:"get-tenant-manifests"`
+	GetManifestTenants   *sqlx.Stmt `query:"get-manifest-tenants"`
+	GetVelocity          *sqlx.Stmt `query:"get-submission-velocity"`
+	UpdateStatusMessage  *sqlx.Stmt `query:"update-manifest-status-message"`
+	UpdateVerification   *sqlx.Stmt `query:"update-manifest-verification"`
 	UpdateManifestStatus *sqlx.Stmt `query:"update-manifest-status"`
 	UpdateCrawlError     *sqlx.Stmt `query:"update-crawl-error"`
 	DeleteManifest       *sqlx.Stmt `query:"delete-manifest"`
 	GetTopTags           *sqlx.Stmt `query:"get-top-tags"`
 	InsertReport         *sqlx.Stmt `query:"insert-report"`
+	UpsertFavicon        *sqlx.Stmt `query:"upsert-favicon"`
+	GetFavicon           *sqlx.Stmt `query:"get-favicon"`
+	UpsertMirror         *sqlx.Stmt `query:"upsert-manifest-mirror"`
+	GetMirror            *sqlx.Stmt `query:"get-manifest-mirror"`
+	InsertCrawlRun       *sqlx.Stmt `query:"insert-crawl-run"`
+	GetCrawlRuns         *sqlx.Stmt `query:"get-crawl-runs"`
+	ArmManifestTrace     *sqlx.Stmt `query:"arm-manifest-trace"`
+	SaveManifestTrace    *sqlx.Stmt `query:"save-manifest-trace"`
+	GetManifestTrace     *sqlx.Stmt `query:"get-manifest-trace"`
+	InsertCrawlError     *sqlx.Stmt `query:"insert-crawl-error"`
+	GetCrawlErrorDomains *sqlx.Stmt `query:"get-crawl-error-domains"`
+	GetCrawlErrorTrends  *sqlx.Stmt `query:"get-crawl-error-trends"`
+	DeleteCrawlErrors    *sqlx.Stmt `query:"delete-crawl-errors"`
+	MoveManifest         *sqlx.Stmt `query:"move-manifest"`
+	InsertAlias          *sqlx.Stmt `query:"insert-manifest-alias"`
+	GetManifestAliases   *sqlx.Stmt `query:"get-manifest-aliases"`
+	GetModTargets        *sqlx.Stmt `query:"get-moderation-targets"`
+	RecrawlManifests     *sqlx.Stmt `query:"recrawl-manifests"`
+	LinkManifest         *sqlx.Stmt `query:"link-manifest"`
+	UnlinkManifest       *sqlx.Stmt `query:"unlink-manifest"`
+	GetLinkedManifests   *sqlx.Stmt `query:"get-linked-manifests"`
+	GetManifestParent    *sqlx.Stmt `query:"get-manifest-parent"`
+	GetChanges           *sqlx.Stmt `query:"get-changes"`
+	GetLastChangeID      *sqlx.Stmt `query:"get-last-change-id"`
+	GetFundingCandidates *sqlx.Stmt `query:"get-funding-candidates"`
+	InsertFundingSnap    *sqlx.Stmt `query:"insert-funding-snapshot"`
+	GetFundingSnaps      *sqlx.Stmt `query:"get-funding-snapshots"`
+	InsertVersion        *sqlx.Stmt `query:"insert-manifest-version"`
+	GetVersion           *sqlx.Stmt `query:"get-manifest-version"`
+	GetFundingGaps       *sqlx.Stmt `query:"get-funding-gaps"`
+	GetTagCategories     *sqlx.Stmt `query:"get-tag-categories"`
+	UpsertTagCategory    *sqlx.Stmt `query:"upsert-tag-category"`
+	DeleteTagCategory    *sqlx.Stmt `query:"delete-tag-category"`
+	GetProjectsRelated   *sqlx.Stmt `query:"get-projects-for-related"`
+	ReplaceRelated       *sqlx.Stmt `query:"replace-related-projects"`
+	GetRelatedProjects   *sqlx.Stmt `query:"get-related-projects"`
+	GetDenylist          *sqlx.Stmt `query:"get-denylist"`
+	GetDenylisted        *sqlx.Stmt `query:"get-denylisted"`
+	ImportDenylist       *sqlx.Stmt `query:"import-denylist"`
+	DeleteDenylist       *sqlx.Stmt `query:"delete-denylist"`
+	UpsertFiscalHost     *sqlx.Stmt `query:"upsert-fiscal-host"`
+	GetFiscalHosts       *sqlx.Stmt `query:"get-fiscal-hosts"`
+	DeleteFiscalHost     *sqlx.Stmt `query:"delete-fiscal-host"`
+	ReplaceFiscalMembers *sqlx.Stmt `query:"replace-fiscal-host-members"`
+	UpdateFiscalHostErr  *sqlx.Stmt `query:"update-fiscal-host-error"`
+	ExportProjects       *sqlx.Stmt `query:"export-projects"`
+	ExportPlans          *sqlx.Stmt `query:"export-plans"`
+	ExportChannels       *sqlx.Stmt `query:"export-channels"`
+	ExportCrawlRuns      *sqlx.Stmt `query:"export-crawl-runs"`
+	ExportChanges        *sqlx.Stmt `query:"export-changes"`
+	GetScorecardRepos    *sqlx.Stmt `query:"get-repos-for-scorecard"`
+	UpsertScorecard      *sqlx.Stmt `query:"upsert-scorecard"`
+	GetScorecards        *sqlx.Stmt `query:"get-scorecards"`
+	UpdateManifestRelay  *sqlx.Stmt `query:"update-manifest-relay"`
+	GetManifestRelay     *sqlx.Stmt `query:"get-manifest-relay"`
+	UpdateManifestIntake *sqlx.Stmt `query:"update-manifest-intake"`
+	GetManifestIntake    *sqlx.Stmt `query:"get-manifest-intake"`
+	GetIntakeStats       *sqlx.Stmt `query:"get-intake-stats"`
+	InsertAPIKey         *sqlx.Stmt `query:"insert-api-key"`
+	GetAPIKeys           *sqlx.Stmt `query:"get-api-keys"`
+	GetAPIKey            *sqlx.Stmt `query:"get-api-key"`
+	DeleteAPIKey         *sqlx.Stmt `query:"delete-api-key"`
+	RecordAPIKeyUsage    *sqlx.Stmt `query:"record-api-key-usage"`
+	GetAPIKeyUsage       *sqlx.Stmt `query:"get-api-key-usage"`
+	GetActivityRepos     *sqlx.Stmt `query:"get-repos-for-activity"`
+	UpsertRepoActivity   *sqlx.Stmt `query:"upsert-repo-activity"`
+	GetRepoActivity      *sqlx.Stmt `query:"get-repo-activity"`
+	GetForTranslation    *sqlx.Stmt `query:"get-for-translation"`
+	UpsertTranslation    *sqlx.Stmt `query:"upsert-translation"`
+	GetTranslations      *sqlx.Stmt `query:"get-translations"`
+	GetInstanceID        *sqlx.Stmt `query:"get-instance-id"`
+	GetInstanceStats     *sqlx.Stmt `query:"get-instance-stats"`
+	UpsertTelemetry      *sqlx.Stmt `query:"upsert-telemetry-report"`
+	GetTelemetryVersions *sqlx.Stmt `query:"get-telemetry-versions"`
+	InsertWebhook        *sqlx.Stmt `query:"insert-webhook"`
+	CountWebhooks        *sqlx.Stmt `query:"count-webhooks"`
+	GetWebhook           *sqlx.Stmt `query:"get-webhook"`
+	ConfirmWebhook       *sqlx.Stmt `query:"confirm-webhook"`
+	DeleteWebhook        *sqlx.Stmt `query:"delete-webhook"`
+	GetEventWebhooks     *sqlx.Stmt `query:"get-webhooks-for-event"`
+	UpdateWebhookDeliv   *sqlx.Stmt `query:"update-webhook-delivery"`
+	GetProvenanceErrors  *sqlx.Stmt `query:"get-provenance-errors"`
+	GetLastCrawlErrorID  *sqlx.Stmt `query:"get-last-crawl-error-id"`
+	GetCursor            *sqlx.Stmt `query:"get-cursor"`
+	ClaimCursor          *sqlx.Stmt `query:"claim-cursor"`
+	GetFundingSummaries  *sqlx.Stmt `query:"get-funding-summaries"`
+	ClaimPaymentChanges  *sqlx.Stmt `query:"claim-payment-changes"`
 }
 
 type Core struct {
 	q   *Queries
+	db  *sqlx.DB
 	opt Opt
 	hc  *http.Client
 	log *log.Logger
@@ -55,18 +195,20 @@ type Core struct {
 
 var (
 	ErrNotFound = errors.New("not found")
+	ErrLinked   = errors.New("manifest is already a parent or a child of another manifest")
 )
 
-func New(q *Queries, o Opt, lo *log.Logger) *Core {
+func New(q *Queries, db *sqlx.DB, o Opt, lo *log.Logger) *Core {
 	return &Core{
 		q:   q,
+		db:  db,
 		log: lo,
 	}
 }
 
 // GetManifest retrieves a particular manifest.
 func (d *Core) GetManifest(id int, guid string) (models.ManifestData, error) {
-	out, err := d.getManifests(id, guid, 0, 1)
+	out, err := d.getManifests(id, guid, 0, 1, "")
 	if err != nil || len(out) == 0 {
 		return models.ManifestData{}, ErrNotFound
 	}
@@ -76,7 +218,7 @@ func (d *Core) GetManifest(id int, guid string) (models.ManifestData, error) {
 
 // GetManifests retrieves N manifests.
 func (d *Core) GetManifests(lastID, limit int) ([]models.ManifestData, error) {
-	out, err := d.getManifests(0, "", lastID, limit)
+	out, err := d.getManifests(0, "", lastID, limit, "")
 	if err != nil {
 		return nil, err
 	}
@@ -85,35 +227,262 @@ func (d *Core) GetManifests(lastID, limit int) ([]models.ManifestData, error) {
 }
 
 // GetManifestStatus checks whether a given manifest URL exists in the databse.
-// If one exists, its status is returned.
-func (d *Core) GetManifestStatus(url string) (string, error) {
-	var status string
-	if err := d.q.GetManifestStatus.Get(&status, url); err != nil {
+// If one exists, its status and crawl status are returned. Otherwise, Status is empty.
+func (d *Core) GetManifestStatus(url string) (models.ManifestStatus, error) {
+	var out models.ManifestStatus
+	if err := d.q.GetManifestStatus.Get(&out, url); err != nil {
 		if err == sql.ErrNoRows {
-			return "", nil
+			return out, nil
 		}
 
 		d.log.Printf("error checking manifest status: %s: %v", url, err)
-		return "", err
+		return out, err
 	}
 
-	return status, nil
+	return out, nil
+}
+
+// GetSubmissionVelocity returns the number of listings created in the last day on the
+// given domain (a host or a host/path prefix) and that share any of the given payment
+// addresses. The listing with the given URL itself is excluded.
+func (d *Core) GetSubmissionVelocity(domain string, addresses []string, url string) (models.SubmissionVelocity, error) {
+	var (
+		out = models.SubmissionVelocity{Domain: domain}
+		pat = "%://" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(domain) + "/%"
+	)
+	if err := d.q.GetVelocity.Get(&out, pat, pq.Array(addresses), url); err != nil {
+		d.log.Printf("error fetching submission velocity: %s: %v", domain, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// UpdateManifestStatusMessage sets the status message (eg: a moderation note) of a manifest.
+func (d *Core) UpdateManifestStatusMessage(url, msg string) error {
+	if _, err := d.q.UpdateStatusMessage.Exec(url, msg); err != nil {
+		d.log.Printf("error updating manifest status message: %s: %v", url, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetManifestIntake returns the submission channel of a manifest.
+func (d *Core) GetManifestIntake(id int) (models.ManifestIntake, error) {
+	var out models.ManifestIntake
+	if err := d.q.GetManifestIntake.Get(&out, id); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching manifest intake: %d: %v", id, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// GetIntakeStats returns the number of manifests submitted within the interval
+// per submission channel, reference, and status.
+func (d *Core) GetIntakeStats(interval string) ([]models.IntakeStat, error) {
+	out := []models.IntakeStat{}
+	if err := d.q.GetIntakeStats.Select(&out, interval); err != nil {
+		d.log.Printf("error fetching intake stats: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetManifestRelay returns the relay settings of a manifest.
+func (d *Core) GetManifestRelay(id int) (models.ManifestRelay, error) {
+	var out models.ManifestRelay
+	if err := d.q.GetManifestRelay.Get(&out, id); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching manifest relay: %d: %v", id, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// ManifestHash returns the hash of a manifest's (normalized) contents as stored
+// with it by UpsertManifest, for detecting changes.
+func ManifestHash(m v1.Manifest) string {
+	b, _ := m.MarshalJSON()
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
 }
 
 // UpsertManifest upserts an entry into the database.
 func (d *Core) UpsertManifest(m models.ManifestData, status string) error {
+	return d.upsertManifest(nil, m, status)
+}
+
+// SubmitOpt are the optional attributes of a submitted manifest that are saved
+// along with it.
+type SubmitOpt struct {
+	// Relay opt-out and the instance the submission was relayed from.
+	NoRelay     bool
+	RelaySource string
+
+	// Submission channel (Intake*) and the reference within it.
+	Intake    string
+	IntakeRef string
+
+	RequestID     string
+	Pin           string
+	StatusMessage string
+
+	// Generate a token for the private preview URL.
+	Preview bool
+}
+
+// SubmitManifest upserts a submitted manifest along with its optional attributes in
+// a single transaction so that a failed submission can be retried as a whole without
+// leaving the manifest saved without them (eg: a relay opt-out). For previews, it
+// returns the token of the preview URL.
+func (d *Core) SubmitManifest(m models.ManifestData, status string, o SubmitOpt) (string, error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		d.log.Printf("error beginning submission transaction: %v", err)
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if err := d.upsertManifest(tx, m, status); err != nil {
+		return "", err
+	}
+
+	url := m.Manifest.URL.URL
+	if o.NoRelay || o.RelaySource != "" {
+		if _, err := tx.Stmtx(d.q.UpdateManifestRelay).Exec(url, o.NoRelay, o.RelaySource); err != nil {
+			d.log.Printf("error updating manifest relay: %s: %v", url, err)
+			return "", err
+		}
+	}
+	if o.Intake != "" {
+		if _, err := tx.Stmtx(d.q.UpdateManifestIntake).Exec(url, o.Intake, o.IntakeRef); err != nil {
+			d.log.Printf("error updating manifest intake: %s: %v", url, err)
+			return "", err
+		}
+	}
+	if o.RequestID != "" {
+		if _, err := tx.Stmtx(d.q.UpdateManifestReqID).Exec(url, o.RequestID); err != nil {
+			d.log.Printf("error updating manifest request ID: %s: %v", url, err)
+			return "", err
+		}
+	}
+	if o.Pin != "" {
+		if _, err := tx.Stmtx(d.q.UpdateManifestPin).Exec(0, url, o.Pin); err != nil {
+			d.log.Printf("error updating manifest pin: %s: %v", url, err)
+			return "", err
+		}
+	}
+	if o.StatusMessage != "" {
+		if _, err := tx.Stmtx(d.q.UpdateStatusMessage).Exec(url, o.StatusMessage); err != nil {
+			d.log.Printf("error updating manifest status message: %s: %v", url, err)
+			return "", err
+		}
+	}
+
+	token := ""
+	if o.Preview {
+		if token, err = newPreviewToken(); err != nil {
+			d.log.Printf("error generating preview token: %v", err)
+			return "", err
+		}
+		if _, err := tx.Stmtx(d.q.UpdatePreview).Exec(url, HashAPIKey(token)); err != nil {
+			d.log.Printf("error saving preview token: %s: %v", url, err)
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		d.log.Printf("error committing submission: %s: %v", url, err)
+		return "", err
+	}
+
+	return token, nil
+}
+
+// upsertManifest upserts a manifest, in the transaction if it's given.
+func (d *Core) upsertManifest(tx *sqlx.Tx, m models.ManifestData, status string) error {
+	stmt := func(s *sqlx.Stmt) *sqlx.Stmt {
+		if tx == nil {
+			return s
+		}
+		return tx.Stmtx(s)
+	}
+
 	body, err := m.Manifest.MarshalJSON()
 	if err != nil {
 		d.log.Printf("error marshalling manifest: %s: %v", m.URL, err)
 		return err
 	}
 
-	if _, err := d.q.UpsertManifest.Exec(json.RawMessage(body), m.Manifest.URL.URL, m.GUID, json.RawMessage("{}"), status, ""); err != nil {
+	var (
+		id   int
+		hash = sha256.Sum256(body)
+	)
+	meta := json.RawMessage("{}")
+	if len(m.Meta) > 0 {
+		meta = json.RawMessage(m.Meta)
+	}
+
+	verif := m.Verification
+	if verif == "" {
+		verif = VerificationUnverified
+	}
+
+	if err := stmt(d.q.UpsertManifest).Get(&id, json.RawMessage(body), m.Manifest.URL.URL, m.GUID, meta, status, "", hex.EncodeToString(hash[:]), verif); err != nil {
 		d.log.Printf("error upsering manifest: %v", err)
 		return err
 	}
 
-	return nil
+	// Record the contents and the funding figures for tracking them over time.
+	// They're best-effort and their failures don't fail the upsert.
+	return bestEffort(tx, func() error {
+		if _, err := stmt(d.q.InsertVersion).Exec(id, hex.EncodeToString(hash[:]), json.RawMessage(body)); err != nil {
+			d.log.Printf("error inserting manifest version: %d: %v", id, err)
+			return err
+		}
+
+		for _, cur := range FundingCurrencies(m.Manifest.Funding) {
+			goal, received := FundingGoal(m.Manifest.Funding, cur)
+			if _, err := stmt(d.q.InsertFundingSnap).Exec(id, cur, goal, received); err != nil {
+				d.log.Printf("error inserting funding snapshot: %d: %v", id, err)
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// bestEffort runs statements whose failure is only logged (by fn) and ignored. In a
+// transaction, a failed statement aborts the whole transaction in Postgres, so they're
+// run under a savepoint that's rolled back on failure. Only savepoint errors are returned.
+func bestEffort(tx *sqlx.Tx, fn func() error) error {
+	if tx == nil {
+		_ = fn()
+		return nil
+	}
+
+	if _, err := tx.Exec("SAVEPOINT best_effort"); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		_, err = tx.Exec("ROLLBACK TO SAVEPOINT best_effort")
+		return err
+	}
+	_, err := tx.Exec("RELEASE SAVEPOINT best_effort")
+
+	return err
 }
 
 // GetManifestForCrawling retrieves manifest URLs that need to be crawled again. It returns records in batches of limit length,
@@ -139,6 +508,357 @@ func (d *Core) GetManifestForCrawling(age string, offsetID, limit int) ([]models
 	return out, nil
 }
 
+// EnqueueCrawlJobs adds manifests to the durable crawl queue (crawl.Queue).
+func (d *Core) EnqueueCrawlJobs(ids []int, p int) error {
+	if _, err := d.q.EnqueueCrawlJobs.Exec(pq.Array(ids), p); err != nil {
+		d.log.Printf("error enqueueing crawl jobs: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// LeaseCrawlJobs leases up to limit available jobs from the durable crawl queue.
+func (d *Core) LeaseCrawlJobs(limit int, lease time.Duration) ([]models.ManifestJob, error) {
+	var out []models.ManifestJob
+	if err := d.q.LeaseCrawlJobs.Select(&out, limit, int(lease.Seconds())); err != nil {
+		d.log.Printf("error leasing crawl jobs: %v", err)
+		return nil, err
+	}
+
+	for n, u := range out {
+		url, err := common.IsURL("url", u.URL, maxURLLen)
+		if err != nil {
+			d.log.Printf("error parsing url: %s: %v: ", u.URL, err)
+			continue
+		}
+
+		u.URLobj = url
+		out[n] = u
+	}
+
+	return out, nil
+}
+
+// AckCrawlJob removes a processed job from the durable crawl queue.
+func (d *Core) AckCrawlJob(id int) error {
+	if _, err := d.q.AckCrawlJob.Exec(id); err != nil {
+		d.log.Printf("error acking crawl job: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// RetryCrawlJob counts a failed attempt of a job in the durable crawl queue
+// and makes it available again after delay with (at most) the given priority.
+func (d *Core) RetryCrawlJob(id int, delay time.Duration, p int) error {
+	if _, err := d.q.RetryCrawlJob.Exec(id, int(delay.Seconds()), p); err != nil {
+		d.log.Printf("error retrying crawl job: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseCrawlJob releases the lease of an unprocessed job in the durable crawl queue.
+func (d *Core) ReleaseCrawlJob(id int) error {
+	if _, err := d.q.ReleaseCrawlJob.Exec(id); err != nil {
+		d.log.Printf("error releasing crawl job: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// CountCrawlJobs returns the number of jobs waiting in the durable crawl queue.
+func (d *Core) CountCrawlJobs() (int, error) {
+	var n int
+	if err := d.q.CountCrawlJobs.Get(&n); err != nil {
+		d.log.Printf("error counting crawl jobs: %v", err)
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// GetManifestsForSweep retrieves manifest URLs for liveness sweeps.
+func (d *Core) GetManifestsForSweep(offsetID, limit int) ([]models.ManifestJob, error) {
+	var out []models.ManifestJob
+	if err := d.q.GetForSweep.Select(&out, offsetID, limit); err != nil {
+		d.log.Printf("error fetching URLs for sweep: %v", err)
+		return nil, err
+	}
+
+	for n, u := range out {
+		url, err := common.IsURL("url", u.URL, maxURLLen)
+		if err != nil {
+			d.log.Printf("error parsing url: %s: %v: ", u.URL, err)
+			continue
+		}
+
+		out[n].URLobj = url
+	}
+
+	return out, nil
+}
+
+// GetDueCrawls returns up to limit manifests that are due for a scheduled crawl at now.
+func (d *Core) GetDueCrawls(now time.Time, limit int) ([]models.ScheduledCrawl, error) {
+	var out []models.ScheduledCrawl
+	if err := d.q.GetDueCrawls.Select(&out, now, limit); err != nil {
+		d.log.Printf("error fetching scheduled crawls: %v", err)
+		return nil, err
+	}
+
+	for n, u := range out {
+		url, err := common.IsURL("url", u.URL, maxURLLen)
+		if err != nil {
+			d.log.Printf("error parsing url: %s: %v: ", u.URL, err)
+			continue
+		}
+
+		out[n].URLobj = url
+	}
+
+	return out, nil
+}
+
+// UpsertCrawlSchedule saves the crawl interval and the time of the next crawl of a manifest.
+func (d *Core) UpsertCrawlSchedule(id int, interval time.Duration, nextAt time.Time) error {
+	if _, err := d.q.UpsertCrawlSchedule.Exec(id, int(interval.Seconds()), nextAt); err != nil {
+		d.log.Printf("error saving crawl schedule: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateManifestLiveness records the availability of a manifest URL.
+func (d *Core) UpdateManifestLiveness(id int, ok bool, statusCode int, message string) error {
+	if _, err := d.q.UpdateLiveness.Exec(id, ok, statusCode, message); err != nil {
+		d.log.Printf("error updating manifest liveness: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateHostDowntime records the hosts that are down as per the last liveness sweep
+// and removes the hosts that are up again.
+func (d *Core) UpdateHostDowntime() (models.HostDowntimeUpdate, error) {
+	var out models.HostDowntimeUpdate
+	if err := d.q.UpdateHostDowntime.Get(&out); err != nil {
+		d.log.Printf("error updating host downtime: %v", err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// GetHostDowntime returns the hosts that are down.
+func (d *Core) GetHostDowntime() ([]models.HostDowntime, error) {
+	out := []models.HostDowntime{}
+	if err := d.q.GetHostDowntime.Select(&out); err != nil {
+		d.log.Printf("error fetching host downtime: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// UpdateManifestETag records the ETag of a manifest's last crawled response.
+func (d *Core) UpdateManifestETag(id int, etag string) error {
+	if _, err := d.q.UpdateManifestETag.Exec(id, etag); err != nil {
+		d.log.Printf("error updating manifest etag: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateManifestBodyHash records the SHA-256 of a manifest's last crawled response body.
+func (d *Core) UpdateManifestBodyHash(id int, hash string) error {
+	if _, err := d.q.UpdateBodyHash.Exec(id, hash); err != nil {
+		d.log.Printf("error updating manifest body hash: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetManifestLiveness returns the availability of a manifest URL recorded by the last sweep.
+func (d *Core) GetManifestLiveness(id int) (models.ManifestLiveness, error) {
+	var out models.ManifestLiveness
+	if err := d.q.GetLiveness.Get(&out, id); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching manifest liveness: %d: %v", id, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// MoveManifest changes the URL of a manifest and records the old URL as an alias
+// so that the listing and everything linked to it is retained.
+func (d *Core) MoveManifest(id int, url, reason string) error {
+	if _, err := d.q.MoveManifest.Exec(id, url, reason); err != nil {
+		d.log.Printf("error moving manifest: %d: %s: %v", id, url, err)
+		return err
+	}
+
+	return nil
+}
+
+// InsertManifestAlias records an alternate URL of the manifest at manifestURL.
+// An existing alias (of any manifest) is left as-is.
+func (d *Core) InsertManifestAlias(manifestURL, url, reason string) error {
+	if _, err := d.q.InsertAlias.Exec(manifestURL, url, reason); err != nil {
+		d.log.Printf("error inserting manifest alias: %s: %s: %v", manifestURL, url, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetManifestAliases returns the previous URLs of a manifest.
+func (d *Core) GetManifestAliases(id int) ([]models.ManifestAlias, error) {
+	out := []models.ManifestAlias{}
+	if err := d.q.GetManifestAliases.Select(&out, id); err != nil {
+		d.log.Printf("error fetching manifest aliases: %d: %v", id, err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// LinkManifest links a manifest to a parent manifest of the same entity so that
+// they're presented together on the parent's pages.
+func (d *Core) LinkManifest(id, parentID int) error {
+	res, err := d.q.LinkManifest.Exec(id, parentID)
+	if err != nil {
+		d.log.Printf("error linking manifest: %d -> %d: %v", id, parentID, err)
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLinked
+	}
+
+	return nil
+}
+
+// UnlinkManifest removes a manifest's link to its parent.
+func (d *Core) UnlinkManifest(id int) error {
+	if _, err := d.q.UnlinkManifest.Exec(id); err != nil {
+		d.log.Printf("error unlinking manifest: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetLinkedManifests returns the active manifests linked to a parent manifest.
+func (d *Core) GetLinkedManifests(parentID int) ([]models.ManifestData, error) {
+	var ids []int
+	if err := d.q.GetLinkedManifests.Select(&ids, parentID); err != nil {
+		d.log.Printf("error fetching linked manifests: %d: %v", parentID, err)
+		return nil, err
+	}
+
+	out := make([]models.ManifestData, 0, len(ids))
+	for _, id := range ids {
+		m, err := d.GetManifest(id, "")
+		if err != nil {
+			continue
+		}
+		out = append(out, m)
+	}
+
+	return out, nil
+}
+
+// GetManifestParent returns the ID of the parent a manifest is linked to, or 0.
+func (d *Core) GetManifestParent(id int) (int, error) {
+	var parentID int
+	if err := d.q.GetManifestParent.Get(&parentID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		d.log.Printf("error fetching manifest parent: %d: %v", id, err)
+		return 0, err
+	}
+
+	return parentID, nil
+}
+
+// GetChanges returns the changes to listings after the given cursor (change ID),
+// only of the manifests listed on a tenant, if one is given.
+func (d *Core) GetChanges(since int64, limit int, tenant string) ([]models.ManifestChange, error) {
+	out := []models.ManifestChange{}
+	if err := d.q.GetChanges.Select(&out, since, limit, tenant); err != nil {
+		d.log.Printf("error fetching changes: %d: %v", since, err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetLastChangeID returns the cursor (ID) of the most recent change.
+func (d *Core) GetLastChangeID() (int64, error) {
+	var id int64
+	if err := d.q.GetLastChangeID.Get(&id); err != nil {
+		d.log.Printf("error fetching last change ID: %v", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetFundingSnapshots returns the funding goal and received figures of a manifest
+// over time, ordered by currency and time.
+func (d *Core) GetFundingSnapshots(manifestID int) ([]models.FundingSnapshot, error) {
+	out := []models.FundingSnapshot{}
+	if err := d.q.GetFundingSnaps.Select(&out, manifestID); err != nil {
+		d.log.Printf("error fetching funding snapshots: %d: %v", manifestID, err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetManifestVersion returns the version of a manifest that was listed just before the given time.
+func (d *Core) GetManifestVersion(manifestID int, at time.Time) (models.ManifestVersion, error) {
+	var out models.ManifestVersion
+	if err := d.q.GetVersion.Get(&out, manifestID, at); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching manifest version: %d: %v", manifestID, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// GetFundingGaps returns the yearly funding goals versus the verified income of active
+// manifests grouped by the categories or tags (groupBy) of their projects and currency,
+// ordered by the biggest gaps. An empty currency returns all currencies.
+func (d *Core) GetFundingGaps(groupBy, currency string, limit int) ([]models.FundingGap, error) {
+	out := []models.FundingGap{}
+	if err := d.q.GetFundingGaps.Select(&out, groupBy, currency, limit); err != nil {
+		d.log.Printf("error fetching funding gaps: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
 // UpdateManifestStatus updates a manifest's status.
 func (d *Core) UpdateManifestStatus(id int, status string) error {
 	if _, err := d.q.UpdateManifestStatus.Exec(id, status); err != nil {
@@ -149,6 +869,42 @@ func (d *Core) UpdateManifestStatus(id int, status string) error {
 	return nil
 }
 
+// GetModerationTargets returns up to limit manifests that match the filters of a bulk moderation action.
+func (d *Core) GetModerationTargets(f models.ModerationFilter, limit int) ([]models.ModerationTarget, error) {
+	out := []models.ModerationTarget{}
+	if err := d.q.GetModTargets.Select(&out, strings.ToLower(f.Domain), f.CreatedFrom, f.CreatedTo, f.RelaySource, f.ErrorClass, f.Status, f.Intake, limit); err != nil {
+		d.log.Printf("error fetching moderation targets: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// RecrawlManifests adds the given manifests to the durable crawl queue with a priority
+// (crawl.Priority) for a crawl that revalidates them.
+func (d *Core) RecrawlManifests(ids []int, p int) error {
+	if _, err := d.q.RecrawlManifests.Exec(pq.Array(ids), p); err != nil {
+		d.log.Printf("error scheduling manifest recrawls: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateManifestVerification sets the verification level of a manifest.
+func (d *Core) UpdateManifestVerification(id int, level string) error {
+	if !IsVerificationLevel(level) {
+		return ErrInvalidVerification
+	}
+
+	if _, err := d.q.UpdateVerification.Exec(id, level); err != nil {
+		d.log.Printf("error updating manifest verification: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
 // UpdateManifestCrawlError updates a manifest's crawl error count and sets
 // it to 'disabled' if it exceeds the given limit.
 func (d *Core) UpdateManifestCrawlError(id int, message string, maxErrors int) (string, error) {
@@ -161,6 +917,18 @@ func (d *Core) UpdateManifestCrawlError(id int, message string, maxErrors int) (
 	return status, nil
 }
 
+// UpdateManifestGoneStreak extends the streak of crawls on which a manifest URL was
+// gone (404, 410), or resets it, and returns the streak.
+func (d *Core) UpdateManifestGoneStreak(id int, gone bool) (int, error) {
+	var streak int
+	if err := d.q.UpdateGoneStreak.Get(&streak, id, gone); err != nil {
+		d.log.Printf("error updating manifest gone streak: %d: %v", id, err)
+		return 0, err
+	}
+
+	return streak, nil
+}
+
 // DeleteManifest deletes a manifest and all associated data;
 func (d *Core) DeleteManifest(id int, guid string) error {
 	if _, err := d.q.DeleteManifest.Exec(id, guid); err != nil {
@@ -199,15 +967,196 @@ func (d *Core) InsertManifestReport(id int, reason string) error {
 	return nil
 }
 
+// UpsertFavicon inserts or updates the favicon captured for a manifest's entity.
+func (d *Core) UpsertFavicon(manifestID int, f models.Favicon) error {
+	og := f.OpenGraph
+	if len(og) == 0 {
+		og = types.JSONText("{}")
+	}
+
+	if _, err := d.q.UpsertFavicon.Exec(manifestID, f.Body, f.ContentType, og); err != nil {
+		d.log.Printf("error upserting favicon: %d: %v", manifestID, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetFavicon retrieves the favicon captured for a manifest's entity.
+func (d *Core) GetFavicon(manifestID int) (models.Favicon, error) {
+	var out models.Favicon
+	if err := d.q.GetFavicon.Get(&out, manifestID); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching favicon: %d: %v", manifestID, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// UpsertManifestMirror inserts or updates the mirrored copy of a manifest's contents.
+func (d *Core) UpsertManifestMirror(manifestID int, body []byte, hash string, fetchedAt time.Time) error {
+	if _, err := d.q.UpsertMirror.Exec(manifestID, body, hash, fetchedAt); err != nil {
+		d.log.Printf("error upserting manifest mirror: %d: %v", manifestID, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetManifestMirror retrieves the mirrored copy of an active manifest by its GUID.
+func (d *Core) GetManifestMirror(guid string) (models.ManifestMirror, error) {
+	var out models.ManifestMirror
+	if err := d.q.GetMirror.Get(&out, guid); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching manifest mirror: %s: %v", guid, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// InsertCrawlRun records the stats of a crawl run.
+func (d *Core) InsertCrawlRun(r models.CrawlRun) error {
+	if _, err := d.q.InsertCrawlRun.Exec(r.StartedAt, r.FinishedAt, r.Total, r.Success, r.Failed, r.Skipped, r.LatencyP50, r.LatencyP95); err != nil {
+		d.log.Printf("error inserting crawl run: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetCrawlStatus returns the summary of the crawler's health over the last N runs.
+func (d *Core) GetCrawlStatus(limit int) (models.CrawlStatus, error) {
+	out := models.CrawlStatus{Runs: []models.CrawlRun{}}
+	if err := d.q.GetCrawlRuns.Select(&out.Runs, limit); err != nil {
+		d.log.Printf("error fetching crawl runs: %v", err)
+		return out, err
+	}
+
+	if len(out.Runs) == 0 {
+		return out, nil
+	}
+
+	var (
+		fetched   = 0
+		success   = 0
+		latencies = make([]int, 0, len(out.Runs))
+	)
+	for _, r := range out.Runs {
+		fetched += r.Success + r.Failed
+		success += r.Success
+		latencies = append(latencies, r.LatencyP50)
+	}
+	slices.Sort(latencies)
+
+	out.NumRuns = len(out.Runs)
+	out.LastRunAt = &out.Runs[0].FinishedAt
+	out.MedianLatency = latencies[len(latencies)/2]
+	if fetched > 0 {
+		out.SuccessRate = float64(success) / float64(fetched)
+	}
+
+	return out, nil
+}
+
+// ArmManifestTrace requests a trace of the next crawl of a manifest.
+func (d *Core) ArmManifestTrace(id int) error {
+	if _, err := d.q.ArmManifestTrace.Exec(id); err != nil {
+		d.log.Printf("error arming manifest trace: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// SaveManifestTrace saves the captured trace of a manifest's crawl and disarms it.
+func (d *Core) SaveManifestTrace(id int, t models.ManifestTrace) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.q.SaveManifestTrace.Exec(id, b); err != nil {
+		d.log.Printf("error saving manifest trace: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetManifestTrace returns the trace of a manifest.
+func (d *Core) GetManifestTrace(id int) (models.ManifestTraceRecord, error) {
+	var out models.ManifestTraceRecord
+	if err := d.q.GetManifestTrace.Get(&out, id); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching manifest trace: %d: %v", id, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// InsertCrawlError records a crawl error with its normalized class.
+func (d *Core) InsertCrawlError(manifestID int, host, class, message, requestID string) error {
+	if _, err := d.q.InsertCrawlError.Exec(manifestID, host, class, message, requestID); err != nil {
+		d.log.Printf("error inserting crawl error: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetCrawlErrorDomains returns the hosts with the most crawl errors within the interval (eg: 30 DAYS).
+func (d *Core) GetCrawlErrorDomains(interval string, limit int) ([]models.CrawlErrorDomain, error) {
+	out := []models.CrawlErrorDomain{}
+	if err := d.q.GetCrawlErrorDomains.Select(&out, interval, limit); err != nil {
+		d.log.Printf("error fetching crawl error domains: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetCrawlErrorTrends returns the daily number of crawl errors per class within the interval.
+func (d *Core) GetCrawlErrorTrends(interval string) ([]models.CrawlErrorTrend, error) {
+	out := []models.CrawlErrorTrend{}
+	if err := d.q.GetCrawlErrorTrends.Select(&out, interval); err != nil {
+		d.log.Printf("error fetching crawl error trends: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// PruneCrawlErrors deletes crawl errors older than the interval.
+func (d *Core) PruneCrawlErrors(interval string) error {
+	if _, err := d.q.DeleteCrawlErrors.Exec(interval); err != nil {
+		d.log.Printf("error pruning crawl errors: %v", err)
+		return err
+	}
+
+	return nil
+}
+
 // getManifests retrieves one or more manifests.
-func (d *Core) getManifests(id int, guid string, lastID, limit int) ([]models.ManifestData, error) {
+func (d *Core) getManifests(id int, guid string, lastID, limit int, previewHash string) ([]models.ManifestData, error) {
 	var (
 		out []models.ManifestData
 	)
 
 	// Get the manifest. entity{} and projects[{}] are retrieved
 	// as JSON fields that need to be manually unmarshalled.
-	if err := d.q.GetManifests.Select(&out, id, guid, lastID, limit); err != nil {
+	if err := d.q.GetManifests.Select(&out, id, guid, lastID, limit, previewHash); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -317,3 +1266,24 @@ func MakeGUID(u *url.URL) string {
 	guid := "@" + path.Join(u.Host, uri)
 	return guid
 }
+
+// MakeID creates a manifest ID (lowercase alpha-numeric-dashes, 3-32 chars) from
+// a name, eg: a project or plan name. IDs in taken are avoided by suffixing a number,
+// and the new ID is added to taken.
+func MakeID(name, fallback string, taken map[string]bool) string {
+	id := strings.Trim(reNonID.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(id) > 28 {
+		id = strings.Trim(id[:28], "-")
+	}
+	if len(id) < 3 {
+		id = fallback
+	}
+
+	out := id
+	for n := 2; taken[out]; n++ {
+		out = fmt.Sprintf("%s-%d", id, n)
+	}
+	taken[out] = true
+
+	return out
+}
@@ -1,9 +1,16 @@
 package core
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/url"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,3 +34,413 @@ func TestMakeGUID(t *testing.T) {
 	f("https://example.com/single", "@example.com/single")
 	f("https://sub.domain.example.com/project", "@sub.domain.example.com/project")
 }
+
+func TestMakeID(t *testing.T) {
+	taken := map[string]bool{"existing": true}
+	f := func(name, fallback, exp string) {
+		assert.Equal(t, exp, MakeID(name, fallback, taken), name)
+	}
+
+	f("My Project!", "project-1", "my-project")
+	f("My project", "project-2", "my-project-2")
+	f("Existing", "project-3", "existing-2")
+	f("日本", "project-4", "project-4")
+	f("A very long project name that goes on and on", "project-5", "a-very-long-project-name-tha")
+}
+
+func TestAllocate(t *testing.T) {
+	f := func(budget, max float64, needs, exp []float64) {
+		assert.Equal(t, exp, Allocate(budget, max, needs))
+	}
+
+	// Budget covers all needs.
+	f(1000, 0, []float64{100, 200}, []float64{100, 200})
+
+	// Proportional split.
+	f(300, 0, []float64{100, 200}, []float64{100, 200})
+	f(150, 0, []float64{100, 200}, []float64{50, 100})
+
+	// Capped recipients have the remainder redistributed.
+	f(300, 120, []float64{100, 500, 500}, []float64{60, 120, 120})
+	f(300, 150, []float64{100, 900, 1000}, []float64{15, 135, 150})
+
+	f(100, 0, nil, []float64{})
+}
+
+func TestIsVerifiedHost(t *testing.T) {
+	var (
+		gm, _ = url.Parse("https://github.com/user/repo/blob/main/funding.json")
+		gw, _ = url.Parse("https://github.com/user")
+		em, _ = url.Parse("https://example.com/funding.json")
+	)
+
+	f := func(host, p string, manifest, webpage *url.URL, exp bool) {
+		assert.Equal(t, exp, isVerifiedHost(host, p, manifest, webpage))
+	}
+
+	f("example.com", "/", em, nil, true)
+	f("example.com", "/security", em, nil, true)
+	f("other.com", "/", em, nil, false)
+
+	// Shared hosts require the same path.
+	f("github.com", "/user/security", gm, gw, true)
+	f("github.com", "/user", gm, gw, true)
+	f("github.com", "/other/security", gm, gw, false)
+	f("github.com", "/", gm, gw, false)
+}
+
+func TestVerificationLevel(t *testing.T) {
+	forges := []string{"github.com"}
+
+	f := func(manifest string, repos []string, wellKnown string, checked bool, exp string) {
+		var m v1.Manifest
+		m.URL.URLobj, _ = url.Parse(manifest)
+		m.Entity.WebpageURL.WellKnown = wellKnown
+		for _, r := range repos {
+			var p v1.Project
+			p.RepositoryURL.URLobj, _ = url.Parse(r)
+			m.Projects = append(m.Projects, p)
+		}
+
+		assert.Equal(t, exp, VerificationLevel(m, checked, forges))
+	}
+
+	f("https://example.com/funding.json", []string{"https://example.com/repo"}, "", false, VerificationProvenance)
+	f("https://example.com/funding.json", []string{"https://example.com/repo"}, "https://example.com/.well-known/x", false, VerificationUnverified)
+	f("https://example.com/funding.json", []string{"https://example.com/repo"}, "https://example.com/.well-known/x", true, VerificationProvenance)
+	f("https://github.com/user/repo/blob/main/funding.json", []string{"https://github.com/user/repo", "https://github.com/User/other"}, "", true, VerificationForge)
+	f("https://github.com/user/repo/blob/main/funding.json", []string{"https://github.com/user/repo", "https://github.com/other/repo"}, "", true, VerificationProvenance)
+}
+
+func TestNormalizeDOI(t *testing.T) {
+	f := func(in, exp string, hasErr bool) {
+		out, err := NormalizeDOI(in)
+		assert.Equal(t, exp, out, in)
+		assert.Equal(t, hasErr, err != nil, in)
+	}
+
+	f("10.5281/zenodo.123", "10.5281/zenodo.123", false)
+	f("https://doi.org/10.5281/Zenodo.123", "10.5281/zenodo.123", false)
+	f("doi:10.1000/xyz", "10.1000/xyz", false)
+	f("10.5281", "", true)
+	f("https://zenodo.org/records/123", "", true)
+}
+
+func TestScorecardPath(t *testing.T) {
+	f := func(in, exp string, ok bool) {
+		out, o := ScorecardPath(in)
+		assert.Equal(t, exp, out, in)
+		assert.Equal(t, ok, o, in)
+	}
+
+	f("https://github.com/org/repo", "/projects/github.com/org/repo", true)
+	f("https://GitHub.com/org/repo.git/", "/projects/github.com/org/repo", true)
+	f("https://gitlab.com/group/repo/-/tree/main", "/projects/gitlab.com/group/repo", true)
+	f("https://github.com/org", "", false)
+	f("https://codeberg.org/org/repo", "", false)
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Now()
+	f := func(age time.Duration, exp string) {
+		last := now.Add(-age)
+		assert.Equal(t, exp, Freshness(&last, now), age)
+	}
+
+	f(time.Hour, FreshnessActive)
+	f(time.Hour*24*200, FreshnessStale)
+	f(time.Hour*24*800, FreshnessAbandoned)
+	assert.Equal(t, FreshnessUnknown, Freshness(nil, now))
+
+	assert.Equal(t, 42, CountFromPagination("", `<https://api.github.com/repositories/1/contributors?per_page=1&anon=1&page=2>; rel="next", <https://api.github.com/repositories/1/contributors?per_page=1&anon=1&page=42>; rel="last"`, 1))
+	assert.Equal(t, 7, CountFromPagination("7", "", 1))
+	assert.Equal(t, 1, CountFromPagination("", "", 1))
+}
+
+func TestParseDeprecations(t *testing.T) {
+	f := func(in string, plans, chans []string, hasErr bool) {
+		out, err := ParseDeprecations([]byte(in))
+		assert.Equal(t, hasErr, err != nil, in)
+		if hasErr {
+			return
+		}
+		if len(plans) == 0 && len(chans) == 0 {
+			assert.Nil(t, out, in)
+			return
+		}
+		for _, g := range plans {
+			assert.Contains(t, out.Plans, g, in)
+		}
+		for _, g := range chans {
+			assert.Contains(t, out.Channels, g, in)
+		}
+	}
+
+	f(`{"funding": {"plans": [{"guid": "a"}], "channels": [{"guid": "x", "deprecated": false}]}}`, nil, nil, false)
+	f(`{"funding": {"plans": [{"guid": "a", "deprecated": true}, {"guid": "b"}]}}`, []string{"a"}, nil, false)
+	f(`{"funding": {"channels": [{"guid": "x", "deprecated": {"replacement": "y", "since": "2024-06-01"}}, {"guid": "y"}]}}`, nil, []string{"x"}, false)
+	f(`{"funding": {"plans": [{"guid": "a", "deprecated": {"replacement": "z"}}]}}`, nil, nil, true)
+	f(`{"funding": {"plans": [{"guid": "a", "deprecated": {"replacement": "b"}}, {"guid": "b", "deprecated": true}]}}`, nil, nil, true)
+	f(`{"funding": {"plans": [{"guid": "a", "deprecated": {"since": "June"}}]}}`, nil, nil, true)
+	f(`{"funding": {"plans": [{"guid": "a", "deprecated": "yes"}]}}`, nil, nil, true)
+
+	assert.Equal(t, "The plan a is deprecated since 2024-06-01. Switch to the plan b. Moved.",
+		DeprecationHint("plan", "a", models.Deprecation{Replacement: "b", Since: "2024-06-01", Message: "Moved."}))
+}
+
+func TestParseRepositories(t *testing.T) {
+	f := func(in string, exp map[string][]models.Repository, hasErr bool) {
+		out, err := ParseRepositories([]byte(in))
+		assert.Equal(t, hasErr, err != nil, in)
+		if !hasErr {
+			assert.Equal(t, exp, out, in)
+		}
+	}
+
+	f(`{"projects": [{"guid": "a", "repositoryUrl": {"url": "https://github.com/u/a"}}]}`, map[string][]models.Repository{}, false)
+	f(`{"projects": [{"guid": "a", "repositoryUrl": {"url": "https://github.com/u/a"}, "repositories": [{"url": "https://codeberg.org/u/a", "role": "mirror"}, {"url": "https://github.com/u/a-docs"}]}]}`,
+		map[string][]models.Repository{"a": {{URL: "https://codeberg.org/u/a", Role: "mirror"}, {URL: "https://github.com/u/a-docs", Role: "other"}}}, false)
+	f(`{"projects": [{"guid": "a", "repositoryUrl": {"url": "https://github.com/u/a"}, "repositories": [{"url": "http://github.com/U/a.git"}]}]}`, nil, true)
+	f(`{"projects": [{"guid": "a", "repositories": [{"url": "https://codeberg.org/u/a"}, {"url": "https://codeberg.org/u/a/"}]}]}`, nil, true)
+	f(`{"projects": [{"guid": "a", "repositories": [{"url": "ftp://codeberg.org/u/a"}]}]}`, nil, true)
+	f(`{"projects": [{"guid": "a", "repositories": [{"url": "https://codeberg.org/u/a", "role": "fork"}]}]}`, nil, true)
+	f(`{"projects": [{"guid": "a", "repositories": [{"url": "https://codeberg.org/u/a", "wellKnown": "codeberg"}]}]}`, nil, true)
+}
+
+func TestRepositoryURL(t *testing.T) {
+	m, _ := url.Parse("https://example.com/u/funding.json")
+	f := func(r models.Repository, wellKnown string, hasErr bool) {
+		out, err := RepositoryURL("r", r, m, "/.well-known/funding-manifest-urls")
+		assert.Equal(t, hasErr, err != nil, r)
+		if !hasErr {
+			assert.Equal(t, wellKnown, out.WellKnown, r)
+		}
+	}
+
+	f(models.Repository{URL: "https://example.com/u/docs"}, "", false)
+	f(models.Repository{URL: "https://example.com/u/docs", WellKnown: "https://example.com/.well-known/funding-manifest-urls"}, "", false)
+	f(models.Repository{URL: "https://codeberg.org/u/a"}, "", true)
+	f(models.Repository{URL: "https://codeberg.org/u/a", WellKnown: "https://codeberg.org/u/a/raw/branch/main/.well-known/funding-manifest-urls"},
+		"https://codeberg.org/u/a/raw/branch/main/.well-known/funding-manifest-urls", false)
+	f(models.Repository{URL: "https://codeberg.org/u/a", WellKnown: "https://github.com/u/a/.well-known/funding-manifest-urls"}, "", true)
+	f(models.Repository{URL: "https://codeberg.org/u/a", WellKnown: "https://codeberg.org/u/a/funding.txt"}, "", true)
+}
+
+func TestParseExtensions(t *testing.T) {
+	f := func(in string, exp *models.Extensions, warns int) {
+		out, w, err := ParseExtensions([]byte(in))
+		assert.NoError(t, err, in)
+		assert.Equal(t, exp, out, in)
+		assert.Len(t, w, warns, in)
+	}
+
+	f(`{"entity": {}}`, nil, 0)
+	f(`{"extensions": {"x-acme-grant": {"id":  1}}, "projects": [{"guid": "a", "extensions": {"x-tier": "gold"}}, {"guid": "b"}]}`,
+		&models.Extensions{
+			Manifest: map[string]json.RawMessage{"x-acme-grant": json.RawMessage(`{"id":1}`)},
+			Projects: map[string]map[string]json.RawMessage{"a": {"x-tier": json.RawMessage(`"gold"`)}},
+		}, 0)
+	f(`{"extensions": {"grant": 1, "x-Grant": 1, "x-big": "`+strings.Repeat("a", 5000)+`"}}`, nil, 3)
+	f(`{"extensions": "x"}`, nil, 1)
+
+	many := make([]string, 25)
+	for n := range many {
+		many[n] = fmt.Sprintf(`"x-e%02d": %d`, n, n)
+	}
+	out, w, _ := ParseExtensions([]byte(`{"extensions": {` + strings.Join(many, ",") + `}}`))
+	assert.Len(t, out.Manifest, 20)
+	assert.Contains(t, out.Manifest, "x-e00")
+	assert.Len(t, w, 5)
+}
+
+func TestSignWebhook(t *testing.T) {
+	assert.Equal(t, "99ac9cb330da0a1c0aa3abc7f0c6eea87a12a6d6bccd612bcd96632fd931b111", SignWebhook("secret", []byte(`{"event":"update"}`)))
+}
+
+func TestParsePortalTXT(t *testing.T) {
+	f := func(in []string, exp string) {
+		assert.Equal(t, exp, ParsePortalTXT(in), in)
+	}
+
+	f([]string{"v=portal1; url=https://portal.example.com/"}, "https://portal.example.com")
+	f([]string{"v=spf1 -all", "v=portal1;url=https://p.example.com"}, "https://p.example.com")
+	f([]string{"v=portal1; url=http://portal.example.com"}, "")
+	f([]string{"v=portal2; url=https://portal.example.com"}, "")
+	f(nil, "")
+
+	assert.Equal(t, []string{"docs.example.com", "example.com"}, DiscoveryDomains("Docs.Example.com."))
+	assert.Nil(t, DiscoveryDomains("localhost"))
+}
+
+func TestNormalizeLookupURL(t *testing.T) {
+	forges := []string{"github.com", "gitlab.com"}
+	f := func(in, exp string) {
+		out, ok := NormalizeLookupURL(in, forges)
+		assert.Equal(t, exp != "", ok, in)
+		assert.Equal(t, exp, out, in)
+	}
+
+	f("https://github.com/Org/Repo", "github.com/org/repo")
+	f("https://www.github.com/org/repo.git", "github.com/org/repo")
+	f("https://github.com/org/repo/issues/1?q=x#top", "github.com/org/repo")
+	f("http://Example.com/docs/", "example.com/docs")
+	f("https://example.com", "example.com")
+	f("https://github.com/org", "")
+	f("ftp://example.com", "")
+	f("not a url", "")
+}
+
+func TestParseContentHash(t *testing.T) {
+	f := func(in, exp string, isErr bool) {
+		out, err := ParseContentHash(in)
+		if isErr {
+			assert.Error(t, err, in)
+			return
+		}
+		assert.NoError(t, err, in)
+		assert.Equal(t, exp, out, in)
+	}
+
+	h := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	f(h, h, false)
+	f(" SHA256:"+strings.ToUpper(h), h, false)
+	f(h[:62], "", true)
+	f("sha1:"+h, "", true)
+	f("", "", true)
+}
+
+func TestDiffChannels(t *testing.T) {
+	old := []byte(`[{"guid": "a", "type": "bank", "address": "x"}, {"guid": "b", "type": "payment-provider", "address": "y", "description": "old"}, {"guid": "c", "type": "other"}]`)
+	cur := []byte(`[{"guid": "a", "type": "bank", "address": "z"}, {"guid": "b", "type": "payment-provider", "address": "y", "description": "new"}, {"guid": "d", "type": "other"}]`)
+
+	out, err := DiffChannels(old, cur)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ChannelChange{
+		{GUID: "a", Change: ChannelModified, OldType: "bank", NewType: "bank", OldAddress: "x", NewAddress: "z"},
+		{GUID: "d", Change: ChannelAdded, NewType: "other"},
+		{GUID: "c", Change: ChannelRemoved, OldType: "other"},
+	}, out)
+
+	out, err = DiffChannels(old, old)
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+
+	_, err = DiffChannels([]byte(`{`), nil)
+	assert.Error(t, err)
+}
+
+func TestCheckCompliance(t *testing.T) {
+	m := v1.Manifest{
+		URL:    v1.URL{URL: "https://example.com/funding.json"},
+		Entity: v1.Entity{Email: "a@example.org", WebpageURL: v1.URL{URL: "https://example.com"}},
+		Funding: v1.Funding{Channels: v1.Channels{
+			{GUID: "bank", Type: "bank", Address: "IBAN 123"},
+			{GUID: "btc", Type: "other", Address: "bc1qxyz"},
+		}},
+	}
+
+	f := func(r ComplianceRules, isErr bool) {
+		err := CheckCompliance(m, r)
+		if !isErr {
+			assert.NoError(t, err)
+			return
+		}
+
+		var ce *ComplianceError
+		assert.ErrorAs(t, err, &ce)
+		assert.Contains(t, err.Error(), "not listed on this instance because")
+	}
+
+	f(ComplianceRules{}, false)
+	f(ComplianceRules{ChannelTypes: []string{"cash"}, Domains: []string{".net"}}, false)
+	f(ComplianceRules{ChannelTypes: []string{"bank"}}, true)
+	f(ComplianceRules{Domains: []string{".org"}}, true)
+	f(ComplianceRules{Domains: []string{"example.com"}}, true)
+	f(ComplianceRules{Domains: []string{"ample.com"}}, false)
+	f(ComplianceRules{AddressPatterns: []*regexp.Regexp{regexp.MustCompile(`^bc1`)}}, true)
+}
+
+func BenchmarkNormalizeLookupURL(b *testing.B) {
+	forges := []string{"github.com", "gitlab.com", "codeberg.org"}
+	for n := 0; n < b.N; n++ {
+		NormalizeLookupURL("https://www.GitHub.com/org/repo/issues/123?x=1", forges)
+		NormalizeLookupURL("https://example.com/docs/", forges)
+	}
+}
+
+func BenchmarkDiffChannels(b *testing.B) {
+	var old, cur strings.Builder
+	old.WriteString("[")
+	cur.WriteString("[")
+	for n := 0; n < 50; n++ {
+		if n > 0 {
+			old.WriteString(",")
+			cur.WriteString(",")
+		}
+		fmt.Fprintf(&old, `{"guid": "c%d", "type": "bank", "address": "a%d"}`, n, n)
+		fmt.Fprintf(&cur, `{"guid": "c%d", "type": "bank", "address": "a%d"}`, n, n+n%2)
+	}
+	old.WriteString("]")
+	cur.WriteString("]")
+
+	o, c := []byte(old.String()), []byte(cur.String())
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := DiffChannels(o, c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFundingDeepLink(t *testing.T) {
+	f := func(addr string, p v1.Plan, exp string, prefilled bool) {
+		l, ok := FundingDeepLink(v1.Channel{GUID: "ch", Address: addr}, p)
+		if exp == "" {
+			assert.False(t, ok, addr)
+			return
+		}
+		assert.True(t, ok, addr)
+		assert.Equal(t, exp, l.URL, addr)
+		assert.Equal(t, prefilled, l.Prefilled, addr)
+	}
+
+	monthly := v1.Plan{GUID: "p", Status: "active", Amount: 10, Currency: "USD", Frequency: "monthly"}
+	f("https://opencollective.com/foo", monthly, "https://opencollective.com/foo/donate?amount=10&interval=month", true)
+	f("https://liberapay.com/foo/", monthly, "https://liberapay.com/foo/donate?amount=10&currency=USD&period=monthly", true)
+	f("https://github.com/sponsors/foo", monthly, "https://github.com/sponsors/foo/sponsorships?amount=10&frequency=recurring", true)
+	f("https://github.com/foo", monthly, "https://github.com/foo", false)
+	f("https://example.com/pay", monthly, "https://example.com/pay", false)
+	f("IBAN 1234", monthly, "", false)
+
+	// Frequencies that the provider doesn't take.
+	f("https://opencollective.com/foo", v1.Plan{Amount: 5, Currency: "USD", Frequency: "weekly"}, "https://opencollective.com/foo", false)
+
+	links := FundingDeepLinks(v1.Funding{
+		Channels: v1.Channels{{GUID: "oc", Address: "https://opencollective.com/foo"}, {GUID: "bank", Address: "IBAN 1"}, {GUID: "old", Address: "https://example.com"}},
+		Plans: v1.Plans{
+			{GUID: "a", Status: "active", Amount: 10, Frequency: "yearly"},
+			{GUID: "b", Status: "inactive", Amount: 10, Frequency: "yearly", Channels: []string{"oc"}},
+		},
+	}, &models.Deprecations{Channels: map[string]models.Deprecation{"old": {}}})
+	assert.Equal(t, []models.DeepLink{
+		{Plan: "a", Channel: "oc", Provider: "opencollective", URL: "https://opencollective.com/foo/donate?amount=10&interval=year", Prefilled: true},
+	}, links)
+}
+
+func TestRejectProjects(t *testing.T) {
+	m := v1.Manifest{Projects: v1.Projects{{GUID: "a", Name: "A"}, {GUID: "b", Name: "B"}}}
+
+	out, rej, err := RejectProjects(m, nil)
+	assert.NoError(t, err)
+	assert.Len(t, out.Projects, 2)
+	assert.Empty(t, rej)
+
+	out, rej, err = RejectProjects(m, map[string]error{"b": fmt.Errorf("provenance failed")})
+	assert.NoError(t, err)
+	assert.Equal(t, []v1.Project{{GUID: "a", Name: "A"}}, []v1.Project(out.Projects))
+	assert.Equal(t, []models.RejectedProject{{GUID: "b", Name: "B", Reason: "provenance failed"}}, rej)
+	assert.Len(t, m.Projects, 2)
+
+	_, _, err = RejectProjects(m, map[string]error{"a": fmt.Errorf("x"), "b": fmt.Errorf("y")})
+	assert.EqualError(t, err, "x")
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// checkout generates the checkout URL of a payment provider from a channel's
+// address (its page on the provider) and a plan. It returns false if the plan
+// can't be prefilled (eg: an unsupported frequency).
+type checkout func(u *url.URL, p v1.Plan) (string, bool)
+
+// Payment providers whose checkouts can be prefilled, by hostname.
+var checkouts = map[string]struct {
+	name string
+	fn   checkout
+}{
+	"opencollective.com": {"opencollective", checkoutOpenCollective},
+	"liberapay.com":      {"liberapay", checkoutLiberapay},
+	"github.com":         {"github-sponsors", checkoutGitHubSponsors},
+}
+
+// FundingDeepLinks returns links to fund the active plans of a manifest through each
+// of their channels (all channels if a plan doesn't list any) that have a URL address.
+// Deprecated plans and channels are skipped.
+func FundingDeepLinks(f v1.Funding, dep *models.Deprecations) []models.DeepLink {
+	if dep == nil {
+		dep = &models.Deprecations{}
+	}
+
+	out := []models.DeepLink{}
+	for _, p := range f.Plans {
+		if _, ok := dep.Plans[p.GUID]; ok || p.Status != "active" {
+			continue
+		}
+
+		for _, ch := range f.Channels {
+			if _, ok := dep.Channels[ch.GUID]; ok {
+				continue
+			}
+			if len(p.Channels) > 0 && !slices.Contains(p.Channels, ch.GUID) {
+				continue
+			}
+
+			if l, ok := FundingDeepLink(ch, p); ok {
+				out = append(out, l)
+			}
+		}
+	}
+
+	return out
+}
+
+// FundingDeepLink returns a link to fund a plan through a channel. It returns
+// false if the channel's address isn't a URL.
+func FundingDeepLink(ch v1.Channel, p v1.Plan) (models.DeepLink, bool) {
+	u, err := url.Parse(strings.TrimSpace(ch.Address))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return models.DeepLink{}, false
+	}
+
+	out := models.DeepLink{Plan: p.GUID, Channel: ch.GUID, URL: u.String()}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if c, ok := checkouts[host]; ok && p.Amount > 0 {
+		if s, ok := c.fn(u, p); ok {
+			out.Provider = c.name
+			out.URL = s
+			out.Prefilled = true
+		}
+	}
+
+	return out, true
+}
+
+// checkoutOpenCollective returns opencollective.com/<slug>/donate with the amount and interval.
+func checkoutOpenCollective(u *url.URL, p v1.Plan) (string, bool) {
+	slug := firstPathSegment(u)
+	if slug == "" {
+		return "", false
+	}
+
+	q := url.Values{}
+	q.Set("amount", formatAmount(p.Amount))
+	switch p.Frequency {
+	case "one-time":
+	case "monthly":
+		q.Set("interval", "month")
+	case "yearly":
+		q.Set("interval", "year")
+	default:
+		return "", false
+	}
+
+	return "https://opencollective.com/" + slug + "/donate?" + q.Encode(), true
+}
+
+// checkoutLiberapay returns liberapay.com/<user>/donate with the amount, currency, and period.
+// Liberapay only takes recurring donations.
+func checkoutLiberapay(u *url.URL, p v1.Plan) (string, bool) {
+	user := firstPathSegment(u)
+	if user == "" {
+		return "", false
+	}
+
+	q := url.Values{}
+	q.Set("amount", formatAmount(p.Amount))
+	q.Set("currency", p.Currency)
+	switch p.Frequency {
+	case "weekly", "monthly", "yearly":
+		q.Set("period", p.Frequency)
+	default:
+		return "", false
+	}
+
+	return "https://liberapay.com/" + user + "/donate?" + q.Encode(), true
+}
+
+// checkoutGitHubSponsors returns github.com/sponsors/<user>/sponsorships with the amount
+// and frequency. GitHub Sponsors only takes monthly and one-time amounts in USD.
+func checkoutGitHubSponsors(u *url.URL, p v1.Plan) (string, bool) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "sponsors" || parts[1] == "" || p.Currency != "USD" {
+		return "", false
+	}
+
+	q := url.Values{}
+	q.Set("amount", formatAmount(p.Amount))
+	switch p.Frequency {
+	case "one-time":
+		q.Set("frequency", "one-time")
+	case "monthly":
+		q.Set("frequency", "recurring")
+	default:
+		return "", false
+	}
+
+	return "https://github.com/sponsors/" + parts[1] + "/sponsorships?" + q.Encode(), true
+}
+
+func firstPathSegment(u *url.URL) string {
+	s, _, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	return s
+}
+
+func formatAmount(a float64) string {
+	return strconv.FormatFloat(a, 'f', -1, 64)
+}
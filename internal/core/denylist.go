@@ -0,0 +1,81 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/lib/pq"
+)
+
+// GetDenylist returns all the payment addresses on the denylist.
+func (d *Core) GetDenylist() ([]models.DenylistEntry, error) {
+	out := []models.DenylistEntry{}
+	if err := d.q.GetDenylist.Select(&out); err != nil {
+		d.log.Printf("error fetching payment denylist: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetDenylisted returns the given payment addresses that are on the denylist.
+func (d *Core) GetDenylisted(addresses []string) ([]models.DenylistEntry, error) {
+	out := []models.DenylistEntry{}
+	if len(addresses) == 0 {
+		return out, nil
+	}
+
+	if err := d.q.GetDenylisted.Select(&out, pq.Array(addresses)); err != nil {
+		d.log.Printf("error checking payment denylist: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// ImportDenylist adds (or updates) payment addresses on the denylist. Entries without
+// a source get the given default source. It returns the number of entries imported.
+func (d *Core) ImportDenylist(entries []models.DenylistEntry, source string) (int, error) {
+	var (
+		addr, types, reasons, sources []string
+		seen                          = make(map[string]struct{})
+	)
+	for _, e := range entries {
+		a := strings.TrimSpace(e.Address)
+		if _, ok := seen[a]; ok || a == "" {
+			continue
+		}
+		seen[a] = struct{}{}
+
+		src := e.Source
+		if src == "" {
+			src = source
+		}
+
+		addr = append(addr, a)
+		types = append(types, e.ChannelType)
+		reasons = append(reasons, e.Reason)
+		sources = append(sources, src)
+	}
+
+	if len(addr) == 0 {
+		return 0, nil
+	}
+
+	if _, err := d.q.ImportDenylist.Exec(pq.Array(addr), pq.Array(types), pq.Array(reasons), pq.Array(sources)); err != nil {
+		d.log.Printf("error importing payment denylist: %v", err)
+		return 0, err
+	}
+
+	return len(addr), nil
+}
+
+// DeleteDenylist removes a payment address from the denylist.
+func (d *Core) DeleteDenylist(address string) error {
+	if _, err := d.q.DeleteDenylist.Exec(address); err != nil {
+		d.log.Printf("error deleting from payment denylist: %s: %v", address, err)
+		return err
+	}
+
+	return nil
+}
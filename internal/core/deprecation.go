@@ -0,0 +1,141 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+const maxDeprecationMsgLen = 500
+
+// ParseDeprecations parses the optional "deprecated" field of the plans and channels
+// in a manifest body. The field is either true or an object:
+// {"replacement": "other-guid", "message": "..", "since": "2024-06-01"}. A replacement
+// should be an existing, non-deprecated plan (or channel) in the same manifest.
+func ParseDeprecations(b []byte) (*models.Deprecations, error) {
+	type item struct {
+		GUID       string          `json:"guid"`
+		Deprecated json.RawMessage `json:"deprecated"`
+	}
+	var raw struct {
+		Funding struct {
+			Channels []item `json:"channels"`
+			Plans    []item `json:"plans"`
+		} `json:"funding"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing JSON body: %v", err)
+	}
+
+	parse := func(tag, kind string, items []item) (map[string]models.Deprecation, error) {
+		out := make(map[string]models.Deprecation)
+		for n, o := range items {
+			d, ok, err := parseDeprecation(o.Deprecated)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d].deprecated: %v", tag, n, err)
+			}
+			if ok {
+				out[o.GUID] = d
+			}
+		}
+
+		// Validate the replacements and generate the hints.
+		guids := make(map[string]bool, len(items))
+		for _, o := range items {
+			guids[o.GUID] = true
+		}
+		for guid, d := range out {
+			if d.Replacement != "" {
+				if d.Replacement == guid || !guids[d.Replacement] {
+					return nil, fmt.Errorf("%s: replacement of %s should be an existing %s", tag, guid, kind)
+				}
+				if _, ok := out[d.Replacement]; ok {
+					return nil, fmt.Errorf("%s: replacement of %s is deprecated", tag, guid)
+				}
+			}
+			d.Hint = DeprecationHint(kind, guid, d)
+			out[guid] = d
+		}
+
+		return out, nil
+	}
+
+	chans, err := parse("funding.channels", "channel", raw.Funding.Channels)
+	if err != nil {
+		return nil, err
+	}
+	plans, err := parse("funding.plans", "plan", raw.Funding.Plans)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chans) == 0 && len(plans) == 0 {
+		return nil, nil
+	}
+
+	return &models.Deprecations{Plans: plans, Channels: chans}, nil
+}
+
+// parseDeprecation parses a single "deprecated" value. ok is false if it's
+// absent or false.
+func parseDeprecation(b json.RawMessage) (models.Deprecation, bool, error) {
+	if len(b) == 0 || string(b) == "null" || string(b) == "false" {
+		return models.Deprecation{}, false, nil
+	}
+	if string(b) == "true" {
+		return models.Deprecation{}, true, nil
+	}
+
+	var d models.Deprecation
+	if err := json.Unmarshal(b, &d); err != nil {
+		return d, false, fmt.Errorf("should be true or an object with replacement, message, since")
+	}
+	d.Hint = ""
+
+	if len(d.Message) > maxDeprecationMsgLen {
+		return d, false, fmt.Errorf("message should be less than %d characters", maxDeprecationMsgLen)
+	}
+	if d.Since != "" {
+		if _, err := time.Parse("2006-01-02", d.Since); err != nil {
+			return d, false, fmt.Errorf("since should be a date (YYYY-MM-DD)")
+		}
+	}
+
+	return d, true, nil
+}
+
+// DeprecationHint returns a migration hint for funders of a deprecated plan or channel.
+func DeprecationHint(kind, guid string, d models.Deprecation) string {
+	s := fmt.Sprintf("The %s %s is deprecated", kind, guid)
+	if d.Since != "" {
+		s += " since " + d.Since
+	}
+	if d.Replacement != "" {
+		s += fmt.Sprintf(". Switch to the %s %s", kind, d.Replacement)
+	} else {
+		s += fmt.Sprintf(". Stop using the %s", kind)
+	}
+	s += "."
+
+	if d.Message != "" {
+		s += " " + d.Message
+	}
+
+	return s
+}
+
+// GetDeprecations returns the deprecated plans and channels of a manifest (from its meta), if any.
+func GetDeprecations(m models.ManifestData) (*models.Deprecations, error) {
+	if len(m.Meta) == 0 {
+		return nil, nil
+	}
+
+	var meta models.ManifestMeta
+	if err := m.Meta.Unmarshal(&meta); err != nil {
+		return nil, err
+	}
+
+	return meta.Deprecations, nil
+}
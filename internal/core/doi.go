@@ -0,0 +1,141 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// DataCiteURL is the DataCite REST API endpoint for DOI metadata. Zenodo
+// DOIs (10.5281/zenodo.*) are registered with DataCite.
+const DataCiteURL = "https://api.datacite.org/dois/"
+
+var (
+	reDOI = regexp.MustCompile(`^10\.\d{4,9}/[^\s]+$`)
+
+	ErrDOINotFound = errors.New("DOI not found")
+)
+
+// ParseDOIs parses the optional "doi" field of the projects in a manifest body:
+// {"projects": [{"guid": "..", "doi": "10.5281/zenodo.123"}]}. doi.org URLs and
+// the doi: prefix are accepted. It returns a map of project GUID => DOI.
+func ParseDOIs(b []byte) (map[string]string, error) {
+	var raw struct {
+		Projects []struct {
+			GUID string `json:"guid"`
+			DOI  string `json:"doi"`
+		} `json:"projects"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing JSON body: %v", err)
+	}
+
+	out := make(map[string]string)
+	for n, p := range raw.Projects {
+		if p.DOI == "" {
+			continue
+		}
+
+		doi, err := NormalizeDOI(p.DOI)
+		if err != nil {
+			return nil, fmt.Errorf("projects[%d].doi: %v", n, err)
+		}
+		out[p.GUID] = doi
+	}
+
+	return out, nil
+}
+
+// NormalizeDOI returns the bare, lowercased form of a DOI (10.x/y).
+func NormalizeDOI(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, p := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "doi:"} {
+		s = strings.TrimPrefix(s, p)
+	}
+
+	if len(s) > 200 || !reDOI.MatchString(s) {
+		return "", errors.New("invalid DOI. Should be of the form 10.xxxx/yyyy")
+	}
+
+	// DOIs are case insensitive.
+	return strings.ToLower(s), nil
+}
+
+// ParseDataCite parses the citation metadata of a DOI from a DataCite API response.
+func ParseDataCite(b []byte) (models.Citation, error) {
+	var raw struct {
+		Data struct {
+			Attributes struct {
+				DOI    string `json:"doi"`
+				URL    string `json:"url"`
+				Titles []struct {
+					Title string `json:"title"`
+				} `json:"titles"`
+				Creators []struct {
+					Name string `json:"name"`
+				} `json:"creators"`
+
+				// publisher is a string in older API versions and an object in newer ones.
+				Publisher       json.RawMessage `json:"publisher"`
+				PublicationYear int             `json:"publicationYear"`
+				Version         string          `json:"version"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return models.Citation{}, fmt.Errorf("error parsing DataCite response: %v", err)
+	}
+
+	a := raw.Data.Attributes
+	if a.DOI == "" {
+		return models.Citation{}, ErrDOINotFound
+	}
+
+	out := models.Citation{
+		DOI:      strings.ToLower(a.DOI),
+		URL:      a.URL,
+		Year:     a.PublicationYear,
+		Version:  a.Version,
+		Verified: true,
+	}
+	if len(a.Titles) > 0 {
+		out.Title = a.Titles[0].Title
+	}
+	for _, c := range a.Creators {
+		out.Authors = append(out.Authors, c.Name)
+	}
+
+	var pub struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(a.Publisher, &out.Publisher); err != nil {
+		if json.Unmarshal(a.Publisher, &pub) == nil {
+			out.Publisher = pub.Name
+		}
+	}
+
+	return out, nil
+}
+
+// GetCitation returns the citation of a project in a manifest (from its meta), if any.
+func GetCitation(m models.ManifestData, projectGUID string) (*models.Citation, error) {
+	if len(m.Meta) == 0 {
+		return nil, nil
+	}
+
+	var meta models.ManifestMeta
+	if err := m.Meta.Unmarshal(&meta); err != nil {
+		return nil, err
+	}
+
+	c, ok := meta.Citations[projectGUID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ExportTables are the analytics-ready tables that can be exported.
+var ExportTables = []string{"projects", "plans", "channels", "crawl_runs", "changes"}
+
+// ExportTable passes the columns (names and DB types) of an export table to
+// begin and then streams its rows to fn, one row at a time.
+func (d *Core) ExportTable(name string, begin func(cols []*sql.ColumnType) error, fn func(row []interface{}) error) error {
+	var stmt *sqlx.Stmt
+	switch name {
+	case "projects":
+		stmt = d.q.ExportProjects
+	case "plans":
+		stmt = d.q.ExportPlans
+	case "channels":
+		stmt = d.q.ExportChannels
+	case "crawl_runs":
+		stmt = d.q.ExportCrawlRuns
+	case "changes":
+		stmt = d.q.ExportChanges
+	default:
+		return fmt.Errorf("unknown export table: %s", name)
+	}
+
+	rows, err := stmt.Queryx()
+	if err != nil {
+		d.log.Printf("error exporting %s: %v", name, err)
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	if err := begin(cols); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		row, err := rows.SliceScan()
+		if err != nil {
+			d.log.Printf("error exporting %s: %v", name, err)
+			return err
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
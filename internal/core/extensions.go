@@ -0,0 +1,114 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Limits of an extensions object (of a manifest or a project).
+const (
+	maxExtensions       = 20
+	maxExtensionNameLen = 64
+	maxExtensionBytes   = 4096
+)
+
+var reExtensionName = regexp.MustCompile(`^x-[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ParseExtensions parses the optional "extensions" objects of a manifest body (at the
+// root and in projects) that hold experimental fields being prototyped before they're
+// adopted in the spec, eg: {"extensions": {"x-acme-grant-id": "123"}}. Names are x-
+// prefixed and values are arbitrary JSON that's stored and served as-is. Experiments
+// should never fail a manifest, so extensions with invalid names or that exceed the
+// limits are left out and returned as warnings instead of errors.
+func ParseExtensions(b []byte) (*models.Extensions, []string, error) {
+	var raw struct {
+		Extensions json.RawMessage `json:"extensions"`
+		Projects   []struct {
+			GUID       string          `json:"guid"`
+			Extensions json.RawMessage `json:"extensions"`
+		} `json:"projects"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, nil, fmt.Errorf("error parsing JSON body: %v", err)
+	}
+
+	var (
+		warns []string
+		out   = &models.Extensions{Projects: make(map[string]map[string]json.RawMessage)}
+	)
+	out.Manifest = parseExtensions("extensions", raw.Extensions, &warns)
+	for n, p := range raw.Projects {
+		if ext := parseExtensions(fmt.Sprintf("projects[%d].extensions", n), p.Extensions, &warns); len(ext) > 0 {
+			out.Projects[p.GUID] = ext
+		}
+	}
+
+	if len(out.Manifest) == 0 && len(out.Projects) == 0 {
+		return nil, warns, nil
+	}
+
+	return out, warns, nil
+}
+
+func parseExtensions(tag string, b json.RawMessage, warns *[]string) map[string]json.RawMessage {
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+
+	var ext map[string]json.RawMessage
+	if err := json.Unmarshal(b, &ext); err != nil {
+		*warns = append(*warns, fmt.Sprintf("%s: should be an object. Left out", tag))
+		return nil
+	}
+
+	// Names are sorted so that the same extensions are kept over the limit.
+	names := make([]string, 0, len(ext))
+	for name := range ext {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	out := make(map[string]json.RawMessage, len(ext))
+	for _, name := range names {
+		if len(out) >= maxExtensions {
+			*warns = append(*warns, fmt.Sprintf("%s: should have at most %d extensions. %s left out", tag, maxExtensions, name))
+			continue
+		}
+		if len(name) > maxExtensionNameLen || !reExtensionName.MatchString(name) {
+			*warns = append(*warns, fmt.Sprintf("%s.%s: name should be x- followed by lowercase letters, digits, and hyphens (max %d chars). Left out", tag, name, maxExtensionNameLen))
+			continue
+		}
+
+		var v bytes.Buffer
+		if err := json.Compact(&v, ext[name]); err != nil {
+			continue
+		}
+		if v.Len() > maxExtensionBytes {
+			*warns = append(*warns, fmt.Sprintf("%s.%s: should be at most %d bytes. Left out", tag, name, maxExtensionBytes))
+			continue
+		}
+
+		out[name] = v.Bytes()
+	}
+
+	return out
+}
+
+// GetExtensions returns the experimental extensions of a manifest (from its meta), if any.
+func GetExtensions(m models.ManifestData) (*models.Extensions, error) {
+	if len(m.Meta) == 0 {
+		return nil, nil
+	}
+
+	var meta models.ManifestMeta
+	if err := m.Meta.Unmarshal(&meta); err != nil {
+		return nil, err
+	}
+
+	return meta.Extensions, nil
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Prefix of the TXT records that point a domain to its authoritative portal
+// instance: _portal.example.com TXT "v=portal1; url=https://portal.example.com".
+const portalTXTVersion = "v=portal1"
+
+// ParsePortalTXT returns the instance root URL from the _portal TXT records of a domain.
+// Records of other versions and records with invalid (non https) URLs are ignored.
+func ParsePortalTXT(records []string) string {
+	for _, r := range records {
+		parts := strings.Split(r, ";")
+		if strings.TrimSpace(parts[0]) != portalTXTVersion {
+			continue
+		}
+
+		for _, p := range parts[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok || k != "url" {
+				continue
+			}
+
+			u, err := url.Parse(strings.TrimSpace(v))
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				continue
+			}
+			return strings.TrimRight(u.String(), "/")
+		}
+	}
+
+	return ""
+}
+
+// DiscoveryDomains returns the domains whose _portal records are looked up for a
+// host (without port), from the most specific: docs.example.com => docs.example.com, example.com.
+func DiscoveryDomains(host string) []string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	parts := strings.Split(host, ".")
+	if len(parts) < 2 {
+		return nil
+	}
+
+	out := make([]string, 0, len(parts)-1)
+	for n := 0; n < len(parts)-1; n++ {
+		out = append(out, strings.Join(parts[n:], "."))
+	}
+
+	return out
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Bounds of per-manifest fetch limits.
+const (
+	maxFetchBytes     = 10 << 20
+	maxFetchTimeoutMS = 60000
+	maxFetchAttempts  = 10
+)
+
+// ValidateFetchLimits checks per-manifest fetch limits against sane bounds.
+func ValidateFetchLimits(l models.FetchLimits) error {
+	if l.MaxBytes != nil && (*l.MaxBytes < 1 || *l.MaxBytes > maxFetchBytes) {
+		return fmt.Errorf("max_bytes should be between 1 and %d", maxFetchBytes)
+	}
+	if l.TimeoutMS != nil && (*l.TimeoutMS < 1 || *l.TimeoutMS > maxFetchTimeoutMS) {
+		return fmt.Errorf("timeout_ms should be between 1 and %d", maxFetchTimeoutMS)
+	}
+	if l.Attempts != nil && (*l.Attempts < 1 || *l.Attempts > maxFetchAttempts) {
+		return fmt.Errorf("attempts should be between 1 and %d", maxFetchAttempts)
+	}
+
+	return nil
+}
+
+// SetManifestFetchLimits sets the overrides of the crawler's global fetch limits
+// for a manifest. Unset (nil) limits use the global ones.
+func (d *Core) SetManifestFetchLimits(id int, l models.FetchLimits) error {
+	res, err := d.q.UpdateFetchLimits.Exec(id, l.MaxBytes, l.TimeoutMS, l.Attempts)
+	if err != nil {
+		d.log.Printf("error updating manifest fetch limits: %d: %v", id, err)
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetManifestFetchLimits returns the fetch limit overrides of a manifest.
+func (d *Core) GetManifestFetchLimits(id int) (models.FetchLimits, error) {
+	var out models.FetchLimits
+	if err := d.q.GetFetchLimits.Get(&out, id); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching manifest fetch limits: %d: %v", id, err)
+		return out, err
+	}
+
+	return out, nil
+}
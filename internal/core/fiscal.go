@@ -0,0 +1,102 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNotAdminVerified = errors.New("only admin-verified manifests can be fiscal hosts")
+	ErrInvalidPublicKey = errors.New("invalid ed25519 public key. Should be base64 encoded")
+)
+
+// ParsePublicKey parses a base64 encoded ed25519 public key.
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+
+	return ed25519.PublicKey(b), nil
+}
+
+// UpsertFiscalHost registers (or updates) an admin-verified manifest as a fiscal host.
+func (d *Core) UpsertFiscalHost(manifestID int, publicKey, membersURL string) (int, error) {
+	if _, err := ParsePublicKey(publicKey); err != nil {
+		return 0, err
+	}
+
+	var id int
+	if err := d.q.UpsertFiscalHost.Get(&id, manifestID, publicKey, membersURL); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotAdminVerified
+		}
+
+		d.log.Printf("error upserting fiscal host: %d: %v", manifestID, err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetFiscalHosts returns all fiscal hosts.
+func (d *Core) GetFiscalHosts() ([]models.FiscalHost, error) {
+	out := []models.FiscalHost{}
+	if err := d.q.GetFiscalHosts.Select(&out, 0); err != nil {
+		d.log.Printf("error fetching fiscal hosts: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetFiscalHost returns a fiscal host.
+func (d *Core) GetFiscalHost(id int) (models.FiscalHost, error) {
+	var out models.FiscalHost
+	if err := d.q.GetFiscalHosts.Get(&out, id); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching fiscal host: %d: %v", id, err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// DeleteFiscalHost deletes a fiscal host. Its members lose their delegated verification.
+func (d *Core) DeleteFiscalHost(id int) error {
+	if _, err := d.q.DeleteFiscalHost.Exec(id); err != nil {
+		d.log.Printf("error deleting fiscal host: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// SetFiscalHostMembers replaces the member manifest URLs of a fiscal host and grants
+// them delegated verification ("signed"). Removed members lose it.
+func (d *Core) SetFiscalHostMembers(id int, urls []string) error {
+	if _, err := d.q.ReplaceFiscalMembers.Exec(id, pq.Array(urls)); err != nil {
+		d.log.Printf("error setting fiscal host members: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// SetFiscalHostError records the error of the last member list refresh of a fiscal host.
+func (d *Core) SetFiscalHostError(id int, msg string) error {
+	if _, err := d.q.UpdateFiscalHostErr.Exec(id, msg); err != nil {
+		d.log.Printf("error updating fiscal host: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
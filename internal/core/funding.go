@@ -0,0 +1,204 @@
+package core
+
+import (
+	"math"
+	"slices"
+
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+)
+
+// Yearly multipliers of plan frequencies for computing yearly goals.
+var freqMultiplier = map[string]float64{
+	"one-time":    1,
+	"weekly":      52,
+	"fortnightly": 26,
+	"monthly":     12,
+	"yearly":      1,
+}
+
+// FundingGoal returns the yearly goal (sum of active plans) and the received
+// amount (income of the most recent year in the history) in the given currency.
+func FundingGoal(f v1.Funding, currency string) (float64, float64) {
+	var goal, received float64
+	for _, p := range f.Plans {
+		if p.Status != "active" || p.Currency != currency {
+			continue
+		}
+
+		if n, ok := freqMultiplier[p.Frequency]; ok {
+			goal += p.Amount * n
+		}
+	}
+
+	year := 0
+	for _, h := range f.History {
+		if h.Currency == currency && h.Year > year {
+			year = h.Year
+			received = h.Income
+		}
+	}
+
+	return goal, received
+}
+
+// FundingCurrencies returns the distinct currencies of the active plans.
+func FundingCurrencies(f v1.Funding) []string {
+	var out []string
+	for _, p := range f.Plans {
+		if p.Status == "active" && !slices.Contains(out, p.Currency) {
+			out = append(out, p.Currency)
+		}
+	}
+
+	return out
+}
+
+// MatchFunding suggests an allocation of a budget across active manifests whose
+// projects match the given licenses and tags, and whose entities match the given
+// types (all optional). Only plans in the budget's currency are considered.
+func (d *Core) MatchFunding(q models.FundingMatchQuery) (models.FundingMatch, error) {
+	out := models.FundingMatch{
+		Budget:      q.Budget,
+		Currency:    q.Currency,
+		Allocations: []models.FundingAllocation{},
+	}
+
+	var res []models.FundingCandidate
+	if err := d.q.GetFundingCandidates.Select(&res, pq.Array(q.Licenses), pq.Array(q.Tags), pq.Array(q.EntityTypes), q.Limit, q.Tenant); err != nil {
+		d.log.Printf("error fetching funding candidates: %v", err)
+		return out, err
+	}
+
+	// Compute the unmet yearly need of every candidate.
+	var needs []float64
+	for _, c := range res {
+		var f v1.Funding
+		if err := f.UnmarshalJSON(c.FundingRaw); err != nil {
+			d.log.Printf("error unmarshalling funding: %d: %v", c.ID, err)
+			continue
+		}
+
+		// Leave out deprecated plans so that funders aren't matched to discontinued ones.
+		hints := filterDeprecatedPlans(&f, c.Meta)
+
+		goal, received := FundingGoal(f, q.Currency)
+		if goal <= received {
+			continue
+		}
+
+		out.Allocations = append(out.Allocations, models.FundingAllocation{
+			ManifestID: c.ID,
+			GUID:       c.GUID,
+			Name:       c.Name,
+			Goal:       goal,
+			Received:   received,
+			Need:       goal - received,
+			Hints:      hints,
+		})
+		needs = append(needs, goal-received)
+	}
+
+	for n, a := range Allocate(q.Budget, q.MaxPerRecipient, needs) {
+		out.Allocations[n].Amount = a
+		out.Allocated += a
+	}
+
+	return out, nil
+}
+
+// filterDeprecatedPlans removes the deprecated plans, and plans whose channels are
+// all deprecated, from the funding and returns their migration hints.
+func filterDeprecatedPlans(f *v1.Funding, meta types.JSONText) []string {
+	if len(meta) == 0 {
+		return nil
+	}
+
+	var m models.ManifestMeta
+	if err := meta.Unmarshal(&m); err != nil || m.Deprecations == nil {
+		return nil
+	}
+	dep := m.Deprecations
+
+	var hints []string
+	for _, d := range dep.Channels {
+		hints = append(hints, d.Hint)
+	}
+
+	plans := make([]v1.Plan, 0, len(f.Plans))
+	for _, p := range f.Plans {
+		if d, ok := dep.Plans[p.GUID]; ok {
+			hints = append(hints, d.Hint)
+			continue
+		}
+
+		if len(p.Channels) > 0 && !slices.ContainsFunc(p.Channels, func(c string) bool {
+			_, ok := dep.Channels[c]
+			return !ok
+		}) {
+			continue
+		}
+
+		plans = append(plans, p)
+	}
+	f.Plans = plans
+	slices.Sort(hints)
+
+	return hints
+}
+
+// Allocate distributes a budget across needs in proportion to them. No allocation
+// exceeds its need or max (if max > 0). Amounts left over after a cap is hit are
+// redistributed among the rest, and whatever can't be allocated is left over.
+func Allocate(budget, max float64, needs []float64) []float64 {
+	var (
+		out  = make([]float64, len(needs))
+		caps = make([]float64, len(needs))
+		left = budget
+	)
+
+	for n, v := range needs {
+		caps[n] = v
+		if max > 0 && max < v {
+			caps[n] = max
+		}
+	}
+
+	for left > 0.005 {
+		// Total need of the recipients that haven't hit their caps.
+		var total float64
+		for n := range needs {
+			if out[n] < caps[n] {
+				total += needs[n]
+			}
+		}
+		if total == 0 {
+			break
+		}
+
+		var spent float64
+		for n := range needs {
+			if out[n] >= caps[n] {
+				continue
+			}
+
+			a := math.Min(left*needs[n]/total, caps[n]-out[n])
+			out[n] += a
+			spent += a
+		}
+
+		left -= spent
+		if spent == 0 {
+			break
+		}
+	}
+
+	// Round to cents.
+	for n := range out {
+		out[n] = math.Floor(out[n]*100) / 100
+	}
+
+	return out
+}
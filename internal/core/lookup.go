@@ -0,0 +1,50 @@
+package core
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/lib/pq"
+)
+
+// NormalizeLookupURL returns the key with which a repository or website URL is
+// matched against the URLs of listed projects and entities: the lowercased host
+// (without www.) and path, without the scheme, query, trailing slash, and .git.
+// URLs on forges are truncated to the repository, eg: github.com/org/repo/issues => github.com/org/repo.
+func NormalizeLookupURL(raw string, forgeHosts []string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	path := strings.Trim(strings.ToLower(u.Path), "/")
+
+	if slices.Contains(forgeHosts, host) {
+		parts := strings.SplitN(path, "/", 3)
+		if len(parts) < 2 || parts[1] == "" {
+			return "", false
+		}
+		path = parts[0] + "/" + parts[1]
+	}
+	path = strings.TrimSuffix(path, ".git")
+
+	if path == "" {
+		return host, true
+	}
+	return host + "/" + path, true
+}
+
+// GetFundingSummaries returns compact funding summaries of the active listings whose
+// projects or entities match the given normalized lookup URLs (NormalizeLookupURL).
+func (d *Core) GetFundingSummaries(keys []string) ([]models.FundingSummary, error) {
+	var out []models.FundingSummary
+	if err := d.q.GetFundingSummaries.Select(&out, pq.Array(keys)); err != nil {
+		d.log.Printf("error fetching funding summaries: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// RejectProjects removes the projects that failed checks (by project GUID => error)
+// from a manifest for partial acceptance and returns them as rejected projects. If
+// every project failed, the manifest can't be accepted and the first error is returned.
+func RejectProjects(m v1.Manifest, errs map[string]error) (v1.Manifest, []models.RejectedProject, error) {
+	if len(errs) == 0 {
+		return m, nil, nil
+	}
+
+	var (
+		prjs     = make([]v1.Project, 0, len(m.Projects))
+		rejected []models.RejectedProject
+		firstErr error
+	)
+	for _, p := range m.Projects {
+		err, ok := errs[p.GUID]
+		if !ok {
+			prjs = append(prjs, p)
+			continue
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+		rejected = append(rejected, models.RejectedProject{GUID: p.GUID, Name: p.Name, Reason: err.Error()})
+	}
+	if len(prjs) == 0 {
+		return m, nil, firstErr
+	}
+
+	m.Projects = prjs
+	return m, rejected, nil
+}
+
+// GetRejectedProjects returns the projects of a partially accepted manifest that
+// were not listed (from its meta), if any.
+func GetRejectedProjects(m models.ManifestData) ([]models.RejectedProject, error) {
+	if len(m.Meta) == 0 {
+		return nil, nil
+	}
+
+	var meta models.ManifestMeta
+	if err := m.Meta.Unmarshal(&meta); err != nil {
+		return nil, err
+	}
+
+	return meta.Rejected, nil
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Payment channel change types.
+const (
+	ChannelAdded    = "added"
+	ChannelRemoved  = "removed"
+	ChannelModified = "modified"
+)
+
+// paymentChannel is the part of a manifest's funding channel that payments go to.
+type paymentChannel struct {
+	GUID    string `json:"guid"`
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+// DiffChannels compares two versions of a manifest's funding.channels (JSON) and
+// returns the changes to where payments go: channels that were added or removed, and
+// channels whose type or address changed. Other changes (eg: descriptions) are ignored.
+func DiffChannels(oldJSON, newJSON []byte) ([]models.ChannelChange, error) {
+	var oldCh, newCh []paymentChannel
+	if err := unmarshalChannels(oldJSON, &oldCh); err != nil {
+		return nil, err
+	}
+	if err := unmarshalChannels(newJSON, &newCh); err != nil {
+		return nil, err
+	}
+
+	olds := make(map[string]paymentChannel, len(oldCh))
+	for _, c := range oldCh {
+		olds[c.GUID] = c
+	}
+
+	var out []models.ChannelChange
+	seen := make(map[string]bool, len(newCh))
+	for _, n := range newCh {
+		seen[n.GUID] = true
+
+		o, ok := olds[n.GUID]
+		if !ok {
+			out = append(out, models.ChannelChange{GUID: n.GUID, Change: ChannelAdded, NewType: n.Type, NewAddress: n.Address})
+			continue
+		}
+
+		if o.Type != n.Type || strings.TrimSpace(o.Address) != strings.TrimSpace(n.Address) {
+			out = append(out, models.ChannelChange{GUID: n.GUID, Change: ChannelModified,
+				OldType: o.Type, NewType: n.Type, OldAddress: o.Address, NewAddress: n.Address})
+		}
+	}
+
+	for _, o := range oldCh {
+		if !seen[o.GUID] {
+			out = append(out, models.ChannelChange{GUID: o.GUID, Change: ChannelRemoved, OldType: o.Type, OldAddress: o.Address})
+		}
+	}
+
+	return out, nil
+}
+
+// DescribeChannelChanges returns a human readable description of channel changes.
+func DescribeChannelChanges(changes []models.ChannelChange) string {
+	var b strings.Builder
+	for _, c := range changes {
+		switch c.Change {
+		case ChannelAdded:
+			fmt.Fprintf(&b, "- %s: added (%s %s)\n", c.GUID, c.NewType, c.NewAddress)
+		case ChannelRemoved:
+			fmt.Fprintf(&b, "- %s: removed (%s %s)\n", c.GUID, c.OldType, c.OldAddress)
+		default:
+			fmt.Fprintf(&b, "- %s: changed from (%s %s) to (%s %s)\n", c.GUID, c.OldType, c.OldAddress, c.NewType, c.NewAddress)
+		}
+	}
+
+	return b.String()
+}
+
+func unmarshalChannels(b []byte, out *[]paymentChannel) error {
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+// ClaimPaymentChanges marks up to N recorded payment channel changes that haven't
+// been alerted as alerted and returns them. A change is only claimed by one caller,
+// eg: one of several site instances.
+func (d *Core) ClaimPaymentChanges(limit int) ([]models.PaymentChange, error) {
+	var out []models.PaymentChange
+	if err := d.q.ClaimPaymentChanges.Select(&out, limit); err != nil {
+		d.log.Printf("error claiming payment changes: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
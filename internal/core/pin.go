@@ -0,0 +1,38 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidHash = errors.New("invalid hash. Should be a hex SHA-256 hash, optionally prefixed with sha256:")
+
+// ParseContentHash validates a pinned content hash (hex SHA-256, eg: the output of
+// sha256sum, optionally prefixed with sha256:) and returns it in lowercase hex.
+func ParseContentHash(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "sha256:")
+
+	if b, err := hex.DecodeString(s); err != nil || len(b) != 32 {
+		return "", ErrInvalidHash
+	}
+
+	return s, nil
+}
+
+// SetManifestPin pins a manifest (by ID, or URL if the ID is 0) to the SHA-256 hash
+// of its contents. Crawls that fetch any other content are rejected. An empty hash unpins it.
+func (d *Core) SetManifestPin(id int, url, hash string) error {
+	res, err := d.q.UpdateManifestPin.Exec(id, url, hash)
+	if err != nil {
+		d.log.Printf("error updating manifest pin: %d: %s: %v", id, url, err)
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Prefix of manifest preview tokens.
+const previewTokenPrefix = "pv_"
+
+// newPreviewToken generates a random preview token.
+func newPreviewToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return previewTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// GetPreviewManifest returns a manifest that's in preview by its preview token.
+func (d *Core) GetPreviewManifest(token string) (models.ManifestData, error) {
+	out, err := d.getManifests(0, "", 0, 1, HashAPIKey(token))
+	if err != nil || len(out) == 0 {
+		return models.ManifestData{}, ErrNotFound
+	}
+
+	return out[0], nil
+}
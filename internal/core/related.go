@@ -0,0 +1,149 @@
+package core
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/lib/pq"
+)
+
+// Max number of related projects computed for every project.
+const numRelated = 10
+
+// Weights of the similarity signals. Shared tags (Jaccard) is the primary signal.
+const (
+	weightTags     = 1.0
+	weightCategory = 0.25
+	weightLicense  = 0.1
+	weightType     = 0.05
+)
+
+type relatedItem struct {
+	ID         int            `db:"id"`
+	ManifestID int            `db:"manifest_id"`
+	Tags       pq.StringArray `db:"tags"`
+	Licenses   pq.StringArray `db:"licenses"`
+	EntityType string         `db:"entity_type"`
+
+	cats []string
+}
+
+type relatedScore struct {
+	id    int
+	score float64
+}
+
+// UpdateRelatedProjects computes the most similar projects of every active project
+// based on shared tags, categories (ecosystems), licenses, and entity types, and
+// replaces the stored recommendations. Projects of the same manifest are excluded.
+// It returns the number of projects processed.
+func (d *Core) UpdateRelatedProjects() (int, error) {
+	var items []relatedItem
+	if err := d.q.GetProjectsRelated.Select(&items); err != nil {
+		d.log.Printf("error fetching projects for related: %v", err)
+		return 0, err
+	}
+
+	cats, err := d.GetTagCategoryMap()
+	if err != nil {
+		return 0, err
+	}
+
+	var projIDs, relIDs []int64
+	var scores []float64
+	for n, r := range computeRelated(items, cats) {
+		for _, s := range r {
+			projIDs = append(projIDs, int64(items[n].ID))
+			relIDs = append(relIDs, int64(s.id))
+			scores = append(scores, s.score)
+		}
+	}
+
+	if _, err := d.q.ReplaceRelated.Exec(pq.Array(projIDs), pq.Array(relIDs), pq.Array(scores)); err != nil {
+		d.log.Printf("error saving related projects: %v", err)
+		return 0, err
+	}
+
+	return len(items), nil
+}
+
+// GetRelatedProjects returns the related projects of a project, only among the
+// manifests listed on a tenant, if one is given.
+func (d *Core) GetRelatedProjects(manifestGUID, projectGUID string, limit int, tenant string) ([]models.RelatedProject, error) {
+	out := []models.RelatedProject{}
+	if err := d.q.GetRelatedProjects.Select(&out, manifestGUID, projectGUID, limit, tenant); err != nil {
+		d.log.Printf("error fetching related projects: %s/%s: %v", manifestGUID, projectGUID, err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// computeRelated returns the top related projects for every item (by index).
+// Only projects that share at least one tag are considered as candidates.
+func computeRelated(items []relatedItem, cats map[string]string) [][]relatedScore {
+	// Inverted index of tag => item indices.
+	idx := make(map[string][]int)
+	for n := range items {
+		items[n].cats = TagsToCategories(items[n].Tags, cats)
+		for _, t := range items[n].Tags {
+			idx[t] = append(idx[t], n)
+		}
+	}
+
+	out := make([][]relatedScore, len(items))
+	for n, a := range items {
+		// Count the shared tags with every candidate.
+		shared := make(map[int]int)
+		for _, t := range a.Tags {
+			for _, m := range idx[t] {
+				if items[m].ManifestID != a.ManifestID {
+					shared[m]++
+				}
+			}
+		}
+
+		res := make([]relatedScore, 0, len(shared))
+		for m, num := range shared {
+			b := items[m]
+
+			score := weightTags * float64(num) / float64(len(a.Tags)+len(b.Tags)-num)
+			if overlaps(a.cats, b.cats) {
+				score += weightCategory
+			}
+			if overlaps(a.Licenses, b.Licenses) {
+				score += weightLicense
+			}
+			if a.EntityType == b.EntityType {
+				score += weightType
+			}
+
+			res = append(res, relatedScore{id: b.ID, score: score})
+		}
+
+		sort.Slice(res, func(i, j int) bool {
+			if res[i].score == res[j].score {
+				return res[i].id < res[j].id
+			}
+			return res[i].score > res[j].score
+		})
+		if len(res) > numRelated {
+			res = res[:numRelated]
+		}
+
+		out[n] = res
+	}
+
+	return out
+}
+
+func overlaps(a, b []string) bool {
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/floss-fund/go-funding-json/common"
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/models"
+)
+
+const maxRepositories = 10
+
+// Roles of the additional repositories of a project.
+var repositoryRoles = []string{models.RepoRoleMirror, models.RepoRoleDocs, models.RepoRoleOther}
+
+// ParseRepositories parses the optional "repositories" field of the projects in a
+// manifest body that lists the repositories of a project other than its primary
+// repositoryUrl: {"projects": [{"guid": "..", "repositories": [{"url": "..", "role": "mirror"}]}]}.
+// It returns a map of project GUID => repositories.
+func ParseRepositories(b []byte) (map[string][]models.Repository, error) {
+	var raw struct {
+		Projects []struct {
+			GUID          string `json:"guid"`
+			RepositoryURL struct {
+				URL string `json:"url"`
+			} `json:"repositoryUrl"`
+			Repositories []models.Repository `json:"repositories"`
+		} `json:"projects"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing JSON body: %v", err)
+	}
+
+	out := make(map[string][]models.Repository)
+	for n, p := range raw.Projects {
+		if len(p.Repositories) == 0 {
+			continue
+		}
+		if len(p.Repositories) > maxRepositories {
+			return nil, fmt.Errorf("projects[%d].repositories: should have at most %d items", n, maxRepositories)
+		}
+
+		seen := map[string]bool{repoKey(p.RepositoryURL.URL): true}
+		for i, r := range p.Repositories {
+			tag := fmt.Sprintf("projects[%d].repositories[%d]", n, i)
+
+			r.URL = strings.TrimSpace(r.URL)
+			if _, err := common.IsURL(tag+".url", r.URL, maxURLLen); err != nil {
+				return nil, err
+			}
+			if seen[repoKey(r.URL)] {
+				return nil, fmt.Errorf("%s.url: duplicate of the project's repositoryUrl or another repository", tag)
+			}
+			seen[repoKey(r.URL)] = true
+
+			r.WellKnown = strings.TrimSpace(r.WellKnown)
+			if r.WellKnown != "" {
+				if _, err := common.IsURL(tag+".wellKnown", r.WellKnown, maxURLLen); err != nil {
+					return nil, err
+				}
+			}
+
+			if r.Role == "" {
+				r.Role = models.RepoRoleOther
+			}
+			if !slices.Contains(repositoryRoles, r.Role) {
+				return nil, fmt.Errorf("%s.role: should be one of %s", tag, strings.Join(repositoryRoles, ", "))
+			}
+
+			out[p.GUID] = append(out[p.GUID], r)
+		}
+	}
+
+	return out, nil
+}
+
+// GetRepositories returns the additional repositories of a project in a manifest (from its meta), if any.
+func GetRepositories(m models.ManifestData, projectGUID string) ([]models.Repository, error) {
+	if len(m.Meta) == 0 {
+		return nil, nil
+	}
+
+	var meta models.ManifestMeta
+	if err := m.Meta.Unmarshal(&meta); err != nil {
+		return nil, err
+	}
+
+	return meta.Repositories[projectGUID], nil
+}
+
+// RepositoryURL returns an additional repository of a project as a URL for provenance
+// checks. As with the primary repositoryUrl, a repository that's not on the manifest's
+// host and path requires a wellKnown URL (ending in wellKnownURI) on the repository's host.
+func RepositoryURL(tag string, r models.Repository, manifest *url.URL, wellKnownURI string) (v1.URL, error) {
+	u, err := common.IsURL(tag+".url", r.URL, maxURLLen)
+	if err != nil {
+		return v1.URL{}, err
+	}
+	out := v1.URL{URL: r.URL, URLobj: u}
+
+	// A repository under the manifest's own host and path needs no proof.
+	dir := path.Dir(manifest.Path)
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	if u.Host == manifest.Host && strings.HasPrefix(u.Path, dir) {
+		return out, nil
+	}
+
+	if r.WellKnown == "" {
+		return out, fmt.Errorf("%s.wellKnown: required as the url is not on the manifest's host and path", tag)
+	}
+	w, err := common.IsURL(tag+".wellKnown", r.WellKnown, maxURLLen)
+	if err != nil {
+		return out, err
+	}
+	if w.Host != u.Host || !strings.HasSuffix(w.Path, wellKnownURI) {
+		return out, fmt.Errorf("%s.wellKnown: should be on the url's host and end in %s", tag, wellKnownURI)
+	}
+	out.WellKnown = r.WellKnown
+	out.WellKnownObj = w
+
+	return out, nil
+}
+
+// repoKey returns the comparable form of a repository URL, ignoring the scheme,
+// case, and trailing slashes and .git.
+func repoKey(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if _, s, ok := strings.Cut(u, "://"); ok {
+		u = s
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+}
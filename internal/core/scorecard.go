@@ -0,0 +1,132 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+)
+
+// Hosts supported by the OpenSSF Scorecard API.
+var scorecardHosts = []string{"github.com", "gitlab.com"}
+
+// ScorecardPath returns the Scorecard API path (eg: /projects/github.com/org/repo)
+// for a repository URL. It returns false if the repository's host isn't supported.
+func ScorecardPath(repo string) (string, bool) {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return "", false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+
+	for _, h := range scorecardHosts {
+		if host == h {
+			return "/projects/" + host + "/" + parts[0] + "/" + strings.TrimSuffix(parts[1], ".git"), true
+		}
+	}
+
+	return "", false
+}
+
+// ParseScorecard parses a Scorecard API response into a scorecard.
+func ParseScorecard(repo string, b []byte) (models.Scorecard, error) {
+	var raw struct {
+		Date   string          `json:"date"`
+		Score  *float64        `json:"score"`
+		Checks json.RawMessage `json:"checks"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return models.Scorecard{}, fmt.Errorf("error parsing scorecard: %v", err)
+	}
+	if raw.Score == nil {
+		return models.Scorecard{}, fmt.Errorf("scorecard has no score")
+	}
+
+	out := models.Scorecard{
+		RepositoryURL: repo,
+		Score:         raw.Score,
+		Checks:        types.JSONText(raw.Checks),
+	}
+	if len(out.Checks) == 0 {
+		out.Checks = types.JSONText("[]")
+	}
+
+	// The date is either a date or a timestamp depending on the API version.
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, raw.Date); err == nil {
+			out.ScoredAt = &t
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// GetReposForScorecard returns the repository URLs that are due for scoring.
+func (d *Core) GetReposForScorecard(age string, limit int) ([]string, error) {
+	var out []string
+	if err := d.q.GetScorecardRepos.Select(&out, age, limit); err != nil {
+		d.log.Printf("error fetching repos for scorecard: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// UpsertScorecard inserts or updates the scorecard of a repository. If lastErr
+// is set, the last known score of the repository is retained.
+func (d *Core) UpsertScorecard(s models.Scorecard, lastErr string) error {
+	checks := s.Checks
+	if len(checks) == 0 {
+		checks = types.JSONText("[]")
+	}
+
+	if _, err := d.q.UpsertScorecard.Exec(s.RepositoryURL, s.Score, checks, s.ScoredAt, lastErr); err != nil {
+		d.log.Printf("error upserting scorecard: %s: %v", s.RepositoryURL, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetScorecards returns the scorecards of the given repository URLs.
+func (d *Core) GetScorecards(repos []string) ([]models.Scorecard, error) {
+	out := []models.Scorecard{}
+	if len(repos) == 0 {
+		return out, nil
+	}
+
+	if err := d.q.GetScorecards.Select(&out, pq.Array(repos)); err != nil {
+		d.log.Printf("error fetching scorecards: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetScorecardMap returns a map of repository URL => score of the given repositories.
+func (d *Core) GetScorecardMap(repos []string) (map[string]float64, error) {
+	res, err := d.GetScorecards(repos)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]float64, len(res))
+	for _, s := range res {
+		if s.Score != nil {
+			out[s.RepositoryURL] = *s.Score
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/floss-fund/go-funding-json/common"
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Max number of security contacts in a manifest.
+const maxSecurityContacts = 5
+
+// ParseSecurityContact parses the optional security.txt style "security" field of
+// a manifest body: {"security": {"contact": ["mailto:..", "https://.."], "policy":
+// "https://..", "encryption": "https://.."}}. Contacts on the manifest's or the entity
+// webpage's domain (both of which have established provenance) are marked as verified.
+// It returns nil if the field doesn't exist.
+func ParseSecurityContact(b []byte, manifest, webpage *url.URL) (*models.SecurityContact, error) {
+	var raw struct {
+		Security *struct {
+			Contact    []string `json:"contact"`
+			Policy     string   `json:"policy"`
+			Encryption string   `json:"encryption"`
+		} `json:"security"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing JSON body: %v", err)
+	}
+
+	s := raw.Security
+	if s == nil {
+		return nil, nil
+	}
+
+	if err := common.InRange[int]("security.contact", len(s.Contact), 1, maxSecurityContacts); err != nil {
+		return nil, err
+	}
+
+	out := &models.SecurityContact{}
+	for n, c := range s.Contact {
+		tag := fmt.Sprintf("security.contact[%d]", n)
+		c = strings.TrimSpace(c)
+
+		// E-mail.
+		if em, ok := strings.CutPrefix(c, "mailto:"); ok || !strings.Contains(c, "://") {
+			if err := common.IsEmail(tag, em, 250); err != nil {
+				return nil, err
+			}
+
+			_, domain, _ := strings.Cut(em, "@")
+			out.Contacts = append(out.Contacts, models.SecurityContactItem{
+				Type:     models.SecurityContactEmail,
+				Value:    em,
+				Verified: isVerifiedHost(domain, "/", manifest, webpage),
+			})
+			continue
+		}
+
+		// URL.
+		u, err := parseHTTPS(tag, c)
+		if err != nil {
+			return nil, err
+		}
+		out.Contacts = append(out.Contacts, models.SecurityContactItem{
+			Type:     models.SecurityContactURL,
+			Value:    u.String(),
+			Verified: isVerifiedHost(u.Host, u.Path, manifest, webpage),
+		})
+	}
+
+	if s.Policy != "" {
+		u, err := parseHTTPS("security.policy", s.Policy)
+		if err != nil {
+			return nil, err
+		}
+		out.Policy = u.String()
+	}
+
+	if s.Encryption != "" {
+		u, err := parseHTTPS("security.encryption", s.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		out.Encryption = u.String()
+	}
+
+	return out, nil
+}
+
+// GetSecurityContact returns the security contact of a manifest (from its meta), if any.
+func GetSecurityContact(m models.ManifestData) (*models.SecurityContact, error) {
+	if len(m.Meta) == 0 {
+		return nil, nil
+	}
+
+	var meta models.ManifestMeta
+	if err := m.Meta.Unmarshal(&meta); err != nil {
+		return nil, err
+	}
+
+	return meta.Security, nil
+}
+
+func parseHTTPS(tag, s string) (*url.URL, error) {
+	u, err := common.IsURL(tag, s, maxURLLen)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, errors.New("`" + tag + "` should be an https URL")
+	}
+
+	return u, nil
+}
+
+// isVerifiedHost checks whether a contact's host and path are on the manifest's or
+// the webpage's domain. On a shared host (eg: github.com/user), the contact should be
+// under the same path as the manifest or the webpage.
+func isVerifiedHost(host, p string, manifest, webpage *url.URL) bool {
+	var dirs []string
+	if manifest != nil && strings.EqualFold(manifest.Host, host) {
+		dirs = append(dirs, path.Dir(manifest.Path))
+	}
+	if webpage != nil && strings.EqualFold(webpage.Host, host) {
+		dirs = append(dirs, strings.TrimSuffix(webpage.Path, "/"))
+	}
+
+	for _, d := range dirs {
+		if d == "" || d == "/" || p == d || strings.HasPrefix(p, d+"/") {
+			return true
+		}
+	}
+
+	return false
+}
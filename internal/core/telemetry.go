@@ -0,0 +1,58 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// GetInstanceID returns the instance's random ID that it's identified by in telemetry
+// reports, generating it on first use.
+func (d *Core) GetInstanceID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	var id string
+	if err := d.q.GetInstanceID.Get(&id, hex.EncodeToString(b)); err != nil {
+		d.log.Printf("error fetching instance ID: %v", err)
+		return "", err
+	}
+
+	return id, nil
+}
+
+// GetInstanceStats returns the number of active listings and their projects.
+func (d *Core) GetInstanceStats() (models.TelemetryReport, error) {
+	var out models.TelemetryReport
+	if err := d.q.GetInstanceStats.Get(&out); err != nil {
+		d.log.Printf("error fetching instance stats: %v", err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// UpsertTelemetryReport saves the latest report of an instance.
+func (d *Core) UpsertTelemetryReport(r models.TelemetryReport) error {
+	if _, err := d.q.UpsertTelemetry.Exec(r.InstanceID, r.Version, r.Manifests, r.Projects); err != nil {
+		d.log.Printf("error saving telemetry report: %s: %v", r.InstanceID, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetTelemetryVersions returns the footprint of the instances that have reported
+// in the given interval (eg: 30 DAYS) by version.
+func (d *Core) GetTelemetryVersions(interval string) ([]models.TelemetryVersion, error) {
+	out := []models.TelemetryVersion{}
+	if err := d.q.GetTelemetryVersions.Select(&out, interval); err != nil {
+		d.log.Printf("error fetching telemetry reports: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/lib/pq"
+)
+
+// AddTenantManifest lists a manifest on a tenant sub-portal.
+func (d *Core) AddTenantManifest(tenant string, id int) error {
+	var ok bool
+	if err := d.q.AddTenantManifest.Get(&ok, tenant, id); err != nil {
+		d.log.Printf("error adding tenant manifest: %s: %d: %v", tenant, id, err)
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteTenantManifest removes a manifest from the listings of a tenant sub-portal.
+func (d *Core) DeleteTenantManifest(tenant string, id int) error {
+	res, err := d.q.DeleteTenantManifest.Exec(tenant, id)
+	if err != nil {
+		d.log.Printf("error deleting tenant manifest: %s: %d: %v", tenant, id, err)
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetTenantManifests returns up to limit manifests listed on a tenant sub-portal after offsetID.
+func (d *Core) GetTenantManifests(tenant string, offsetID, limit int) ([]models.TenantManifest, error) {
+	out := []models.TenantManifest{}
+	if err := d.q.GetTenantManifests.Select(&out, tenant, offsetID, limit); err != nil {
+		d.log.Printf("error fetching tenant manifests: %s: %v", tenant, err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetManifestTenants returns the slugs of the tenant sub-portals a manifest is listed on.
+func (d *Core) GetManifestTenants(id int) ([]string, error) {
+	var out pq.StringArray
+	if err := d.q.GetManifestTenants.Get(&out, id); err != nil {
+		d.log.Printf("error fetching manifest tenants: %d: %v", id, err)
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/lib/pq"
+)
+
+// Kinds of translated descriptions.
+const (
+	TranslationEntity  = "entity"
+	TranslationProject = "project"
+)
+
+// GetForTranslation returns the descriptions of active manifests that are due for
+// translation (new or changed since the last translation) to the given locales.
+func (d *Core) GetForTranslation(locales []string, limit int) ([]models.TranslationJob, error) {
+	var out []models.TranslationJob
+	if err := d.q.GetForTranslation.Select(&out, pq.Array(locales), limit); err != nil {
+		d.log.Printf("error fetching descriptions for translation: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// UpsertTranslation saves the machine translation of a description.
+func (d *Core) UpsertTranslation(j models.TranslationJob, text string) error {
+	if _, err := d.q.UpsertTranslation.Exec(j.ManifestID, j.Kind, j.GUID, j.Locale, text, j.Description); err != nil {
+		d.log.Printf("error upserting translation: %d: %s: %s: %v", j.ManifestID, j.GUID, j.Locale, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetTranslations returns the machine translations of a manifest's descriptions,
+// optionally of a single locale.
+func (d *Core) GetTranslations(manifestID int, locale string) ([]models.Translation, error) {
+	out := []models.Translation{}
+	if err := d.q.GetTranslations.Select(&out, manifestID, locale); err != nil {
+		d.log.Printf("error fetching translations: %d: %v", manifestID, err)
+		return nil, err
+	}
+
+	for n := range out {
+		out[n].Machine = true
+	}
+
+	return out, nil
+}
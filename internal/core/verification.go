@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+)
+
+// Verification (trust) levels of manifests in increasing order of trust.
+const (
+	// Nothing about the manifest has been verified.
+	VerificationUnverified = "unverified"
+
+	// All URLs in the manifest are on the manifest's domain or have
+	// been verified with .well-known provenance checks.
+	VerificationProvenance = "provenance-verified"
+
+	// The manifest is hosted on a code forge (eg: GitHub) in the same
+	// user or org namespace as all its projects' repositories.
+	VerificationForge = "forge-verified"
+
+	// The manifest has been vouched for by a trusted signer.
+	VerificationSigned = "signed"
+
+	// The manifest has been manually verified by an admin.
+	VerificationAdmin = "admin-verified"
+)
+
+// VerificationLevels is the list of verification levels in increasing order of trust.
+var VerificationLevels = []string{
+	VerificationUnverified,
+	VerificationProvenance,
+	VerificationForge,
+	VerificationSigned,
+	VerificationAdmin,
+}
+
+var ErrInvalidVerification = errors.New("invalid verification level")
+
+// IsVerificationLevel checks whether the given level is a valid verification level.
+func IsVerificationLevel(level string) bool {
+	return slices.Contains(VerificationLevels, level)
+}
+
+// VerificationLevel computes the verification level of a manifest that has been
+// parsed and validated. provenanceChecked indicates whether the .well-known
+// provenance of its URLs was checked. forgeHosts is the list of code forge hosts.
+func VerificationLevel(m v1.Manifest, provenanceChecked bool, forgeHosts []string) string {
+	// URLs on other domains (which have .well-known URLs) were not checked.
+	if !provenanceChecked && hasWellKnown(m) {
+		return VerificationUnverified
+	}
+
+	if isForgeVerified(m, forgeHosts) {
+		return VerificationForge
+	}
+
+	return VerificationProvenance
+}
+
+// isForgeVerified checks whether the manifest is on a code forge in the same user/org
+// namespace as all its project repositories, ie, the owner of the repositories published it.
+func isForgeVerified(m v1.Manifest, forgeHosts []string) bool {
+	host, owner := forgeOwner(m.URL.URLobj, forgeHosts)
+	if owner == "" || len(m.Projects) == 0 {
+		return false
+	}
+
+	for _, p := range m.Projects {
+		h, o := forgeOwner(p.RepositoryURL.URLobj, forgeHosts)
+		if h != host || o != owner {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasWellKnown checks whether any of the URLs in the manifest require
+// a .well-known provenance check.
+func hasWellKnown(m v1.Manifest) bool {
+	if m.Entity.WebpageURL.WellKnown != "" {
+		return true
+	}
+
+	for _, p := range m.Projects {
+		if p.WebpageURL.WellKnown != "" || p.RepositoryURL.WellKnown != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forgeOwner returns the forge host and the owner (first path segment) of a URL.
+func forgeOwner(u *url.URL, forgeHosts []string) (string, string) {
+	if u == nil {
+		return "", ""
+	}
+
+	host := strings.ToLower(u.Host)
+	if !slices.Contains(forgeHosts, host) {
+		return "", ""
+	}
+
+	owner, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	return host, strings.ToLower(owner)
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/lib/pq"
+)
+
+// Prefix of webhook management tokens.
+const webhookTokenPrefix = "wh_"
+
+// WebhookEvents are the events that manifest webhooks can subscribe to.
+var WebhookEvents = []string{models.WebhookEventUpdate, models.WebhookEventDelist, models.WebhookEventProvenanceLost, models.WebhookEventPaymentChange}
+
+// SignWebhook returns the HMAC-SHA256 (hex) signature of a webhook payload.
+func SignWebhook(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CreateWebhook registers an unconfirmed webhook for a manifest. It returns the
+// webhook and its plain text management token, which is not stored.
+func (d *Core) CreateWebhook(manifestID int, url string, events []string) (models.Webhook, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		d.log.Printf("error generating webhook secret: %v", err)
+		return models.Webhook{}, "", err
+	}
+	secret := hex.EncodeToString(b)
+
+	if _, err := rand.Read(b); err != nil {
+		d.log.Printf("error generating webhook token: %v", err)
+		return models.Webhook{}, "", err
+	}
+	token := webhookTokenPrefix + hex.EncodeToString(b)
+
+	var out models.Webhook
+	if err := d.q.InsertWebhook.Get(&out, manifestID, url, pq.Array(events), secret, HashAPIKey(token)); err != nil {
+		d.log.Printf("error creating webhook: %d: %v", manifestID, err)
+		return models.Webhook{}, "", err
+	}
+
+	return out, token, nil
+}
+
+// CountWebhooks returns the number of webhooks registered for a manifest.
+func (d *Core) CountWebhooks(manifestID int) (int, error) {
+	var n int
+	if err := d.q.CountWebhooks.Get(&n, manifestID); err != nil {
+		d.log.Printf("error counting webhooks: %d: %v", manifestID, err)
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// GetWebhook returns a webhook by its management token.
+func (d *Core) GetWebhook(token string) (models.Webhook, error) {
+	var out models.Webhook
+	if err := d.q.GetWebhook.Get(&out, HashAPIKey(token)); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error fetching webhook: %v", err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// ConfirmWebhook confirms (and re-enables) a webhook by its management token and
+// returns it along with its signing secret.
+func (d *Core) ConfirmWebhook(token string) (models.Webhook, error) {
+	var out models.Webhook
+	if err := d.q.ConfirmWebhook.Get(&out, HashAPIKey(token)); err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+
+		d.log.Printf("error confirming webhook: %v", err)
+		return out, err
+	}
+
+	return out, nil
+}
+
+// DeleteWebhook deletes a webhook by its management token.
+func (d *Core) DeleteWebhook(token string) error {
+	res, err := d.q.DeleteWebhook.Exec(HashAPIKey(token))
+	if err != nil {
+		d.log.Printf("error deleting webhook: %v", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetEventWebhooks returns the confirmed and enabled webhooks of a manifest
+// that are subscribed to an event.
+func (d *Core) GetEventWebhooks(manifestID int, event string) ([]models.Webhook, error) {
+	out := []models.Webhook{}
+	if err := d.q.GetEventWebhooks.Select(&out, manifestID, event); err != nil {
+		d.log.Printf("error fetching webhooks: %d: %v", manifestID, err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// UpdateWebhookDelivery records the result of a delivery. A webhook is disabled
+// after maxFailures consecutive failed deliveries.
+func (d *Core) UpdateWebhookDelivery(id, status int, ok bool, maxFailures int) error {
+	if _, err := d.q.UpdateWebhookDeliv.Exec(id, status, ok, maxFailures); err != nil {
+		d.log.Printf("error updating webhook delivery: %d: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetProvenanceErrors returns the provenance crawl errors of manifests after the given cursor (error ID).
+func (d *Core) GetProvenanceErrors(since int64, limit int) ([]models.ProvenanceError, error) {
+	out := []models.ProvenanceError{}
+	if err := d.q.GetProvenanceErrors.Select(&out, since, limit); err != nil {
+		d.log.Printf("error fetching provenance errors: %d: %v", since, err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetLastCrawlErrorID returns the cursor (ID) of the most recent crawl error.
+func (d *Core) GetLastCrawlErrorID() (int64, error) {
+	var id int64
+	if err := d.q.GetLastCrawlErrorID.Get(&id); err != nil {
+		d.log.Printf("error fetching last crawl error ID: %v", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetCursor returns a stored cursor (eg: the ID of the last change processed by a
+// poller), creating it with init if it doesn't exist.
+func (d *Core) GetCursor(key string, init int64) (int64, error) {
+	var out int64
+	if err := d.q.GetCursor.Get(&out, key, init); err != nil {
+		d.log.Printf("error fetching cursor: %s: %v", key, err)
+		return 0, err
+	}
+
+	return out, nil
+}
+
+// ClaimCursor advances a stored cursor from cur to next. It returns false if the
+// cursor has been advanced in the meantime, eg: by another instance, which then
+// owns the items in between.
+func (d *Core) ClaimCursor(key string, cur, next int64) (bool, error) {
+	res, err := d.q.ClaimCursor.Exec(key, cur, next)
+	if err != nil {
+		d.log.Printf("error claiming cursor: %s: %v", key, err)
+		return false, err
+	}
+
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,36 @@
+package crawl
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff is the exponential backoff between the retries of a fetch. The n-th retry
+// (from 1) waits Base * Multiplier^(n-1), capped at Max, and randomized by +/- Jitter
+// (a fraction of the wait) so that retries against a host don't arrive in bursts.
+type Backoff struct {
+	Base       time.Duration `json:"base"`
+	Multiplier float64       `json:"multiplier"`
+	Jitter     float64       `json:"jitter"`
+	Max        time.Duration `json:"max"`
+}
+
+// wait returns the wait before the n-th retry.
+func (b Backoff) wait(n int) time.Duration {
+	d := float64(b.Base)
+	for i := 1; i < n && b.Multiplier > 1; i++ {
+		d *= b.Multiplier
+		if b.Max > 0 && d >= float64(b.Max) {
+			break
+		}
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(d)
+}
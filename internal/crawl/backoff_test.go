@@ -0,0 +1,26 @@
+package crawl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Base: time.Second, Multiplier: 2, Max: time.Second * 5}
+	assert.Equal(t, time.Second, b.wait(1))
+	assert.Equal(t, time.Second*2, b.wait(2))
+	assert.Equal(t, time.Second*4, b.wait(3))
+	assert.Equal(t, time.Second*5, b.wait(4))
+	assert.Equal(t, time.Second*5, b.wait(100))
+
+	// Fixed wait.
+	assert.Equal(t, time.Second, Backoff{Base: time.Second}.wait(3))
+
+	b.Jitter = 0.5
+	for n := 0; n < 100; n++ {
+		d := b.wait(2)
+		assert.True(t, d >= time.Second && d <= time.Second*3, d)
+	}
+}
@@ -0,0 +1,96 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// latencyFetcher delays the responses of a Fetcher to simulate slow hosts.
+type latencyFetcher struct {
+	f       Fetcher
+	latency func(host string) time.Duration
+}
+
+func (l latencyFetcher) Fetch(ctx context.Context, r Request) (*Response, error) {
+	select {
+	case <-time.After(l.latency(r.URL.Hostname())):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return l.f.Fetch(ctx, r)
+}
+
+// benchManifests returns n synthetic manifest URLs spread across hosts.
+func benchManifests(n, hosts int) (map[string][]byte, []string) {
+	var (
+		files = make(map[string][]byte, n)
+		urls  = make([]string, 0, n)
+	)
+	for i := 0; i < n; i++ {
+		u := fmt.Sprintf("https://host%d.example.com/%d/funding.json", i%hosts, i)
+		files[u] = []byte("{}")
+		urls = append(urls, u)
+	}
+
+	return files, urls
+}
+
+func BenchmarkFetchAll(b *testing.B) {
+	f := func(hosts int, latency time.Duration, hostRPS float64, hostConc int) {
+		b.Run(fmt.Sprintf("hosts=%d,latency=%s,rps=%.0f,conc=%d", hosts, latency, hostRPS, hostConc), func(b *testing.B) {
+			files, urls := benchManifests(200, hosts)
+
+			c := newTestCrawl(latencyFetcher{
+				f:       NewMemFetcher(files),
+				latency: func(string) time.Duration { return latency },
+			})
+			c.sc = testSchema{}
+			c.hostLimits = newHostLimiter(hostRPS, hostConc)
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				for r := range c.FetchAll(context.Background(), urls, 32) {
+					if r.Err != nil {
+						b.Fatal(r.Err)
+					}
+				}
+			}
+		})
+	}
+
+	f(1, 0, 0, 0)
+	f(20, time.Millisecond*2, 0, 0)
+	f(20, time.Millisecond*2, 0, 4)
+	f(200, time.Millisecond*2, 0, 4)
+}
+
+func BenchmarkParseRobots(b *testing.B) {
+	var s strings.Builder
+	s.WriteString("User-agent: *\n")
+	for n := 0; n < 500; n++ {
+		fmt.Fprintf(&s, "Disallow: /private/%d/*.json$\nAllow: /public/%d/\n", n, n)
+	}
+	body := []byte(s.String())
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r := parseRobots(body, "funding-manifest-bot/1.0")
+		r.allowed("/public/499/funding.json")
+	}
+}
+
+func BenchmarkHostLimiter(b *testing.B) {
+	l := newHostLimiter(0, 4)
+
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			done, _ := l.acquire(context.Background(), fmt.Sprintf("host%d.com", n%100))
+			done()
+			n++
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package crawl
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BreakerState is the circuit breaker state of a host.
+type BreakerState struct {
+	Host string `json:"host"`
+
+	// Consecutive failed requests to the host.
+	Failures int `json:"failures"`
+
+	// Open is true if requests to the host are short-circuited until Until.
+	// After it, one trial request is let through (half-open) which closes
+	// the circuit if it succeeds and opens it again if it fails.
+	Open  bool      `json:"open"`
+	Until time.Time `json:"until"`
+}
+
+// CircuitOpenError is returned for requests to a host whose circuit breaker is open.
+type CircuitOpenError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("error: %s is failing. Requests are paused until %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+// breaker is a per-host (hostname) circuit breaker that trips after a number of
+// consecutive failed requests (connection errors, timeouts, 5xx) to a host, so
+// that manifests on a host that's down don't all burn their retries.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	hosts map[string]*hostBreaker
+	mu    sync.Mutex
+}
+
+type hostBreaker struct {
+	failures int
+	until    time.Time
+	trial    bool
+}
+
+// newBreaker returns a breaker. threshold <= 0 disables it.
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*hostBreaker),
+	}
+}
+
+// allow returns nil if a request can be made to a host. Once the cooldown of an
+// open circuit is over, only one (trial) request is allowed until it's recorded
+// or released. The bool indicates whether the request is the trial.
+func (b *breaker) allow(host string) (bool, error) {
+	if b.threshold <= 0 {
+		return false, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h, ok := b.hosts[host]
+	if !ok || h.until.IsZero() {
+		return false, nil
+	}
+
+	if time.Now().Before(h.until) || h.trial {
+		return false, &CircuitOpenError{Host: host, Until: h.until}
+	}
+	h.trial = true
+
+	return true, nil
+}
+
+// release lets another trial request through to a half-open host if the trial
+// ended without an outcome being recorded (eg: cancelled).
+func (b *breaker) release(host string) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if h, ok := b.hosts[host]; ok {
+		h.trial = false
+	}
+}
+
+// record records the outcome of a request to a host.
+func (b *breaker) record(host string, failed bool) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		delete(b.hosts, host)
+		return
+	}
+
+	h, ok := b.hosts[host]
+	if !ok {
+		h = &hostBreaker{}
+		b.hosts[host] = h
+	}
+
+	h.failures++
+	h.trial = false
+	if h.failures >= b.threshold {
+		h.until = time.Now().Add(b.cooldown)
+	}
+}
+
+// state returns the state of hosts with failures.
+func (b *breaker) state() []BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	out := make([]BreakerState, 0, len(b.hosts))
+	for host, h := range b.hosts {
+		out = append(out, BreakerState{
+			Host:     host,
+			Failures: h.failures,
+			Open:     !h.until.IsZero() && (now.Before(h.until) || h.trial),
+			Until:    h.until,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+
+	return out
+}
+
+// BreakerState returns the circuit breaker state of hosts that have recently failed.
+func (c *Crawl) BreakerState() []BreakerState {
+	return c.breaker.state()
+}
@@ -0,0 +1,133 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	b := newBreaker(2, 50*time.Millisecond)
+	allow := func(host string) error {
+		_, err := b.allow(host)
+		return err
+	}
+
+	assert.NoError(t, allow("a"))
+	b.record("a", true)
+	assert.NoError(t, allow("a"))
+	b.record("a", true)
+
+	// Tripped.
+	var ce *CircuitOpenError
+	assert.True(t, errors.As(allow("a"), &ce))
+	assert.NoError(t, allow("b"))
+	assert.Equal(t, []BreakerState{{Host: "a", Failures: 2, Open: true, Until: ce.Until}}, b.state())
+
+	// Half-open after the cooldown lets only one trial request through.
+	time.Sleep(60 * time.Millisecond)
+	trial, err := b.allow("a")
+	assert.NoError(t, err)
+	assert.True(t, trial)
+	assert.Error(t, allow("a"))
+
+	// A released trial lets another one through.
+	b.release("a")
+	assert.NoError(t, allow("a"))
+
+	// A failed trial opens it again and a successful one closes it.
+	b.record("a", true)
+	assert.Error(t, allow("a"))
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, allow("a"))
+	b.record("a", false)
+	assert.NoError(t, allow("a"))
+	assert.Empty(t, b.state())
+
+	// Disabled.
+	b = newBreaker(0, time.Minute)
+	b.record("a", true)
+	assert.NoError(t, allow("a"))
+}
+
+func TestFetchBreaker(t *testing.T) {
+	var hits int32
+	c := newTestCrawl(FetcherFunc(func(ctx context.Context, r Request) (*Response, error) {
+		atomic.AddInt32(&hits, 1)
+		return &Response{StatusCode: http.StatusServiceUnavailable, FinalURL: r.URL}, nil
+	}))
+	c.breaker = newBreaker(3, time.Minute)
+	u, _ := url.Parse("https://example.com/funding.json")
+
+	// The first fetch stops retrying once the breaker trips.
+	_, err := c.Fetch(context.Background(), u, WithRetries(5))
+	assert.Equal(t, ErrClassCircuitOpen, ClassifyError(err))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&hits))
+
+	// Further fetches are short-circuited.
+	_, err = c.Fetch(context.Background(), u)
+	assert.Equal(t, ErrClassCircuitOpen, ClassifyError(err))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&hits))
+	assert.True(t, c.BreakerState()[0].Open)
+}
+
+func TestFetchBreakerTrial(t *testing.T) {
+	var (
+		hits    int32
+		robots  = "User-agent: *\nDisallow: /private\n"
+		blockCh = make(chan struct{})
+	)
+	c := newTestCrawl(FetcherFunc(func(ctx context.Context, r Request) (*Response, error) {
+		if r.URL.Path == "/robots.txt" {
+			return &Response{StatusCode: http.StatusOK, Body: []byte(robots), FinalURL: r.URL}, nil
+		}
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/slow" {
+			<-blockCh
+			return nil, ctx.Err()
+		}
+		return &Response{StatusCode: http.StatusOK, FinalURL: r.URL}, nil
+	}))
+	c.opt.RespectRobots = true
+	c.opt.RobotsTTL = time.Hour
+	c.breaker = newBreaker(1, 10*time.Millisecond)
+
+	// Trip the breaker and wait for the cooldown so that the next request is the trial.
+	halfOpen := func() {
+		c.breaker.record("example.com", true)
+		time.Sleep(20 * time.Millisecond)
+	}
+	parse := func(s string) *url.URL {
+		u, _ := url.Parse(s)
+		return u
+	}
+
+	// A robots denial doesn't take up the trial.
+	halfOpen()
+	_, err := c.Fetch(context.Background(), parse("https://example.com/private/funding.json"))
+	var re *RobotsError
+	assert.True(t, errors.As(err, &re))
+	assert.False(t, c.BreakerState()[0].Open)
+
+	// A cancelled trial releases it.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		close(blockCh)
+	}()
+	_, err = c.Fetch(ctx, parse("https://example.com/slow"))
+	assert.Error(t, err)
+	assert.False(t, c.BreakerState()[0].Open)
+
+	// The next trial goes through and closes the circuit.
+	_, err = c.Fetch(context.Background(), parse("https://example.com/funding.json"))
+	assert.NotEqual(t, ErrClassCircuitOpen, ClassifyError(err))
+	assert.Empty(t, c.BreakerState())
+}
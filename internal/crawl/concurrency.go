@@ -0,0 +1,112 @@
+package crawl
+
+import (
+	"sync"
+	"time"
+)
+
+// Error rate in a window above which concurrency is decreased.
+const maxWindowErrRate = 0.2
+
+// concurrency is an AIMD (additive increase, multiplicative decrease) controller
+// that limits the number of workers processing jobs at a time. After every window
+// of completions (the current limit), the limit is halved if the error rate or the
+// mean latency in the window crossed the thresholds, or else increased by one, within
+// [min, max]. Workers acquire a slot before processing a job.
+type concurrency struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+
+	min, max      int
+	targetLatency time.Duration
+
+	// Current window.
+	n       int
+	errs    int
+	latency time.Duration
+
+	log func(format string, v ...interface{})
+}
+
+func newConcurrency(min, max int, targetLatency time.Duration, log func(string, ...interface{})) *concurrency {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+
+	c := &concurrency{
+		limit:         (min + max) / 2,
+		min:           min,
+		max:           max,
+		targetLatency: targetLatency,
+		log:           log,
+	}
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+// acquire blocks until a slot is available.
+func (c *concurrency) acquire() {
+	c.mu.Lock()
+	for c.active >= c.limit {
+		c.cond.Wait()
+	}
+	c.active++
+	c.mu.Unlock()
+}
+
+// release frees a slot.
+func (c *concurrency) release() {
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+	c.cond.Signal()
+}
+
+// observe records the outcome and latency of a fetch and adjusts the limit
+// at the end of a window.
+func (c *concurrency) observe(ok bool, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.n++
+	if !ok {
+		c.errs++
+	}
+	c.latency += d
+
+	if c.n < c.limit {
+		return
+	}
+
+	var (
+		errRate = float64(c.errs) / float64(c.n)
+		mean    = c.latency / time.Duration(c.n)
+		prev    = c.limit
+	)
+	if errRate > maxWindowErrRate || (c.targetLatency > 0 && mean > c.targetLatency) {
+		c.limit = max(c.min, c.limit/2)
+	} else {
+		c.limit = min(c.max, c.limit+1)
+	}
+	c.n, c.errs, c.latency = 0, 0, 0
+
+	if c.limit != prev {
+		if c.log != nil && c.limit < prev {
+			c.log("crawl concurrency %d -> %d (error rate %.2f, mean latency %s)", prev, c.limit, errRate, mean)
+		}
+		c.cond.Broadcast()
+	}
+}
+
+// current returns the current limit.
+func (c *concurrency) current() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
@@ -0,0 +1,39 @@
+package crawl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrency(t *testing.T) {
+	c := newConcurrency(2, 10, time.Second, nil)
+	assert.Equal(t, 6, c.current())
+
+	// A healthy window increases the limit by one.
+	for n := 0; n < 6; n++ {
+		c.observe(true, time.Millisecond*100)
+	}
+	assert.Equal(t, 7, c.current())
+
+	// Errors halve it.
+	for n := 0; n < 7; n++ {
+		c.observe(n%2 == 0, time.Millisecond*100)
+	}
+	assert.Equal(t, 3, c.current())
+
+	// So does high latency, within the bounds.
+	for w := 0; w < 3; w++ {
+		for n := 0; n < c.current(); n++ {
+			c.observe(true, time.Second*2)
+		}
+	}
+	assert.Equal(t, 2, c.current())
+
+	// Never exceeds the max.
+	for n := 0; n < 1000; n++ {
+		c.observe(true, time.Millisecond)
+	}
+	assert.Equal(t, 10, c.current())
+}
@@ -1,8 +1,12 @@
 package crawl
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"log"
+	"net/http"
 	"net/url"
 	"sync"
 	"time"
@@ -13,13 +17,27 @@ import (
 
 type Schema interface {
 	Validate(models.ManifestData) (models.ManifestData, error)
-	ParseManifest(b []byte, manifestURL string, checkProvenance bool) (models.ManifestData, error)
+	ParseManifest(ctx context.Context, b []byte, manifestURL string, checkProvenance bool) (models.ManifestData, error)
 }
 
 type DB interface {
 	GetManifestForCrawling(age string, offsetID, limit int) ([]models.ManifestJob, error)
 	UpsertManifest(m models.ManifestData, status string) error
+	UpdateManifestETag(id int, etag string) error
+	UpdateManifestBodyHash(id int, hash string) error
 	UpdateManifestCrawlError(id int, message string, maxErrors int) (string, error)
+	UpdateManifestGoneStreak(id int, gone bool) (int, error)
+	UpsertFavicon(manifestID int, f models.Favicon) error
+	UpsertManifestMirror(manifestID int, body []byte, hash string, fetchedAt time.Time) error
+	InsertCrawlRun(r models.CrawlRun) error
+	InsertCrawlError(manifestID int, host, class, message, requestID string) error
+	SaveManifestTrace(manifestID int, t models.ManifestTrace) error
+	GetManifestsForSweep(offsetID, limit int) ([]models.ManifestJob, error)
+	UpdateManifestLiveness(id int, ok bool, statusCode int, message string) error
+	UpdateHostDowntime() (models.HostDowntimeUpdate, error)
+	MoveManifest(id int, url, reason string) error
+	InsertManifestAlias(manifestURL, url, reason string) error
+	UpdateManifestStatusMessage(url, msg string) error
 }
 
 type Opt struct {
@@ -29,7 +47,99 @@ type Opt struct {
 	CheckProvenance bool   `json:"check_provenance"`
 	MaxCrawlErrors  int    `json:"max_crawl_errors"`
 
+	// Number of consecutive crawls on which a manifest URL responds with 404 or 410
+	// after which the manifest is gone (GoneError, Callbacks.OnManifestGone). 0 disables it.
+	GoneAfter int `json:"gone_after"`
+
+	// Max size of .well-known lists fetched for provenance checks.
+	WellKnownMaxBytes int64 `json:"wellknown_max_bytes"`
+
+	// Capture entity webpage favicons (and optionally OpenGraph tags) during crawls.
+	FetchFavicons   bool  `json:"fetch_favicons"`
+	FaviconMaxBytes int64 `json:"favicon_max_bytes"`
+	FetchOpenGraph  bool  `json:"fetch_opengraph"`
+
+	// Store a copy of the contents of validated manifests.
+	Mirror bool `json:"mirror"`
+
+	// Always fetch, parse, and validate manifests on crawls even if they haven't
+	// been modified (conditional fetches) or their contents haven't changed since
+	// the last crawl, eg: after validation rules change.
+	ForceRevalidate bool `json:"force_revalidate"`
+
+	// Skip URLs disallowed by the robots.txt of their hosts, which is cached
+	// for RobotsTTL. Individual fetches can bypass it with IgnoreRobots().
+	RespectRobots bool          `json:"respect_robots"`
+	RobotsTTL     time.Duration `json:"robots_ttl"`
+
+	// Adjust the number of concurrent workers between MinWorkers and Workers
+	// based on the observed error rates and latencies (AIMD) instead of always
+	// running Workers workers.
+	AdaptiveConcurrency bool          `json:"adaptive_concurrency"`
+	MinWorkers          int           `json:"min_workers"`
+	TargetLatency       time.Duration `json:"target_latency"`
+
 	HTTP common.HTTPOpt
+
+	// Politeness limits per host (hostname) that all fetches, including robots.txt
+	// and provenance checks, go through. 0 disables the respective limit.
+	HostRPS         float64 `json:"host_rps"`
+	HostConcurrency int     `json:"host_concurrency"`
+
+	// Circuit breaker per host (hostname). After BreakerThreshold consecutive failed
+	// requests (connection errors, timeouts, 5xx) to a host, requests to it fail
+	// with CircuitOpenError for BreakerCooldown. 0 disables it.
+	BreakerThreshold int           `json:"breaker_threshold"`
+	BreakerCooldown  time.Duration `json:"breaker_cooldown"`
+
+	// Backoff between the retries of a fetch. If Backoff.Base isn't set,
+	// retries wait HTTP.RetryWait.
+	Backoff Backoff `json:"backoff"`
+
+	// HTTP/2, TLS, keep-alive, and per-host header settings of the HTTPFetcher's transport.
+	Transport TransportOpt `json:"transport"`
+
+	// URL schemes that can be fetched (empty allows all), and whether private,
+	// loopback, link-local, and multicast addresses can be connected to (SSRF).
+	AllowedSchemes    []string `json:"allowed_schemes"`
+	AllowPrivateAddrs bool     `json:"allow_private_addrs"`
+
+	// Redirect policy. See Guard.MaxRedirects and Guard.AllowCrossHost.
+	MaxRedirects            int  `json:"max_redirects"`
+	AllowCrossHostRedirects bool `json:"allow_cross_host_redirects"`
+
+	// Proxy (http://, https://, or socks5://) that requests are made through, and
+	// per-host (hostname) overrides of it ("direct" connects directly).
+	ProxyURL    string            `json:"proxy_url"`
+	HostProxies map[string]string `json:"host_proxies"`
+
+	// Cache the DNS lookups of up to DNSCacheSize hosts for their TTLs, capped at
+	// DNSCacheTTL. 0 disables it.
+	DNSCacheSize int           `json:"dns_cache_size"`
+	DNSCacheTTL  time.Duration `json:"dns_cache_ttl"`
+
+	// Fetcher is used for making requests. If it's not set,
+	// an HTTPFetcher is created with the HTTP options.
+	Fetcher Fetcher `json:"-"`
+
+	// URLChecker checks the links in crawled manifests for known malicious
+	// destinations. Listings with unsafe links are flagged, or quarantined
+	// if UnsafeURLAction is UnsafeQuarantine. If it's not set, links aren't checked.
+	URLChecker      URLChecker `json:"-"`
+	UnsafeURLAction string     `json:"unsafe_url_action"`
+
+	// Queue is the durable queue that crawls (Crawl()) lease jobs from so that
+	// interrupted crawls resume where they left off. Leases expire after QueueLease,
+	// and jobs that fail with transient errors are retried after QueueRetryWait (backed
+	// off) up to QueueMaxAttempts. If it's not set, crawls aren't persisted.
+	Queue            Queue         `json:"-"`
+	QueueLease       time.Duration `json:"queue_lease"`
+	QueueRetryWait   time.Duration `json:"queue_retry_wait"`
+	QueueMaxAttempts int           `json:"queue_max_attempts"`
+
+	// Metrics records requests (counts, status codes, retries, bytes, latencies).
+	// If it's not set, nothing is recorded.
+	Metrics Metrics `json:"-"`
 }
 
 type Crawl struct {
@@ -38,57 +148,161 @@ type Crawl struct {
 	Callbacks *Callbacks
 	db        DB
 
-	wg   *sync.WaitGroup
-	jobs chan models.ManifestJob
+	wg    *sync.WaitGroup
+	queue *queue
+	stats *runStats
+	conc  *concurrency
+
+	fetcher     Fetcher
+	metrics     Metrics
+	guard       *Guard
+	rateLimited map[string]struct{}
+	robots      map[string]robotsRules
+	hostLimits  *hostLimiter
+	breaker     *breaker
+	mu          sync.RWMutex
 
-	hc  *common.HTTPClient
 	log *log.Logger
 }
 
+// FetchResult is a fetched and parsed manifest along with the metadata
+// of the HTTP response it was fetched from.
+type FetchResult struct {
+	Manifest models.ManifestData
+
+	StatusCode   int
+	ETag         string
+	LastModified string
+	ContentType  string
+	FinalURL     string
+	Duration     time.Duration
+
+	// Response headers and the size of the (decoded) body in bytes.
+	Header http.Header
+	Size   int
+
+	// Raw response body and its SHA-256 (hex).
+	Body      []byte
+	Hash      string
+	FetchedAt time.Time
+
+	// Moved is true if the manifest has permanently moved (301, 308) to FinalURL.
+	Moved bool
+
+	// RawURL is the raw content URL that the manifest was fetched from if its URL
+	// is a blob URL (eg: github.com/../blob/..). See RawURL().
+	RawURL string
+
+	// NotModified is true if a conditional fetch (WithConditional) returned 304.
+	// Manifest and Body are empty.
+	NotModified bool
+
+	// Unchanged is true if the body's hash is the known hash (WithKnownHash)
+	// of a previous fetch. The manifest isn't parsed and Manifest is empty.
+	Unchanged bool
+}
+
 type Callbacks struct {
 	OnManifestUpdate func(m models.ManifestData, status string)
+
+	// OnManifestGone is called when a manifest is gone (Opt.GoneAfter), eg: to disable it.
+	OnManifestGone func(j models.ManifestJob, err *GoneError)
 }
 
 var (
 	ErrRatelimited = errors.New("host rate limited the request")
+	ErrPinMismatch = errors.New("manifest contents don't match the pinned hash")
+	ErrTooLarge    = errors.New("response body is too large")
 )
 
 func New(o *Opt, sc Schema, cb *Callbacks, db DB, l *log.Logger) *Crawl {
+	g := &Guard{
+		Schemes:        o.AllowedSchemes,
+		AllowPrivate:   o.AllowPrivateAddrs,
+		MaxRedirects:   o.MaxRedirects,
+		AllowCrossHost: o.AllowCrossHostRedirects,
+	}
+
+	f := o.Fetcher
+	if f == nil {
+		p, err := NewProxy(o.ProxyURL, o.HostProxies)
+		if err != nil {
+			l.Printf("error initializing proxy. Connecting directly: %v", err)
+		}
+		var dns *DNSCache
+		if o.DNSCacheSize > 0 {
+			dns = NewDNSCache(o.DNSCacheSize, o.DNSCacheTTL)
+		}
+		f = NewHTTPFetcher(o.HTTP, o.Transport, g, p, dns)
+	}
+
+	m := o.Metrics
+	if m == nil {
+		m = nopMetrics{}
+	}
+
 	return &Crawl{
 		opt:       o,
 		sc:        sc,
 		Callbacks: cb,
 		db:        db,
-		hc:        common.NewHTTPClient(o.HTTP, l),
+		fetcher:   f,
+		metrics:   m,
+		guard:     g,
 
-		wg:   &sync.WaitGroup{},
-		jobs: make(chan models.ManifestJob, o.BatchSize),
-		log:  l,
+		rateLimited: make(map[string]struct{}),
+		robots:      make(map[string]robotsRules),
+		hostLimits:  newHostLimiter(o.HostRPS, o.HostConcurrency),
+		breaker:     newBreaker(o.BreakerThreshold, o.BreakerCooldown),
+
+		wg:    &sync.WaitGroup{},
+		queue: newQueue(o.BatchSize),
+		stats: newRunStats(),
+		log:   l,
 	}
 }
 
-func (c *Crawl) Crawl() error {
+// Crawl crawls all the manifests due for crawling. Cancelling ctx stops the crawl:
+// no more jobs are picked up and in-flight fetches are aborted.
+func (c *Crawl) Crawl(ctx context.Context) error {
+	c.stats = newRunStats()
+	if c.opt.AdaptiveConcurrency {
+		c.conc = newConcurrency(c.opt.MinWorkers, c.opt.Workers, c.opt.TargetLatency, c.log.Printf)
+	}
+
 	for n := 0; n < c.opt.Workers; n++ {
 		c.wg.Add(1)
 
-		go c.worker()
+		go c.worker(ctx)
 	}
 
-	go c.dbWorker()
+	go c.dbWorker(ctx)
 
 	c.wg.Wait()
+
+	// Record the stats of the run.
+	r := c.stats.result()
+	c.log.Printf("crawl finished. total=%d success=%d failed=%d skipped=%d p50=%dms",
+		r.Total, r.Success, r.Failed, r.Skipped, r.LatencyP50)
+	if c.conc != nil {
+		c.log.Printf("final crawl concurrency: %d", c.conc.current())
+	}
+	if err := c.db.InsertCrawlRun(r); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // IsManifestModified sends a head request to a manifest URL and
 // indicates whether it's been updated (true=needs re-crawling).
-func (c *Crawl) IsManifestModified(manifest *url.URL, lastModified time.Time) (bool, error) {
-	hdr, err := c.hc.Head(manifest)
+func (c *Crawl) IsManifestModified(ctx context.Context, manifest *url.URL, lastModified time.Time, opts ...FetchOpt) (bool, error) {
+	resp, err := c.fetch(ctx, http.MethodHead, manifest, c.makeFetchOpt(append([]FetchOpt{withPhase(PhaseManifest)}, opts...)))
 	if err != nil {
 		return false, err
 	}
 
-	last := hdr.Get("Last-Modified")
+	last := resp.Header.Get("Last-Modified")
 
 	// Header doesn't exist. Re-crawl.
 	if last == "" {
@@ -103,12 +317,85 @@ func (c *Crawl) IsManifestModified(manifest *url.URL, lastModified time.Time) (b
 	return lastModified.Before(t), nil
 }
 
-// FetchManifest fetches a given funding.json manifest, parses it, and returns.
-func (c *Crawl) FetchManifest(manifest *url.URL) (models.ManifestData, error) {
-	b, err := c.hc.Get(common.TransformURLOrigin(manifest))
+// Fetch fetches a given URL (after transforming it to its raw origin, eg: GitHub
+// blob URLs to raw URLs) and returns the raw response without parsing it.
+func (c *Crawl) Fetch(ctx context.Context, u *url.URL, opts ...FetchOpt) (*Response, error) {
+	return c.fetch(ctx, http.MethodGet, originURL(u), c.makeFetchOpt(opts))
+}
+
+// FetchManifest fetches a given funding.json manifest, parses it, and returns it
+// along with the response metadata. The global HTTP options can be overridden for
+// the fetch with opts. If the fetch is conditional (WithConditional) and the manifest
+// hasn't been modified, a NotModified result is returned without parsing. Likewise,
+// if the body's hash is the known hash (WithKnownHash), an Unchanged result is returned.
+func (c *Crawl) FetchManifest(ctx context.Context, manifest *url.URL, opts ...FetchOpt) (FetchResult, error) {
+	var (
+		u = originURL(manifest)
+		o = c.makeFetchOpt(append([]FetchOpt{withPhase(PhaseManifest)}, opts...))
+	)
+
+	// A truncated manifest would only fail with a confusing JSON parse error.
+	o.failTooLarge = true
+
+	resp, err := c.fetch(ctx, http.MethodGet, u, o)
+	if err != nil {
+		var se *StatusError
+		if errors.As(err, &se) && se.StatusCode == http.StatusNotModified && resp != nil {
+			return FetchResult{
+				StatusCode:   resp.StatusCode,
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+				FinalURL:     resp.FinalURL.String(),
+				Duration:     resp.Duration,
+				Header:       resp.Header,
+				FetchedAt:    time.Now(),
+				NotModified:  true,
+			}, nil
+		}
+
+		return FetchResult{}, err
+	}
+
+	hash := sha256.Sum256(resp.Body)
+	out := FetchResult{
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		FinalURL:     resp.FinalURL.String(),
+		Duration:     resp.Duration,
+		Header:       resp.Header,
+		Size:         len(resp.Body),
+		Body:         resp.Body,
+		Hash:         hex.EncodeToString(hash[:]),
+		FetchedAt:    time.Now(),
+
+		// Moves are only tracked for URLs that are fetched as-is and not
+		// transformed (eg: GitHub blob URLs to raw URLs).
+		Moved: resp.Moved && u.String() == manifest.String() && resp.FinalURL.String() != manifest.String(),
+	}
+
+	if r, ok := RawURL(manifest); ok {
+		out.RawURL = r.String()
+	}
+
+	if o.knownHash != "" && out.Hash == o.knownHash {
+		out.Unchanged = true
+		return out, nil
+	}
+
+	// A manifest that has permanently moved is canonicalized to (and its
+	// provenance checked against) the URL it has moved to.
+	canonical := manifest.String()
+	if out.Moved {
+		canonical = out.FinalURL
+	}
+
+	m, err := c.sc.ParseManifest(ctx, resp.Body, canonical, c.opt.CheckProvenance)
 	if err != nil {
-		return models.ManifestData{}, err
+		return out, err
 	}
+	out.Manifest = m
 
-	return c.sc.ParseManifest(b, manifest.String(), c.opt.CheckProvenance)
+	return out, nil
 }
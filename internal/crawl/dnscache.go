@@ -0,0 +1,280 @@
+package crawl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSCache is an in-process cache of the DNS lookups of the crawler's dialer so that
+// bursts of fetches (eg: bulk re-crawls) don't resolve the same hosts every time.
+// Lookups are cached for the TTLs of their records, capped at a max TTL.
+type DNSCache struct {
+	maxTTL time.Duration
+	size   int
+	res    *net.Resolver
+
+	hosts map[string]dnsEntry
+	mu    sync.Mutex
+}
+
+type dnsEntry struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// Context key of the TTL recorder of a lookup.
+type ttlKey struct{}
+
+// NewDNSCache returns a DNSCache that holds up to size hosts for up to maxTTL.
+// Failed lookups are not cached.
+func NewDNSCache(size int, maxTTL time.Duration) *DNSCache {
+	c := &DNSCache{
+		maxTTL: maxTTL,
+		size:   size,
+		hosts:  make(map[string]dnsEntry),
+	}
+
+	// The system resolver doesn't expose the TTLs of records. The Go resolver is
+	// used instead and its responses are inspected for them as they're read.
+	d := &net.Dialer{}
+	c.res = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := d.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			if rec, ok := ctx.Value(ttlKey{}).(*ttlRecorder); ok {
+				return &ttlConn{Conn: conn, rec: rec, stream: strings.HasPrefix(network, "tcp")}, nil
+			}
+			return conn, nil
+		},
+	}
+
+	return c
+}
+
+// LookupNetIP returns the IP addresses of a host.
+func (c *DNSCache) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.hosts[host]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.addrs, nil
+	}
+
+	rec := &ttlRecorder{}
+	addrs, err := c.res.LookupNetIP(context.WithValue(ctx, ttlKey{}, rec), "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for n, a := range addrs {
+		addrs[n] = a.Unmap()
+	}
+
+	// Names that aren't resolved over DNS (eg: /etc/hosts) have no TTL.
+	ttl := c.maxTTL
+	if t, ok := rec.get(); ok && t < ttl {
+		ttl = t
+	}
+	c.set(host, dnsEntry{addrs: addrs, expires: now.Add(ttl)})
+
+	return addrs, nil
+}
+
+// Len returns the number of cached hosts.
+func (c *DNSCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.hosts)
+}
+
+// set caches an entry. If the cache is full, expired entries are evicted, and
+// if it's still full, an arbitrary one.
+func (c *DNSCache) set(host string, e dnsEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.hosts[host]; !ok && len(c.hosts) >= c.size {
+		now := time.Now()
+		for h, o := range c.hosts {
+			if !now.Before(o.expires) {
+				delete(c.hosts, h)
+			}
+		}
+
+		if len(c.hosts) >= c.size {
+			for h := range c.hosts {
+				delete(c.hosts, h)
+				break
+			}
+		}
+	}
+
+	c.hosts[host] = e
+}
+
+// dialContext returns a dial function that resolves hosts through the cache and
+// connects to their addresses with d, in order, until one connects.
+func (c *DNSCache) dialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := netip.ParseAddr(host); err == nil {
+			return d.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := c.LookupNetIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		lastErr := errors.New("no addresses for " + host)
+		for _, a := range addrs {
+			if (strings.HasSuffix(network, "4") && !a.Is4()) || (strings.HasSuffix(network, "6") && !a.Is6()) {
+				continue
+			}
+
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(a.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		return nil, lastErr
+	}
+}
+
+// ttlRecorder records the lowest TTL seen in the DNS responses of a lookup.
+type ttlRecorder struct {
+	ttl  time.Duration
+	seen bool
+	mu   sync.Mutex
+}
+
+func (r *ttlRecorder) add(ttl time.Duration) {
+	r.mu.Lock()
+	if !r.seen || ttl < r.ttl {
+		r.ttl = ttl
+		r.seen = true
+	}
+	r.mu.Unlock()
+}
+
+func (r *ttlRecorder) get() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.ttl, r.seen
+}
+
+// ttlConn is a connection to a DNS server that records the TTLs of the responses
+// read from it. UDP reads are whole messages and TCP messages are length prefixed.
+type ttlConn struct {
+	net.Conn
+
+	rec    *ttlRecorder
+	stream bool
+	buf    []byte
+}
+
+func (c *ttlConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n <= 0 {
+		return n, err
+	}
+
+	if !c.stream {
+		if ttl, ok := dnsMinTTL(b[:n]); ok {
+			c.rec.add(ttl)
+		}
+		return n, err
+	}
+
+	c.buf = append(c.buf, b[:n]...)
+	for len(c.buf) >= 2 {
+		l := int(binary.BigEndian.Uint16(c.buf))
+		if len(c.buf) < 2+l {
+			break
+		}
+		if ttl, ok := dnsMinTTL(c.buf[2 : 2+l]); ok {
+			c.rec.add(ttl)
+		}
+		c.buf = c.buf[2+l:]
+	}
+
+	return n, err
+}
+
+// dnsMinTTL returns the lowest TTL of the answer records in a DNS message.
+func dnsMinTTL(b []byte) (time.Duration, bool) {
+	if len(b) < 12 {
+		return 0, false
+	}
+
+	var (
+		qd  = int(binary.BigEndian.Uint16(b[4:]))
+		an  = int(binary.BigEndian.Uint16(b[6:]))
+		off = 12
+		ok  bool
+	)
+	for i := 0; i < qd; i++ {
+		if off, ok = skipDNSName(b, off); !ok || off+4 > len(b) {
+			return 0, false
+		}
+		off += 4
+	}
+
+	var (
+		min   uint32
+		found bool
+	)
+	for i := 0; i < an; i++ {
+		if off, ok = skipDNSName(b, off); !ok || off+10 > len(b) {
+			break
+		}
+
+		ttl := binary.BigEndian.Uint32(b[off+4:])
+		if !found || ttl < min {
+			min = ttl
+			found = true
+		}
+		off += 10 + int(binary.BigEndian.Uint16(b[off+8:]))
+	}
+
+	return time.Duration(min) * time.Second, found
+}
+
+// skipDNSName returns the offset after a (possibly compressed) name in a DNS message.
+func skipDNSName(b []byte, off int) (int, bool) {
+	for off < len(b) {
+		l := int(b[off])
+		switch {
+		case l == 0:
+			return off + 1, true
+		case l&0xC0 == 0xC0:
+			return off + 2, off+2 <= len(b)
+		default:
+			off += 1 + l
+		}
+	}
+
+	return off, false
+}
@@ -0,0 +1,60 @@
+package crawl
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSMinTTL(t *testing.T) {
+	// Response for example.com with two A records (TTLs 300 and 60) whose names
+	// point to the question.
+	msg := []byte{0, 1, 0x81, 0x80, 0, 1, 0, 2, 0, 0, 0, 0}
+	msg = append(msg, "\x07example\x03com\x00"...)
+	msg = append(msg, 0, 1, 0, 1)
+	msg = append(msg, 0xC0, 12, 0, 1, 0, 1, 0, 0, 0x01, 0x2C, 0, 4, 93, 184, 216, 34)
+	msg = append(msg, 0xC0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 93, 184, 216, 35)
+
+	ttl, ok := dnsMinTTL(msg)
+	assert.True(t, ok)
+	assert.Equal(t, 60*time.Second, ttl)
+
+	// No answers and truncated messages.
+	_, ok = dnsMinTTL(msg[:12])
+	assert.False(t, ok)
+	_, ok = dnsMinTTL(msg[:20])
+	assert.False(t, ok)
+}
+
+func TestDNSCache(t *testing.T) {
+	c := NewDNSCache(2, time.Minute)
+	lo := []netip.Addr{netip.MustParseAddr("127.0.0.1")}
+
+	// Cached hosts are not resolved.
+	c.set("a.invalid", dnsEntry{addrs: lo, expires: time.Now().Add(time.Minute)})
+	addrs, err := c.LookupNetIP(context.Background(), "a.invalid")
+	assert.NoError(t, err)
+	assert.Equal(t, lo, addrs)
+
+	// Expired entries are evicted first when the cache is full.
+	c.set("b.invalid", dnsEntry{addrs: lo, expires: time.Now().Add(-time.Second)})
+	c.set("c.invalid", dnsEntry{addrs: lo, expires: time.Now().Add(time.Minute)})
+	assert.Equal(t, 2, c.Len())
+	_, err = c.LookupNetIP(context.Background(), "a.invalid")
+	assert.NoError(t, err)
+
+	// Connections to hosts are made to their cached addresses.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	conn, err := c.dialContext(&net.Dialer{})(context.Background(), "tcp", net.JoinHostPort("a.invalid", port))
+	assert.NoError(t, err)
+	conn.Close()
+}
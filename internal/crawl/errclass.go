@@ -0,0 +1,147 @@
+package crawl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/core"
+)
+
+// Normalized classes of crawl errors for analytics.
+const (
+	ErrClassTimeout     = "timeout"
+	ErrClassDNS         = "dns"
+	ErrClassTLS         = "tls"
+	ErrClassConnection  = "connection"
+	ErrClassRatelimited = "ratelimited"
+	ErrClassCircuitOpen = "circuit_open"
+	ErrClassRobots      = "robots"
+	ErrClassBlocked     = "blocked"
+	ErrClassNotFound    = "not_found"
+	ErrClassHTTP4xx     = "http_4xx"
+	ErrClassHTTP5xx     = "http_5xx"
+	ErrClassProvenance  = "provenance"
+	ErrClassPinMismatch = "pin_mismatch"
+	ErrClassCompliance  = "compliance"
+	ErrClassInvalid     = "invalid_manifest"
+	ErrClassTooLarge    = "too_large"
+	ErrClassOther       = "other"
+)
+
+// StatusError is returned for non-2xx responses.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error: %s returned %d", e.URL, e.StatusCode)
+}
+
+// GoneError is returned for a manifest whose URL has responded with 404 or 410
+// on Streak consecutive crawls (Opt.GoneAfter).
+type GoneError struct {
+	URL        string
+	StatusCode int
+	Streak     int
+}
+
+func (e *GoneError) Error() string {
+	return fmt.Sprintf("%s is gone: returned %d on %d consecutive crawls", e.URL, e.StatusCode, e.Streak)
+}
+
+// RobotsError is returned for URLs that are disallowed by the robots.txt of their host.
+type RobotsError struct {
+	URL string
+}
+
+func (e *RobotsError) Error() string {
+	return fmt.Sprintf("error: %s is disallowed by robots.txt", e.URL)
+}
+
+// ClassifyError returns the normalized class of an error returned by a fetch.
+// Errors in fetched manifest bodies (parsing, validation) are classified by
+// the caller as it has the response.
+func ClassifyError(err error) string {
+	var (
+		se   *StatusError
+		re   *RobotsError
+		be   *BlockedError
+		coE  *CircuitOpenError
+		ce   *core.ComplianceError
+		dnsE *net.DNSError
+		opE  *net.OpError
+		crtE *tls.CertificateVerificationError
+		uaE  x509.UnknownAuthorityError
+		hnE  x509.HostnameError
+		ciE  x509.CertificateInvalidError
+		recE tls.RecordHeaderError
+	)
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRatelimited):
+		return ErrClassRatelimited
+	case errors.Is(err, ErrPinMismatch):
+		return ErrClassPinMismatch
+	case errors.Is(err, ErrTooLarge):
+		return ErrClassTooLarge
+	case errors.As(err, &ce):
+		return ErrClassCompliance
+	case errors.As(err, &coE):
+		return ErrClassCircuitOpen
+	case errors.As(err, &re):
+		return ErrClassRobots
+	case errors.As(err, &be):
+		return ErrClassBlocked
+	case errors.As(err, &se):
+		switch {
+		case se.StatusCode == http.StatusTooManyRequests:
+			return ErrClassRatelimited
+		case se.StatusCode == http.StatusNotFound || se.StatusCode == http.StatusGone:
+			return ErrClassNotFound
+		case se.StatusCode >= 500:
+			return ErrClassHTTP5xx
+		}
+		return ErrClassHTTP4xx
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrClassTimeout
+	case errors.As(err, &dnsE):
+		if dnsE.IsTimeout {
+			return ErrClassTimeout
+		}
+		return ErrClassDNS
+	case errors.As(err, &crtE), errors.As(err, &uaE), errors.As(err, &hnE), errors.As(err, &ciE), errors.As(err, &recE):
+		return ErrClassTLS
+	case errors.As(err, &opE):
+		if opE.Timeout() {
+			return ErrClassTimeout
+		}
+		return ErrClassConnection
+	case errors.Is(err, ErrWellKnownTooLarge) || strings.Contains(err.Error(), ".well-known"):
+		return ErrClassProvenance
+	}
+
+	// Errors wrapped as strings by the HTTP client.
+	s := err.Error()
+	switch {
+	case strings.Contains(s, "timeout") || strings.Contains(s, "deadline exceeded"):
+		return ErrClassTimeout
+	case strings.Contains(s, "x509:") || strings.Contains(s, "tls:"):
+		return ErrClassTLS
+	case strings.Contains(s, "no such host"):
+		return ErrClassDNS
+	case strings.Contains(s, "connection refused") || strings.Contains(s, "connection reset") || strings.Contains(s, "EOF"):
+		return ErrClassConnection
+	}
+
+	return ErrClassOther
+}
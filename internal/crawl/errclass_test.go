@@ -0,0 +1,34 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	f := func(err error, exp string) {
+		assert.Equal(t, exp, ClassifyError(err), err)
+	}
+
+	f(nil, "")
+	f(ErrRatelimited, ErrClassRatelimited)
+	f(&RobotsError{URL: "https://example.com/funding.json"}, ErrClassRobots)
+	f(&StatusError{URL: "https://example.com", StatusCode: 404}, ErrClassNotFound)
+	f(&StatusError{URL: "https://example.com", StatusCode: 403}, ErrClassHTTP4xx)
+	f(&StatusError{URL: "https://example.com", StatusCode: 503}, ErrClassHTTP5xx)
+	f(&net.DNSError{Err: "no such host", Name: "example.com"}, ErrClassDNS)
+	f(fmt.Errorf("get: %w", context.DeadlineExceeded), ErrClassTimeout)
+	f(errors.New("tls: failed to verify certificate: x509: certificate has expired"), ErrClassTLS)
+	f(ErrWellKnownTooLarge, ErrClassProvenance)
+	f(fmt.Errorf("%w (max 10 bytes)", ErrTooLarge), ErrClassTooLarge)
+	f(fmt.Errorf("%w: sha256 abc", ErrPinMismatch), ErrClassPinMismatch)
+	f(&net.OpError{Op: "dial", Err: &BlockedError{URL: "127.0.0.1", Reason: "x"}}, ErrClassBlocked)
+	f(&core.ComplianceError{Reason: "x"}, ErrClassCompliance)
+	f(errors.New("something else"), ErrClassOther)
+}
@@ -0,0 +1,116 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Max bytes of a webpage to read when looking for icon and OpenGraph tags
+// which are expected to be in the <head>.
+const maxPageBytes = 256 * 1024
+
+var (
+	reTag  = regexp.MustCompile(`(?i)<(link|meta)\s[^>]*>`)
+	reAttr = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9:_-]*)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+	errNotImage = errors.New("favicon is not an image")
+)
+
+// FetchFavicon fetches the webpage at the given URL, discovers its favicon
+// (falling back to /favicon.ico), and fetches it within FaviconMaxBytes.
+// If FetchOpenGraph is enabled, the page's OpenGraph tags are also captured.
+func (c *Crawl) FetchFavicon(ctx context.Context, page *url.URL) (models.Favicon, error) {
+	var (
+		out  models.Favicon
+		icon = page.ResolveReference(&url.URL{Path: "/favicon.ico"})
+	)
+
+	// Look for <link rel="icon"> and OpenGraph tags in the page.
+	if resp, err := c.fetch(ctx, http.MethodGet, page, c.makeFetchOpt([]FetchOpt{WithMaxBytes(maxPageBytes)})); err == nil {
+		href, og := parsePageMeta(resp.Body)
+		if href != "" {
+			if u, err := resp.FinalURL.Parse(href); err == nil && (u.Scheme == "https" || u.Scheme == "http") {
+				icon = u
+			}
+		}
+
+		if c.opt.FetchOpenGraph {
+			b, _ := json.Marshal(og)
+			out.OpenGraph = b
+		}
+	}
+
+	resp, err := c.fetch(ctx, http.MethodGet, icon, c.makeFetchOpt([]FetchOpt{WithMaxBytes(c.opt.FaviconMaxBytes + 1)}))
+	if err != nil {
+		return out, err
+	}
+
+	if int64(len(resp.Body)) > c.opt.FaviconMaxBytes {
+		return out, errors.New("favicon exceeds max size")
+	}
+
+	// Use the declared content type if it's an image, or sniff it.
+	typ := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(typ, "image/") {
+		typ = http.DetectContentType(resp.Body)
+	}
+
+	// SVGs can carry scripts and are not served.
+	if !strings.HasPrefix(typ, "image/") || strings.HasPrefix(typ, "image/svg") {
+		return out, errNotImage
+	}
+
+	out.Body = resp.Body
+	out.ContentType = typ
+
+	return out, nil
+}
+
+// parsePageMeta scans the <link> and <meta> tags in an HTML page and returns
+// the favicon href (if any) and the OpenGraph metadata.
+func parsePageMeta(b []byte) (string, models.OpenGraph) {
+	var (
+		href string
+		og   models.OpenGraph
+	)
+
+	for _, t := range reTag.FindAllSubmatch(b, -1) {
+		attr := make(map[string]string)
+		for _, a := range reAttr.FindAllSubmatch(t[0], -1) {
+			v := a[2]
+			if len(v) == 0 {
+				v = a[3]
+			}
+			attr[strings.ToLower(string(a[1]))] = html.UnescapeString(string(v))
+		}
+
+		if strings.EqualFold(string(t[1]), "link") {
+			rel := strings.ToLower(attr["rel"])
+			if href == "" && (rel == "icon" || rel == "shortcut icon") {
+				href = attr["href"]
+			}
+			continue
+		}
+
+		switch attr["property"] {
+		case "og:title":
+			og.Title = attr["content"]
+		case "og:description":
+			og.Description = attr["content"]
+		case "og:image":
+			og.Image = attr["content"]
+		case "og:site_name":
+			og.SiteName = attr["content"]
+		}
+	}
+
+	return href, og
+}
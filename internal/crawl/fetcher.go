@@ -0,0 +1,479 @@
+package crawl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/floss-fund/go-funding-json/common"
+)
+
+// Fetcher executes a single request (without retries) and returns the response.
+// An error is only returned when there's no response at all (eg: network error).
+// Non-2xx responses are returned as-is and it's for the crawler to interpret them.
+type Fetcher interface {
+	Fetch(ctx context.Context, r Request) (*Response, error)
+}
+
+// FetcherFunc is an adapter for using a function as a Fetcher, eg: a fake in tests.
+type FetcherFunc func(ctx context.Context, r Request) (*Response, error)
+
+// Fetch calls f(ctx, r).
+func (f FetcherFunc) Fetch(ctx context.Context, r Request) (*Response, error) {
+	return f(ctx, r)
+}
+
+// Request represents a single request made by the crawler.
+type Request struct {
+	Method   string
+	URL      *url.URL
+	Header   http.Header
+	MaxBytes int64
+
+	// Scan, if set, is called with the body stream (limited to MaxBytes) instead of
+	// reading it into Response.Body. Scan may return without consuming the whole body.
+	Scan func(io.Reader) error
+
+	// FailTooLarge fails bodies that exceed MaxBytes with ErrTooLarge instead of
+	// truncating them. Responses with a larger Content-Length are not read at all.
+	FailTooLarge bool
+}
+
+// Response represents the parts of a response that are relevant to the crawler.
+type Response struct {
+	Body       []byte
+	Header     http.Header
+	StatusCode int
+	FinalURL   *url.URL
+	Duration   time.Duration
+
+	// Moved is true if the request was redirected to FinalURL and
+	// all the redirects were permanent (301, 308).
+	Moved bool
+}
+
+// TransportOpt tunes the HTTPFetcher's transport, eg: for self-hosted servers
+// with broken HTTP/2 or legacy TLS.
+type TransportOpt struct {
+	// HTTP/2 is only negotiated (over TLS) with ForceHTTP2. DisableHTTP2
+	// restricts connections to HTTP/1.1 even if it's forced.
+	ForceHTTP2   bool `json:"force_http2"`
+	DisableHTTP2 bool `json:"disable_http2"`
+
+	// Min TLS version (tls.VersionTLS12 etc.). 0 uses the Go default.
+	TLSMinVersion uint16 `json:"tls_min_version"`
+
+	// Skip TLS certificate verification. Only for staging and testing.
+	TLSSkipVerify bool `json:"tls_skip_verify"`
+
+	// Open a new connection for every request, and close idle connections
+	// after IdleConnTimeout. 0 uses the request timeout.
+	DisableKeepAlives bool          `json:"disable_keepalives"`
+	IdleConnTimeout   time.Duration `json:"idle_conn_timeout"`
+
+	// Extra headers and auth for requests to specific hosts.
+	HostHeaders []HostHeaders `json:"host_headers"`
+}
+
+// TLS versions by their names.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion parses a TLS version name (1.0, 1.1, 1.2, 1.3). Empty returns 0.
+func ParseTLSVersion(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	v, ok := tlsVersions[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version: %s", s)
+	}
+
+	return v, nil
+}
+
+// HTTPFetcher is the default Fetcher that makes requests over HTTP.
+type HTTPFetcher struct {
+	hc *http.Client
+}
+
+// FileFetcher is a Fetcher that reads file:// URLs from the local filesystem.
+type FileFetcher struct{}
+
+// MemFetcher is a Fetcher that serves bodies from an in-memory map of URL => body.
+// URLs that are not in the map return a 404.
+type MemFetcher struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// Max number of times an interrupted response body read is resumed.
+const maxResumes = 3
+
+// Max number of unread bytes of a response body that are drained to reuse the
+// connection. Connections with more left over are closed instead.
+const maxDrainBytes = 64 * 1024
+
+// Content encodings requested for (and decoded from) responses.
+const acceptEncoding = "gzip, deflate, br"
+
+var (
+	errUnknownScheme   = errors.New("unsupported URL scheme")
+	errUnknownEncoding = errors.New("unsupported content encoding")
+)
+
+// NewHTTPFetcher returns an HTTP Fetcher for fetching manifests and .well-known URLs.
+// Request timeouts are applied per request (context) so that they can be overridden.
+// t tunes the transport. If g is set, connections and redirects are restricted by it.
+// If p is set, requests are made through its proxies. If dns is set, hosts are
+// resolved through it.
+func NewHTTPFetcher(o common.HTTPOpt, t TransportOpt, g *Guard, p *Proxy, dns *DNSCache) *HTTPFetcher {
+	dial := g.dialer().DialContext
+	if dns != nil {
+		dial = dns.dialContext(g.dialer())
+	}
+
+	tr := &http.Transport{
+		// Compressed responses are decoded by the fetcher so that MaxBytes
+		// is enforced on the decoded stream.
+		DisableCompression: true,
+
+		DialContext:           dial,
+		MaxIdleConnsPerHost:   o.MaxHostConns,
+		MaxConnsPerHost:       o.MaxHostConns,
+		ResponseHeaderTimeout: o.ReqTimeout,
+		IdleConnTimeout:       o.ReqTimeout,
+
+		TLSClientConfig: &tls.Config{
+			MinVersion:         t.TLSMinVersion,
+			InsecureSkipVerify: t.TLSSkipVerify,
+		},
+		ForceAttemptHTTP2: t.ForceHTTP2,
+		DisableKeepAlives: t.DisableKeepAlives,
+	}
+	if t.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = t.IdleConnTimeout
+	}
+	if t.DisableHTTP2 {
+		// A non-nil empty map disables HTTP/2.
+		tr.ForceAttemptHTTP2 = false
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if p != nil {
+		tr.Proxy = p.proxyFor
+		tr.DialContext = p.dialContext(dial)
+	}
+
+	var rt http.RoundTripper = tr
+	if len(t.HostHeaders) > 0 {
+		rt = &hostHeaderTransport{rt: tr, hosts: t.HostHeaders}
+	}
+
+	return &HTTPFetcher{
+		hc: &http.Client{
+			CheckRedirect: g.checkRedirect,
+			Transport:     rt,
+		},
+	}
+}
+
+// Fetch executes an HTTP request.
+func (h *HTTPFetcher) Fetch(ctx context.Context, r Request) (*Response, error) {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	resp, err := h.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Abort early on uncompressed bodies that are declared to be too large.
+	if r.FailTooLarge && r.Method != http.MethodHead && resp.Header.Get("Content-Encoding") == "" && resp.ContentLength > r.MaxBytes {
+		resp.Body.Close()
+		return nil, tooLarge(r.MaxBytes)
+	}
+
+	drain := r.Scan == nil
+	defer func() {
+		// Drain and close the body to let the Transport reuse the connection.
+		// Scanned bodies may be abandoned midway (and be large), so they're not
+		// drained, and neither are bodies that are too large. Bodies cut off at
+		// MaxBytes are only drained up to a small limit.
+		if drain {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+		}
+		resp.Body.Close()
+	}()
+
+	rd, encoded, err := decodeBody(resp, r.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := readBody(rd, r)
+	if errors.Is(err, ErrTooLarge) {
+		drain = false
+		return nil, err
+	}
+	if err != nil && r.Scan == nil && r.Method == http.MethodGet && !encoded {
+		// The body read failed midway. Resume it from the last received offset.
+		// Offsets of decoded bodies don't map to the (encoded) ranges, so they aren't resumed.
+		body, err = h.resume(ctx, r, resp, body, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if encoded {
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+	}
+
+	// Walk back the redirect chain, if any.
+	moved := resp.Request.Response != nil
+	for rq := resp.Request; rq.Response != nil; rq = rq.Response.Request {
+		if c := rq.Response.StatusCode; c != http.StatusMovedPermanently && c != http.StatusPermanentRedirect {
+			moved = false
+			break
+		}
+	}
+
+	return &Response{
+		Body:       body,
+		Header:     resp.Header,
+		StatusCode: resp.StatusCode,
+		FinalURL:   resp.Request.URL,
+		Duration:   time.Since(start),
+		Moved:      moved,
+	}, nil
+}
+
+// resume resumes an interrupted body read with Range requests from the last received
+// offset instead of restarting the fetch. This is only done if the server supports
+// ranges and the response has a validator (ETag, Last-Modified) for If-Range so that
+// a resource that changed midway is fetched afresh and not spliced.
+func (h *HTTPFetcher) resume(ctx context.Context, r Request, resp *http.Response, body []byte, readErr error) ([]byte, error) {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || len(body) == 0 {
+		return nil, readErr
+	}
+
+	// Weak ETags can't be used with If-Range.
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		return nil, readErr
+	}
+
+	// Release the interrupted response's connection so that the Range requests
+	// aren't blocked by the per-host connection limit.
+	resp.Body.Close()
+
+	for n := 0; n < maxResumes && ctx.Err() == nil; n++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, resp.Request.URL.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header = r.Header.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set("Accept-Encoding", "identity")
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(body)))
+		req.Header.Set("If-Range", validator)
+
+		rs, err := h.hc.Do(req)
+		if err != nil {
+			readErr = err
+			continue
+		}
+
+		switch rs.StatusCode {
+		case http.StatusPartialContent:
+			if !strings.HasPrefix(rs.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", len(body))) {
+				rs.Body.Close()
+				return nil, readErr
+			}
+		case http.StatusOK:
+			// The resource has changed and the server sent the whole of it.
+			body = body[:0]
+		default:
+			rs.Body.Close()
+			return nil, readErr
+		}
+
+		// Read a byte past the limit to tell a body that fits from one that was cut off.
+		b, err := io.ReadAll(io.LimitReader(rs.Body, r.MaxBytes-int64(len(body))+1))
+		rs.Body.Close()
+
+		body = append(body, b...)
+		if int64(len(body)) > r.MaxBytes {
+			if r.FailTooLarge {
+				return nil, tooLarge(r.MaxBytes)
+			}
+			return body[:r.MaxBytes], nil
+		}
+		if err == nil {
+			return body, nil
+		}
+		readErr = err
+	}
+
+	return nil, readErr
+}
+
+// Fetch reads a file:// URL. Files that don't exist return a 404.
+func (FileFetcher) Fetch(ctx context.Context, r Request) (*Response, error) {
+	if r.URL.Scheme != "file" {
+		return nil, errUnknownScheme
+	}
+
+	f, err := os.Open(r.URL.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Response{StatusCode: http.StatusNotFound, Header: http.Header{}, FinalURL: r.URL}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var body []byte
+	if r.Method != http.MethodHead {
+		b, err := readBody(f, r)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	hdr := http.Header{}
+	if st, err := f.Stat(); err == nil {
+		hdr.Set("Last-Modified", st.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	return &Response{Body: body, Header: hdr, StatusCode: http.StatusOK, FinalURL: r.URL}, nil
+}
+
+// NewMemFetcher returns an in-memory Fetcher with the given map of URL => body.
+func NewMemFetcher(files map[string][]byte) *MemFetcher {
+	if files == nil {
+		files = make(map[string][]byte)
+	}
+
+	return &MemFetcher{files: files}
+}
+
+// Set adds or replaces the body of a URL.
+func (m *MemFetcher) Set(u string, body []byte) {
+	m.mu.Lock()
+	m.files[u] = body
+	m.mu.Unlock()
+}
+
+// Fetch returns the in-memory body of the given URL.
+func (m *MemFetcher) Fetch(ctx context.Context, r Request) (*Response, error) {
+	m.mu.RLock()
+	b, ok := m.files[r.URL.String()]
+	m.mu.RUnlock()
+
+	if !ok {
+		return &Response{StatusCode: http.StatusNotFound, Header: http.Header{}, FinalURL: r.URL}, nil
+	}
+
+	if int64(len(b)) > r.MaxBytes {
+		if r.FailTooLarge && r.Method != http.MethodHead {
+			return nil, tooLarge(r.MaxBytes)
+		}
+		b = b[:r.MaxBytes]
+	}
+	if r.Method == http.MethodHead {
+		b = nil
+	} else if r.Scan != nil {
+		if err := r.Scan(bytes.NewReader(b)); err != nil {
+			return nil, err
+		}
+		b = nil
+	}
+
+	return &Response{Body: b, Header: http.Header{}, StatusCode: http.StatusOK, FinalURL: r.URL}, nil
+}
+
+// decodeBody returns a reader that decodes a response body as per its Content-Encoding.
+// The bool indicates whether the body is encoded.
+func decodeBody(resp *http.Response, method string) (io.Reader, bool, error) {
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if method == http.MethodHead || enc == "" || enc == "identity" {
+		return resp.Body, false, nil
+	}
+
+	switch enc {
+	case "gzip", "x-gzip":
+		rd, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, true, fmt.Errorf("error decoding gzip response: %v", err)
+		}
+		return rd, true, nil
+	case "deflate":
+		rd, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return nil, true, fmt.Errorf("error decoding deflate response: %v", err)
+		}
+		return rd, true, nil
+	case "br":
+		// Unlike gzip and zlib, brotli has no header, and errors surface when the body is read.
+		return brotli.NewReader(resp.Body), true, nil
+	}
+
+	return nil, true, fmt.Errorf("%w: %s", errUnknownEncoding, enc)
+}
+
+// readBody reads a response body up to the max bytes of a request, or
+// hands the body stream over to the request's Scan function.
+func readBody(body io.Reader, r Request) ([]byte, error) {
+	if r.Scan != nil {
+		return nil, r.Scan(io.LimitReader(body, r.MaxBytes))
+	}
+	if !r.FailTooLarge {
+		return io.ReadAll(io.LimitReader(body, r.MaxBytes))
+	}
+
+	// Read a byte past the limit to tell a body that fits from one that was cut off.
+	b, err := io.ReadAll(io.LimitReader(body, r.MaxBytes+1))
+	if err == nil && int64(len(b)) > r.MaxBytes {
+		return nil, tooLarge(r.MaxBytes)
+	}
+
+	return b, err
+}
+
+func tooLarge(max int64) error {
+	return fmt.Errorf("%w (max %d bytes)", ErrTooLarge, max)
+}
@@ -0,0 +1,326 @@
+package crawl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/floss-fund/go-funding-json/common"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCrawl(f Fetcher) *Crawl {
+	return New(&Opt{
+		HTTP: common.HTTPOpt{
+			ReqTimeout: time.Second,
+			Retries:    1,
+			MaxBytes:   1024,
+			UserAgent:  "test",
+		},
+		Fetcher: f,
+	}, nil, &Callbacks{}, nil, log.New(io.Discard, "", 0))
+}
+
+func TestMemFetcher(t *testing.T) {
+	c := newTestCrawl(NewMemFetcher(map[string][]byte{
+		"https://example.com/funding.json": []byte(`{"version": "v1.0.0"}`),
+	}))
+
+	u, _ := url.Parse("https://example.com/funding.json")
+	resp, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version": "v1.0.0"}`, string(resp.Body))
+
+	// MaxBytes override.
+	resp, err = c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt([]FetchOpt{WithMaxBytes(5)}))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ver`, string(resp.Body))
+
+	u, _ = url.Parse("https://example.com/nope.json")
+	_, err = c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.Error(t, err)
+}
+
+func TestFileFetcher(t *testing.T) {
+	dir := t.TempDir()
+	fPath := filepath.Join(dir, "funding.json")
+	if err := os.WriteFile(fPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCrawl(FileFetcher{})
+
+	resp, err := c.fetch(context.Background(), http.MethodGet, &url.URL{Scheme: "file", Path: fPath}, c.makeFetchOpt(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(resp.Body))
+	assert.NotEmpty(t, resp.Header.Get("Last-Modified"))
+
+	_, err = c.fetch(context.Background(), http.MethodGet, &url.URL{Scheme: "file", Path: filepath.Join(dir, "nope.json")}, c.makeFetchOpt(nil))
+	assert.Error(t, err)
+}
+
+func TestHTTPFetcherResume(t *testing.T) {
+	body := []byte(strings.Repeat("0123456789", 100))
+
+	// The first response is cut off midway and the rest is served with ranges.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") != "" {
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+			return
+		}
+
+		conn, buf, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+
+		// Chunked responses don't declare their length.
+		if r.URL.Path == "/chunked" {
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nAccept-Ranges: bytes\r\nETag: \"v1\"\r\n\r\n%x\r\n", len(body))
+		} else {
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\nAccept-Ranges: bytes\r\nETag: \"v1\"\r\n\r\n", len(body))
+		}
+		buf.Write(body[:300])
+		buf.Flush()
+	}))
+	defer srv.Close()
+
+	h := NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, TransportOpt{}, nil, nil, nil)
+	c := newTestCrawl(h)
+	c.opt.HTTP.MaxBytes = 10000
+
+	u, _ := url.Parse(srv.URL)
+	resp, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, body, resp.Body)
+
+	// Requests without headers are resumed too.
+	r, err := h.Fetch(context.Background(), Request{Method: http.MethodGet, URL: u, MaxBytes: 10000})
+	assert.NoError(t, err)
+	assert.Equal(t, body, r.Body)
+
+	// The limit applies to the resumed body.
+	u, _ = url.Parse(srv.URL + "/chunked")
+	_, err = h.Fetch(context.Background(), Request{Method: http.MethodGet, URL: u, MaxBytes: 500, FailTooLarge: true})
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	r, err = h.Fetch(context.Background(), Request{Method: http.MethodGet, URL: u, MaxBytes: 500})
+	assert.NoError(t, err)
+	assert.Equal(t, body[:500], r.Body)
+}
+
+func TestConditionalFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, TransportOpt{}, nil, nil, nil))
+
+	u, _ := url.Parse(srv.URL + "/funding.json")
+	res, err := c.FetchManifest(context.Background(), u, WithConditional(`"v1"`, time.Time{}))
+	assert.NoError(t, err)
+	assert.True(t, res.NotModified)
+	assert.Equal(t, `"v1"`, res.ETag)
+}
+
+func TestKnownHashFetch(t *testing.T) {
+	c := newTestCrawl(NewMemFetcher(map[string][]byte{
+		"https://example.com/funding.json": []byte(`{}`),
+	}))
+
+	// The manifest isn't parsed (there's no schema) if the contents are unchanged.
+	u, _ := url.Parse("https://example.com/funding.json")
+	res, err := c.FetchManifest(context.Background(), u, WithKnownHash("44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"))
+	assert.NoError(t, err)
+	assert.True(t, res.Unchanged)
+	assert.Equal(t, "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", res.Hash)
+}
+
+func TestFetchCompressed(t *testing.T) {
+	// 10 MB of zeroes compress to ~10 KB.
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(make([]byte, 10*1024*1024))
+	w.Close()
+
+	var br bytes.Buffer
+	bw := brotli.NewWriter(&br)
+	bw.Write(make([]byte, 10*1024*1024))
+	bw.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			assert.Contains(t, r.Header.Get("Accept-Encoding"), "gzip")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gz.Bytes())
+		case "/br":
+			assert.Contains(t, r.Header.Get("Accept-Encoding"), "br")
+			w.Header().Set("Content-Encoding", "br")
+			w.Write(br.Bytes())
+		case "/zstd":
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write([]byte("x"))
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, TransportOpt{}, nil, nil, nil))
+	c.opt.HTTP.MaxBytes = 1000
+
+	// MaxBytes applies to the decompressed body.
+	u, _ := url.Parse(srv.URL + "/gzip")
+	resp, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, len(resp.Body))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	u, _ = url.Parse(srv.URL + "/br")
+	resp, err = c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, len(resp.Body))
+
+	u, _ = url.Parse(srv.URL + "/zstd")
+	_, err = c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.ErrorIs(t, err, errUnknownEncoding)
+}
+
+func TestWithLimits(t *testing.T) {
+	var (
+		c        = newTestCrawl(nil)
+		maxBytes = int64(1 << 20)
+		attempts = 3
+	)
+
+	// Unset limits are the global ones.
+	o := c.makeFetchOpt([]FetchOpt{WithLimits(models.FetchLimits{})})
+	assert.Equal(t, int64(1024), o.maxBytes)
+	assert.Equal(t, time.Second, o.timeout)
+	assert.Equal(t, 1, o.retries)
+
+	o = c.makeFetchOpt([]FetchOpt{WithLimits(models.FetchLimits{MaxBytes: &maxBytes, Attempts: &attempts})})
+	assert.Equal(t, maxBytes, o.maxBytes)
+	assert.Equal(t, time.Second, o.timeout)
+	assert.Equal(t, 3, o.retries)
+}
+
+func TestFetchTooLarge(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Chunked responses don't declare their length.
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{MaxHostConns: 2, ReqTimeout: time.Second}, TransportOpt{}, nil, nil, nil))
+	f := func(path string, max int64, expErr bool) {
+		u, _ := url.Parse(srv.URL + path)
+		o := c.makeFetchOpt([]FetchOpt{WithMaxBytes(max)})
+		o.failTooLarge = true
+
+		resp, err := c.fetch(context.Background(), http.MethodGet, u, o)
+		if expErr {
+			assert.ErrorIs(t, err, ErrTooLarge, path)
+			return
+		}
+		assert.NoError(t, err, path)
+		assert.Equal(t, body, string(resp.Body), path)
+	}
+	f("/", 50, true)
+	f("/chunked", 50, true)
+	f("/", 100, false)
+	f("/chunked", 100, false)
+
+	// Without failTooLarge, the body is truncated.
+	u, _ := url.Parse(srv.URL)
+	resp, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt([]FetchOpt{WithMaxBytes(50)}))
+	assert.NoError(t, err)
+	assert.Equal(t, body[:50], string(resp.Body))
+}
+
+func TestFetchDrainLimit(t *testing.T) {
+	var (
+		written atomic.Int64
+		done    = make(chan struct{})
+	)
+
+	// A large body that's streamed until the client goes away.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+
+		b := make([]byte, 32*1024)
+		for written.Load() < 200*1024*1024 {
+			n, err := w.Write(b)
+			written.Add(int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{MaxHostConns: 1, ReqTimeout: time.Second * 10}, TransportOpt{}, nil, nil, nil))
+
+	// The truncated body isn't downloaded in full to drain the connection.
+	u, _ := url.Parse(srv.URL)
+	resp, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt([]FetchOpt{WithMaxBytes(1000)}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, len(resp.Body))
+
+	<-done
+	assert.Less(t, written.Load(), int64(100*1024*1024))
+}
+
+func TestTransportOpt(t *testing.T) {
+	var proto atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto.Store(int32(r.ProtoMajor))
+		w.Write([]byte("{}"))
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	f := func(o TransportOpt, expErr bool, expProto int32) {
+		c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, o, nil, nil, nil))
+		_, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+		if expErr {
+			assert.Error(t, err, o)
+			return
+		}
+		assert.NoError(t, err, o)
+		assert.Equal(t, expProto, proto.Load(), o)
+	}
+
+	// The test server's certificate is self-signed.
+	f(TransportOpt{}, true, 0)
+	f(TransportOpt{TLSSkipVerify: true}, false, 1)
+	f(TransportOpt{TLSSkipVerify: true, ForceHTTP2: true}, false, 2)
+	f(TransportOpt{TLSSkipVerify: true, ForceHTTP2: true, DisableHTTP2: true}, false, 1)
+	f(TransportOpt{TLSSkipVerify: true, DisableKeepAlives: true}, false, 1)
+}
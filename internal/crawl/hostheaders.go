@@ -0,0 +1,89 @@
+package crawl
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HostHeaders are extra request headers, and optionally basic or bearer auth, that
+// are sent to hosts matching a pattern, eg: hosts that require a token during a
+// private beta.
+type HostHeaders struct {
+	// Hostname, or *.example.com to match the subdomains of example.com.
+	Host    string            `json:"host"`
+	Headers map[string]string `json:"headers"`
+
+	// Basic auth if Username is set, or bearer auth if Token is set.
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+// ValidateHostHeaders validates a list of HostHeaders.
+func ValidateHostHeaders(hh []HostHeaders) error {
+	for _, h := range hh {
+		host := strings.TrimPrefix(h.Host, "*.")
+		if host == "" || strings.ContainsAny(host, "*/:@ ") {
+			return fmt.Errorf("invalid host pattern: %q", h.Host)
+		}
+		if h.Username != "" && h.Token != "" {
+			return fmt.Errorf("%s: only one of username or token can be set", h.Host)
+		}
+		if h.Username == "" && h.Token == "" && len(h.Headers) == 0 {
+			return fmt.Errorf("%s: no headers or auth", h.Host)
+		}
+
+		for k := range h.Headers {
+			if k == "" || strings.ContainsAny(k, " :\r\n") {
+				return fmt.Errorf("%s: invalid header name: %q", h.Host, k)
+			}
+		}
+	}
+
+	return nil
+}
+
+// matches checks whether a (lowercase) hostname matches the host pattern.
+func (h HostHeaders) matches(host string) bool {
+	p := strings.ToLower(h.Host)
+	if d, ok := strings.CutPrefix(p, "*."); ok {
+		return strings.HasSuffix(host, "."+d)
+	}
+
+	return host == p
+}
+
+// hostHeaderTransport adds HostHeaders to the requests to matching hosts. As it's
+// applied per request, including redirects, the headers are never sent to other hosts.
+type hostHeaderTransport struct {
+	rt    http.RoundTripper
+	hosts []HostHeaders
+}
+
+func (t *hostHeaderTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	host := strings.ToLower(r.URL.Hostname())
+	cloned := false
+	for _, h := range t.hosts {
+		if !h.matches(host) {
+			continue
+		}
+
+		// RoundTrippers must not modify the request.
+		if !cloned {
+			r = r.Clone(r.Context())
+			cloned = true
+		}
+
+		for k, v := range h.Headers {
+			r.Header.Set(k, v)
+		}
+		if h.Username != "" {
+			r.SetBasicAuth(h.Username, h.Password)
+		} else if h.Token != "" {
+			r.Header.Set("Authorization", "Bearer "+h.Token)
+		}
+	}
+
+	return t.rt.RoundTrip(r)
+}
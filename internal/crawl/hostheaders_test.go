@@ -0,0 +1,53 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostHeaders(t *testing.T) {
+	// Echoes the auth headers, and redirects /moved to the same server on another hostname.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, strings.Replace("http://"+r.Host, "127.0.0.1", "localhost", 1)+"/", http.StatusFound)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Beta")))
+	}))
+	defer srv.Close()
+
+	hh := []HostHeaders{
+		{Host: "127.0.0.1", Token: "secret", Headers: map[string]string{"X-Beta": "1"}},
+		{Host: "*.example.com", Username: "u", Password: "p"},
+	}
+	assert.NoError(t, ValidateHostHeaders(hh))
+
+	c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 2}, TransportOpt{HostHeaders: hh}, nil, nil, nil))
+	f := func(u string, exp string) {
+		pu, _ := url.Parse(u)
+		resp, err := c.fetch(context.Background(), http.MethodGet, pu, c.makeFetchOpt(nil))
+		assert.NoError(t, err, u)
+		assert.Equal(t, exp, string(resp.Body), u)
+	}
+	f(srv.URL, "Bearer secret|1")
+
+	// Not sent to other hosts on redirects.
+	f(srv.URL+"/moved", "|")
+
+	assert.True(t, hh[1].matches("git.example.com"))
+	assert.False(t, hh[1].matches("example.com"))
+	assert.False(t, hh[1].matches("badexample.com"))
+
+	assert.Error(t, ValidateHostHeaders([]HostHeaders{{Host: "", Token: "x"}}))
+	assert.Error(t, ValidateHostHeaders([]HostHeaders{{Host: "example.com", Token: "x", Username: "u"}}))
+	assert.Error(t, ValidateHostHeaders([]HostHeaders{{Host: "example.com"}}))
+	assert.Error(t, ValidateHostHeaders([]HostHeaders{{Host: "example.com", Headers: map[string]string{"X Bad": "1"}}}))
+}
@@ -0,0 +1,101 @@
+package crawl
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostLimiter is a per-host politeness limiter that spaces out requests to a host
+// (requests per second) and caps the number of concurrent requests to it, so that
+// many manifests on the same host (eg: of a fiscal host) don't slam it.
+type hostLimiter struct {
+	interval time.Duration
+	maxConc  int
+
+	hosts map[string]*hostLimit
+	mu    sync.Mutex
+}
+
+type hostLimit struct {
+	sem  chan struct{}
+	next time.Time
+}
+
+// newHostLimiter returns a limiter. rps <= 0 or maxConc <= 0 disable the respective limits.
+func newHostLimiter(rps float64, maxConc int) *hostLimiter {
+	l := &hostLimiter{
+		maxConc: maxConc,
+		hosts:   make(map[string]*hostLimit),
+	}
+	if rps > 0 {
+		l.interval = time.Duration(float64(time.Second) / rps)
+	}
+
+	return l
+}
+
+// acquire blocks until a request can be made to a host (or ctx is done) and returns
+// the function that must be called after the request is done.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	l.mu.Lock()
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostLimit{}
+		if l.maxConc > 0 {
+			h.sem = make(chan struct{}, l.maxConc)
+		}
+		l.hosts[host] = h
+	}
+	l.mu.Unlock()
+
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	done := func() {
+		if h.sem != nil {
+			<-h.sem
+		}
+	}
+
+	// Reserve the next slot of the host.
+	if l.interval > 0 {
+		l.mu.Lock()
+		now := time.Now()
+		if h.next.Before(now) {
+			h.next = now
+		}
+		wait := h.next.Sub(now)
+		h.next = h.next.Add(l.interval)
+		l.mu.Unlock()
+
+		if err := sleep(ctx, wait); err != nil {
+			done()
+			return nil, err
+		}
+	}
+
+	return done, nil
+}
+
+// sleep sleeps for the given duration or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,59 @@
+package crawl
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostLimiter(t *testing.T) {
+	// Requests to a host are spaced out.
+	l := newHostLimiter(20, 0)
+	start := time.Now()
+	for n := 0; n < 5; n++ {
+		done, _ := l.acquire(context.Background(), "a.com")
+		done()
+	}
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*200)
+
+	// Other hosts aren't affected.
+	start = time.Now()
+	done, _ := l.acquire(context.Background(), "b.com")
+	done()
+	assert.Less(t, time.Since(start), time.Millisecond*50)
+
+	// Concurrency is capped.
+	l = newHostLimiter(0, 2)
+	var (
+		cur, max atomic.Int32
+		wg       sync.WaitGroup
+	)
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done, _ := l.acquire(context.Background(), "a.com")
+			if c := cur.Add(1); c > max.Load() {
+				max.Store(c)
+			}
+			time.Sleep(time.Millisecond * 10)
+			cur.Add(-1)
+			done()
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, max.Load(), int32(2))
+
+	// Waits are aborted when the context is done.
+	l = newHostLimiter(1, 1)
+	done, _ = l.acquire(context.Background(), "a.com")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err := l.acquire(ctx, "a.com")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	done()
+}
@@ -0,0 +1,330 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// FetchOpt overrides one of the global HTTP options (Opt.HTTP) for a single fetch.
+type FetchOpt func(*fetchOpt)
+
+// fetchOpt represents the effective options for a single fetch.
+type fetchOpt struct {
+	timeout  time.Duration
+	maxBytes int64
+	retries  int
+	headers  http.Header
+	scan     func(io.Reader) error
+	trace    *models.ManifestTrace
+
+	// Phase of the request for metrics.
+	phase string
+
+	// SHA-256 of the body of a previous fetch.
+	knownHash string
+
+	ignoreRobots bool
+
+	// Fail bodies that exceed maxBytes instead of truncating them.
+	failTooLarge bool
+}
+
+// WithTimeout overrides the request timeout for a fetch.
+func WithTimeout(d time.Duration) FetchOpt {
+	return func(o *fetchOpt) {
+		o.timeout = d
+	}
+}
+
+// WithMaxBytes overrides the maximum number of body bytes read for a fetch.
+func WithMaxBytes(n int64) FetchOpt {
+	return func(o *fetchOpt) {
+		o.maxBytes = n
+	}
+}
+
+// WithRetries overrides the number of attempts made for a fetch.
+func WithRetries(n int) FetchOpt {
+	return func(o *fetchOpt) {
+		o.retries = n
+	}
+}
+
+// WithLimits overrides the global limits with a manifest's fetch limits (size, timeout,
+// attempts) for a fetch. Unset limits are left as they are.
+func WithLimits(l models.FetchLimits) FetchOpt {
+	return func(o *fetchOpt) {
+		if l.MaxBytes != nil {
+			o.maxBytes = *l.MaxBytes
+		}
+		if l.TimeoutMS != nil {
+			o.timeout = time.Duration(*l.TimeoutMS) * time.Millisecond
+		}
+		if l.Attempts != nil {
+			o.retries = *l.Attempts
+		}
+	}
+}
+
+// WithHeaders adds (or replaces) request headers for a fetch.
+func WithHeaders(h http.Header) FetchOpt {
+	return func(o *fetchOpt) {
+		for k, v := range h {
+			o.headers[k] = v
+		}
+	}
+}
+
+// WithConditional makes a fetch conditional (If-None-Match, If-Modified-Since) with
+// the validators of a previous fetch. Empty validators are not sent.
+func WithConditional(etag string, lastModified time.Time) FetchOpt {
+	return func(o *fetchOpt) {
+		if etag != "" {
+			o.headers.Set("If-None-Match", etag)
+		}
+		if !lastModified.IsZero() {
+			o.headers.Set("If-Modified-Since", lastModified.UTC().Format(http.TimeFormat))
+		}
+	}
+}
+
+// WithKnownHash skips parsing and validating a fetched manifest if the SHA-256 (hex)
+// of its body is the given hash of a previous fetch. The result is Unchanged.
+// An empty hash is ignored.
+func WithKnownHash(hash string) FetchOpt {
+	return func(o *fetchOpt) {
+		o.knownHash = hash
+	}
+}
+
+// IgnoreRobots bypasses robots.txt for a fetch, eg: for URLs explicitly submitted by users.
+func IgnoreRobots() FetchOpt {
+	return func(o *fetchOpt) {
+		o.ignoreRobots = true
+	}
+}
+
+// withScan streams the body of a fetch to the given function instead of reading it.
+func withScan(fn func(io.Reader) error) FetchOpt {
+	return func(o *fetchOpt) {
+		o.scan = fn
+	}
+}
+
+// withPhase sets the phase of a fetch that's recorded in metrics.
+func withPhase(p string) FetchOpt {
+	return func(o *fetchOpt) {
+		o.phase = p
+	}
+}
+
+// makeFetchOpt returns the fetch options derived from the global HTTP options
+// with the given overrides applied.
+func (c *Crawl) makeFetchOpt(opts []FetchOpt) fetchOpt {
+	o := fetchOpt{
+		timeout:  c.opt.HTTP.ReqTimeout,
+		maxBytes: c.opt.HTTP.MaxBytes,
+		retries:  c.opt.HTTP.Retries,
+		headers:  http.Header{},
+		phase:    PhaseOther,
+	}
+	o.headers.Set("User-Agent", c.opt.HTTP.UserAgent)
+
+	for _, f := range opts {
+		f(&o)
+	}
+
+	if o.retries < 1 {
+		o.retries = 1
+	}
+
+	return o
+}
+
+// fetch fetches a given URL with error retries. On non-2xx responses, the
+// response is returned along with the error.
+func (c *Crawl) fetch(ctx context.Context, method string, u *url.URL, o fetchOpt) (*Response, error) {
+	var (
+		resp  *Response
+		err   error
+		retry bool
+	)
+
+	if err := c.guard.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	// Host is disabled due to rate limiting.
+	if c.isRateLimited(u.Host) {
+		return nil, ErrRatelimited
+	}
+
+	if c.opt.RespectRobots && !o.ignoreRobots && !c.allowRobots(ctx, u, o) {
+		return nil, &RobotsError{URL: u.String()}
+	}
+
+	// Host is down.
+	trial, err := c.breaker.allow(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	// A trial request that ends without a recorded outcome (eg: cancelled) shouldn't
+	// keep the host's circuit open.
+	defer func() {
+		if trial {
+			c.breaker.release(u.Hostname())
+		}
+	}()
+
+	// Retry N times.
+	for n := 0; n < o.retries; n++ {
+		resp, retry, err = c.doReq(ctx, method, u, o)
+
+		// Connection errors, timeouts, and server errors count towards the host's
+		// circuit breaker. Any other response means that the host is up.
+		if ctx.Err() == nil {
+			c.breaker.record(u.Hostname(), err != nil && retry && (resp == nil || resp.StatusCode != http.StatusTooManyRequests))
+			trial = false
+		}
+		if err == nil || !retry {
+			break
+		}
+
+		// If the host sent a 429, don't send any more requests.
+		if c.opt.HTTP.SkipRateLimitedHost && resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			c.setRateLimited(u.Host)
+			return nil, ErrRatelimited
+		}
+
+		if n < o.retries-1 {
+			// Stop retrying if the failures tripped the breaker.
+			t, bErr := c.breaker.allow(u.Hostname())
+			if bErr != nil {
+				return resp, bErr
+			}
+			trial = trial || t
+
+			if err := sleep(ctx, c.retryWait(n+1)); err != nil {
+				return nil, err
+			}
+			c.metrics.ObserveRetry(o.phase)
+		}
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// doReq executes an HTTP request. The bool indicates whether it's a retriable error.
+// On non-2xx responses, the response is returned along with the error.
+func (c *Crawl) doReq(ctx context.Context, method string, u *url.URL, o fetchOpt) (resp *Response, retry bool, retErr error) {
+	var (
+		statusCode = 0
+		reqStart   = time.Now()
+	)
+	defer func() {
+		msg := "OK"
+		if retErr != nil {
+			msg = retErr.Error()
+		}
+
+		c.logf(ctx, "%s %s -> %d: %v duration=%s", method, u.String(), statusCode, msg, time.Since(reqStart))
+	}()
+
+	// Wait for the host's politeness limits. The wait doesn't count towards the timeout.
+	done, err := c.hostLimits.acquire(ctx, u.Hostname())
+	if err != nil {
+		return nil, false, err
+	}
+	defer done()
+
+	rctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	var (
+		hdr   = o.headers.Clone()
+		scan  = o.scan
+		cr    *countReader
+		start = time.Now()
+	)
+	if scan != nil {
+		scan = func(r io.Reader) error {
+			cr = &countReader{r: r}
+			return o.scan(cr)
+		}
+	}
+	r, err := c.fetcher.Fetch(rctx, Request{
+		Method:       method,
+		URL:          u,
+		Header:       hdr,
+		MaxBytes:     o.maxBytes,
+		Scan:         scan,
+		FailTooLarge: o.failTooLarge,
+	})
+	c.observeRequest(o.phase, r, cr, time.Since(start))
+	if o.trace != nil {
+		traceExchange(o.trace, method, u, hdr, r, time.Since(start), err)
+	}
+	if err != nil {
+		// If the caller's context is done, or the body is too large, there's no point in retrying.
+		return nil, ctx.Err() == nil && !errors.Is(err, ErrTooLarge), err
+	}
+	statusCode = r.StatusCode
+
+	if r.StatusCode > 299 {
+		// Only rate limits and server errors are worth retrying.
+		retry := r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500
+		return r, retry, &StatusError{URL: u.String(), StatusCode: r.StatusCode}
+	}
+
+	return r, false, nil
+}
+
+// observeRequest records a request in the metrics.
+func (c *Crawl) observeRequest(phase string, r *Response, cr *countReader, d time.Duration) {
+	var (
+		code  int
+		bytes int64
+	)
+	if r != nil {
+		code = r.StatusCode
+		bytes = int64(len(r.Body))
+	}
+	if cr != nil {
+		bytes = cr.n
+	}
+
+	c.metrics.ObserveRequest(phase, code, bytes, d)
+}
+
+// retryWait returns the wait before the n-th retry of a fetch.
+func (c *Crawl) retryWait(n int) time.Duration {
+	if c.opt.Backoff.Base <= 0 {
+		return c.opt.HTTP.RetryWait
+	}
+
+	return c.opt.Backoff.wait(n)
+}
+
+func (c *Crawl) isRateLimited(host string) bool {
+	c.mu.RLock()
+	_, ok := c.rateLimited[host]
+	c.mu.RUnlock()
+
+	return ok
+}
+
+func (c *Crawl) setRateLimited(host string) {
+	c.mu.Lock()
+	c.rateLimited[host] = struct{}{}
+	c.mu.Unlock()
+}
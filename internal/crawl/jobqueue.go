@@ -0,0 +1,112 @@
+package crawl
+
+import (
+	"context"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Queue is a durable crawl job queue (eg: Postgres-backed, core.Core). With it, a crawl
+// first adds all the manifests due for crawling to the queue and then leases jobs in
+// batches, removing (acking) each one once it's processed. Jobs of an interrupted crawl
+// remain in the queue and are picked up by the next crawl, and jobs whose leases
+// expire (eg: the process was killed) are leased again.
+type Queue interface {
+	// EnqueueCrawlJobs adds manifests to the queue with a priority (Priority). Manifests that are
+	// already in it are left as they are, unless they're enqueued as PriorityInteractive, which
+	// makes them available right away at the top priority.
+	EnqueueCrawlJobs(ids []int, p int) error
+
+	// LeaseCrawlJobs leases up to limit available jobs for the lease period in the order of priority.
+	LeaseCrawlJobs(limit int, lease time.Duration) ([]models.ManifestJob, error)
+
+	// AckCrawlJob removes a processed job.
+	AckCrawlJob(id int) error
+
+	// RetryCrawlJob counts a failed attempt of a job and makes it available again after delay,
+	// lowering it to the given priority (Priority) if it's higher.
+	RetryCrawlJob(id int, delay time.Duration, p int) error
+
+	// ReleaseCrawlJob makes an unprocessed job available again without counting an attempt.
+	ReleaseCrawlJob(id int) error
+}
+
+// Classes of errors that failed jobs in the durable queue are retried on.
+var retryClasses = map[string]bool{
+	ErrClassTimeout:     true,
+	ErrClassConnection:  true,
+	ErrClassRatelimited: true,
+	ErrClassCircuitOpen: true,
+	ErrClassHTTP5xx:     true,
+}
+
+// leaseWorker adds the manifests due for crawling to the durable queue and then
+// feeds the workers with leased jobs until there are none available. Jobs that are
+// scheduled for a retry later are left for the next crawl.
+func (c *Crawl) leaseWorker(ctx context.Context) {
+	// Jobs left over from an interrupted crawl are already in the queue.
+	lastID := 0
+	for ctx.Err() == nil {
+		items, err := c.db.GetManifestForCrawling(c.opt.ManifestAge, lastID, c.opt.BatchSize)
+		if err != nil {
+			sleep(ctx, time.Second*5)
+			continue
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		ids := make([]int, 0, len(items))
+		for _, i := range items {
+			ids = append(ids, i.ID)
+		}
+		if err := c.opt.Queue.EnqueueCrawlJobs(ids, int(PriorityScheduled)); err != nil {
+			sleep(ctx, time.Second*5)
+			continue
+		}
+
+		lastID = items[len(items)-1].ID
+	}
+
+	n := 0
+	for ctx.Err() == nil {
+		jobs, err := c.opt.Queue.LeaseCrawlJobs(c.opt.BatchSize, c.opt.QueueLease)
+		if err != nil {
+			sleep(ctx, time.Second*5)
+			continue
+		}
+
+		if len(jobs) == 0 {
+			c.log.Println("no more jobs in the crawl queue. stopping.")
+			break
+		}
+
+		n++
+		c.log.Printf("leased batch %d of %d jobs", n, len(jobs))
+		for _, j := range jobs {
+			c.queue.push(j, Priority(j.Priority))
+		}
+	}
+}
+
+// finishJob acks a job in the durable queue once it's processed. Jobs that failed
+// with a transient error are retried with a backoff up to Opt.QueueMaxAttempts, and
+// jobs that weren't processed as the crawl was interrupted are released.
+func (c *Crawl) finishJob(ctx context.Context, j models.ManifestJob, err error) {
+	q := c.opt.Queue
+	if q == nil {
+		return
+	}
+
+	attempt := j.Attempts + 1
+	switch {
+	case ctx.Err() != nil:
+		q.ReleaseCrawlJob(j.ID)
+	case err != nil && retryClasses[ClassifyError(err)] && attempt < c.opt.QueueMaxAttempts:
+		b := Backoff{Base: c.opt.QueueRetryWait, Multiplier: 2, Jitter: 0.2}
+		q.RetryCrawlJob(j.ID, b.wait(attempt), int(PriorityBackfill))
+	default:
+		q.AckCrawlJob(j.ID)
+	}
+}
@@ -0,0 +1,50 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// memJobQueue records the outcomes of jobs.
+type memJobQueue struct {
+	out map[int]string
+}
+
+func (q *memJobQueue) EnqueueCrawlJobs(ids []int, p int) error { return nil }
+func (q *memJobQueue) LeaseCrawlJobs(limit int, lease time.Duration) ([]models.ManifestJob, error) {
+	return nil, nil
+}
+func (q *memJobQueue) AckCrawlJob(id int) error { q.out[id] = "ack"; return nil }
+func (q *memJobQueue) RetryCrawlJob(id int, delay time.Duration, p int) error {
+	q.out[id] = "retry"
+	return nil
+}
+func (q *memJobQueue) ReleaseCrawlJob(id int) error { q.out[id] = "release"; return nil }
+
+func TestFinishJob(t *testing.T) {
+	var (
+		q = &memJobQueue{out: map[int]string{}}
+		c = &Crawl{opt: &Opt{Queue: q, QueueMaxAttempts: 3, QueueRetryWait: time.Minute}}
+
+		cancelled, cancel = context.WithCancel(context.Background())
+	)
+	cancel()
+
+	f := func(ctx context.Context, attempts int, err error, exp string) {
+		j := models.ManifestJob{ID: len(q.out), Attempts: attempts}
+		c.finishJob(ctx, j, err)
+		assert.Equal(t, exp, q.out[j.ID], err)
+	}
+	f(context.Background(), 0, nil, "ack")
+	f(context.Background(), 0, &StatusError{StatusCode: 503}, "retry")
+	f(context.Background(), 1, ErrRatelimited, "retry")
+	f(context.Background(), 2, &StatusError{StatusCode: 503}, "ack")
+	f(context.Background(), 0, &StatusError{StatusCode: 404}, "ack")
+	f(context.Background(), 0, errors.New("invalid manifest"), "ack")
+	f(cancelled, 0, nil, "release")
+}
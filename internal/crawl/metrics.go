@@ -0,0 +1,52 @@
+package crawl
+
+import (
+	"io"
+	"strconv"
+	"time"
+)
+
+// Phases of crawler requests recorded in Metrics.
+const (
+	PhaseManifest   = "manifest"
+	PhaseProvenance = "provenance"
+	PhaseOther      = "other"
+)
+
+// Metrics records the crawler's requests, eg: in Prometheus collectors, so that
+// operators can see crawl health. Its methods are called concurrently.
+type Metrics interface {
+	// ObserveRequest records a request (every attempt) of a phase with the status
+	// code of its response (0 if there's none), the body bytes read, and its duration.
+	ObserveRequest(phase string, statusCode int, bytes int64, d time.Duration)
+
+	// ObserveRetry records the retry of a failed request of a phase.
+	ObserveRetry(phase string)
+}
+
+// StatusClass returns the class (2xx, 3xx, 4xx, 5xx) of a status code,
+// or "error" for requests without a response.
+func StatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "error"
+	}
+
+	return strconv.Itoa(code/100) + "xx"
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(string, int, int64, time.Duration) {}
+func (nopMetrics) ObserveRetry(string)                              {}
+
+// countReader counts the bytes read from a reader.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,74 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetrics struct {
+	reqs    []string
+	bytes   int64
+	retries map[string]int
+	mu      sync.Mutex
+}
+
+func (m *testMetrics) ObserveRequest(phase string, code int, bytes int64, d time.Duration) {
+	m.mu.Lock()
+	m.reqs = append(m.reqs, phase+":"+StatusClass(code))
+	m.bytes += bytes
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) ObserveRetry(phase string) {
+	m.mu.Lock()
+	m.retries[phase]++
+	m.mu.Unlock()
+}
+
+func TestMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down/funding.json" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	m := &testMetrics{retries: map[string]int{}}
+	c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, TransportOpt{}, nil, nil, nil))
+	c.metrics = m
+	c.opt.HTTP.Retries = 2
+
+	u, _ := url.Parse(srv.URL + "/funding.json")
+	_, err := c.Fetch(context.Background(), u)
+	assert.NoError(t, err)
+
+	u, _ = url.Parse(srv.URL + "/down/funding.json")
+	_, err = c.FetchManifest(context.Background(), u)
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"other:2xx", "manifest:5xx", "manifest:5xx"}, m.reqs)
+	assert.Equal(t, int64(2), m.bytes)
+	assert.Equal(t, map[string]int{PhaseManifest: 1}, m.retries)
+}
+
+func TestStatusClass(t *testing.T) {
+	f := func(code int, exp string) {
+		assert.Equal(t, exp, StatusClass(code), code)
+	}
+
+	f(0, "error")
+	f(200, "2xx")
+	f(304, "3xx")
+	f(404, "4xx")
+	f(503, "5xx")
+}
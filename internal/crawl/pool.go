@@ -0,0 +1,64 @@
+package crawl
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// PoolResult is the outcome of fetching one of the URLs given to FetchAll.
+type PoolResult struct {
+	URL    string
+	Result FetchResult
+	Err    error
+}
+
+// FetchAll fetches and parses many manifests (FetchManifest) concurrently with up to
+// concurrency workers. Fetches go through the same per-host limits as crawls. Results
+// are sent on the returned channel in the order they complete, and the channel is
+// closed once all URLs have been processed. The channel must be drained. If ctx is
+// cancelled, the remaining URLs are returned with the context's error.
+func (c *Crawl) FetchAll(ctx context.Context, urls []string, concurrency int, opts ...FetchOpt) <-chan PoolResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	concurrency = min(concurrency, max(len(urls), 1))
+
+	var (
+		jobs = make(chan string)
+		out  = make(chan PoolResult, concurrency)
+		wg   sync.WaitGroup
+	)
+
+	for n := 0; n < concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for u := range jobs {
+				res := PoolResult{URL: u}
+
+				pu, err := url.Parse(u)
+				if err != nil {
+					res.Err = err
+				} else {
+					res.Result, res.Err = c.FetchManifest(ctx, pu, opts...)
+				}
+
+				out <- res
+			}
+		}()
+	}
+
+	go func() {
+		for _, u := range urls {
+			jobs <- u
+		}
+		close(jobs)
+
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
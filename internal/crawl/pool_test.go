@@ -0,0 +1,64 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSchema struct{}
+
+func (testSchema) Validate(m models.ManifestData) (models.ManifestData, error) { return m, nil }
+
+func (testSchema) ParseManifest(_ context.Context, b []byte, u string, _ bool) (models.ManifestData, error) {
+	if string(b) != "{}" {
+		return models.ManifestData{}, errors.New("invalid manifest")
+	}
+	return models.ManifestData{}, nil
+}
+
+func TestFetchAll(t *testing.T) {
+	var (
+		files = map[string][]byte{}
+		urls  []string
+	)
+	for n := 0; n < 20; n++ {
+		u := fmt.Sprintf("https://example%d.com/funding.json", n%5)
+		if n >= 5 {
+			u = fmt.Sprintf("https://example%d.com/%d/funding.json", n%5, n)
+		}
+		files[u] = []byte("{}")
+		urls = append(urls, u)
+	}
+	files["https://bad.com/funding.json"] = []byte("{")
+	urls = append(urls, "https://bad.com/funding.json", "https://nope.com/funding.json", "://x")
+
+	c := newTestCrawl(NewMemFetcher(files))
+	c.sc = testSchema{}
+
+	var ok, failed int
+	seen := map[string]bool{}
+	for r := range c.FetchAll(context.Background(), urls, 4) {
+		seen[r.URL] = true
+		if r.Err != nil {
+			failed++
+		} else {
+			ok++
+		}
+	}
+
+	assert.Equal(t, 20, ok)
+	assert.Equal(t, 3, failed)
+	assert.Len(t, seen, len(urls))
+
+	// No URLs.
+	n := 0
+	for range c.FetchAll(context.Background(), nil, 4) {
+		n++
+	}
+	assert.Equal(t, 0, n)
+}
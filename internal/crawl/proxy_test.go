@@ -0,0 +1,46 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy(t *testing.T) {
+	// A forward proxy on loopback, which is otherwise blocked by the Guard.
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	p, err := NewProxy(proxy.URL, map[string]string{"direct.example.com": "direct"})
+	assert.NoError(t, err)
+
+	g := &Guard{Schemes: []string{"http", "https"}}
+	c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, TransportOpt{}, g, p, nil))
+
+	u, _ := url.Parse("http://proxied.example.com/funding.json")
+	resp, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, u.String(), string(resp.Body))
+
+	// Overridden to connect directly.
+	assert.Nil(t, p.Hosts["direct.example.com"])
+	req, _ := http.NewRequest(http.MethodGet, "http://direct.example.com/funding.json", nil)
+	pu, _ := p.proxyFor(req)
+	assert.Nil(t, pu)
+
+	_, err = NewProxy("ftp://10.0.0.1", nil)
+	assert.Error(t, err)
+	_, err = NewProxy("", map[string]string{"example.com": "10.0.0.1:1080"})
+	assert.Error(t, err)
+	p, err = NewProxy("", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
@@ -0,0 +1,125 @@
+package crawl
+
+import (
+	"sync"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Priority is the priority class of a crawl job. It's stored with jobs in the
+// durable queue (Queue) and carried over when they're leased.
+type Priority int
+
+const (
+	// Recrawls requested by admins (eg: bulk moderation).
+	PriorityInteractive Priority = iota
+
+	// Manifests due for their regular crawl.
+	PriorityScheduled
+
+	// Retries of jobs that failed with transient errors.
+	PriorityBackfill
+
+	numPriorities
+)
+
+// priorityWeights is the number of dequeue slots each priority class gets in one
+// round of the schedule. Higher classes are preferred, but lower classes are never
+// starved even when there's a steady stream of higher priority jobs.
+var priorityWeights = [numPriorities]int{8, 2, 1}
+
+// queue is a crawl job queue with priority classes and weighted dequeueing.
+type queue struct {
+	classes [numPriorities]chan models.ManifestJob
+	done    chan struct{}
+	once    sync.Once
+
+	// Weighted round robin schedule of classes and the current position in it.
+	sched  []Priority
+	cursor int
+	mu     sync.Mutex
+}
+
+func newQueue(size int) *queue {
+	q := &queue{
+		done: make(chan struct{}),
+	}
+
+	for p := Priority(0); p < numPriorities; p++ {
+		q.classes[p] = make(chan models.ManifestJob, size)
+
+		for n := 0; n < priorityWeights[p]; n++ {
+			q.sched = append(q.sched, p)
+		}
+	}
+
+	return q
+}
+
+// push adds a job to the given priority class. It blocks if the class is full.
+func (q *queue) push(j models.ManifestJob, p Priority) {
+	if p < 0 || p >= numPriorities {
+		p = PriorityBackfill
+	}
+
+	q.classes[p] <- j
+}
+
+// close signals that no more jobs are expected. Workers exit once
+// the queue is drained.
+func (q *queue) close() {
+	q.once.Do(func() {
+		close(q.done)
+	})
+}
+
+// pop returns the next job as per the weighted schedule. It blocks until a job
+// is available and returns false if the queue is closed and empty.
+func (q *queue) pop() (models.ManifestJob, bool) {
+	for {
+		// The preferred class for this slot in the schedule.
+		q.mu.Lock()
+		pref := q.sched[q.cursor]
+		q.cursor = (q.cursor + 1) % len(q.sched)
+		q.mu.Unlock()
+
+		select {
+		case j := <-q.classes[pref]:
+			return j, true
+		default:
+		}
+
+		// The preferred class is empty. Take from any class in the order of priority.
+		for _, ch := range q.classes {
+			select {
+			case j := <-ch:
+				return j, true
+			default:
+			}
+		}
+
+		// All classes are empty. Wait for a job or for the queue to be closed.
+		select {
+		case j := <-q.classes[PriorityInteractive]:
+			return j, true
+		case j := <-q.classes[PriorityScheduled]:
+			return j, true
+		case j := <-q.classes[PriorityBackfill]:
+			return j, true
+		case <-q.done:
+			if q.len() == 0 {
+				return models.ManifestJob{}, false
+			}
+		}
+	}
+}
+
+// len returns the total number of jobs in the queue.
+func (q *queue) len() int {
+	n := 0
+	for _, ch := range q.classes {
+		n += len(ch)
+	}
+
+	return n
+}
@@ -0,0 +1,39 @@
+package crawl
+
+import (
+	"testing"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueuePriority(t *testing.T) {
+	q := newQueue(100)
+	for n := 0; n < 20; n++ {
+		q.push(models.ManifestJob{ID: n}, PriorityBackfill)
+		q.push(models.ManifestJob{ID: 100 + n}, PriorityInteractive)
+	}
+	q.close()
+
+	// In the first round of the schedule, interactive jobs get 8 of the 11 slots
+	// and as the scheduled class is empty, its slots fall through to interactive
+	// too, except for the backfill slot.
+	var first []int
+	for n := 0; n < 11; n++ {
+		j, ok := q.pop()
+		assert.True(t, ok)
+		first = append(first, j.ID)
+	}
+	assert.Contains(t, first, 0, "backfill job starved")
+	assert.Equal(t, 100, first[0])
+
+	// Drain the rest.
+	total := len(first)
+	for {
+		if _, ok := q.pop(); !ok {
+			break
+		}
+		total++
+	}
+	assert.Equal(t, 40, total)
+}
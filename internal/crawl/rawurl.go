@@ -0,0 +1,60 @@
+package crawl
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/floss-fund/go-funding-json/common"
+)
+
+// RawURL returns the raw content URL of a file's blob (HTML view) URL on GitHub,
+// GitLab, or Codeberg, eg: github.com/user/repo/blob/main/funding.json to
+// raw.githubusercontent.com/user/repo/main/funding.json. It returns false if u
+// isn't a blob URL.
+func RawURL(u *url.URL) (*url.URL, bool) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch strings.ToLower(u.Hostname()) {
+	case "github.com", "www.github.com":
+		// user/repo/(blob|raw)/ref/path...
+		if len(parts) < 5 || (parts[2] != "blob" && parts[2] != "raw") {
+			return nil, false
+		}
+
+		return &url.URL{
+			Scheme: "https",
+			Host:   "raw.githubusercontent.com",
+			Path:   "/" + strings.Join(append(parts[:2:2], parts[3:]...), "/"),
+		}, true
+
+	case "gitlab.com":
+		// group/[subgroups/]project/-/blob/ref/path...
+		for n := 2; n < len(parts)-2; n++ {
+			if parts[n] == "-" && parts[n+1] == "blob" {
+				return withPath(u, "/"+strings.Join(parts[:n+1], "/")+"/raw/"+strings.Join(parts[n+2:], "/")), true
+			}
+		}
+
+	case "codeberg.org":
+		// user/repo/src/(branch|tag|commit)/ref/path...
+		if len(parts) < 6 || parts[2] != "src" {
+			return nil, false
+		}
+		switch parts[3] {
+		case "branch", "tag", "commit":
+			return withPath(u, "/"+strings.Join(parts[:2], "/")+"/raw/"+strings.Join(parts[3:], "/")), true
+		}
+	}
+
+	return nil, false
+}
+
+// originURL returns the URL that a manifest or .well-known URL is fetched from:
+// the raw content URL of a blob URL (RawURL), or the origin transformed by the schema.
+func originURL(u *url.URL) *url.URL {
+	if r, ok := RawURL(u); ok {
+		return r
+	}
+
+	return common.TransformURLOrigin(u)
+}
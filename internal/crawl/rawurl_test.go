@@ -0,0 +1,35 @@
+package crawl
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawURL(t *testing.T) {
+	f := func(in, exp string) {
+		u, _ := url.Parse(in)
+		r, ok := RawURL(u)
+		if exp == "" {
+			assert.False(t, ok, in)
+			return
+		}
+		if assert.True(t, ok, in) {
+			assert.Equal(t, exp, r.String(), in)
+		}
+	}
+
+	f("https://github.com/user/repo/blob/main/funding.json", "https://raw.githubusercontent.com/user/repo/main/funding.json")
+	f("https://github.com/user/repo/raw/v1.0/dir/funding.json", "https://raw.githubusercontent.com/user/repo/v1.0/dir/funding.json")
+	f("https://gitlab.com/group/sub/repo/-/blob/main/funding.json", "https://gitlab.com/group/sub/repo/-/raw/main/funding.json")
+	f("https://codeberg.org/user/repo/src/branch/main/funding.json", "https://codeberg.org/user/repo/raw/branch/main/funding.json")
+	f("https://codeberg.org/user/repo/src/commit/abc123/funding.json", "https://codeberg.org/user/repo/raw/commit/abc123/funding.json")
+
+	f("https://raw.githubusercontent.com/user/repo/main/funding.json", "")
+	f("https://github.com/user/repo/tree/main/funding.json", "")
+	f("https://github.com/user/repo", "")
+	f("https://gitlab.com/group/repo/-/raw/main/funding.json", "")
+	f("https://codeberg.org/user/repo/src/main/funding.json", "")
+	f("https://example.com/user/repo/blob/main/funding.json", "")
+}
@@ -0,0 +1,39 @@
+package crawl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type reqIDKey struct{}
+
+// NewRequestID returns a random request ID for tracing a submission or a crawl
+// across subsystems (logs, crawl errors, API responses).
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a context that carries a request ID. Fetches made with
+// the context are logged with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, reqIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by a context, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(reqIDKey{}).(string)
+	return id
+}
+
+// logf logs a message with the request ID of the context, if any.
+func (c *Crawl) logf(ctx context.Context, format string, a ...interface{}) {
+	if id := RequestID(ctx); id != "" {
+		format += " request_id=%s"
+		a = append(a, id)
+	}
+
+	c.log.Printf(format, a...)
+}
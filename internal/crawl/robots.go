@@ -0,0 +1,217 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Max size of robots.txt files. Rules beyond it are ignored.
+	robotsMaxBytes = 500000
+
+	// Duration for which robots.txt files that couldn't be fetched due to server
+	// errors (which disallow the whole host) are cached.
+	robotsErrTTL = time.Minute * 10
+)
+
+// robotsRules is the set of rules of a robots.txt file that apply to the crawler.
+type robotsRules struct {
+	rules   []robotsRule
+	expires time.Time
+}
+
+type robotsRule struct {
+	path  string
+	allow bool
+}
+
+// allowRobots checks whether a URL is allowed by the robots.txt of its host,
+// which is fetched and cached for Opt.RobotsTTL.
+func (c *Crawl) allowRobots(ctx context.Context, u *url.URL, o fetchOpt) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return true
+	}
+
+	key := u.Scheme + "://" + u.Host
+	c.mu.RLock()
+	r, ok := c.robots[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(r.expires) {
+		r = c.fetchRobots(ctx, u, o)
+
+		// The fetch was cancelled. Don't cache the result and let the
+		// actual fetch run into (and report) the error.
+		if ctx.Err() != nil {
+			return true
+		}
+
+		c.mu.Lock()
+		c.robots[key] = r
+		c.mu.Unlock()
+	}
+
+	return r.allowed(u.RequestURI())
+}
+
+// fetchRobots fetches and parses the robots.txt of a URL's host. As per RFC 9309,
+// a missing robots.txt (4xx) allows everything and a server error disallows everything.
+func (c *Crawl) fetchRobots(ctx context.Context, u *url.URL, o fetchOpt) robotsRules {
+	ru := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+
+	// Only the User-Agent of the fetch applies to robots.txt. Its other headers
+	// (eg: conditional validators of the manifest) are for the URL being fetched.
+	hdr := http.Header{}
+	hdr.Set("User-Agent", o.headers.Get("User-Agent"))
+
+	resp, _, err := c.doReq(ctx, http.MethodGet, ru, fetchOpt{
+		timeout:  o.timeout,
+		maxBytes: robotsMaxBytes,
+		retries:  1,
+		headers:  hdr,
+		trace:    o.trace,
+	})
+
+	var se *StatusError
+	switch {
+	case err == nil:
+		out := parseRobots(resp.Body, c.opt.HTTP.UserAgent)
+		out.expires = time.Now().Add(c.opt.RobotsTTL)
+		return out
+	case errors.As(err, &se) && se.StatusCode >= 500:
+		return robotsRules{rules: []robotsRule{{path: "/"}}, expires: time.Now().Add(robotsErrTTL)}
+	case errors.As(err, &se):
+		return robotsRules{expires: time.Now().Add(c.opt.RobotsTTL)}
+	}
+
+	// The host is unreachable. Let the actual fetch run into (and report) the error.
+	return robotsRules{expires: time.Now().Add(robotsErrTTL)}
+}
+
+// parseRobots parses a robots.txt file and returns the rules of the group(s) that
+// match the product token of the user agent (eg: funding-manifest-bot/1.0), or the
+// rules of the * group(s) if there are none.
+func parseRobots(b []byte, userAgent string) robotsRules {
+	token, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(userAgent)), "/")
+	token, _, _ = strings.Cut(token, " ")
+
+	var (
+		own, all []robotsRule
+		hasOwn   bool
+
+		// Whether the current group applies to the crawler or to all.
+		isOwn, isAny bool
+
+		// Consecutive user-agent lines make up one group.
+		inAgents bool
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				isOwn, isAny = false, false
+			}
+			inAgents = true
+
+			switch strings.ToLower(val) {
+			case "*":
+				isAny = true
+			case token:
+				isOwn, hasOwn = true, true
+			}
+
+		case "allow", "disallow":
+			inAgents = false
+
+			// An empty disallow allows everything.
+			if val == "" {
+				continue
+			}
+
+			r := robotsRule{path: val, allow: key == "allow"}
+			if isOwn {
+				own = append(own, r)
+			}
+			if isAny {
+				all = append(all, r)
+			}
+
+		default:
+			inAgents = false
+		}
+	}
+
+	if hasOwn {
+		return robotsRules{rules: own}
+	}
+	return robotsRules{rules: all}
+}
+
+// allowed checks whether a path (with the query) is allowed. The most specific
+// (longest) matching rule wins, and allow wins over disallow on ties.
+func (r robotsRules) allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	var (
+		allow = true
+		best  = -1
+	)
+	for _, rl := range r.rules {
+		if !matchRobots(rl.path, path) {
+			continue
+		}
+
+		if n := len(rl.path); n > best || (n == best && rl.allow) {
+			allow, best = rl.allow, n
+		}
+	}
+
+	return allow
+}
+
+// matchRobots matches a path against a robots.txt path pattern that may
+// have * wildcards and a $ end anchor.
+func matchRobots(pattern, path string) bool {
+	end := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !end || rest == ""
+	}
+
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, p)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(p):]
+	}
+
+	last := parts[len(parts)-1]
+	if end {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
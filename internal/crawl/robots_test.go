@@ -0,0 +1,93 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRobots(t *testing.T) {
+	r := parseRobots([]byte(`
+# Comment.
+User-agent: *
+Disallow: /private/
+Allow: /private/funding.json
+
+User-agent: other-bot
+Disallow: /
+`), "funding-manifest-bot/1.0")
+
+	f := func(path string, exp bool) {
+		assert.Equal(t, exp, r.allowed(path), path)
+	}
+	f("/funding.json", true)
+	f("/private/x.json", false)
+	f("/private/funding.json", true)
+
+	// Own group takes precedence over *.
+	r = parseRobots([]byte(`
+User-agent: *
+Disallow: /
+
+User-agent: Funding-Manifest-Bot
+User-agent: other-bot
+Disallow: /*.json$
+Disallow:
+`), "funding-manifest-bot")
+	f("/", true)
+	f("/funding.json", false)
+	f("/funding.json?x=1", true)
+
+	assert.True(t, matchRobots("/a*/c", "/ab/b/c/d"))
+	assert.False(t, matchRobots("/a$", "/ab"))
+}
+
+func TestRobotsFetch(t *testing.T) {
+	c := newTestCrawl(NewMemFetcher(map[string][]byte{
+		"https://example.com/robots.txt":   []byte("User-agent: test\nDisallow: /funding.json"),
+		"https://example.com/funding.json": []byte(`{}`),
+	}))
+	c.opt.RespectRobots = true
+	c.opt.RobotsTTL = time.Hour
+
+	u, _ := url.Parse("https://example.com/funding.json")
+	_, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+
+	var re *RobotsError
+	assert.True(t, errors.As(err, &re))
+
+	// Bypass.
+	_, err = c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt([]FetchOpt{IgnoreRobots()}))
+	assert.NoError(t, err)
+
+	// Hosts without a robots.txt (404) allow everything.
+	u, _ = url.Parse("https://example.org/funding.json")
+	_, err = c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.False(t, errors.As(err, &re))
+}
+
+func TestRobotsFetchHeaders(t *testing.T) {
+	var hdr http.Header
+	c := newTestCrawl(FetcherFunc(func(ctx context.Context, r Request) (*Response, error) {
+		if r.URL.Path == "/robots.txt" {
+			hdr = r.Header
+			return &Response{StatusCode: http.StatusOK, Body: []byte("User-agent: *\nDisallow: /"), FinalURL: r.URL}, nil
+		}
+		return &Response{StatusCode: http.StatusOK, FinalURL: r.URL}, nil
+	}))
+	c.opt.RespectRobots = true
+	c.opt.RobotsTTL = time.Hour
+
+	// The manifest's validators aren't sent for robots.txt.
+	u, _ := url.Parse("https://example.com/funding.json")
+	_, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt([]FetchOpt{WithConditional(`"abc"`, time.Now())}))
+
+	var re *RobotsError
+	assert.True(t, errors.As(err, &re))
+	assert.Equal(t, http.Header{"User-Agent": []string{"test"}}, hdr)
+}
@@ -0,0 +1,184 @@
+// Package scheduler periodically re-crawls known manifests at adaptive intervals.
+// The interval of a manifest is backed off every time it's found unchanged and
+// tightened when it changes, so that rarely changing manifests are crawled less
+// often and recently updated ones more often. The schedule is persisted through
+// a Store so that it survives restarts.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Entry is the crawl schedule of a manifest.
+type Entry struct {
+	Job models.ManifestJob
+
+	// Interval between crawls. 0 (never scheduled) is Opt.MinInterval.
+	Interval time.Duration
+
+	// Time of the next crawl.
+	NextAt time.Time
+}
+
+// Store persists the schedule.
+type Store interface {
+	// Due returns up to limit entries that are due for crawling at now, including
+	// manifests that haven't been scheduled yet, most overdue first.
+	Due(ctx context.Context, now time.Time, limit int) ([]Entry, error)
+
+	// Save saves an entry after it has been crawled and rescheduled.
+	Save(ctx context.Context, e Entry) error
+}
+
+// CrawlFunc crawls a manifest and returns whether its contents changed.
+type CrawlFunc func(ctx context.Context, j models.ManifestJob) (changed bool, err error)
+
+// Opt are the scheduler options.
+type Opt struct {
+	// Bounds of the crawl interval of a manifest.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// Factor that the interval is multiplied by when a manifest is unchanged and
+	// divided by when it changes (>= 1).
+	Factor float64
+
+	// How often due manifests are checked for, how many are picked up at once,
+	// and the number of concurrent crawls.
+	Tick      time.Duration
+	BatchSize int
+	Workers   int
+
+	// Paused, if set, is checked on every tick. Due manifests are left as they
+	// are while it returns true (eg: outside crawl windows).
+	Paused func(time.Time) bool
+}
+
+// Scheduler re-crawls manifests when they're due.
+type Scheduler struct {
+	opt   Opt
+	store Store
+	crawl CrawlFunc
+
+	log *log.Logger
+}
+
+// New returns a Scheduler.
+func New(o Opt, s Store, fn CrawlFunc, l *log.Logger) *Scheduler {
+	if o.Factor < 1 {
+		o.Factor = 1
+	}
+	if o.Workers < 1 {
+		o.Workers = 1
+	}
+
+	return &Scheduler{
+		opt:   o,
+		store: s,
+		crawl: fn,
+		log:   l,
+	}
+}
+
+// Run crawls due manifests every tick until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	t := time.NewTicker(s.opt.Tick)
+	defer t.Stop()
+
+	for {
+		// Crawl due manifests in batches until there are no more.
+		for ctx.Err() == nil {
+			if s.opt.Paused != nil && s.opt.Paused(time.Now()) {
+				break
+			}
+
+			n, err := s.runBatch(ctx)
+			if err != nil {
+				s.log.Printf("error fetching scheduled crawls: %v", err)
+				break
+			}
+			if n < s.opt.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// runBatch crawls a batch of due manifests and reschedules them. It returns
+// the number of manifests that were rescheduled.
+func (s *Scheduler) runBatch(ctx context.Context) (int, error) {
+	entries, err := s.store.Due(ctx, time.Now(), s.opt.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		jobs  = make(chan Entry)
+		wg    sync.WaitGroup
+		saved atomic.Int64
+	)
+	for n := 0; n < s.opt.Workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for e := range jobs {
+				changed, err := s.crawl(ctx, e.Job)
+				if ctx.Err() != nil {
+					continue
+				}
+
+				e = Next(e, changed, err, time.Now(), s.opt)
+				if err := s.store.Save(ctx, e); err != nil {
+					s.log.Printf("error saving crawl schedule: %s: %v", e.Job.URL, err)
+					continue
+				}
+				saved.Add(1)
+			}
+		}()
+	}
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- e
+	}
+	close(jobs)
+	wg.Wait()
+
+	return int(saved.Load()), nil
+}
+
+// Next reschedules an entry after a crawl. The interval is backed off if the manifest
+// was unchanged and tightened if it changed. Failed crawls keep the interval as the
+// crawler tracks errors (and disables failing manifests) on its own.
+func Next(e Entry, changed bool, err error, now time.Time, o Opt) Entry {
+	iv := e.Interval
+	switch {
+	case iv <= 0:
+		iv = o.MinInterval
+	case err != nil:
+	case changed:
+		iv = time.Duration(float64(iv) / o.Factor)
+	default:
+		iv = time.Duration(float64(iv) * o.Factor)
+	}
+
+	e.Interval = min(max(iv, o.MinInterval), o.MaxInterval)
+	e.NextAt = now.Add(e.Interval)
+
+	return e
+}
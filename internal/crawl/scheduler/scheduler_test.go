@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNext(t *testing.T) {
+	var (
+		o   = Opt{MinInterval: time.Hour, MaxInterval: 8 * time.Hour, Factor: 2}
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	f := func(iv time.Duration, changed bool, err error, exp time.Duration) {
+		e := Next(Entry{Interval: iv}, changed, err, now, o)
+		assert.Equal(t, exp, e.Interval, iv)
+		assert.Equal(t, now.Add(exp), e.NextAt, iv)
+	}
+	f(0, false, nil, time.Hour)
+	f(2*time.Hour, false, nil, 4*time.Hour)
+	f(2*time.Hour, true, nil, time.Hour)
+	f(time.Hour, true, nil, time.Hour)
+	f(6*time.Hour, false, nil, 8*time.Hour)
+	f(2*time.Hour, false, errors.New("x"), 2*time.Hour)
+}
+
+// memStore is a Store with all entries due.
+type memStore struct {
+	mu    sync.Mutex
+	saved map[int]Entry
+	due   []Entry
+}
+
+func (s *memStore) Due(ctx context.Context, now time.Time, limit int) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Entry
+	for _, e := range s.due {
+		if e.NextAt.After(now) {
+			continue
+		}
+		if se, ok := s.saved[e.Job.ID]; ok && se.NextAt.After(now) {
+			continue
+		}
+		out = append(out, e)
+	}
+
+	return out[:min(len(out), limit)], nil
+}
+
+func (s *memStore) Save(ctx context.Context, e Entry) error {
+	s.mu.Lock()
+	s.saved[e.Job.ID] = e
+	s.mu.Unlock()
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	st := &memStore{saved: map[int]Entry{}}
+	for n := 1; n <= 5; n++ {
+		st.due = append(st.due, Entry{Job: models.ManifestJob{ID: n}, Interval: 2 * time.Hour})
+	}
+
+	s := New(Opt{MinInterval: time.Hour, MaxInterval: 8 * time.Hour, Factor: 2, Tick: time.Hour, BatchSize: 2, Workers: 2},
+		st, func(ctx context.Context, j models.ManifestJob) (bool, error) {
+			return j.ID == 1, nil
+		}, log.New(io.Discard, "", 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	assert.Len(t, st.saved, 5)
+	assert.Equal(t, time.Hour, st.saved[1].Interval)
+	assert.Equal(t, 4*time.Hour, st.saved[2].Interval)
+}
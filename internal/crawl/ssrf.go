@@ -0,0 +1,145 @@
+package crawl
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Guard restricts the URLs the crawler fetches so that submitted manifest and
+// .well-known URLs can't be used to reach internal services (SSRF). Addresses are
+// checked at the dialer after DNS resolution, so hostnames that resolve (or are
+// rebound) to internal addresses and redirects to them are also blocked.
+type Guard struct {
+	// URL schemes that can be fetched. Empty allows all.
+	Schemes []string
+
+	// Allow private, loopback, link-local, and multicast addresses (eg: for development).
+	AllowPrivate bool
+
+	// Max number of redirects that are followed. 0 uses the default (10)
+	// and -1 doesn't follow redirects.
+	MaxRedirects int
+
+	// Follow redirects to hosts other than the one of the requested URL. Provenance
+	// of a manifest is only established for its own host, so cross-host redirects
+	// can be disallowed to prevent a host from pointing to another's contents.
+	AllowCrossHost bool
+}
+
+// Max number of redirects that are followed by default.
+const defaultMaxRedirects = 10
+
+// BlockedError is returned for URLs that are blocked by the Guard.
+type BlockedError struct {
+	URL    string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("error: %s is blocked: %s", e.URL, e.Reason)
+}
+
+// Ranges that are blocked in addition to the ones netip classifies as private,
+// loopback, link-local, multicast, and unspecified.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT.
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking.
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 of (possibly internal) IPv4.
+}
+
+// checkURL checks whether a URL's scheme is allowed.
+func (g *Guard) checkURL(u *url.URL) error {
+	if g == nil || len(g.Schemes) == 0 || slices.Contains(g.Schemes, u.Scheme) {
+		return nil
+	}
+
+	return &BlockedError{URL: u.String(), Reason: fmt.Sprintf("scheme %s is not allowed", u.Scheme)}
+}
+
+// checkAddr checks whether an IP address can be connected to.
+func (g *Guard) checkAddr(ip netip.Addr) error {
+	if g == nil || g.AllowPrivate {
+		return nil
+	}
+
+	ip = ip.Unmap()
+	blocked := ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+	for _, p := range blockedPrefixes {
+		if blocked {
+			break
+		}
+		blocked = p.Contains(ip)
+	}
+
+	if blocked {
+		return &BlockedError{URL: ip.String(), Reason: "address is private or reserved"}
+	}
+	return nil
+}
+
+// control is the net.Dialer hook that checks the resolved address of every
+// connection before it's made.
+func (g *Guard) control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &BlockedError{URL: address, Reason: "invalid address"}
+	}
+
+	return g.checkAddr(ap.Addr())
+}
+
+// checkRedirect checks the number of redirects and the scheme and host of redirect
+// targets (addresses are checked at the dialer).
+func (g *Guard) checkRedirect(req *http.Request, via []*http.Request) error {
+	max := defaultMaxRedirects
+	if g != nil && g.MaxRedirects != 0 {
+		max = g.MaxRedirects
+	}
+	if max < 0 {
+		return &BlockedError{URL: req.URL.String(), Reason: "redirects are not followed"}
+	}
+	if len(via) >= max {
+		return fmt.Errorf("stopped after %d redirects", max)
+	}
+
+	if g != nil && !g.AllowCrossHost && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+		return &BlockedError{URL: req.URL.String(), Reason: fmt.Sprintf("cross-host redirect from %s", via[0].URL.Hostname())}
+	}
+
+	return g.checkURL(req.URL)
+}
+
+// dialer returns a dialer that enforces the Guard.
+func (g *Guard) dialer() *net.Dialer {
+	d := &net.Dialer{}
+	if g != nil && !g.AllowPrivate {
+		d.Control = g.control
+	}
+
+	return d
+}
+
+// Client returns an HTTP client that enforces the Guard, eg: for delivering to
+// other user supplied URLs like webhooks.
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = g.dialer().DialContext
+
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     t,
+		CheckRedirect: g.checkRedirect,
+	}
+}
@@ -0,0 +1,128 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/floss-fund/go-funding-json/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard(t *testing.T) {
+	g := &Guard{Schemes: []string{"https"}}
+
+	f := func(addr string, blocked bool) {
+		err := g.checkAddr(netip.MustParseAddr(addr))
+		assert.Equal(t, blocked, err != nil, addr)
+	}
+	f("127.0.0.1", true)
+	f("10.1.2.3", true)
+	f("172.16.0.1", true)
+	f("192.168.1.1", true)
+	f("169.254.169.254", true)
+	f("100.64.0.1", true)
+	f("0.0.0.0", true)
+	f("224.0.0.1", true)
+	f("::1", true)
+	f("fe80::1", true)
+	f("fd00::1", true)
+	f("::ffff:127.0.0.1", true)
+	f("93.184.215.14", false)
+	f("2606:2800:21f:cb07:6820:80da:af6b:8b2c", false)
+
+	u, _ := url.Parse("http://example.com/funding.json")
+	assert.Error(t, g.checkURL(u))
+	u, _ = url.Parse("https://example.com/funding.json")
+	assert.NoError(t, g.checkURL(u))
+
+	// Private addresses can be allowed.
+	assert.NoError(t, (&Guard{AllowPrivate: true}).checkAddr(netip.MustParseAddr("127.0.0.1")))
+}
+
+func TestGuardFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/funding.json")
+
+	// The test server is on loopback, which is blocked at the dialer.
+	g := &Guard{Schemes: []string{"http", "https"}}
+	c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, TransportOpt{}, g, nil, nil))
+	c.guard = g
+	_, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+
+	var be *BlockedError
+	assert.True(t, errors.As(err, &be))
+	assert.Equal(t, ErrClassBlocked, ClassifyError(err))
+
+	// Blocked scheme.
+	c.guard = &Guard{Schemes: []string{"https"}}
+	_, err = c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.True(t, errors.As(err, &be))
+
+	// Allowed.
+	g = &Guard{AllowPrivate: true}
+	c = newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, TransportOpt{}, g, nil, nil))
+	_, err = c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+	assert.NoError(t, err)
+}
+
+func TestGuardRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/funding.json", http.StatusMovedPermanently)
+		case "/other":
+			// Same server on another hostname.
+			http.Redirect(w, r, "http://"+strings.Replace(r.Host, "127.0.0.1", "localhost", 1)+"/funding.json", http.StatusFound)
+		default:
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer srv.Close()
+
+	f := func(g *Guard, path string, ok bool, final string) {
+		c := newTestCrawl(NewHTTPFetcher(common.HTTPOpt{ReqTimeout: time.Second, MaxHostConns: 1}, TransportOpt{}, g, nil, nil))
+		u, _ := url.Parse(srv.URL + path)
+
+		resp, err := c.fetch(context.Background(), http.MethodGet, u, c.makeFetchOpt(nil))
+		assert.Equal(t, ok, err == nil, path, err)
+		if ok {
+			assert.Equal(t, final, resp.FinalURL.Path)
+		}
+	}
+	f(&Guard{AllowPrivate: true, AllowCrossHost: true}, "/a", true, "/funding.json")
+	f(&Guard{AllowPrivate: true, AllowCrossHost: true, MaxRedirects: 1}, "/a", false, "")
+	f(&Guard{AllowPrivate: true, AllowCrossHost: true, MaxRedirects: -1}, "/a", false, "")
+	f(&Guard{AllowPrivate: true, AllowCrossHost: true}, "/other", true, "/funding.json")
+	f(&Guard{AllowPrivate: true}, "/a", true, "/funding.json")
+	f(&Guard{AllowPrivate: true}, "/other", false, "")
+}
+
+func TestGuardClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// The test server is on loopback, which is blocked at the dialer.
+	_, err := (&Guard{}).Client(time.Second).Get(srv.URL)
+	var be *BlockedError
+	assert.True(t, errors.As(err, &be))
+
+	resp, err := (&Guard{AllowPrivate: true}).Client(time.Second).Get(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp.Body.Close()
+}
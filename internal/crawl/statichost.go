@@ -0,0 +1,93 @@
+package crawl
+
+import (
+	"net/url"
+	"strings"
+)
+
+// staticHost is a static site, object storage, or CDN host where .well-known
+// paths don't always work as-is. alternates returns the alternate URLs where a
+// .well-known list on the host may be found.
+type staticHost struct {
+	name       string
+	match      func(host string) bool
+	alternates func(u *url.URL) []*url.URL
+}
+
+// staticHosts are the hosts with special-case handling (documented in README).
+var staticHosts = []staticHost{
+	// S3 website endpoints: bucket.s3-website-region.amazonaws.com and
+	// bucket.s3-website.region.amazonaws.com. Extensionless keys are often uploaded
+	// with a .txt extension and "directories" are served with their index document.
+	{
+		name: "s3-website",
+		match: func(host string) bool {
+			return strings.HasSuffix(host, ".amazonaws.com") && strings.Contains(host, ".s3-website")
+		},
+		alternates: func(u *url.URL) []*url.URL {
+			p := strings.TrimSuffix(u.Path, "/")
+			return []*url.URL{withPath(u, p+".txt"), withPath(u, p+"/index.html")}
+		},
+	},
+
+	// GitHub Pages: Jekyll skips dot directories (.well-known) unless they're explicitly
+	// included, so the list is read from the source repository instead. user.github.io
+	// is served from the user.github.io repo and user.github.io/repo from repo, either
+	// from the root or the docs directory of the default branch.
+	{
+		name: "github-pages",
+		match: func(host string) bool {
+			return strings.HasSuffix(host, ".github.io")
+		},
+		alternates: func(u *url.URL) []*url.URL {
+			user := strings.TrimSuffix(u.Host, ".github.io")
+			repo, p := u.Host, strings.TrimPrefix(u.Path, "/")
+			if seg, rest, ok := strings.Cut(p, "/"); ok && !strings.HasPrefix(seg, ".") {
+				repo, p = seg, rest
+			}
+
+			var out []*url.URL
+			for _, dir := range []string{"", "docs/"} {
+				out = append(out, &url.URL{
+					Scheme: "https",
+					Host:   "raw.githubusercontent.com",
+					Path:   "/" + user + "/" + repo + "/HEAD/" + dir + p,
+				})
+			}
+			return out
+		},
+	},
+
+	// Netlify: "pretty URLs" rewrite extensionless paths to their .html or
+	// index.html equivalents, and the list is often published with a .txt extension.
+	{
+		name: "netlify",
+		match: func(host string) bool {
+			return strings.HasSuffix(host, ".netlify.app")
+		},
+		alternates: func(u *url.URL) []*url.URL {
+			p := strings.TrimSuffix(u.Path, "/")
+			return []*url.URL{withPath(u, p+".txt"), withPath(u, p+"/index.html")}
+		},
+	},
+}
+
+// staticHostAlternates returns the alternate .well-known URLs for the given
+// URL if it's on one of the known static hosts.
+func staticHostAlternates(u *url.URL) []*url.URL {
+	host := strings.ToLower(u.Hostname())
+	for _, h := range staticHosts {
+		if h.match(host) {
+			return h.alternates(u)
+		}
+	}
+
+	return nil
+}
+
+func withPath(u *url.URL, p string) *url.URL {
+	out := *u
+	out.Path = p
+	out.RawPath = ""
+	return &out
+}
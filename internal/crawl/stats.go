@@ -0,0 +1,70 @@
+package crawl
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// runStats records the outcome of fetches in a crawl run.
+type runStats struct {
+	mu        sync.Mutex
+	started   time.Time
+	success   int
+	failed    int
+	skipped   int
+	latencies []time.Duration
+}
+
+func newRunStats() *runStats {
+	return &runStats{started: time.Now()}
+}
+
+// add records the outcome and latency of a fetch. A zero latency is not
+// recorded (eg: failures before a fetch was made).
+func (s *runStats) add(ok bool, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ok {
+		s.success++
+	} else {
+		s.failed++
+	}
+	if d > 0 {
+		s.latencies = append(s.latencies, d)
+	}
+}
+
+// skip records a manifest that was skipped as it was not modified.
+func (s *runStats) skip() {
+	s.mu.Lock()
+	s.skipped++
+	s.mu.Unlock()
+}
+
+// result returns the stats of the run.
+func (s *runStats) result() models.CrawlRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := models.CrawlRun{
+		StartedAt:  s.started,
+		FinishedAt: time.Now(),
+		Total:      s.success + s.failed + s.skipped,
+		Success:    s.success,
+		Failed:     s.failed,
+		Skipped:    s.skipped,
+	}
+
+	if len(s.latencies) > 0 {
+		l := slices.Clone(s.latencies)
+		slices.Sort(l)
+		out.LatencyP50 = int(l[len(l)*50/100].Milliseconds())
+		out.LatencyP95 = int(l[len(l)*95/100].Milliseconds())
+	}
+
+	return out
+}
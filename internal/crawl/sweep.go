@@ -0,0 +1,117 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// Sweep is a lightweight liveness sweep across all the manifests that only issues
+// HEAD requests (or conditional GETs to hosts that don't support HEAD) to record the
+// availability of manifests cheaply between full crawls. Manifests are not fetched,
+// parsed, or updated. Hosts that none of the manifests of are reachable are recorded as
+// down at the end of a complete sweep, deferring their scheduled recrawls until a sweep
+// finds them up again. Cancelling ctx stops the sweep.
+func (c *Crawl) Sweep(ctx context.Context) error {
+	var (
+		jobs = make(chan models.ManifestJob, c.opt.Workers)
+		wg   sync.WaitGroup
+
+		total, down atomic.Int64
+	)
+
+	for n := 0; n < c.opt.Workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				ok, code, err := c.checkLiveness(ctx, j)
+				if ctx.Err() != nil {
+					continue
+				}
+
+				msg := ""
+				if err != nil {
+					msg = err.Error()
+				}
+
+				total.Add(1)
+				if !ok {
+					down.Add(1)
+				}
+				if err := c.db.UpdateManifestLiveness(j.ID, ok, code, msg); err != nil {
+					c.log.Printf("error updating liveness: %s: %v", j.URL, err)
+				}
+			}
+		}()
+	}
+
+	lastID := 0
+	for ctx.Err() == nil {
+		items, err := c.db.GetManifestsForSweep(lastID, c.opt.BatchSize)
+		if err != nil {
+			close(jobs)
+			wg.Wait()
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, j := range items {
+			if j.URLobj != nil && ctx.Err() == nil {
+				jobs <- j
+			}
+		}
+		lastID = items[len(items)-1].ID
+	}
+	close(jobs)
+	wg.Wait()
+
+	c.log.Printf("liveness sweep finished. total=%d down=%d", total.Load(), down.Load())
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// Host downtime is only updated on complete sweeps.
+	h, err := c.db.UpdateHostDowntime()
+	if err != nil {
+		return err
+	}
+	c.log.Printf("host downtime updated. down=%d up=%d", h.Down, h.Up)
+
+	return nil
+}
+
+// checkLiveness checks whether a manifest URL is reachable. A 304 to the
+// conditional request is as good as a 2xx.
+func (c *Crawl) checkLiveness(ctx context.Context, j models.ManifestJob) (bool, int, error) {
+	hdr := http.Header{}
+	if !j.LastModified.IsZero() {
+		hdr.Set("If-Modified-Since", j.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := c.fetch(ctx, http.MethodHead, j.URLobj, c.makeFetchOpt([]FetchOpt{WithLimits(j.FetchLimits), WithHeaders(hdr), withPhase(PhaseManifest)}))
+
+	// Some hosts don't support HEAD. Fall back to a conditional GET.
+	var se *StatusError
+	if errors.As(err, &se) && (se.StatusCode == http.StatusMethodNotAllowed || se.StatusCode == http.StatusNotImplemented) {
+		resp, err = c.fetch(ctx, http.MethodGet, j.URLobj, c.makeFetchOpt([]FetchOpt{WithLimits(j.FetchLimits), WithHeaders(hdr), WithMaxBytes(1), withPhase(PhaseManifest)}))
+	}
+
+	switch {
+	case err == nil:
+		return true, resp.StatusCode, nil
+	case errors.As(err, &se):
+		if se.StatusCode == http.StatusNotModified {
+			return true, se.StatusCode, nil
+		}
+		return false, se.StatusCode, err
+	}
+
+	return false, 0, err
+}
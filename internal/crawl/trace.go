@@ -0,0 +1,52 @@
+package crawl
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// withTrace records every request made by a fetch (including retries)
+// and its response in the given trace.
+func withTrace(t *models.ManifestTrace) FetchOpt {
+	return func(o *fetchOpt) {
+		o.trace = t
+	}
+}
+
+// traceExchange records a request and its response (or error) in a trace.
+func traceExchange(t *models.ManifestTrace, method string, u *url.URL, hdr http.Header, r *Response, d time.Duration, err error) {
+	ex := models.TraceExchange{
+		Method:         method,
+		URL:            u.String(),
+		RequestHeaders: hdr,
+		Duration:       int(d.Milliseconds()),
+	}
+	if r != nil {
+		ex.Status = r.StatusCode
+		ex.ResponseHeaders = r.Header
+		ex.Body = string(r.Body)
+		if r.FinalURL != nil {
+			ex.FinalURL = r.FinalURL.String()
+		}
+	}
+	if err != nil {
+		ex.Error = err.Error()
+	}
+
+	t.Exchanges = append(t.Exchanges, ex)
+}
+
+// traceResult records the result of a traced job. t may be nil.
+func traceResult(t *models.ManifestTrace, err error) {
+	if t == nil {
+		return
+	}
+
+	t.Result = "ok"
+	if err != nil {
+		t.Result = err.Error()
+	}
+}
@@ -0,0 +1,82 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+)
+
+// Actions taken on listings with links to unsafe URLs.
+const (
+	// The listing stays as-is and is flagged with a status message.
+	UnsafeFlag = "flag"
+
+	// The listing is held for moderation (pending) and flagged.
+	UnsafeQuarantine = "quarantine"
+)
+
+// URLChecker checks URLs against a URL-safety service or list of known malicious
+// destinations, eg: Google Safe Browsing.
+type URLChecker interface {
+	// CheckURLs returns the unsafe URLs of the given URLs with their threats (eg: MALWARE).
+	CheckURLs(ctx context.Context, urls []string) (map[string]string, error)
+}
+
+// manifestLinks returns the webpage and repository URLs, and the URLs of the
+// funding channels (eg: payment provider links) in a manifest.
+func manifestLinks(m v1.Manifest) []string {
+	var (
+		out  []string
+		seen = map[string]bool{}
+	)
+	add := func(u string) {
+		u = strings.TrimSpace(u)
+		if u == "" || seen[u] {
+			return
+		}
+		seen[u] = true
+		out = append(out, u)
+	}
+
+	add(m.Entity.WebpageURL.URL)
+	for _, p := range m.Projects {
+		add(p.WebpageURL.URL)
+		add(p.RepositoryURL.URL)
+	}
+	for _, ch := range m.Funding.Channels {
+		if a := strings.ToLower(ch.Address); strings.HasPrefix(a, "https://") || strings.HasPrefix(a, "http://") {
+			add(ch.Address)
+		}
+	}
+
+	return out
+}
+
+// checkURLSafety checks the links in a manifest with the URL checker and returns
+// a message describing the unsafe ones, if any. Errors of the checker are logged
+// and the links are considered safe, so that an outage doesn't hold up listings.
+func (c *Crawl) checkURLSafety(ctx context.Context, m v1.Manifest) string {
+	if c.opt.URLChecker == nil {
+		return ""
+	}
+
+	res, err := c.opt.URLChecker.CheckURLs(ctx, manifestLinks(m))
+	if err != nil {
+		c.logf(ctx, "error checking URL safety: %v manifest_url=%s", err, m.URL.URL)
+		return ""
+	}
+	if len(res) == 0 {
+		return ""
+	}
+
+	out := make([]string, 0, len(res))
+	for u, threat := range res {
+		out = append(out, fmt.Sprintf("%s (%s)", u, threat))
+	}
+	sort.Strings(out)
+
+	return "Links to unsafe URLs: " + strings.Join(out, ", ")
+}
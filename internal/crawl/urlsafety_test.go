@@ -0,0 +1,51 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type testChecker struct {
+	unsafe map[string]string
+	err    error
+}
+
+func (t testChecker) CheckURLs(ctx context.Context, urls []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, u := range urls {
+		if th, ok := t.unsafe[u]; ok {
+			out[u] = th
+		}
+	}
+
+	return out, t.err
+}
+
+func TestCheckURLSafety(t *testing.T) {
+	m := v1.Manifest{
+		URL:    v1.URL{URL: "https://example.com/funding.json"},
+		Entity: v1.Entity{WebpageURL: v1.URL{URL: "https://example.com"}},
+		Projects: v1.Projects{
+			{WebpageURL: v1.URL{URL: "https://example.com"}, RepositoryURL: v1.URL{URL: "https://github.com/example/repo"}},
+		},
+		Funding: v1.Funding{Channels: v1.Channels{
+			{GUID: "bank", Type: "bank", Address: "IBAN 123"},
+			{GUID: "pay", Type: "payment-provider", Address: "https://pay.example.net/donate"},
+		}},
+	}
+	assert.Equal(t, []string{"https://example.com", "https://github.com/example/repo", "https://pay.example.net/donate"}, manifestLinks(m))
+
+	c := newTestCrawl(nil)
+	assert.Equal(t, "", c.checkURLSafety(context.Background(), m))
+
+	c.opt.URLChecker = testChecker{unsafe: map[string]string{"https://pay.example.net/donate": "SOCIAL_ENGINEERING"}}
+	assert.Equal(t, "Links to unsafe URLs: https://pay.example.net/donate (SOCIAL_ENGINEERING)", c.checkURLSafety(context.Background(), m))
+
+	// Checker errors don't flag listings.
+	c.opt.URLChecker = testChecker{err: errors.New("down")}
+	assert.Equal(t, "", c.checkURLSafety(context.Background(), m))
+}
@@ -0,0 +1,100 @@
+package crawl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+)
+
+// Max length of a line in a .well-known list.
+const maxWellKnownLine = 4096
+
+var (
+	ErrWellKnownTooLarge = errors.New(".well-known list is too large")
+)
+
+// CheckProvenance checks whether the manifest URL is listed in the .well-known list
+// of the given URL (if it has one). The list is streamed and scanned line by line
+// up to Opt.WellKnownMaxBytes, so that large files on misconfigured hosts are not
+// read in full.
+func (c *Crawl) CheckProvenance(ctx context.Context, u v1.URL, manifest v1.URL) error {
+	if u.WellKnown == "" {
+		return nil
+	}
+
+	// Static hosts where .well-known paths behave oddly have alternate
+	// locations that are tried if the list isn't found at the original one.
+	var (
+		mURL   = manifest.URLobj.String()
+		wURL   = originURL(u.WellKnownObj)
+		urls   = append([]*url.URL{wURL}, staticHostAlternates(wURL)...)
+		retErr error
+	)
+	for _, w := range urls {
+		found, err := c.scanWellKnown(ctx, w, mURL)
+		if found {
+			return nil
+		}
+
+		// Report the error of the original URL.
+		if retErr == nil {
+			retErr = err
+		}
+	}
+	if retErr != nil {
+		return retErr
+	}
+
+	return fmt.Errorf("manifest URL %s was not found in the .well-known list", mURL)
+}
+
+// scanWellKnown fetches a .well-known list and looks for the given URL in it.
+func (c *Crawl) scanWellKnown(ctx context.Context, u *url.URL, target string) (bool, error) {
+	max := c.opt.WellKnownMaxBytes
+	if max <= 0 {
+		max = c.opt.HTTP.MaxBytes
+	}
+
+	found := false
+	scan := func(r io.Reader) error {
+		lr := &io.LimitedReader{R: r, N: max}
+
+		sc := bufio.NewScanner(lr)
+		sc.Buffer(make([]byte, 0, 1024), maxWellKnownLine)
+		for sc.Scan() {
+			if strings.TrimSpace(sc.Text()) == target {
+				found = true
+				return nil
+			}
+		}
+		if err := sc.Err(); err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				return fmt.Errorf("invalid .well-known list: line exceeds %d bytes", maxWellKnownLine)
+			}
+			return fmt.Errorf("error reading .well-known list: %v", err)
+		}
+
+		// The limit was reached and there's more.
+		if lr.N == 0 {
+			if n, _ := r.Read(make([]byte, 1)); n > 0 {
+				return fmt.Errorf("%w (max %d bytes)", ErrWellKnownTooLarge, max)
+			}
+		}
+
+		return nil
+	}
+
+	// Read one byte beyond the max to know if the list exceeds it.
+	if _, err := c.fetch(ctx, http.MethodGet, u, c.makeFetchOpt([]FetchOpt{WithMaxBytes(max + 1), withScan(scan), withPhase(PhaseProvenance)})); err != nil {
+		return false, err
+	}
+
+	return found, nil
+}
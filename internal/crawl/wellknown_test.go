@@ -0,0 +1,65 @@
+package crawl
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanWellKnown(t *testing.T) {
+	const target = "https://example.com/funding.json"
+
+	c := newTestCrawl(NewMemFetcher(map[string][]byte{
+		"https://example.com/ok":    []byte("https://other.com/funding.json\r\n" + target + "\n"),
+		"https://example.com/nope":  []byte("https://other.com/funding.json\n"),
+		"https://example.com/large": []byte(strings.Repeat("https://other.com/funding.json\n", 100) + target),
+		"https://example.com/line":  []byte(strings.Repeat("x", maxWellKnownLine+1)),
+	}))
+	c.opt.HTTP.MaxBytes = 1 << 20
+	c.opt.WellKnownMaxBytes = 1024
+
+	f := func(u string, found bool, hasErr bool) {
+		p, _ := url.Parse(u)
+		ok, err := c.scanWellKnown(context.Background(), p, target)
+		assert.Equal(t, found, ok, u)
+		assert.Equal(t, hasErr, err != nil, u)
+	}
+
+	f("https://example.com/ok", true, false)
+	f("https://example.com/nope", false, false)
+	f("https://example.com/large", false, true)
+	f("https://example.com/line", false, true)
+	f("https://example.com/404", false, true)
+}
+
+func TestStaticHostAlternates(t *testing.T) {
+	f := func(u string, exp []string) {
+		p, _ := url.Parse(u)
+		var out []string
+		for _, a := range staticHostAlternates(p) {
+			out = append(out, a.String())
+		}
+		assert.Equal(t, exp, out, u)
+	}
+
+	f("https://example.com/.well-known/funding-manifest-urls", nil)
+	f("https://user.github.io/.well-known/funding-manifest-urls", []string{
+		"https://raw.githubusercontent.com/user/user.github.io/HEAD/.well-known/funding-manifest-urls",
+		"https://raw.githubusercontent.com/user/user.github.io/HEAD/docs/.well-known/funding-manifest-urls",
+	})
+	f("https://user.github.io/project/.well-known/funding-manifest-urls", []string{
+		"https://raw.githubusercontent.com/user/project/HEAD/.well-known/funding-manifest-urls",
+		"https://raw.githubusercontent.com/user/project/HEAD/docs/.well-known/funding-manifest-urls",
+	})
+	f("https://site.netlify.app/.well-known/funding-manifest-urls", []string{
+		"https://site.netlify.app/.well-known/funding-manifest-urls.txt",
+		"https://site.netlify.app/.well-known/funding-manifest-urls/index.html",
+	})
+	f("http://bucket.s3-website-us-east-1.amazonaws.com/.well-known/funding-manifest-urls/", []string{
+		"http://bucket.s3-website-us-east-1.amazonaws.com/.well-known/funding-manifest-urls.txt",
+		"http://bucket.s3-website-us-east-1.amazonaws.com/.well-known/funding-manifest-urls/index.html",
+	})
+}
@@ -0,0 +1,125 @@
+package crawl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time window in which crawls can run, as offsets from midnight.
+// A window that ends before it starts (eg: 22:00-06:00) spans midnight.
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Blackout is a period in which crawls don't run, eg: a hosting provider's maintenance.
+type Blackout struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Schedule restricts when (batch) crawls run. Without windows, crawls can
+// run at any time outside of the blackouts.
+type Schedule struct {
+	Windows   []Window
+	Blackouts []Blackout
+
+	// Timezone of the windows. Defaults to UTC.
+	Location *time.Location
+}
+
+// ParseWindow parses a daily window in the HH:MM-HH:MM format.
+func ParseWindow(s string) (Window, error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q (eg: 22:00-06:00)", s)
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(a))
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q (eg: 22:00-06:00)", s)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(b))
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q (eg: 22:00-06:00)", s)
+	}
+	if start.Equal(end) {
+		return Window{}, fmt.Errorf("invalid window %q: start and end are the same", s)
+	}
+
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return Window{Start: start.Sub(midnight), End: end.Sub(midnight)}, nil
+}
+
+// ParseBlackout parses a blackout period of two RFC3339 timestamps separated by a slash,
+// eg: 2024-12-24T00:00:00Z/2024-12-26T00:00:00Z.
+func ParseBlackout(s string) (Blackout, error) {
+	a, b, ok := strings.Cut(s, "/")
+	if !ok {
+		return Blackout{}, fmt.Errorf("invalid blackout %q (eg: 2024-12-24T00:00:00Z/2024-12-26T00:00:00Z)", s)
+	}
+
+	start, err := time.Parse(time.RFC3339, strings.TrimSpace(a))
+	if err != nil {
+		return Blackout{}, fmt.Errorf("invalid blackout %q: %v", s, err)
+	}
+	end, err := time.Parse(time.RFC3339, strings.TrimSpace(b))
+	if err != nil {
+		return Blackout{}, fmt.Errorf("invalid blackout %q: %v", s, err)
+	}
+	if !end.After(start) {
+		return Blackout{}, fmt.Errorf("invalid blackout %q: end is not after start", s)
+	}
+
+	return Blackout{Start: start, End: end}, nil
+}
+
+// Allowed returns whether crawls can run at t and if so, the time until which they
+// can run (the end of the window or the start of the next blackout). The time is
+// zero if there's no limit.
+func (s Schedule) Allowed(t time.Time) (bool, time.Time) {
+	for _, b := range s.Blackouts {
+		if !t.Before(b.Start) && t.Before(b.End) {
+			return false, time.Time{}
+		}
+	}
+
+	var until time.Time
+	if len(s.Windows) > 0 {
+		loc := s.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+
+		lt := t.In(loc)
+		for _, w := range s.Windows {
+			// The window may have started today or, if it spans midnight, yesterday.
+			for _, day := range []int{-1, 0} {
+				midnight := time.Date(lt.Year(), lt.Month(), lt.Day()+day, 0, 0, 0, 0, loc)
+
+				start, end := midnight.Add(w.Start), midnight.Add(w.End)
+				if w.End <= w.Start {
+					end = end.Add(24 * time.Hour)
+				}
+
+				if !t.Before(start) && t.Before(end) && end.After(until) {
+					until = end
+				}
+			}
+		}
+
+		if until.IsZero() {
+			return false, time.Time{}
+		}
+	}
+
+	// Stop at the start of the next blackout.
+	for _, b := range s.Blackouts {
+		if b.Start.After(t) && (until.IsZero() || b.Start.Before(until)) {
+			until = b.Start
+		}
+	}
+
+	return true, until
+}
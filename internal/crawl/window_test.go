@@ -0,0 +1,59 @@
+package crawl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	night, err := ParseWindow("22:00-06:00")
+	assert.NoError(t, err)
+	lunch, err := ParseWindow("12:00-13:30")
+	assert.NoError(t, err)
+	b, err := ParseBlackout("2024-12-24T00:00:00Z/2024-12-26T00:00:00Z")
+	assert.NoError(t, err)
+
+	s := Schedule{Windows: []Window{night, lunch}, Blackouts: []Blackout{b}}
+
+	f := func(at string, ok bool, until string) {
+		tm, _ := time.Parse(time.RFC3339, at)
+		gotOK, gotUntil := s.Allowed(tm)
+		assert.Equal(t, ok, gotOK, at)
+		if until == "" {
+			assert.True(t, gotUntil.IsZero(), at)
+		} else {
+			exp, _ := time.Parse(time.RFC3339, until)
+			assert.True(t, exp.Equal(gotUntil), "%s: %s", at, gotUntil)
+		}
+	}
+	f("2024-12-01T23:00:00Z", true, "2024-12-02T06:00:00Z")
+	f("2024-12-02T03:00:00Z", true, "2024-12-02T06:00:00Z")
+	f("2024-12-02T06:00:00Z", false, "")
+	f("2024-12-02T12:30:00Z", true, "2024-12-02T13:30:00Z")
+	f("2024-12-02T15:00:00Z", false, "")
+
+	// Blackouts.
+	f("2024-12-23T23:00:00Z", true, "2024-12-24T00:00:00Z")
+	f("2024-12-24T23:00:00Z", false, "")
+	f("2024-12-26T01:00:00Z", true, "2024-12-26T06:00:00Z")
+
+	// No windows.
+	s = Schedule{Blackouts: []Blackout{b}}
+	f("2024-12-02T15:00:00Z", true, "2024-12-24T00:00:00Z")
+	f("2024-12-27T15:00:00Z", true, "")
+
+	// Windows in another timezone.
+	loc := time.FixedZone("IST", 5*3600+1800)
+	s = Schedule{Windows: []Window{night}, Location: loc}
+	f("2024-12-02T17:00:00Z", true, "2024-12-03T00:30:00Z")
+	f("2024-12-02T15:00:00Z", false, "")
+
+	_, err = ParseWindow("22:00")
+	assert.Error(t, err)
+	_, err = ParseWindow("10:00-10:00")
+	assert.Error(t, err)
+	_, err = ParseBlackout("2024-12-26T00:00:00Z/2024-12-24T00:00:00Z")
+	assert.Error(t, err)
+}
@@ -1,22 +1,36 @@
 package crawl
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"time"
 
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
 	"github.com/floss-fund/portal/internal/core"
 	"github.com/floss-fund/portal/internal/models"
 )
 
-func (c *Crawl) dbWorker() {
+func (c *Crawl) dbWorker(ctx context.Context) {
+	// Signal for running workers to quit once the queue is drained.
+	defer c.queue.close()
+
+	if c.opt.Queue != nil {
+		c.leaseWorker(ctx)
+		return
+	}
+
 	var (
 		n      = 0
 		lastID = 0
 	)
-	for {
+	for ctx.Err() == nil {
 		n++
 		items, err := c.db.GetManifestForCrawling(c.opt.ManifestAge, lastID, c.opt.BatchSize)
 		if err != nil {
-			time.Sleep(time.Second * 5)
+			sleep(ctx, time.Second*5)
 			continue
 		}
 
@@ -27,9 +41,7 @@ func (c *Crawl) dbWorker() {
 		}
 
 		for _, i := range items {
-			select {
-			case c.jobs <- i:
-			}
+			c.queue.push(i, PriorityScheduled)
 		}
 
 		newID := items[len(items)-1].ID
@@ -37,68 +49,268 @@ func (c *Crawl) dbWorker() {
 
 		lastID = newID
 	}
-
-	// Signal for running workers to quit.
-	close(c.jobs)
 }
 
-func (c *Crawl) worker() {
-loop:
+func (c *Crawl) worker(ctx context.Context) {
 	for {
-		select {
-		case j, ok := <-c.jobs:
-			if !ok {
-				break loop
-			}
-
-			// Fetch and validate the manifest.
-			reCrawl, err := c.IsManifestModified(j.URLobj, j.LastModified)
-			if err != nil {
-				c.log.Printf("error fetching modified date: %s: %v", j.URL, err)
-
-				// Record the error.
-				if status, err := c.db.UpdateManifestCrawlError(j.ID, err.Error(), c.opt.MaxCrawlErrors); err == nil {
-					// If the manifest is no longer active, delete it from search.
-					if c.Callbacks.OnManifestUpdate != nil && status != core.ManifestStatusActive {
-						c.Callbacks.OnManifestUpdate(models.ManifestData{ID: j.ID}, status)
-					}
-				}
-
-				continue
-			}
-
-			if !reCrawl {
-				c.log.Printf("no modification. Skipping: %s", j.URL)
-				continue
-			}
-
-			// Fetch and validate the manifest.
-			status := ""
-			m, err := c.FetchManifest(j.URLobj)
-			m.ID = j.ID
-			if err != nil {
-				c.log.Printf("error crawling: %s: %v", j.URL, err)
-
-				// Record the error.
-				status, _ = c.db.UpdateManifestCrawlError(j.ID, err.Error(), c.opt.MaxCrawlErrors)
-				if c.Callbacks.OnManifestUpdate != nil {
-					c.Callbacks.OnManifestUpdate(m, status)
-				}
-
-				continue
-			}
-
-			// Add it to the database.
-			if err := c.db.UpsertManifest(m, status); err != nil {
-				c.log.Printf("error upserting manifest: %s: %v", j.URL, err)
-				continue
-			}
-
-			if c.Callbacks.OnManifestUpdate != nil {
-				c.Callbacks.OnManifestUpdate(m, status)
-			}
+		j, ok := c.queue.pop()
+		if !ok {
+			break
 		}
+
+		// The crawl was cancelled. Drain the queue without processing.
+		if ctx.Err() != nil {
+			c.finishJob(ctx, j, nil)
+			continue
+		}
+
+		var err error
+		if c.conc != nil {
+			c.conc.acquire()
+			_, err = c.processJob(ctx, j)
+			c.conc.release()
+		} else {
+			_, err = c.processJob(ctx, j)
+		}
+		c.finishJob(ctx, j, err)
 	}
 
 	c.wg.Done()
 }
+
+// processJob fetches and validates a manifest job and records the result in the DB.
+// It returns whether the manifest's contents changed since the last crawl.
+func (c *Crawl) processJob(ctx context.Context, j models.ManifestJob) (bool, error) {
+	// If a trace was requested for the manifest, record all the requests and responses.
+	// Traced manifests are always fetched and validated. Others are fetched conditionally
+	// with the validators seen on the last crawl, and skipped if they haven't been modified
+	// or their contents are the same as on the last crawl (unless revalidation is forced).
+	var (
+		opts  = []FetchOpt{WithLimits(j.FetchLimits)}
+		trace *models.ManifestTrace
+	)
+	if j.Trace {
+		trace = &models.ManifestTrace{}
+		opts = append(opts, withTrace(trace))
+		defer c.saveTrace(j, trace)
+	} else if !c.opt.ForceRevalidate {
+		opts = append(opts, WithConditional(j.ETag, j.LastModified), WithKnownHash(j.BodyHash))
+	}
+
+	// Trace the crawl with the ID of the request the manifest was submitted
+	// with, or a new one.
+	reqID := j.RequestID
+	if reqID == "" {
+		reqID = NewRequestID()
+	}
+	ctx = WithRequestID(ctx, reqID)
+
+	// Fetch and validate the manifest.
+	status := ""
+	start := time.Now()
+	res, err := c.FetchManifest(ctx, j.URLobj, opts...)
+
+	// The crawl was cancelled. It's not an error of the manifest.
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	traceResult(trace, err)
+	c.observe(err == nil || res.StatusCode != 0, time.Since(start))
+
+	if res.NotModified {
+		c.logf(ctx, "no modification. Skipping manifest_url=%s", j.URL)
+		c.stats.skip()
+		return false, nil
+	}
+
+	// The manifest is pinned to the hash of its contents. Reject any other contents
+	// so that a compromised host can't silently alter it (eg: payment details).
+	if err == nil && j.PinnedHash != "" && res.Hash != j.PinnedHash {
+		err = fmt.Errorf("%w: fetched contents have the sha256 %s", ErrPinMismatch, res.Hash)
+		res.Manifest = models.ManifestData{}
+	}
+
+	if err == nil && res.Unchanged {
+		c.logf(ctx, "contents unchanged. Skipping manifest_url=%s", j.URL)
+		if res.ETag != j.ETag {
+			c.db.UpdateManifestETag(j.ID, res.ETag)
+		}
+		c.stats.skip()
+		return false, nil
+	}
+	c.stats.add(err == nil, time.Since(start))
+
+	m := res.Manifest
+	m.ID = j.ID
+	if err != nil {
+		c.logf(ctx, "error crawling: %v manifest_url=%s", err, j.URL)
+
+		// If the body was fetched, the manifest itself is invalid.
+		class := ClassifyError(err)
+		if res.StatusCode != 0 && class != ErrClassProvenance && class != ErrClassPinMismatch && class != ErrClassCompliance {
+			class = ErrClassInvalid
+		}
+		c.recordError(j, class, err, reqID)
+
+		// Record the error.
+		status, _ = c.db.UpdateManifestCrawlError(j.ID, err.Error(), c.opt.MaxCrawlErrors)
+		if c.Callbacks.OnManifestUpdate != nil {
+			c.Callbacks.OnManifestUpdate(m, status)
+		}
+
+		if gErr := c.checkGone(ctx, j, res, err); gErr != nil {
+			return false, gErr
+		}
+
+		return false, err
+	}
+
+	// Listings that link to known malicious destinations are flagged, and optionally
+	// held for moderation.
+	unsafe := c.checkURLSafety(ctx, m.Manifest)
+	if unsafe != "" {
+		c.logf(ctx, "%s manifest_url=%s", unsafe, j.URL)
+		if c.opt.UnsafeURLAction == UnsafeQuarantine {
+			status = core.ManifestStatusPending
+		}
+	}
+
+	// If the manifest has permanently moved, move the existing record to the
+	// new URL instead of creating a new one.
+	if res.Moved {
+		u, err := url.Parse(res.FinalURL)
+		if err != nil {
+			return false, err
+		}
+
+		if err := c.db.MoveManifest(j.ID, res.FinalURL, core.AliasRedirect); err != nil {
+			c.logf(ctx, "error moving manifest: %s -> %s: %v", j.URL, res.FinalURL, err)
+			return false, err
+		}
+
+		c.logf(ctx, "manifest moved: %s -> %s", j.URL, res.FinalURL)
+		m.Manifest.URL = v1.URL{URL: res.FinalURL, URLobj: u}
+	}
+
+	// Add it to the database.
+	if err := c.db.UpsertManifest(m, status); err != nil {
+		c.logf(ctx, "error upserting manifest: %v manifest_url=%s", err, j.URL)
+		return false, err
+	}
+	if res.ETag != j.ETag {
+		c.db.UpdateManifestETag(j.ID, res.ETag)
+	}
+	if res.Hash != j.BodyHash {
+		c.db.UpdateManifestBodyHash(j.ID, res.Hash)
+	}
+	if res.RawURL != "" {
+		c.db.InsertManifestAlias(m.Manifest.URL.URL, res.RawURL, core.AliasRaw)
+	}
+	if unsafe != "" {
+		c.db.UpdateManifestStatusMessage(m.Manifest.URL.URL, unsafe)
+	}
+
+	if c.Callbacks.OnManifestUpdate != nil {
+		c.Callbacks.OnManifestUpdate(m, status)
+	}
+
+	// Mirror the manifest's contents.
+	if c.opt.Mirror {
+		if err := c.db.UpsertManifestMirror(j.ID, res.Body, res.Hash, res.FetchedAt); err != nil {
+			c.logf(ctx, "error saving manifest mirror: %v manifest_url=%s", err, j.URL)
+		}
+	}
+
+	// Capture the entity's favicon.
+	if c.opt.FetchFavicons {
+		c.saveFavicon(ctx, m)
+	}
+
+	return core.ManifestHash(m.Manifest) != j.Hash, nil
+}
+
+// checkGone tracks the streak of consecutive crawls on which a manifest URL has
+// responded with 404 or 410, and once it reaches Opt.GoneAfter, returns a GoneError.
+// Errors without a response (eg: timeouts) neither extend nor reset the streak.
+func (c *Crawl) checkGone(ctx context.Context, j models.ManifestJob, res FetchResult, err error) *GoneError {
+	if c.opt.GoneAfter < 1 {
+		return nil
+	}
+
+	code := res.StatusCode
+	var se *StatusError
+	if errors.As(err, &se) {
+		code = se.StatusCode
+	}
+	if code == 0 {
+		return nil
+	}
+
+	gone := code == http.StatusNotFound || code == http.StatusGone
+	streak, dbErr := c.db.UpdateManifestGoneStreak(j.ID, gone)
+	if dbErr != nil || !gone || streak < c.opt.GoneAfter {
+		return nil
+	}
+
+	gErr := &GoneError{URL: j.URL, StatusCode: code, Streak: streak}
+	c.logf(ctx, "%v", gErr)
+	if c.Callbacks.OnManifestGone != nil {
+		c.Callbacks.OnManifestGone(j, gErr)
+	}
+
+	return gErr
+}
+
+// CrawlManifest crawls a single manifest job outside of a crawl run (eg: by a
+// scheduler) and records the result in the DB like a crawl does. It returns
+// whether the manifest's contents changed since the last crawl.
+func (c *Crawl) CrawlManifest(ctx context.Context, j models.ManifestJob) (bool, error) {
+	return c.processJob(ctx, j)
+}
+
+// observe feeds the outcome of a fetch to the adaptive concurrency controller.
+// Invalid manifests are not fetch errors and don't count towards the error rate.
+func (c *Crawl) observe(ok bool, d time.Duration) {
+	if c.conc != nil {
+		c.conc.observe(ok, d)
+	}
+}
+
+// saveTrace saves the captured trace of a job.
+func (c *Crawl) saveTrace(j models.ManifestJob, t *models.ManifestTrace) {
+	t.URL = j.URL
+	t.CapturedAt = time.Now()
+	if err := c.db.SaveManifestTrace(j.ID, *t); err != nil {
+		c.log.Printf("error saving trace: %s: %v", j.URL, err)
+	}
+}
+
+// recordError records a crawl error with its class for analytics.
+func (c *Crawl) recordError(j models.ManifestJob, class string, err error, reqID string) {
+	host := ""
+	if j.URLobj != nil {
+		host = j.URLobj.Hostname()
+	}
+
+	if err := c.db.InsertCrawlError(j.ID, host, class, err.Error(), reqID); err != nil {
+		c.log.Printf("error recording crawl error: %s: %v", j.URL, err)
+	}
+}
+
+// saveFavicon fetches the favicon of a manifest's entity webpage and saves it.
+func (c *Crawl) saveFavicon(ctx context.Context, m models.ManifestData) {
+	u := m.Manifest.Entity.WebpageURL.URLobj
+	if u == nil {
+		return
+	}
+
+	f, err := c.FetchFavicon(ctx, u)
+	if err != nil {
+		c.log.Printf("error fetching favicon: %s: %v", u.String(), err)
+		return
+	}
+
+	if err := c.db.UpsertFavicon(m.ID, f); err != nil {
+		c.log.Printf("error saving favicon: %s: %v", u.String(), err)
+	}
+}
@@ -0,0 +1,56 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type goneDB struct {
+	DB
+	streak int
+}
+
+func (d *goneDB) UpdateManifestGoneStreak(id int, gone bool) (int, error) {
+	if gone {
+		d.streak++
+	} else {
+		d.streak = 0
+	}
+
+	return d.streak, nil
+}
+
+func TestCheckGone(t *testing.T) {
+	var (
+		db   = &goneDB{}
+		c    = newTestCrawl(nil)
+		j    = models.ManifestJob{ID: 1, URL: "https://example.com/funding.json"}
+		gone []*GoneError
+	)
+	c.db = db
+	c.opt.GoneAfter = 2
+	c.Callbacks.OnManifestGone = func(j models.ManifestJob, err *GoneError) {
+		gone = append(gone, err)
+	}
+
+	notFound := &StatusError{URL: j.URL, StatusCode: 404}
+	assert.Nil(t, c.checkGone(context.Background(), j, FetchResult{}, notFound))
+
+	// Errors without a response don't reset the streak.
+	assert.Nil(t, c.checkGone(context.Background(), j, FetchResult{}, errors.New("timeout")))
+	assert.Equal(t, 1, db.streak)
+
+	err := c.checkGone(context.Background(), j, FetchResult{}, &StatusError{URL: j.URL, StatusCode: 410})
+	assert.Equal(t, &GoneError{URL: j.URL, StatusCode: 410, Streak: 2}, err)
+	assert.Len(t, gone, 1)
+
+	// Any other response resets the streak.
+	assert.Nil(t, c.checkGone(context.Background(), j, FetchResult{}, &StatusError{URL: j.URL, StatusCode: 500}))
+	assert.Equal(t, 0, db.streak)
+	assert.Nil(t, c.checkGone(context.Background(), j, FetchResult{}, notFound))
+	assert.Len(t, gone, 1)
+}
@@ -0,0 +1,298 @@
+// Read API of the portal over gRPC. The messages mirror the models in
+// internal/models and internal/search (field names follow their JSON tags)
+// and the RPCs mirror the public REST endpoints. Generate the Go code in
+// internal/grpc/portalv1 with `make proto`.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: portal/v1/portal.proto
+
+package portalv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Portal_SearchProjects_FullMethodName = "/portal.v1.Portal/SearchProjects"
+	Portal_SearchEntities_FullMethodName = "/portal.v1.Portal/SearchEntities"
+	Portal_GetManifest_FullMethodName    = "/portal.v1.Portal/GetManifest"
+	Portal_LookupManifest_FullMethodName = "/portal.v1.Portal/LookupManifest"
+	Portal_GetChanges_FullMethodName     = "/portal.v1.Portal/GetChanges"
+)
+
+// PortalClient is the client API for Portal service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PortalClient interface {
+	// Search projects (GET /search).
+	SearchProjects(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchProjectsResponse, error)
+	// Search entities (GET /search?type=entity).
+	SearchEntities(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchEntitiesResponse, error)
+	// Get a manifest by its GUID (GET /api/entities/:guid).
+	GetManifest(ctx context.Context, in *GetManifestRequest, opts ...grpc.CallOption) (*ManifestData, error)
+	// Look up the listing status of a manifest by its URL, resolving aliases of
+	// moved manifests and federating misses (GET /api/v1/lookup).
+	LookupManifest(ctx context.Context, in *LookupManifestRequest, opts ...grpc.CallOption) (*ManifestLookup, error)
+	// Stream the change feed of listings after a cursor (GET /api/v1/changes).
+	// Without follow, up to limit changes are sent and the stream ends. With follow,
+	// the stream stays open and new changes are sent as they happen (like GET /api/v1/live).
+	GetChanges(ctx context.Context, in *GetChangesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ManifestChange], error)
+}
+
+type portalClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPortalClient(cc grpc.ClientConnInterface) PortalClient {
+	return &portalClient{cc}
+}
+
+func (c *portalClient) SearchProjects(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchProjectsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchProjectsResponse)
+	err := c.cc.Invoke(ctx, Portal_SearchProjects_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portalClient) SearchEntities(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchEntitiesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchEntitiesResponse)
+	err := c.cc.Invoke(ctx, Portal_SearchEntities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portalClient) GetManifest(ctx context.Context, in *GetManifestRequest, opts ...grpc.CallOption) (*ManifestData, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ManifestData)
+	err := c.cc.Invoke(ctx, Portal_GetManifest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portalClient) LookupManifest(ctx context.Context, in *LookupManifestRequest, opts ...grpc.CallOption) (*ManifestLookup, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ManifestLookup)
+	err := c.cc.Invoke(ctx, Portal_LookupManifest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portalClient) GetChanges(ctx context.Context, in *GetChangesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ManifestChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Portal_ServiceDesc.Streams[0], Portal_GetChanges_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetChangesRequest, ManifestChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Portal_GetChangesClient = grpc.ServerStreamingClient[ManifestChange]
+
+// PortalServer is the server API for Portal service.
+// All implementations must embed UnimplementedPortalServer
+// for forward compatibility.
+type PortalServer interface {
+	// Search projects (GET /search).
+	SearchProjects(context.Context, *SearchRequest) (*SearchProjectsResponse, error)
+	// Search entities (GET /search?type=entity).
+	SearchEntities(context.Context, *SearchRequest) (*SearchEntitiesResponse, error)
+	// Get a manifest by its GUID (GET /api/entities/:guid).
+	GetManifest(context.Context, *GetManifestRequest) (*ManifestData, error)
+	// Look up the listing status of a manifest by its URL, resolving aliases of
+	// moved manifests and federating misses (GET /api/v1/lookup).
+	LookupManifest(context.Context, *LookupManifestRequest) (*ManifestLookup, error)
+	// Stream the change feed of listings after a cursor (GET /api/v1/changes).
+	// Without follow, up to limit changes are sent and the stream ends. With follow,
+	// the stream stays open and new changes are sent as they happen (like GET /api/v1/live).
+	GetChanges(*GetChangesRequest, grpc.ServerStreamingServer[ManifestChange]) error
+	mustEmbedUnimplementedPortalServer()
+}
+
+// UnimplementedPortalServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPortalServer struct{}
+
+func (UnimplementedPortalServer) SearchProjects(context.Context, *SearchRequest) (*SearchProjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchProjects not implemented")
+}
+func (UnimplementedPortalServer) SearchEntities(context.Context, *SearchRequest) (*SearchEntitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchEntities not implemented")
+}
+func (UnimplementedPortalServer) GetManifest(context.Context, *GetManifestRequest) (*ManifestData, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetManifest not implemented")
+}
+func (UnimplementedPortalServer) LookupManifest(context.Context, *LookupManifestRequest) (*ManifestLookup, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupManifest not implemented")
+}
+func (UnimplementedPortalServer) GetChanges(*GetChangesRequest, grpc.ServerStreamingServer[ManifestChange]) error {
+	return status.Errorf(codes.Unimplemented, "method GetChanges not implemented")
+}
+func (UnimplementedPortalServer) mustEmbedUnimplementedPortalServer() {}
+func (UnimplementedPortalServer) testEmbeddedByValue()                {}
+
+// UnsafePortalServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PortalServer will
+// result in compilation errors.
+type UnsafePortalServer interface {
+	mustEmbedUnimplementedPortalServer()
+}
+
+func RegisterPortalServer(s grpc.ServiceRegistrar, srv PortalServer) {
+	// If the following call pancis, it indicates UnimplementedPortalServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Portal_ServiceDesc, srv)
+}
+
+func _Portal_SearchProjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortalServer).SearchProjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Portal_SearchProjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortalServer).SearchProjects(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Portal_SearchEntities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortalServer).SearchEntities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Portal_SearchEntities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortalServer).SearchEntities(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Portal_GetManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetManifestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortalServer).GetManifest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Portal_GetManifest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortalServer).GetManifest(ctx, req.(*GetManifestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Portal_LookupManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupManifestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortalServer).LookupManifest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Portal_LookupManifest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortalServer).LookupManifest(ctx, req.(*LookupManifestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Portal_GetChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetChangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PortalServer).GetChanges(m, &grpc.GenericServerStream[GetChangesRequest, ManifestChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Portal_GetChangesServer = grpc.ServerStreamingServer[ManifestChange]
+
+// Portal_ServiceDesc is the grpc.ServiceDesc for Portal service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Portal_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "portal.v1.Portal",
+	HandlerType: (*PortalServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SearchProjects",
+			Handler:    _Portal_SearchProjects_Handler,
+		},
+		{
+			MethodName: "SearchEntities",
+			Handler:    _Portal_SearchEntities_Handler,
+		},
+		{
+			MethodName: "GetManifest",
+			Handler:    _Portal_GetManifest_Handler,
+		},
+		{
+			MethodName: "LookupManifest",
+			Handler:    _Portal_LookupManifest_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetChanges",
+			Handler:       _Portal_GetChanges_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "portal/v1/portal.proto",
+}
@@ -0,0 +1,361 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V1_1_0 performs the DB migrations for v1.1.0.
+func V1_1_0(db *sqlx.DB, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS favicons (
+		manifest_id         INTEGER NOT NULL UNIQUE REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		body                BYTEA NOT NULL,
+		content_type        TEXT NOT NULL,
+		opengraph           JSONB NOT NULL DEFAULT '{}',
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS crawl_runs (
+		id                  SERIAL PRIMARY KEY,
+		started_at          TIMESTAMP WITH TIME ZONE NOT NULL,
+		finished_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+		total               INT NOT NULL DEFAULT 0,
+		success             INT NOT NULL DEFAULT 0,
+		failed              INT NOT NULL DEFAULT 0,
+		skipped             INT NOT NULL DEFAULT 0,
+		latency_p50         INT NOT NULL DEFAULT 0,
+		latency_p95         INT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_crawl_runs_started ON crawl_runs(started_at);
+
+	CREATE TABLE IF NOT EXISTS manifest_aliases (
+		id                  SERIAL PRIMARY KEY,
+		manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		url                 TEXT NOT NULL UNIQUE,
+		reason              TEXT NOT NULL,
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_manifest_aliases_manifest ON manifest_aliases(manifest_id);
+
+	CREATE TABLE IF NOT EXISTS entity_links (
+		manifest_id         INTEGER NOT NULL UNIQUE REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		parent_id           INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+		CHECK (manifest_id != parent_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_entity_links_parent ON entity_links(parent_id);
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS hash TEXT NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS manifest_changes (
+		id                  BIGSERIAL PRIMARY KEY,
+		manifest_id         INTEGER NOT NULL,
+		guid                TEXT NOT NULL,
+		url                 TEXT NOT NULL,
+		event               TEXT NOT NULL,
+		hash                TEXT NOT NULL DEFAULT '',
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE OR REPLACE FUNCTION record_manifest_change() RETURNS TRIGGER AS $$
+	DECLARE
+		ev TEXT;
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			IF OLD.status = 'active' THEN
+				INSERT INTO manifest_changes (manifest_id, guid, url, event, hash) VALUES (OLD.id, OLD.guid, OLD.url, 'delete', OLD.hash);
+			END IF;
+			RETURN OLD;
+		END IF;
+
+		IF TG_OP = 'INSERT' THEN
+			IF NEW.status = 'active' THEN
+				ev := 'create';
+			END IF;
+		ELSIF OLD.status != 'active' AND NEW.status = 'active' THEN
+			ev := 'create';
+		ELSIF OLD.status = 'active' AND NEW.status != 'active' THEN
+			ev := 'delete';
+		ELSIF NEW.status = 'active' AND (OLD.hash != NEW.hash OR OLD.url != NEW.url) THEN
+			ev := 'update';
+		END IF;
+
+		IF ev IS NOT NULL THEN
+			INSERT INTO manifest_changes (manifest_id, guid, url, event, hash) VALUES (NEW.id, NEW.guid, NEW.url, ev, NEW.hash);
+		END IF;
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS trg_manifest_changes ON manifests;
+	CREATE TRIGGER trg_manifest_changes AFTER INSERT OR UPDATE OR DELETE ON manifests
+		FOR EACH ROW EXECUTE FUNCTION record_manifest_change();
+
+	CREATE TABLE IF NOT EXISTS funding_snapshots (
+		id                  BIGSERIAL PRIMARY KEY,
+		manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		currency            TEXT NOT NULL,
+		goal                NUMERIC NOT NULL DEFAULT 0,
+		received            NUMERIC NOT NULL DEFAULT 0,
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_funding_snapshots_manifest ON funding_snapshots(manifest_id, currency, id);
+
+	CREATE TABLE IF NOT EXISTS tag_categories (
+		tag                 TEXT NOT NULL PRIMARY KEY,
+		category            TEXT NOT NULL,
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_tag_categories_category ON tag_categories(category);
+
+	CREATE TABLE IF NOT EXISTS related_projects (
+		project_id          INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		related_id          INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		score               REAL NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_related_projects ON related_projects(project_id);
+
+	CREATE TABLE IF NOT EXISTS payment_denylist (
+		address             TEXT NOT NULL PRIMARY KEY,
+		channel_type        TEXT NOT NULL DEFAULT '',
+		reason              TEXT NOT NULL DEFAULT '',
+		source              TEXT NOT NULL DEFAULT '',
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS verification TEXT NOT NULL DEFAULT 'unverified';
+	CREATE INDEX IF NOT EXISTS idx_manifests_verification ON manifests(verification);
+
+	CREATE TABLE IF NOT EXISTS fiscal_hosts (
+		id                  SERIAL PRIMARY KEY,
+		manifest_id         INTEGER NOT NULL UNIQUE REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		public_key          TEXT NOT NULL,
+		members_url         TEXT NOT NULL,
+		num_members         INT NOT NULL DEFAULT 0,
+		last_error          TEXT NOT NULL DEFAULT '',
+		refreshed_at        TIMESTAMP WITH TIME ZONE NULL,
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS fiscal_host_members (
+		fiscal_host_id      INTEGER NOT NULL REFERENCES fiscal_hosts(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		url                 TEXT NOT NULL,
+		PRIMARY KEY (fiscal_host_id, url)
+	);
+	CREATE INDEX IF NOT EXISTS idx_fiscal_host_members_url ON fiscal_host_members(url);
+
+	CREATE TABLE IF NOT EXISTS manifest_mirrors (
+		manifest_id         INTEGER NOT NULL UNIQUE REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		body                BYTEA NOT NULL,
+		hash                TEXT NOT NULL DEFAULT '',
+		fetched_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS scorecards (
+		repository_url      TEXT NOT NULL PRIMARY KEY,
+		score               REAL NULL,
+		checks              JSONB NOT NULL DEFAULT '[]',
+		scored_at           TIMESTAMP WITH TIME ZONE NULL,
+		last_error          TEXT NOT NULL DEFAULT '',
+		updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS relay_optout BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS relay_source TEXT NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id                  SERIAL PRIMARY KEY,
+		name                TEXT NOT NULL,
+		key_hash            TEXT NOT NULL UNIQUE,
+		daily_quota         INT NOT NULL DEFAULT 10000,
+		enabled             BOOLEAN NOT NULL DEFAULT true,
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS api_key_usage (
+		key_id              INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		day                 DATE NOT NULL DEFAULT CURRENT_DATE,
+		endpoint            TEXT NOT NULL,
+		requests            INT NOT NULL DEFAULT 0,
+		errors              INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (key_id, day, endpoint)
+	);
+
+	CREATE TABLE IF NOT EXISTS manifest_liveness (
+		manifest_id         INTEGER NOT NULL PRIMARY KEY REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		available           BOOLEAN NOT NULL,
+		status_code         INT NOT NULL DEFAULT 0,
+		message             TEXT NOT NULL DEFAULT '',
+		checked_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		down_since          TIMESTAMP WITH TIME ZONE NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS manifest_traces (
+		manifest_id         INTEGER NOT NULL PRIMARY KEY REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		armed               BOOLEAN NOT NULL DEFAULT true,
+		trace               JSONB NULL,
+		requested_at        TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		captured_at         TIMESTAMP WITH TIME ZONE NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS crawl_errors (
+		id                  BIGSERIAL PRIMARY KEY,
+		manifest_id         INTEGER NULL REFERENCES manifests(id) ON DELETE SET NULL ON UPDATE CASCADE,
+		host                TEXT NOT NULL,
+		class               TEXT NOT NULL,
+		message             TEXT NOT NULL DEFAULT '',
+		created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_crawl_errors_created ON crawl_errors(created_at);
+	CREATE INDEX IF NOT EXISTS idx_crawl_errors_host ON crawl_errors(host);
+
+	CREATE TABLE IF NOT EXISTS repo_activity (
+		repository_url      TEXT NOT NULL PRIMARY KEY,
+		last_commit_at      TIMESTAMP WITH TIME ZONE NULL,
+		contributors        INT NOT NULL DEFAULT 0,
+		open_issues         INT NOT NULL DEFAULT 0,
+		last_error          TEXT NOT NULL DEFAULT '',
+		updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS translations (
+		manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		kind                TEXT NOT NULL,
+		guid                TEXT NOT NULL DEFAULT '',
+		locale              TEXT NOT NULL,
+		text                TEXT NOT NULL,
+		source_hash         TEXT NOT NULL,
+		updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+		PRIMARY KEY (manifest_id, kind, guid, locale)
+	);
+
+	CREATE TABLE IF NOT EXISTS manifest_webhooks (
+		id                  SERIAL PRIMARY KEY,
+		manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		url                 TEXT NOT NULL,
+		events              TEXT[] NOT NULL,
+		secret              TEXT NOT NULL,
+		token_hash          TEXT NOT NULL UNIQUE,
+		confirmed           BOOLEAN NOT NULL DEFAULT false,
+		failures            INT NOT NULL DEFAULT 0,
+		enabled             BOOLEAN NOT NULL DEFAULT true,
+		last_status         INT NOT NULL DEFAULT 0,
+		last_delivered_at   TIMESTAMP WITH TIME ZONE NULL,
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_manifest_webhooks_manifest ON manifest_webhooks(manifest_id);
+
+	CREATE INDEX IF NOT EXISTS idx_project_repository_key ON projects((LOWER(REGEXP_REPLACE(repository_url, '^https?://(www\.)?|/+$|\.git/*$', '', 'g'))));
+	CREATE INDEX IF NOT EXISTS idx_project_webpage_key ON projects((LOWER(REGEXP_REPLACE(webpage_url, '^https?://(www\.)?|/+$', '', 'g'))));
+	CREATE INDEX IF NOT EXISTS idx_entity_webpage_key ON entities((LOWER(REGEXP_REPLACE(webpage_url, '^https?://(www\.)?|/+$', '', 'g'))));
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS etag TEXT NOT NULL DEFAULT '';
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS pinned_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE crawl_errors ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS payment_changes (
+		id                  BIGSERIAL PRIMARY KEY,
+		manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		old_channels        JSONB NOT NULL DEFAULT '[]',
+		new_channels        JSONB NOT NULL DEFAULT '[]',
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE OR REPLACE FUNCTION record_payment_change() RETURNS TRIGGER AS $$
+	BEGIN
+		INSERT INTO payment_changes (manifest_id, old_channels, new_channels)
+			VALUES (NEW.id, COALESCE(OLD.funding->'channels', '[]'), COALESCE(NEW.funding->'channels', '[]'));
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS trg_payment_changes ON manifests;
+	CREATE TRIGGER trg_payment_changes AFTER UPDATE OF funding ON manifests
+		FOR EACH ROW WHEN (OLD.status = 'active' AND OLD.funding->'channels' IS DISTINCT FROM NEW.funding->'channels')
+		EXECUTE FUNCTION record_payment_change();
+
+	CREATE TABLE IF NOT EXISTS telemetry_reports (
+		instance_id         TEXT NOT NULL PRIMARY KEY,
+		version             TEXT NOT NULL DEFAULT '',
+		manifests           INTEGER NOT NULL DEFAULT 0,
+		projects            INTEGER NOT NULL DEFAULT 0,
+		reported_at         TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS crawl_schedule (
+		manifest_id         INTEGER NOT NULL PRIMARY KEY REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		interval_secs       INTEGER NOT NULL DEFAULT 0,
+		next_at             TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_crawl_schedule_next ON crawl_schedule(next_at);
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS body_hash TEXT NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS manifest_versions (
+		id                  BIGSERIAL PRIMARY KEY,
+		manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		hash                TEXT NOT NULL,
+		body                JSONB NOT NULL,
+		created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_manifest_versions_manifest ON manifest_versions(manifest_id, id);
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS preview_token_hash TEXT NOT NULL DEFAULT '';
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS gone_streak INT NOT NULL DEFAULT 0;
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS intake TEXT NOT NULL DEFAULT '';
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS intake_ref TEXT NOT NULL DEFAULT '';
+
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS fetch_max_bytes BIGINT NULL;
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS fetch_timeout_ms INTEGER NULL;
+	ALTER TABLE manifests ADD COLUMN IF NOT EXISTS fetch_attempts INTEGER NULL;
+
+	CREATE TABLE IF NOT EXISTS crawl_queue (
+		manifest_id         INTEGER NOT NULL PRIMARY KEY REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		priority            SMALLINT NOT NULL DEFAULT 0,
+		attempts            INTEGER NOT NULL DEFAULT 0,
+		available_at        TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		leased_until        TIMESTAMP WITH TIME ZONE NULL,
+		created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_crawl_queue_available ON crawl_queue(priority, available_at);
+	CREATE TABLE IF NOT EXISTS host_downtime (
+		host                TEXT NOT NULL PRIMARY KEY,
+		down_since          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		checked_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+	CREATE TABLE IF NOT EXISTS tenant_manifests (
+		tenant              TEXT NOT NULL,
+		manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+		created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (tenant, manifest_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_tenant_manifests_manifest ON tenant_manifests(manifest_id);
+	DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'payment_changes' AND column_name = 'alerted_at') THEN
+			ALTER TABLE payment_changes ADD COLUMN alerted_at TIMESTAMP WITH TIME ZONE NULL;
+
+			-- Changes until now were alerted by in-memory cursors.
+			UPDATE payment_changes SET alerted_at = created_at;
+		END IF;
+	END $$;
+	CREATE INDEX IF NOT EXISTS idx_payment_changes_unalerted ON payment_changes(id) WHERE alerted_at IS NULL;
+	`); err != nil {
+		return err
+	}
+
+	// New enum values can't be added in a (multi-statement) transaction.
+	if _, err := db.Exec(`ALTER TYPE manifest_status ADD VALUE IF NOT EXISTS 'preview'`); err != nil {
+		return err
+	}
+
+	return nil
+}
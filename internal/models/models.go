@@ -1,21 +1,125 @@
 package models
 
 import (
+	"encoding/json"
+	"net/http"
 	"net/url"
 	"time"
 
 	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
 	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
 )
 
 type ManifestJob struct {
 	ID           int       `json:"id" db:"id"`
 	URL          string    `json:"url" db:"url"`
 	LastModified time.Time `json:"updated_at" db:"updated_at"`
+	ETag         string    `json:"etag" db:"etag"`
+	PinnedHash   string    `json:"pinned_hash" db:"pinned_hash"`
+
+	// SHA-256 of the (normalized) manifest stored on the last crawl, and of
+	// the raw body it was parsed from.
+	Hash     string `json:"-" db:"hash"`
+	BodyHash string `json:"-" db:"body_hash"`
+
+	// ID of the request the manifest was submitted with, for tracing its crawls.
+	RequestID string `json:"request_id" db:"request_id"`
+
+	// An admin requested a trace of the next crawl of the manifest.
+	Trace bool `json:"trace" db:"trace"`
+
+	// Number of failed attempts and the priority class (crawl.Priority) of the job
+	// in the durable crawl queue.
+	Attempts int `json:"-" db:"attempts"`
+	Priority int `json:"-" db:"priority"`
+
+	FetchLimits
 
 	URLobj *url.URL `json:"-" db:"-"`
 }
 
+// FetchLimits are per-manifest overrides of the crawler's global fetch limits, eg: for
+// large fiscal host manifests that legitimately exceed them. Unset (nil) limits use
+// the global ones.
+type FetchLimits struct {
+	MaxBytes  *int64 `json:"max_bytes" db:"fetch_max_bytes"`
+	TimeoutMS *int   `json:"timeout_ms" db:"fetch_timeout_ms"`
+	Attempts  *int   `json:"attempts" db:"fetch_attempts"`
+}
+
+// ScheduledCrawl is a manifest job due for a scheduled crawl with its current
+// crawl interval (0 if it hasn't been scheduled yet).
+type ScheduledCrawl struct {
+	ManifestJob
+
+	IntervalSecs int `json:"interval_secs" db:"interval_secs"`
+}
+
+// ManifestLiveness is the availability of a manifest URL recorded by the last liveness sweep.
+type ManifestLiveness struct {
+	ManifestID int        `db:"manifest_id" json:"manifest_id"`
+	Available  bool       `db:"available" json:"available"`
+	StatusCode int        `db:"status_code" json:"status_code"`
+	Message    string     `db:"message" json:"message"`
+	CheckedAt  time.Time  `db:"checked_at" json:"checked_at"`
+	DownSince  *time.Time `db:"down_since" json:"down_since"`
+}
+
+// HostDowntime is a host that all the manifests of were unreachable on the last liveness sweep.
+type HostDowntime struct {
+	Host      string    `db:"host" json:"host"`
+	DownSince time.Time `db:"down_since" json:"down_since"`
+	CheckedAt time.Time `db:"checked_at" json:"checked_at"`
+	Manifests int       `db:"manifests" json:"manifests"`
+}
+
+// TenantManifest is a manifest listed on a tenant sub-portal.
+type TenantManifest struct {
+	ID        int       `db:"id" json:"id"`
+	URL       string    `db:"url" json:"url"`
+	Status    string    `db:"status" json:"status"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// HostDowntimeUpdate is the number of hosts that went down and came back up on a
+// liveness sweep.
+type HostDowntimeUpdate struct {
+	Down int `db:"down"`
+	Up   int `db:"up"`
+}
+
+// ManifestTrace is the full capture of the requests and responses
+// of a crawl of a manifest for debugging.
+type ManifestTrace struct {
+	URL        string          `json:"url"`
+	Exchanges  []TraceExchange `json:"exchanges"`
+	Result     string          `json:"result"`
+	CapturedAt time.Time       `json:"captured_at"`
+}
+
+// ManifestTraceRecord is the trace request of a manifest and the captured trace, if any.
+type ManifestTraceRecord struct {
+	ManifestID  int            `db:"manifest_id" json:"manifest_id"`
+	Armed       bool           `db:"armed" json:"armed"`
+	Trace       types.JSONText `db:"trace" json:"trace"`
+	RequestedAt time.Time      `db:"requested_at" json:"requested_at"`
+	CapturedAt  *time.Time     `db:"captured_at" json:"captured_at"`
+}
+
+// TraceExchange is a single request and its response in a trace.
+type TraceExchange struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"request_headers"`
+	Status          int         `json:"status"`
+	ResponseHeaders http.Header `json:"response_headers"`
+	FinalURL        string      `json:"final_url"`
+	Body            string      `json:"body"`
+	Duration        int         `json:"duration_ms"`
+	Error           string      `json:"error"`
+}
+
 //easyjson:json
 type ManifestData struct {
 	v1.Manifest
@@ -34,6 +138,8 @@ type ManifestData struct {
 	Meta          types.JSONText `db:"meta" json:"meta"`
 	Status        string         `db:"status" json:"status"`
 	StatusMessage *string        `db:"status_message" json:"status_message"`
+	Verification  string         `db:"verification" json:"verification"`
+	Hash          string         `db:"hash" json:"-"`
 	CrawlErrors   int            `db:"crawl_errors" json:"crawl_errors"`
 	CrawlMessage  *string        `db:"crawl_message" json:"crawl_message"`
 	CreatedAt     time.Time      `db:"created_at" json:"created_at"`
@@ -53,3 +159,627 @@ type ProjectURL struct {
 
 //easyjson:json
 type ProjectURLs []ProjectURL
+
+// Favicon is the icon (and optional OpenGraph metadata) of an entity's webpage
+// captured during crawling.
+type Favicon struct {
+	Body        []byte         `db:"body" json:"-"`
+	ContentType string         `db:"content_type" json:"content_type"`
+	OpenGraph   types.JSONText `db:"opengraph" json:"opengraph"`
+	UpdatedAt   time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// ManifestMirror is the last validated copy of a manifest's raw contents
+// captured during crawling.
+type ManifestMirror struct {
+	URL       string    `db:"url" json:"url"`
+	Body      []byte    `db:"body" json:"-"`
+	Hash      string    `db:"hash" json:"hash"`
+	FetchedAt time.Time `db:"fetched_at" json:"fetched_at"`
+}
+
+// Scorecard is the cached OpenSSF Scorecard result of a repository. Score is
+// nil if the repository hasn't been scored (yet).
+type Scorecard struct {
+	RepositoryURL string         `db:"repository_url" json:"repository_url"`
+	Score         *float64       `db:"score" json:"score"`
+	Checks        types.JSONText `db:"checks" json:"checks"`
+	ScoredAt      *time.Time     `db:"scored_at" json:"scored_at"`
+	LastError     string         `db:"last_error" json:"last_error"`
+	UpdatedAt     time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// APIKey represents an API key issued to an integrator.
+type APIKey struct {
+	ID            int       `db:"id" json:"id"`
+	Name          string    `db:"name" json:"name"`
+	DailyQuota    int       `db:"daily_quota" json:"daily_quota"`
+	Enabled       bool      `db:"enabled" json:"enabled"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	RequestsToday int       `db:"requests_today" json:"requests_today"`
+}
+
+// Manifest webhook events.
+const (
+	WebhookEventUpdate         = "update"
+	WebhookEventDelist         = "delist"
+	WebhookEventProvenanceLost = "provenance-lost"
+	WebhookEventPaymentChange  = "payment-change"
+)
+
+// Webhook is a webhook registered by a maintainer, scoped to their manifest.
+// Secret is the key deliveries are signed with, and is only shown on confirmation.
+type Webhook struct {
+	ID              int            `db:"id" json:"id"`
+	ManifestID      int            `db:"manifest_id" json:"manifest_id"`
+	URL             string         `db:"url" json:"url"`
+	Events          pq.StringArray `db:"events" json:"events"`
+	Confirmed       bool           `db:"confirmed" json:"confirmed"`
+	Enabled         bool           `db:"enabled" json:"enabled"`
+	Failures        int            `db:"failures" json:"failures"`
+	LastStatus      int            `db:"last_status" json:"last_status"`
+	LastDeliveredAt *time.Time     `db:"last_delivered_at" json:"last_delivered_at"`
+	CreatedAt       time.Time      `db:"created_at" json:"created_at"`
+	Secret          string         `db:"secret" json:"secret,omitempty"`
+}
+
+// WebhookEvent is the payload of a webhook delivery.
+type WebhookEvent struct {
+	Event      string    `json:"event"`
+	ManifestID int       `json:"manifest_id"`
+	GUID       string    `json:"guid"`
+	URL        string    `json:"url"`
+	Message    string    `json:"message,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PaymentChange is a change to the funding channels of an active manifest between crawls.
+type PaymentChange struct {
+	ID          int64          `db:"id" json:"id"`
+	ManifestID  int            `db:"manifest_id" json:"manifest_id"`
+	GUID        string         `db:"guid" json:"guid"`
+	URL         string         `db:"url" json:"url"`
+	OldChannels types.JSONText `db:"old_channels" json:"old_channels"`
+	NewChannels types.JSONText `db:"new_channels" json:"new_channels"`
+	CreatedAt   time.Time      `db:"created_at" json:"created_at"`
+}
+
+// ChannelChange is a change to where the payments of a funding channel go.
+type ChannelChange struct {
+	GUID       string `json:"guid"`
+	Change     string `json:"change"`
+	OldType    string `json:"old_type,omitempty"`
+	NewType    string `json:"new_type,omitempty"`
+	OldAddress string `json:"old_address,omitempty"`
+	NewAddress string `json:"new_address,omitempty"`
+}
+
+// ProvenanceError is a crawl error of a manifest whose provenance couldn't be established.
+type ProvenanceError struct {
+	ID         int64     `db:"id"`
+	ManifestID int       `db:"manifest_id"`
+	GUID       string    `db:"guid"`
+	URL        string    `db:"url"`
+	Message    string    `db:"message"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// APIKeyUsage is the usage of an API key on an endpoint on a day.
+type APIKeyUsage struct {
+	Day      time.Time `db:"day" json:"day"`
+	Endpoint string    `db:"endpoint" json:"endpoint"`
+	Requests int       `db:"requests" json:"requests"`
+	Errors   int       `db:"errors" json:"errors"`
+}
+
+// ManifestRelay represents the relay settings of a manifest.
+type ManifestRelay struct {
+	OptOut bool   `db:"relay_optout" json:"optout"`
+	Source string `db:"relay_source" json:"source"`
+}
+
+// RepoActivity is the cached activity metrics of a repository from its forge's API.
+type RepoActivity struct {
+	RepositoryURL string     `db:"repository_url" json:"repository_url"`
+	LastCommitAt  *time.Time `db:"last_commit_at" json:"last_commit_at"`
+	Contributors  int        `db:"contributors" json:"contributors"`
+	OpenIssues    int        `db:"open_issues" json:"open_issues"`
+	LastError     string     `db:"last_error" json:"last_error"`
+	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
+
+	// Freshness (active, stale, abandoned) going by the last commit.
+	Freshness string `db:"-" json:"freshness"`
+}
+
+// Translation is a machine translation of an entity's (GUID is empty) or
+// a project's description.
+type Translation struct {
+	ManifestID int       `db:"manifest_id" json:"manifest_id"`
+	Kind       string    `db:"kind" json:"kind"`
+	GUID       string    `db:"guid" json:"guid"`
+	Locale     string    `db:"locale" json:"locale"`
+	Text       string    `db:"text" json:"text"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+
+	// Always true. Translations are machine generated and labeled as such.
+	Machine bool `db:"-" json:"machine"`
+}
+
+// TranslationJob is a description that's due for translation to a locale.
+type TranslationJob struct {
+	ManifestID  int    `db:"manifest_id"`
+	Kind        string `db:"kind"`
+	GUID        string `db:"guid"`
+	Locale      string `db:"locale"`
+	Description string `db:"description"`
+}
+
+// OpenGraph represents the link-preview metadata of a webpage.
+type OpenGraph struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// CrawlRun represents the stats of a single crawl run. Latencies are in milliseconds.
+type CrawlRun struct {
+	ID         int       `db:"id" json:"id"`
+	StartedAt  time.Time `db:"started_at" json:"started_at"`
+	FinishedAt time.Time `db:"finished_at" json:"finished_at"`
+	Total      int       `db:"total" json:"total"`
+	Success    int       `db:"success" json:"success"`
+	Failed     int       `db:"failed" json:"failed"`
+	Skipped    int       `db:"skipped" json:"skipped"`
+	LatencyP50 int       `db:"latency_p50" json:"latency_p50"`
+	LatencyP95 int       `db:"latency_p95" json:"latency_p95"`
+}
+
+// CrawlErrorDomain is the aggregate of crawl errors on a host.
+type CrawlErrorDomain struct {
+	Host        string         `db:"host" json:"host"`
+	Errors      int            `db:"errors" json:"errors"`
+	Manifests   int            `db:"manifests" json:"manifests"`
+	Classes     pq.StringArray `db:"classes" json:"classes"`
+	LastErrorAt time.Time      `db:"last_error_at" json:"last_error_at"`
+	LastMessage string         `db:"last_message" json:"last_message"`
+}
+
+// CrawlErrorTrend is the number of crawl errors of a class on a day.
+type CrawlErrorTrend struct {
+	Day    time.Time `db:"day" json:"day"`
+	Class  string    `db:"class" json:"class"`
+	Errors int       `db:"errors" json:"errors"`
+}
+
+// CrawlStatus is the summary of the crawler's health over recent runs.
+type CrawlStatus struct {
+	NumRuns       int        `json:"num_runs"`
+	LastRunAt     *time.Time `json:"last_run_at"`
+	SuccessRate   float64    `json:"success_rate"`
+	MedianLatency int        `json:"median_latency"`
+	Runs          []CrawlRun `json:"runs"`
+}
+
+// ManifestMeta is additional data of a manifest stored in its meta field.
+type ManifestMeta struct {
+	Security *SecurityContact `json:"security,omitempty"`
+
+	// Citations of projects (by project GUID) that reference a DOI.
+	Citations map[string]Citation `json:"citations,omitempty"`
+
+	// Deprecated funding plans and channels.
+	Deprecations *Deprecations `json:"deprecations,omitempty"`
+
+	// Additional repositories of projects (by project GUID) besides their primary repositoryUrl.
+	Repositories map[string][]Repository `json:"repositories,omitempty"`
+
+	// Projects that failed checks and were not listed (partial acceptance).
+	Rejected []RejectedProject `json:"rejected_projects,omitempty"`
+
+	// Experimental fields of the manifest and its projects that aren't in the spec.
+	Extensions *Extensions `json:"extensions,omitempty"`
+}
+
+// Extensions are the experimental (x-) fields of a manifest and of its projects
+// (by project GUID) that are being prototyped before they're adopted in the spec.
+type Extensions struct {
+	Manifest map[string]json.RawMessage            `json:"manifest,omitempty"`
+	Projects map[string]map[string]json.RawMessage `json:"projects,omitempty"`
+}
+
+// ModerationFilter selects the manifests of a bulk moderation action. Empty fields match everything.
+type ModerationFilter struct {
+	// Host of the manifest URL, or a parent domain of it.
+	Domain string `json:"domain"`
+
+	// Submission window.
+	CreatedFrom *time.Time `json:"created_from"`
+	CreatedTo   *time.Time `json:"created_to"`
+
+	// Root URL of the instance the manifests were relayed from.
+	RelaySource string `json:"relay_source"`
+
+	// Class of the latest crawl error of the manifests.
+	ErrorClass string `json:"error_class"`
+
+	Status string `json:"status"`
+
+	// Channel the manifests were submitted through (web, api, email, relay).
+	Intake string `json:"intake"`
+}
+
+// ModerationTarget is a manifest matched by a bulk moderation action.
+type ModerationTarget struct {
+	ID        int       `db:"id" json:"id"`
+	URL       string    `db:"url" json:"url"`
+	Status    string    `db:"status" json:"status"`
+	Intake    string    `db:"intake" json:"intake"`
+	IntakeRef string    `db:"intake_ref" json:"intake_ref"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// ManifestIntake is the channel a manifest was submitted through (eg: web, api) and
+// a reference within it (eg: the API key's name). It's empty for manifests that
+// predate intake tracking.
+type ManifestIntake struct {
+	Intake    string    `db:"intake" json:"intake"`
+	Ref       string    `db:"intake_ref" json:"ref"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// IntakeStat is the number of manifests of a status submitted through a channel.
+type IntakeStat struct {
+	Intake    string `db:"intake" json:"intake"`
+	Ref       string `db:"intake_ref" json:"ref"`
+	Status    string `db:"status" json:"status"`
+	Manifests int    `db:"manifests" json:"manifests"`
+}
+
+// RejectedProject is a project of a partially accepted manifest that failed checks
+// (eg: provenance) and was left out while the rest of the manifest was listed.
+type RejectedProject struct {
+	GUID   string `json:"guid"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Roles of additional project repositories.
+const (
+	RepoRoleMirror = "mirror"
+	RepoRoleDocs   = "docs"
+	RepoRoleOther  = "other"
+)
+
+// Repository is an additional repository of a project (eg: a mirror or a docs repository).
+type Repository struct {
+	URL  string `json:"url"`
+	Role string `json:"role"`
+
+	// Like the primary repositoryUrl, required for provenance checks if the
+	// repository isn't on the manifest's host and path.
+	WellKnown string `json:"wellKnown,omitempty"`
+}
+
+// Deprecations are the deprecated plans and channels (by GUID) of a manifest.
+type Deprecations struct {
+	Plans    map[string]Deprecation `json:"plans,omitempty"`
+	Channels map[string]Deprecation `json:"channels,omitempty"`
+}
+
+// Deprecation marks a plan or channel as discontinued. Replacement is the
+// GUID of the plan or channel that replaces it (if any), and Hint is a
+// human readable migration hint for funders.
+type Deprecation struct {
+	Replacement string `json:"replacement,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Since       string `json:"since,omitempty"`
+	Hint        string `json:"hint"`
+}
+
+// DeepLink is a link to fund a plan through a channel. Prefilled is true if the
+// link is to the checkout of a known payment provider with the plan's amount
+// and frequency filled in, and false if it's the channel's address as-is.
+type DeepLink struct {
+	Plan      string `json:"plan"`
+	Channel   string `json:"channel"`
+	Provider  string `json:"provider,omitempty"`
+	URL       string `json:"url"`
+	Prefilled bool   `json:"prefilled"`
+}
+
+// Citation is the citation metadata of a project's DOI (eg: Zenodo). Verified is
+// true if the DOI was resolved with DataCite and the metadata was filled in from it.
+type Citation struct {
+	DOI       string   `json:"doi"`
+	Title     string   `json:"title,omitempty"`
+	Authors   []string `json:"authors,omitempty"`
+	Publisher string   `json:"publisher,omitempty"`
+	Year      int      `json:"year,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	Verified  bool     `json:"verified"`
+}
+
+// Security contact types.
+const (
+	SecurityContactEmail = "email"
+	SecurityContactURL   = "url"
+)
+
+// SecurityContact is the security.txt style contact of a manifest for
+// vulnerability disclosure and due diligence.
+type SecurityContact struct {
+	Contacts   []SecurityContactItem `json:"contacts"`
+	Policy     string                `json:"policy,omitempty"`
+	Encryption string                `json:"encryption,omitempty"`
+}
+
+// SecurityContactItem is an e-mail or URL contact. Verified is true if it's on
+// the domain of the manifest or the entity's webpage.
+type SecurityContactItem struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Verified bool   `json:"verified"`
+}
+
+// ManifestStatus is the status of a manifest record and its last crawl.
+type ManifestStatus struct {
+	ID           int       `db:"id" json:"id"`
+	GUID         string    `db:"guid" json:"guid"`
+	URL          string    `db:"url" json:"url"`
+	Status       string    `db:"status" json:"status"`
+	CrawlErrors  int       `db:"crawl_errors" json:"crawl_errors"`
+	CrawlMessage *string   `db:"crawl_message" json:"crawl_message"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// ProxiedManifest is a manifest fetched and validated from an arbitrary URL
+// by the manifest proxy. Error is the validation error if it's not valid.
+type ProxiedManifest struct {
+	URL          string         `json:"url"`
+	Valid        bool           `json:"valid"`
+	Error        string         `json:"error,omitempty"`
+	Verification string         `json:"verification,omitempty"`
+	Manifest     types.JSONText `json:"manifest,omitempty"`
+	FetchedAt    time.Time      `json:"fetched_at"`
+}
+
+// ManifestLookup is the listing of a manifest looked up by its URL. Instance is the
+// root URL of the portal instance it's listed on, which is another instance if
+// the lookup was federated.
+type ManifestLookup struct {
+	ManifestStatus
+	Instance  string `json:"instance"`
+	Federated bool   `json:"federated"`
+}
+
+// FundingSummary is a compact summary of the listing that a repository or website
+// URL belongs to. Project fields are empty if the URL matched the entity's webpage.
+type FundingSummary struct {
+	URL          string         `db:"key" json:"url"`
+	ManifestGUID string         `db:"manifest_guid" json:"manifest_guid"`
+	EntityName   string         `db:"entity_name" json:"entity_name"`
+	ProjectGUID  string         `db:"project_guid" json:"project_guid,omitempty"`
+	ProjectName  string         `db:"project_name" json:"project_name,omitempty"`
+	Verification string         `db:"verification" json:"verification"`
+	Channels     pq.StringArray `db:"channels" json:"channels"`
+	FundingURL   string         `db:"-" json:"funding_url"`
+}
+
+// SubmissionVelocity is the number of listings created in the last day on a
+// domain and with shared payment addresses.
+type SubmissionVelocity struct {
+	Domain       string `db:"-" json:"domain"`
+	DomainCount  int    `db:"domain" json:"domain_count"`
+	AddressCount int    `db:"address" json:"address_count"`
+}
+
+// ManifestAlias is a previous URL of a manifest that has moved.
+type ManifestAlias struct {
+	ID         int       `db:"id" json:"id"`
+	ManifestID int       `db:"manifest_id" json:"manifest_id"`
+	URL        string    `db:"url" json:"url"`
+	Reason     string    `db:"reason" json:"reason"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// ManifestChange is an entry in the change feed of listings. Event is one of
+// create, update, or delete. ID is the cursor for fetching subsequent changes.
+type ManifestChange struct {
+	ID         int64     `db:"id" json:"id"`
+	ManifestID int       `db:"manifest_id" json:"manifest_id"`
+	GUID       string    `db:"guid" json:"guid"`
+	URL        string    `db:"url" json:"url"`
+	Event      string    `db:"event" json:"event"`
+	Hash       string    `db:"hash" json:"hash"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// FundingMatchQuery represents the constraints for allocating a budget across listings.
+type FundingMatchQuery struct {
+	Budget          float64
+	Currency        string
+	MaxPerRecipient float64
+	Licenses        []string
+	Tags            []string
+	EntityTypes     []string
+	Tenant          string
+	Limit           int
+}
+
+// FundingCandidate is a manifest that matches a FundingMatchQuery.
+type FundingCandidate struct {
+	ID         int            `db:"id"`
+	GUID       string         `db:"guid"`
+	Name       string         `db:"name"`
+	FundingRaw types.JSONText `db:"funding_raw"`
+	Meta       types.JSONText `db:"meta"`
+}
+
+// FundingMatch is a suggested allocation of a budget across listings.
+type FundingMatch struct {
+	Budget      float64             `json:"budget"`
+	Currency    string              `json:"currency"`
+	Allocated   float64             `json:"allocated"`
+	Allocations []FundingAllocation `json:"allocations"`
+}
+
+// FundingAllocation is the suggested amount for a single listing. Goal, Received,
+// and Need (goal - received) are yearly figures.
+type FundingAllocation struct {
+	ManifestID int     `json:"manifest_id"`
+	GUID       string  `json:"guid"`
+	Name       string  `json:"name"`
+	Goal       float64 `json:"goal"`
+	Received   float64 `json:"received"`
+	Need       float64 `json:"need"`
+	Amount     float64 `json:"amount"`
+
+	// Migration hints of deprecated plans and channels that were left out.
+	Hints []string `json:"hints,omitempty"`
+}
+
+// FundingSnapshot is a manifest's yearly funding goal and received amount in
+// a currency at a point in time.
+type FundingSnapshot struct {
+	ManifestID int       `db:"manifest_id" json:"manifest_id"`
+	Currency   string    `db:"currency" json:"currency"`
+	Goal       float64   `db:"goal" json:"goal"`
+	Received   float64   `db:"received" json:"received"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// ManifestVersion is the contents of a manifest as recorded at a point in time.
+type ManifestVersion struct {
+	ManifestID int            `db:"manifest_id" json:"manifest_id"`
+	Hash       string         `db:"hash" json:"hash"`
+	Manifest   types.JSONText `db:"body" json:"manifest"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+}
+
+// TelemetryReport is the anonymous aggregate stats of an instance reported to
+// the central instance. Instances are identified by a random ID.
+type TelemetryReport struct {
+	InstanceID string `db:"-" json:"instance_id"`
+	Version    string `db:"-" json:"version"`
+	Manifests  int    `db:"manifests" json:"manifests"`
+	Projects   int    `db:"projects" json:"projects"`
+}
+
+// TelemetryVersion is the footprint of the instances running a version.
+type TelemetryVersion struct {
+	Version   string `db:"version" json:"version"`
+	Instances int    `db:"instances" json:"instances"`
+	Manifests int    `db:"manifests" json:"manifests"`
+	Projects  int    `db:"projects" json:"projects"`
+}
+
+// FundingGap is the sum of the yearly funding goals of the active manifests in a
+// category (or tag) in a currency versus the amounts they received. Only the received
+// amounts of verified manifests count towards VerifiedReceived, which the Gap is of.
+type FundingGap struct {
+	Name             string  `db:"name" json:"name"`
+	Currency         string  `db:"currency" json:"currency"`
+	NumManifests     int     `db:"num_manifests" json:"num_manifests"`
+	Goal             float64 `db:"goal" json:"goal"`
+	Received         float64 `db:"received" json:"received"`
+	VerifiedReceived float64 `db:"verified_received" json:"verified_received"`
+	Gap              float64 `db:"gap" json:"gap"`
+}
+
+// Category is a top-level category in the fixed taxonomy of listings.
+type Category struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// TagCategory maps a project tag to a category.
+type TagCategory struct {
+	Tag      string `db:"tag" json:"tag"`
+	Category string `db:"category" json:"category"`
+}
+
+// RelatedProject is a project similar to another project.
+type RelatedProject struct {
+	GUID         string  `db:"guid" json:"guid"`
+	Name         string  `db:"name" json:"name"`
+	Description  string  `db:"description" json:"description"`
+	ManifestGUID string  `db:"manifest_guid" json:"manifest_guid"`
+	EntityName   string  `db:"entity_name" json:"entity_name"`
+	Score        float64 `db:"score" json:"score"`
+}
+
+// Conformance check statuses.
+const (
+	CheckPass = "pass"
+	CheckFail = "fail"
+	CheckWarn = "warn"
+	CheckSkip = "skip"
+)
+
+// ConformanceCheck is the result of a single spec conformance check. Ref is the
+// section of the spec (manifest path) that the check covers.
+type ConformanceCheck struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Ref     string `json:"ref"`
+	Message string `json:"message"`
+}
+
+// ConformanceReport is a machine-readable report of a manifest's conformance
+// to the spec. Conformant is false if any check has failed.
+type ConformanceReport struct {
+	URL         string `json:"url"`
+	SpecVersion string `json:"spec_version"`
+	Conformant  bool   `json:"conformant"`
+	Summary     struct {
+		Passed   int `json:"passed"`
+		Failed   int `json:"failed"`
+		Warnings int `json:"warnings"`
+		Skipped  int `json:"skipped"`
+	} `json:"summary"`
+	Checks []ConformanceCheck `json:"checks"`
+}
+
+// ManifestDraft is a manifest generated from the wizard's form input along
+// with its conformance report.
+type ManifestDraft struct {
+	Manifest types.JSONText    `json:"manifest"`
+	Report   ConformanceReport `json:"report"`
+}
+
+// DenylistVersion is the version of the payment address denylist exchange format.
+const DenylistVersion = "v1"
+
+// Denylist is the exchange format of the payment address denylist for
+// sharing it between instances.
+type Denylist struct {
+	Version   string          `json:"version"`
+	Addresses []DenylistEntry `json:"addresses"`
+}
+
+// DenylistEntry is a payment address known to be fraudulent.
+type DenylistEntry struct {
+	Address     string    `db:"address" json:"address"`
+	ChannelType string    `db:"channel_type" json:"channel_type"`
+	Reason      string    `db:"reason" json:"reason"`
+	Source      string    `db:"source" json:"source"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// FiscalHost is an admin-verified entity (eg: a foundation) that vouches for the
+// manifests of its members with a signed member list.
+type FiscalHost struct {
+	ID           int        `db:"id" json:"id"`
+	ManifestID   int        `db:"manifest_id" json:"manifest_id"`
+	GUID         string     `db:"guid" json:"guid"`
+	Verification string     `db:"verification" json:"verification"`
+	PublicKey    string     `db:"public_key" json:"public_key"`
+	MembersURL   string     `db:"members_url" json:"members_url"`
+	NumMembers   int        `db:"num_members" json:"num_members"`
+	LastError    string     `db:"last_error" json:"last_error"`
+	RefreshedAt  *time.Time `db:"refreshed_at" json:"refreshed_at"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
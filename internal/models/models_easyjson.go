@@ -199,6 +199,8 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalModels2(in *jlexer.Le
 				}
 				*out.StatusMessage = string(in.String())
 			}
+		case "verification":
+			out.Verification = string(in.String())
 		case "crawl_errors":
 			out.CrawlErrors = int(in.Int())
 		case "crawl_message":
@@ -283,6 +285,11 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalModels2(out *jwriter.
 			out.String(string(*in.StatusMessage))
 		}
 	}
+	{
+		const prefix string = ",\"verification\":"
+		out.RawString(prefix)
+		out.String(string(in.Verification))
+	}
 	{
 		const prefix string = ",\"crawl_errors\":"
 		out.RawString(prefix)
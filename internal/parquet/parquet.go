@@ -0,0 +1,423 @@
+// Package parquet is a minimal Apache Parquet file writer for flat tables of
+// nullable columns. Pages are PLAIN encoded and uncompressed, which keeps it
+// free of dependencies while being readable by DuckDB, BigQuery, Arrow etc.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Type is the type of a column.
+type Type int
+
+const (
+	String Type = iota
+	Int64
+	Double
+	Bool
+	Timestamp
+)
+
+// Parquet physical types, converted types, and encodings.
+const (
+	ptBoolean   = 0
+	ptInt64     = 2
+	ptDouble    = 5
+	ptByteArray = 6
+
+	ctUTF8            = 0
+	ctTimestampMillis = 9
+
+	encPlain = 0
+	encRLE   = 3
+
+	repOptional = 1
+)
+
+var magic = []byte("PAR1")
+
+// Column is a column of a table.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Writer writes rows to a Parquet file in row groups of up to a given number of rows.
+type Writer struct {
+	w    *countWriter
+	cols []Column
+
+	// Buffered rows of the current row group.
+	rowGroupSize int
+	chunks       []chunk
+	rows         int
+
+	rowGroups []rowGroup
+	numRows   int64
+}
+
+// chunk is the buffered data of a column in the current row group.
+type chunk struct {
+	present []bool
+	values  bytes.Buffer
+	bools   []bool
+}
+
+type rowGroup struct {
+	numRows int64
+	size    int64
+	cols    []chunkMeta
+}
+
+type chunkMeta struct {
+	offset int64
+	size   int64
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer, cols []Column, rowGroupSize int) (*Writer, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("no columns")
+	}
+	if rowGroupSize < 1 {
+		rowGroupSize = 100000
+	}
+
+	out := &Writer{
+		w:            &countWriter{w: w},
+		cols:         cols,
+		rowGroupSize: rowGroupSize,
+		chunks:       make([]chunk, len(cols)),
+	}
+	if _, err := out.w.Write(magic); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Write adds a row. Values are nil (null) or of the column's type: string or []byte for
+// String, int/int32/int64 for Int64, float64 or a numeric string/[]byte for Double,
+// bool for Bool, and time.Time for Timestamp.
+func (w *Writer) Write(row []interface{}) error {
+	if len(row) != len(w.cols) {
+		return fmt.Errorf("row has %d values, expected %d", len(row), len(w.cols))
+	}
+
+	// Encode all the values first so that an invalid value doesn't leave a partial row.
+	vals := make([][]byte, len(row))
+	for n, v := range row {
+		b, err := encode(w.cols[n].Type, v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", w.cols[n].Name, err)
+		}
+		vals[n] = b
+	}
+	for n, b := range vals {
+		w.chunks[n].add(w.cols[n].Type, b)
+	}
+
+	w.rows++
+	if w.rows >= w.rowGroupSize {
+		return w.flush()
+	}
+
+	return nil
+}
+
+// Close flushes the buffered rows and writes the file footer. An empty table has
+// no row groups. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if w.rows > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	meta := w.fileMeta()
+	if _, err := w.w.Write(meta); err != nil {
+		return err
+	}
+
+	var l [4]byte
+	binary.LittleEndian.PutUint32(l[:], uint32(len(meta)))
+	if _, err := w.w.Write(l[:]); err != nil {
+		return err
+	}
+	_, err := w.w.Write(magic)
+
+	return err
+}
+
+// encode returns the PLAIN encoding of a value, or nil for null. Bools are
+// encoded as a single 0 or 1 byte that's bit-packed when the page is written.
+func encode(typ Type, v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch typ {
+	case String:
+		var s []byte
+		switch v := v.(type) {
+		case string:
+			s = []byte(v)
+		case []byte:
+			s = v
+		default:
+			return nil, fmt.Errorf("invalid string value: %T", v)
+		}
+		return append(binary.LittleEndian.AppendUint32(make([]byte, 0, 4+len(s)), uint32(len(s))), s...), nil
+
+	case Int64:
+		var i int64
+		switch v := v.(type) {
+		case int64:
+			i = v
+		case int32:
+			i = int64(v)
+		case int:
+			i = int64(v)
+		default:
+			return nil, fmt.Errorf("invalid int value: %T", v)
+		}
+		return binary.LittleEndian.AppendUint64(nil, uint64(i)), nil
+
+	case Double:
+		var f float64
+		switch v := v.(type) {
+		case float64:
+			f = v
+		case string, []byte:
+			// Numeric values (eg: Postgres NUMERIC) that are scanned as text.
+			p, err := strconv.ParseFloat(fmt.Sprintf("%s", v), 64)
+			if err != nil {
+				return nil, err
+			}
+			f = p
+		default:
+			return nil, fmt.Errorf("invalid float value: %T", v)
+		}
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)), nil
+
+	case Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool value: %T", v)
+		}
+		if b {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+
+	case Timestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("invalid timestamp value: %T", v)
+		}
+		return binary.LittleEndian.AppendUint64(nil, uint64(t.UnixMilli())), nil
+	}
+
+	return nil, fmt.Errorf("unknown column type: %d", typ)
+}
+
+// add adds an encoded value (nil for null) to the chunk.
+func (c *chunk) add(typ Type, b []byte) {
+	c.present = append(c.present, b != nil)
+	if b == nil {
+		return
+	}
+
+	if typ == Bool {
+		c.bools = append(c.bools, b[0] == 1)
+		return
+	}
+	c.values.Write(b)
+}
+
+// flush writes the buffered rows as a row group with one data page per column.
+func (w *Writer) flush() error {
+	rg := rowGroup{numRows: int64(w.rows)}
+	for n := range w.chunks {
+		c := &w.chunks[n]
+
+		var page bytes.Buffer
+
+		// Definition levels (0 = null, 1 = present) as 1 bit wide RLE runs, prefixed with their length.
+		levels := rleLevels(c.present)
+		var l [4]byte
+		binary.LittleEndian.PutUint32(l[:], uint32(len(levels)))
+		page.Write(l[:])
+		page.Write(levels)
+
+		if w.cols[n].Type == Bool {
+			page.Write(packBools(c.bools))
+		} else {
+			page.Write(c.values.Bytes())
+		}
+
+		var h thrift
+		h.begin(0)
+		h.i32(1, 0) // DATA_PAGE
+		h.i32(2, int32(page.Len()))
+		h.i32(3, int32(page.Len()))
+		h.begin(5)
+		h.i32(1, int32(len(c.present)))
+		h.i32(2, encPlain)
+		h.i32(3, encRLE)
+		h.i32(4, encRLE)
+		h.end()
+		h.end()
+
+		offset := w.w.n
+		if _, err := w.w.Write(h.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := w.w.Write(page.Bytes()); err != nil {
+			return err
+		}
+
+		size := int64(h.buf.Len() + page.Len())
+		rg.cols = append(rg.cols, chunkMeta{offset: offset, size: size})
+		rg.size += size
+
+		*c = chunk{}
+	}
+
+	w.rowGroups = append(w.rowGroups, rg)
+	w.numRows += rg.numRows
+	w.rows = 0
+
+	return nil
+}
+
+// fileMeta returns the encoded FileMetaData of the file.
+func (w *Writer) fileMeta() []byte {
+	var t thrift
+	t.begin(0)
+	t.i32(1, 1)
+
+	// Schema. The root element is followed by the columns.
+	t.list(2, tStruct, len(w.cols)+1)
+	t.begin(0)
+	t.str(4, "schema")
+	t.i32(5, int32(len(w.cols)))
+	t.end()
+	for _, c := range w.cols {
+		pt, ct := physicalType(c.Type)
+		t.begin(0)
+		t.i32(1, pt)
+		t.i32(3, repOptional)
+		t.str(4, c.Name)
+		if ct >= 0 {
+			t.i32(6, ct)
+		}
+		t.end()
+	}
+
+	t.i64(3, w.numRows)
+
+	t.list(4, tStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		t.begin(0)
+		t.list(1, tStruct, len(rg.cols))
+		for n, cm := range rg.cols {
+			pt, _ := physicalType(w.cols[n].Type)
+
+			t.begin(0)
+			t.i64(2, cm.offset)
+			t.begin(3)
+			t.i32(1, pt)
+			t.list(2, tI32, 2)
+			t.i32Val(encPlain)
+			t.i32Val(encRLE)
+			t.list(3, tBinary, 1)
+			t.binaryVal([]byte(w.cols[n].Name))
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, rg.numRows)
+			t.i64(6, cm.size)
+			t.i64(7, cm.size)
+			t.i64(9, cm.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, rg.size)
+		t.i64(3, rg.numRows)
+		t.end()
+	}
+
+	t.str(6, "floss.fund portal")
+	t.end()
+
+	return t.buf.Bytes()
+}
+
+// physicalType returns the Parquet physical type and the converted type (-1 for none) of a column type.
+func physicalType(t Type) (int32, int32) {
+	switch t {
+	case Int64:
+		return ptInt64, -1
+	case Double:
+		return ptDouble, -1
+	case Bool:
+		return ptBoolean, -1
+	case Timestamp:
+		return ptInt64, ctTimestampMillis
+	}
+
+	return ptByteArray, ctUTF8
+}
+
+// rleLevels encodes 1 bit wide levels as runs of the RLE/bit-packing hybrid encoding.
+func rleLevels(present []bool) []byte {
+	var (
+		out bytes.Buffer
+		b   [binary.MaxVarintLen64]byte
+	)
+	for i := 0; i < len(present); {
+		j := i
+		for j < len(present) && present[j] == present[i] {
+			j++
+		}
+
+		out.Write(b[:binary.PutUvarint(b[:], uint64(j-i)<<1)])
+		if present[i] {
+			out.WriteByte(1)
+		} else {
+			out.WriteByte(0)
+		}
+		i = j
+	}
+
+	return out.Bytes()
+}
+
+// packBools bit-packs PLAIN boolean values, LSB first.
+func packBools(v []bool) []byte {
+	out := make([]byte, (len(v)+7)/8)
+	for n, b := range v {
+		if b {
+			out[n/8] |= 1 << (n % 8)
+		}
+	}
+
+	return out
+}
+
+// countWriter counts the bytes written to track the offsets of column chunks.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
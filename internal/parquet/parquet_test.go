@@ -0,0 +1,433 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, []Column{{Name: "name", Type: String}, {Name: "n", Type: Int64}, {Name: "at", Type: Timestamp}}, 2)
+	assert.NoError(t, err)
+
+	ts := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, w.Write([]interface{}{"a", int64(1), ts}))
+	assert.NoError(t, w.Write([]interface{}{nil, nil, nil}))
+	assert.NoError(t, w.Write([]interface{}{[]byte("bc"), 3, ts}))
+	assert.Error(t, w.Write([]interface{}{"d", "x", ts}))
+	assert.Error(t, w.Write([]interface{}{"d"}))
+
+	// The invalid rows weren't partially added.
+	assert.Equal(t, []bool{true}, w.chunks[0].present)
+	assert.Equal(t, []byte{2, 0, 0, 0, 'b', 'c'}, w.chunks[0].values.Bytes())
+	assert.NoError(t, w.Close())
+
+	out := b.Bytes()
+	assert.Equal(t, []byte("PAR1"), out[:4])
+	assert.Equal(t, []byte("PAR1"), out[len(out)-4:])
+
+	// The footer (FileMetaData) ends right before its length.
+	n := binary.LittleEndian.Uint32(out[len(out)-8:])
+	meta := out[len(out)-8-int(n) : len(out)-8]
+	assert.Equal(t, w.fileMeta(), meta)
+
+	// Two row groups of 2 and 1 rows.
+	assert.Len(t, w.rowGroups, 2)
+	assert.EqualValues(t, 3, w.numRows)
+	assert.EqualValues(t, 4, w.rowGroups[0].cols[0].offset)
+}
+
+func TestLevels(t *testing.T) {
+	// Runs of 2 present, 1 null, 1 present.
+	assert.Equal(t, []byte{4, 1, 2, 0, 2, 1}, rleLevels([]bool{true, true, false, true}))
+	assert.Equal(t, []byte{0b101, 0b1}, packBools([]bool{true, false, true, false, false, false, false, false, true}))
+}
+
+func TestThrift(t *testing.T) {
+	var th thrift
+	th.begin(0)
+	th.i32(1, 1)
+	th.i64(20, -1)
+	th.begin(21)
+	th.str(1, "x")
+	th.end()
+	th.list(22, tI32, 2)
+	th.i32Val(0)
+	th.i32Val(3)
+	th.end()
+
+	assert.Equal(t, []byte{0x15, 0x02, 0x06, 0x28, 0x01, 0x1c, 0x18, 0x01, 'x', 0x00, 0x19, 0x25, 0x00, 0x06, 0x00}, th.buf.Bytes())
+}
+
+// TestRoundTrip reads written files back with a reader that's written from the
+// Parquet and Thrift compact protocol specs, independent of the writer's encoders.
+func TestRoundTrip(t *testing.T) {
+	cols := []Column{
+		{Name: "name", Type: String},
+		{Name: "n", Type: Int64},
+		{Name: "amount", Type: Double},
+		{Name: "ok", Type: Bool},
+		{Name: "at", Type: Timestamp},
+	}
+	// Pad the schema past 15 elements, which are encoded with a long list header.
+	for n := 0; n < 12; n++ {
+		cols = append(cols, Column{Name: fmt.Sprintf("c%d", n), Type: Int64})
+	}
+
+	// Rows with nulls in runs longer than a byte's worth of varint, and of every column type.
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC)
+	var rows [][]interface{}
+	for n := 0; n < 1000; n++ {
+		row := make([]interface{}, len(cols))
+		if n%3 != 0 {
+			row[0] = fmt.Sprintf("row-%d", n)
+		}
+		if n < 200 || n > 600 {
+			row[1] = int64(n - 500)
+		}
+		if n%2 == 0 {
+			row[2] = float64(n) / 4
+		}
+		if n%5 != 0 {
+			row[3] = n%7 == 0
+		}
+		if n > 990 {
+			row[4] = ts.Add(time.Duration(n) * time.Hour)
+		}
+		for c := 5; c < len(cols); c++ {
+			row[c] = int64(c * n)
+		}
+		rows = append(rows, row)
+	}
+
+	var b bytes.Buffer
+	w, err := NewWriter(&b, cols, 300)
+	require.NoError(t, err)
+	for _, r := range rows {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+
+	f, err := readFile(b.Bytes())
+	require.NoError(t, err)
+
+	// Schema.
+	require.Len(t, f.cols, len(cols))
+	for n, c := range cols {
+		pt, ct := physicalType(c.Type)
+		assert.Equal(t, c.Name, f.cols[n].name)
+		assert.EqualValues(t, pt, f.cols[n].typ)
+		assert.EqualValues(t, ct, f.cols[n].conv)
+		assert.EqualValues(t, repOptional, f.cols[n].rep)
+	}
+
+	// Row groups of 300, 300, 300, and 100 rows.
+	assert.EqualValues(t, len(rows), f.numRows)
+	assert.Equal(t, []int64{300, 300, 300, 100}, f.groupRows)
+
+	// Values, with timestamps compared at the millisecond precision they're stored at.
+	require.Len(t, f.rows, len(rows))
+	for n, r := range rows {
+		if r[4] != nil {
+			r[4] = r[4].(time.Time).Truncate(time.Millisecond)
+		}
+		assert.Equal(t, r, f.rows[n], "row %d", n)
+	}
+
+	// An empty table has a schema and no row groups.
+	b.Reset()
+	w, err = NewWriter(&b, cols[:2], 10)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	f, err = readFile(b.Bytes())
+	require.NoError(t, err)
+	assert.Len(t, f.cols, 2)
+	assert.Empty(t, f.groupRows)
+	assert.Empty(t, f.rows)
+}
+
+// pqFile is a Parquet file that's read by readFile.
+type pqFile struct {
+	cols      []pqCol
+	numRows   int64
+	groupRows []int64
+	rows      [][]interface{}
+}
+
+type pqCol struct {
+	name           string
+	typ, rep, conv int64
+}
+
+// readFile reads a flat Parquet file of optional columns with uncompressed, PLAIN
+// encoded data pages.
+func readFile(b []byte) (pqFile, error) {
+	var out pqFile
+	if len(b) < 12 || string(b[:4]) != "PAR1" || string(b[len(b)-4:]) != "PAR1" {
+		return out, fmt.Errorf("invalid magic")
+	}
+
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if n > len(b)-12 {
+		return out, fmt.Errorf("invalid footer length %d", n)
+	}
+	meta, _, err := readStruct(b[len(b)-8-n : len(b)-8])
+	if err != nil {
+		return out, fmt.Errorf("footer: %v", err)
+	}
+
+	// The schema's root element is followed by the columns.
+	schema := meta[2].([]interface{})
+	if len(schema) == 0 || schema[0].(tStructVal)[5] != int64(len(schema)-1) {
+		return out, fmt.Errorf("invalid schema root")
+	}
+	for _, e := range schema[1:] {
+		e := e.(tStructVal)
+		c := pqCol{name: string(e[4].([]byte)), typ: e[1].(int64), rep: e[3].(int64), conv: -1}
+		if v, ok := e[6]; ok {
+			c.conv = v.(int64)
+		}
+		out.cols = append(out.cols, c)
+	}
+	out.numRows = meta[3].(int64)
+
+	groups, _ := meta[4].([]interface{})
+	for _, g := range groups {
+		g := g.(tStructVal)
+		num := g[3].(int64)
+		out.groupRows = append(out.groupRows, num)
+
+		chunks := g[1].([]interface{})
+		if len(chunks) != len(out.cols) {
+			return out, fmt.Errorf("row group has %d columns", len(chunks))
+		}
+
+		rows := make([][]interface{}, num)
+		for n := range rows {
+			rows[n] = make([]interface{}, len(out.cols))
+		}
+
+		var size int64
+		for c, ch := range chunks {
+			cm := ch.(tStructVal)[3].(tStructVal)
+			if cm[4] != int64(0) || cm[5] != num || cm[1] != out.cols[c].typ {
+				return out, fmt.Errorf("%s: invalid column chunk metadata", out.cols[c].name)
+			}
+			if p := cm[3].([]interface{}); len(p) != 1 || string(p[0].([]byte)) != out.cols[c].name {
+				return out, fmt.Errorf("%s: invalid path in schema", out.cols[c].name)
+			}
+
+			vals, err := readChunk(b, cm[9].(int64), cm[7].(int64), out.cols[c], num)
+			if err != nil {
+				return out, fmt.Errorf("%s: %v", out.cols[c].name, err)
+			}
+			for n, v := range vals {
+				rows[n][c] = v
+			}
+			size += cm[6].(int64)
+		}
+		if g[2] != size {
+			return out, fmt.Errorf("row group size %v, expected %d", g[2], size)
+		}
+
+		out.rows = append(out.rows, rows...)
+	}
+
+	return out, nil
+}
+
+// readChunk reads the values of a column chunk of a single data page.
+func readChunk(b []byte, offset, size int64, col pqCol, num int64) ([]interface{}, error) {
+	if offset < 4 || offset+size > int64(len(b)) {
+		return nil, fmt.Errorf("invalid chunk offset")
+	}
+	b = b[offset : offset+size]
+
+	h, n, err := readStruct(b)
+	if err != nil {
+		return nil, fmt.Errorf("page header: %v", err)
+	}
+	dp := h[5].(tStructVal)
+	if h[1] != int64(0) || dp[1] != num || dp[2] != int64(encPlain) || dp[3] != int64(encRLE) {
+		return nil, fmt.Errorf("unexpected page header %v", h)
+	}
+	page := b[n:]
+	if int64(len(page)) != h[3].(int64) {
+		return nil, fmt.Errorf("page size %d, header says %v", len(page), h[3])
+	}
+
+	// Definition levels.
+	l := int(binary.LittleEndian.Uint32(page))
+	levels, err := readLevels(page[4:4+l], int(num))
+	if err != nil {
+		return nil, err
+	}
+	page = page[4+l:]
+
+	out := make([]interface{}, num)
+	bit := 0
+	for n, present := range levels {
+		if !present {
+			continue
+		}
+
+		switch col.typ {
+		case ptByteArray:
+			l := int(binary.LittleEndian.Uint32(page))
+			out[n] = string(page[4 : 4+l])
+			page = page[4+l:]
+		case ptInt64:
+			v := int64(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+			if col.conv == ctTimestampMillis {
+				out[n] = time.UnixMilli(v).UTC()
+			} else {
+				out[n] = v
+			}
+		case ptDouble:
+			out[n] = math.Float64frombits(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case ptBoolean:
+			out[n] = page[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		default:
+			return nil, fmt.Errorf("unknown type %d", col.typ)
+		}
+	}
+	if col.typ == ptBoolean {
+		page = page[(bit+7)/8:]
+	}
+	if len(page) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes in page", len(page))
+	}
+
+	return out, nil
+}
+
+// readLevels decodes num 1 bit wide levels of the RLE/bit-packing hybrid encoding.
+func readLevels(b []byte, num int) ([]bool, error) {
+	var out []bool
+	for len(b) > 0 {
+		h, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid run header")
+		}
+		b = b[n:]
+
+		if h&1 == 1 {
+			// Bit-packed groups of 8 values.
+			for i := 0; i < int(h>>1); i++ {
+				for j := 0; j < 8; j++ {
+					out = append(out, b[i]&(1<<j) != 0)
+				}
+			}
+			b = b[h>>1:]
+			continue
+		}
+
+		for i := 0; i < int(h>>1); i++ {
+			out = append(out, b[0] == 1)
+		}
+		b = b[1:]
+	}
+	if len(out) < num {
+		return nil, fmt.Errorf("%d levels, expected %d", len(out), num)
+	}
+
+	return out[:num], nil
+}
+
+// tStructVal is a decoded Thrift struct of field IDs to values.
+type tStructVal map[int16]interface{}
+
+// readStruct decodes a Thrift compact protocol struct and returns it along with
+// the number of bytes read.
+func readStruct(b []byte) (tStructVal, int, error) {
+	out := tStructVal{}
+	pos, lastID := 0, int16(0)
+	for {
+		if pos >= len(b) {
+			return nil, 0, fmt.Errorf("unexpected end of struct")
+		}
+		h := b[pos]
+		pos++
+		if h == 0 {
+			return out, pos, nil
+		}
+
+		id := lastID + int16(h>>4)
+		if h>>4 == 0 {
+			v, n := binary.Varint(b[pos:])
+			if n <= 0 {
+				return nil, 0, fmt.Errorf("invalid field ID")
+			}
+			id = int16(v)
+			pos += n
+		}
+		lastID = id
+
+		v, n, err := readValue(b[pos:], h&0x0f)
+		if err != nil {
+			return nil, 0, fmt.Errorf("field %d: %v", id, err)
+		}
+		out[id] = v
+		pos += n
+	}
+}
+
+// readValue decodes a Thrift compact protocol value of a type.
+func readValue(b []byte, typ byte) (interface{}, int, error) {
+	switch typ {
+	case 1, 2:
+		// Booleans in struct fields are encoded in the type.
+		return typ == 1, 0, nil
+	case 3:
+		return int64(int8(b[0])), 1, nil
+	case 4, 5, 6:
+		v, n := binary.Varint(b)
+		if n <= 0 {
+			return nil, 0, fmt.Errorf("invalid varint")
+		}
+		return v, n, nil
+	case 7:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), 8, nil
+	case 8:
+		l, n := binary.Uvarint(b)
+		if n <= 0 || n+int(l) > len(b) {
+			return nil, 0, fmt.Errorf("invalid binary")
+		}
+		return b[n : n+int(l)], n + int(l), nil
+	case 9, 10:
+		size, elem, pos := int(b[0]>>4), b[0]&0x0f, 1
+		if size == 15 {
+			l, n := binary.Uvarint(b[1:])
+			if n <= 0 {
+				return nil, 0, fmt.Errorf("invalid list size")
+			}
+			size, pos = int(l), 1+n
+		}
+
+		out := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			v, n, err := readValue(b[pos:], elem)
+			if err != nil {
+				return nil, 0, err
+			}
+			out = append(out, v)
+			pos += n
+		}
+		return out, pos, nil
+	case 12:
+		return readStruct(b)
+	}
+
+	return nil, 0, fmt.Errorf("unsupported type %d", typ)
+}
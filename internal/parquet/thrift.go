@@ -0,0 +1,95 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol field types.
+const (
+	tI32    = 5
+	tI64    = 6
+	tBinary = 8
+	tList   = 9
+	tStruct = 12
+)
+
+// thrift is a minimal Thrift compact protocol encoder for the Parquet
+// metadata structs (FileMetaData, PageHeader etc.).
+type thrift struct {
+	buf bytes.Buffer
+
+	// ID of the last field written in the current struct, and of the enclosing structs.
+	lastID int16
+	stack  []int16
+}
+
+func (t *thrift) field(id int16, typ byte) {
+	if d := id - t.lastID; d > 0 && d <= 15 {
+		t.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.uvarint(uint64(uint16((id << 1) ^ (id >> 15))))
+	}
+	t.lastID = id
+}
+
+func (t *thrift) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thrift) i32Val(v int32) {
+	t.uvarint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+func (t *thrift) i64Val(v int64) {
+	t.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thrift) binaryVal(b []byte) {
+	t.uvarint(uint64(len(b)))
+	t.buf.Write(b)
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, tI32)
+	t.i32Val(v)
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, tI64)
+	t.i64Val(v)
+}
+
+func (t *thrift) str(id int16, s string) {
+	t.field(id, tBinary)
+	t.binaryVal([]byte(s))
+}
+
+// list writes the header of a list field of n elements of the given type.
+func (t *thrift) list(id int16, elemType byte, n int) {
+	t.field(id, tList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elemType)
+	t.uvarint(uint64(n))
+}
+
+// begin starts a struct, either as a field (id > 0) or as a list element (id = 0).
+func (t *thrift) begin(id int16) {
+	if id > 0 {
+		t.field(id, tStruct)
+	}
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+// end ends a struct.
+func (t *thrift) end() {
+	t.buf.WriteByte(0)
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
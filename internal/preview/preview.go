@@ -0,0 +1,229 @@
+// Package preview renders social preview (OpenGraph) card images for listings.
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"sync"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+)
+
+const (
+	Width  = 1200
+	Height = 630
+
+	padding = 50
+)
+
+var (
+	colBg       = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colBand     = color.RGBA{0x1f, 0x2d, 0x3d, 0xff}
+	colText     = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	colTextBand = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colGrey     = color.RGBA{0x88, 0x88, 0x88, 0xff}
+	colBar      = color.RGBA{0xee, 0xee, 0xee, 0xff}
+	colProgress = color.RGBA{0x2e, 0xb8, 0x72, 0xff}
+	colChip     = color.RGBA{0xe8, 0xf0, 0xfe, 0xff}
+)
+
+// Card represents the data rendered on a preview image.
+type Card struct {
+	Title    string
+	Subtitle string
+
+	// Yearly funding goal and the latest yearly income in Currency.
+	Goal     float64
+	Received float64
+	Currency string
+
+	// Funding channel types.
+	Channels []string
+}
+
+// Cache is an in-memory cache of rendered cards keyed by ID. An entry is
+// invalidated when its version (eg: a manifest's updated_at) changes.
+type Cache struct {
+	mu    sync.Mutex
+	max   int
+	items map[int]cacheItem
+}
+
+type cacheItem struct {
+	version string
+	b       []byte
+}
+
+// NewCard returns a Card for a manifest. The goal is the yearly sum of active plans
+// and the received amount is the income of the most recent year in the history,
+// both in the currency of the first active plan.
+func NewCard(m models.ManifestData) Card {
+	c := Card{
+		Title:    m.Manifest.Entity.Name,
+		Subtitle: fmt.Sprintf("%d project(s) seeking funding", len(m.Manifest.Projects)),
+	}
+
+	for _, p := range m.Manifest.Funding.Plans {
+		if p.Status == "active" {
+			c.Currency = p.Currency
+			break
+		}
+	}
+	c.Goal, c.Received = core.FundingGoal(m.Manifest.Funding, c.Currency)
+
+	seen := make(map[string]bool)
+	for _, ch := range m.Manifest.Funding.Channels {
+		if !seen[ch.Type] {
+			seen[ch.Type] = true
+			c.Channels = append(c.Channels, ch.Type)
+		}
+	}
+
+	return c
+}
+
+// Render renders a card as a PNG image.
+func Render(c Card) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{colBg}, image.Point{}, draw.Src)
+
+	// Title band.
+	fillRect(img, 0, 0, Width, 230, colBand)
+	drawText(img, padding, 60, 8, colTextBand, abbrev(c.Title, 22))
+	drawText(img, padding, 160, 4, colTextBand, abbrev(c.Subtitle, 45))
+
+	// Goal progress.
+	if c.Goal > 0 {
+		pct := c.Received / c.Goal
+		if pct > 1 {
+			pct = 1
+		}
+
+		drawText(img, padding, 280, 4, colText, abbrev(fmt.Sprintf("%s %s / YEAR GOAL", formatAmount(c.Goal), c.Currency), 45))
+		fillRect(img, padding, 340, Width-padding*2, 40, colBar)
+		fillRect(img, padding, 340, int(float64(Width-padding*2)*pct), 40, colProgress)
+		drawText(img, padding, 400, 3, colGrey, fmt.Sprintf("%d%% RECEIVED (%s %s)", int(pct*100), formatAmount(c.Received), c.Currency))
+	}
+
+	// Channel chips.
+	x := padding
+	for _, ch := range c.Channels {
+		txt := strings.ToUpper(ch)
+		w := len(txt)*(glyphW+1)*3 + 40
+		if x+w > Width-padding {
+			break
+		}
+
+		fillRect(img, x, 500, w, 60, colChip)
+		drawText(img, x+20, 520, 3, colText, txt)
+		x += w + 20
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// NewCache returns a new cache that holds a maximum of max cards.
+func NewCache(max int) *Cache {
+	return &Cache{
+		max:   max,
+		items: make(map[int]cacheItem),
+	}
+}
+
+// Get returns a cached card if it exists for the given version.
+func (c *Cache) Get(id int, version string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it, ok := c.items[id]
+	if !ok || it.version != version {
+		return nil, false
+	}
+
+	return it.b, true
+}
+
+// Set caches a card. If the cache is full, an arbitrary entry is evicted.
+func (c *Cache) Set(id int, version string, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[id]; !ok && len(c.items) >= c.max {
+		for k := range c.items {
+			delete(c.items, k)
+			break
+		}
+	}
+
+	c.items[id] = cacheItem{version: version, b: b}
+}
+
+// Delete removes a card from the cache.
+func (c *Cache) Delete(id int) {
+	c.mu.Lock()
+	delete(c.items, id)
+	c.mu.Unlock()
+}
+
+// Versions returns the versions of the cached cards by their IDs.
+func (c *Cache) Versions() map[int]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[int]string, len(c.items))
+	for id, it := range c.items {
+		out[id] = it.version
+	}
+
+	return out
+}
+
+// drawText draws a string with the bitmap font at the given scale.
+func drawText(img *image.RGBA, x, y, scale int, col color.Color, s string) {
+	for _, r := range s {
+		for row, bits := range glyph(r) {
+			for n, b := range bits {
+				if b == '1' {
+					fillRect(img, x+n*scale, y+row*scale, scale, scale, col)
+				}
+			}
+		}
+		x += (glyphW + 1) * scale
+	}
+}
+
+func fillRect(img *image.RGBA, x, y, w, h int, col color.Color) {
+	draw.Draw(img, image.Rect(x, y, x+w, y+h), &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+// formatAmount formats an amount in a short form (eg: 12.5K, 1.2M).
+func formatAmount(n float64) string {
+	switch {
+	case n >= 1000000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", n/1000000), ".0") + "M"
+	case n >= 1000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", n/1000), ".0") + "K"
+	}
+
+	return fmt.Sprintf("%.0f", n)
+}
+
+func abbrev(s string, ln int) string {
+	r := []rune(s)
+	if len(r) <= ln {
+		return s
+	}
+
+	return string(r[:ln-2]) + ".."
+}
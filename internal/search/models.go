@@ -12,6 +12,9 @@ type Entity struct {
 	WebpageURL   string `json:"webpage_url"`
 	NumProjects  int    `json:"num_projects"`
 	UpdatedAt    int64  `json:"updated_at"`
+
+	// Slugs of the tenant sub-portals the manifest is listed on.
+	Tenants []string `json:"tenants,omitempty"`
 }
 
 //easyjson:json
@@ -22,6 +25,9 @@ type EntityQuery struct {
 	Query string `json:"q"`
 	Field string `json:"field"`
 	Page  int    `json:"page"`
+
+	// Tenant sub-portal (slug) to restrict the results to.
+	Tenant string `json:"-"`
 	Entity
 }
 
@@ -40,7 +46,13 @@ type Project struct {
 	RepositoryURL string   `json:"repository_url"`
 	Licenses      []string `json:"licenses"`
 	Tags          []string `json:"tags"`
+	Categories    []string `json:"categories"`
+	Verification  string   `json:"verification"`
+	Scorecard     float64  `json:"scorecard,omitempty"`
 	UpdatedAt     int64    `json:"updated_at"`
+
+	// Slugs of the tenant sub-portals the manifest is listed on.
+	Tenants []string `json:"tenants,omitempty"`
 }
 
 //easyjson:json
@@ -51,6 +63,12 @@ type ProjectQuery struct {
 	Query string `json:"q"`
 	Field string `json:"field"`
 	Page  int    `json:"page"`
+
+	// Minimum OpenSSF Scorecard score (0 = no filter).
+	MinScorecard float64 `json:"-"`
+
+	// Tenant sub-portal (slug) to restrict the results to.
+	Tenant string `json:"-"`
 	Project
 }
 
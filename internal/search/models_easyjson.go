@@ -331,8 +331,58 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalSearch2(in *jlexer.Le
 				}
 				in.Delim(']')
 			}
+		case "categories":
+			if in.IsNull() {
+				in.Skip()
+				out.Categories = nil
+			} else {
+				in.Delim('[')
+				if out.Categories == nil {
+					if !in.IsDelim(']') {
+						out.Categories = make([]string, 0, 4)
+					} else {
+						out.Categories = []string{}
+					}
+				} else {
+					out.Categories = (out.Categories)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v9 string
+					v9 = string(in.String())
+					out.Categories = append(out.Categories, v9)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "verification":
+			out.Verification = string(in.String())
+		case "scorecard":
+			out.Scorecard = float64(in.Float64())
 		case "updated_at":
 			out.UpdatedAt = int64(in.Int64())
+		case "tenants":
+			if in.IsNull() {
+				in.Skip()
+				out.Tenants = nil
+			} else {
+				in.Delim('[')
+				if out.Tenants == nil {
+					if !in.IsDelim(']') {
+						out.Tenants = make([]string, 0, 4)
+					} else {
+						out.Tenants = []string{}
+					}
+				} else {
+					out.Tenants = (out.Tenants)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v10 string
+					v10 = string(in.String())
+					out.Tenants = append(out.Tenants, v10)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -419,11 +469,11 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalSearch2(out *jwriter.
 			out.RawString("null")
 		} else {
 			out.RawByte('[')
-			for v9, v10 := range in.Licenses {
-				if v9 > 0 {
+			for v11, v12 := range in.Licenses {
+				if v11 > 0 {
 					out.RawByte(',')
 				}
-				out.String(string(v10))
+				out.String(string(v12))
 			}
 			out.RawByte(']')
 		}
@@ -435,20 +485,60 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalSearch2(out *jwriter.
 			out.RawString("null")
 		} else {
 			out.RawByte('[')
-			for v11, v12 := range in.Tags {
-				if v11 > 0 {
+			for v13, v14 := range in.Tags {
+				if v13 > 0 {
 					out.RawByte(',')
 				}
-				out.String(string(v12))
+				out.String(string(v14))
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"categories\":"
+		out.RawString(prefix)
+		if in.Categories == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v15, v16 := range in.Categories {
+				if v15 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v16))
 			}
 			out.RawByte(']')
 		}
 	}
+	{
+		const prefix string = ",\"verification\":"
+		out.RawString(prefix)
+		out.String(string(in.Verification))
+	}
+	if in.Scorecard != 0 {
+		const prefix string = ",\"scorecard\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Scorecard))
+	}
 	{
 		const prefix string = ",\"updated_at\":"
 		out.RawString(prefix)
 		out.Int64(int64(in.UpdatedAt))
 	}
+	if len(in.Tenants) != 0 {
+		const prefix string = ",\"tenants\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v17, v18 := range in.Tenants {
+				if v17 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v18))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
@@ -530,9 +620,9 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalSearch3(in *jlexer.Le
 					out.Licenses = (out.Licenses)[:0]
 				}
 				for !in.IsDelim(']') {
-					var v13 string
-					v13 = string(in.String())
-					out.Licenses = append(out.Licenses, v13)
+					var v19 string
+					v19 = string(in.String())
+					out.Licenses = append(out.Licenses, v19)
 					in.WantComma()
 				}
 				in.Delim(']')
@@ -553,15 +643,65 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalSearch3(in *jlexer.Le
 					out.Tags = (out.Tags)[:0]
 				}
 				for !in.IsDelim(']') {
-					var v14 string
-					v14 = string(in.String())
-					out.Tags = append(out.Tags, v14)
+					var v20 string
+					v20 = string(in.String())
+					out.Tags = append(out.Tags, v20)
 					in.WantComma()
 				}
 				in.Delim(']')
 			}
+		case "categories":
+			if in.IsNull() {
+				in.Skip()
+				out.Categories = nil
+			} else {
+				in.Delim('[')
+				if out.Categories == nil {
+					if !in.IsDelim(']') {
+						out.Categories = make([]string, 0, 4)
+					} else {
+						out.Categories = []string{}
+					}
+				} else {
+					out.Categories = (out.Categories)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v21 string
+					v21 = string(in.String())
+					out.Categories = append(out.Categories, v21)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "verification":
+			out.Verification = string(in.String())
+		case "scorecard":
+			out.Scorecard = float64(in.Float64())
 		case "updated_at":
 			out.UpdatedAt = int64(in.Int64())
+		case "tenants":
+			if in.IsNull() {
+				in.Skip()
+				out.Tenants = nil
+			} else {
+				in.Delim('[')
+				if out.Tenants == nil {
+					if !in.IsDelim(']') {
+						out.Tenants = make([]string, 0, 4)
+					} else {
+						out.Tenants = []string{}
+					}
+				} else {
+					out.Tenants = (out.Tenants)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v22 string
+					v22 = string(in.String())
+					out.Tenants = append(out.Tenants, v22)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -633,11 +773,11 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalSearch3(out *jwriter.
 			out.RawString("null")
 		} else {
 			out.RawByte('[')
-			for v15, v16 := range in.Licenses {
-				if v15 > 0 {
+			for v23, v24 := range in.Licenses {
+				if v23 > 0 {
 					out.RawByte(',')
 				}
-				out.String(string(v16))
+				out.String(string(v24))
 			}
 			out.RawByte(']')
 		}
@@ -649,20 +789,60 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalSearch3(out *jwriter.
 			out.RawString("null")
 		} else {
 			out.RawByte('[')
-			for v17, v18 := range in.Tags {
-				if v17 > 0 {
+			for v25, v26 := range in.Tags {
+				if v25 > 0 {
 					out.RawByte(',')
 				}
-				out.String(string(v18))
+				out.String(string(v26))
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"categories\":"
+		out.RawString(prefix)
+		if in.Categories == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v27, v28 := range in.Categories {
+				if v27 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v28))
 			}
 			out.RawByte(']')
 		}
 	}
+	{
+		const prefix string = ",\"verification\":"
+		out.RawString(prefix)
+		out.String(string(in.Verification))
+	}
+	if in.Scorecard != 0 {
+		const prefix string = ",\"scorecard\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Scorecard))
+	}
 	{
 		const prefix string = ",\"updated_at\":"
 		out.RawString(prefix)
 		out.Int64(int64(in.UpdatedAt))
 	}
+	if len(in.Tenants) != 0 {
+		const prefix string = ",\"tenants\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v29, v30 := range in.Tenants {
+				if v29 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v30))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
@@ -734,6 +914,29 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalSearch4(in *jlexer.Le
 			out.NumProjects = int(in.Int())
 		case "updated_at":
 			out.UpdatedAt = int64(in.Int64())
+		case "tenants":
+			if in.IsNull() {
+				in.Skip()
+				out.Tenants = nil
+			} else {
+				in.Delim('[')
+				if out.Tenants == nil {
+					if !in.IsDelim(']') {
+						out.Tenants = make([]string, 0, 4)
+					} else {
+						out.Tenants = []string{}
+					}
+				} else {
+					out.Tenants = (out.Tenants)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v31 string
+					v31 = string(in.String())
+					out.Tenants = append(out.Tenants, v31)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -813,6 +1016,20 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalSearch4(out *jwriter.
 		out.RawString(prefix)
 		out.Int64(int64(in.UpdatedAt))
 	}
+	if len(in.Tenants) != 0 {
+		const prefix string = ",\"tenants\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v32, v33 := range in.Tenants {
+				if v32 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v33))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
@@ -878,6 +1095,29 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalSearch5(in *jlexer.Le
 			out.NumProjects = int(in.Int())
 		case "updated_at":
 			out.UpdatedAt = int64(in.Int64())
+		case "tenants":
+			if in.IsNull() {
+				in.Skip()
+				out.Tenants = nil
+			} else {
+				in.Delim('[')
+				if out.Tenants == nil {
+					if !in.IsDelim(']') {
+						out.Tenants = make([]string, 0, 4)
+					} else {
+						out.Tenants = []string{}
+					}
+				} else {
+					out.Tenants = (out.Tenants)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v34 string
+					v34 = string(in.String())
+					out.Tenants = append(out.Tenants, v34)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -942,6 +1182,20 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalSearch5(out *jwriter.
 		out.RawString(prefix)
 		out.Int64(int64(in.UpdatedAt))
 	}
+	if len(in.Tenants) != 0 {
+		const prefix string = ",\"tenants\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v35, v36 := range in.Tenants {
+				if v35 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v36))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
@@ -1009,11 +1263,11 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalSearch6(in *jlexer.Le
 					out.Hits = (out.Hits)[:0]
 				}
 				for !in.IsDelim(']') {
-					var v19 struct {
+					var v37 struct {
 						Entity Entity `json:"document"`
 					}
-					easyjsonD2b7633eDecode1(in, &v19)
-					out.Hits = append(out.Hits, v19)
+					easyjsonD2b7633eDecode1(in, &v37)
+					out.Hits = append(out.Hits, v37)
 					in.WantComma()
 				}
 				in.Delim(']')
@@ -1044,11 +1298,11 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalSearch6(out *jwriter.
 			out.RawString("null")
 		} else {
 			out.RawByte('[')
-			for v20, v21 := range in.Hits {
-				if v20 > 0 {
+			for v38, v39 := range in.Hits {
+				if v38 > 0 {
 					out.RawByte(',')
 				}
-				easyjsonD2b7633eEncode1(out, v21)
+				easyjsonD2b7633eEncode1(out, v39)
 			}
 			out.RawByte(']')
 		}
@@ -1142,9 +1396,9 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalSearch7(in *jlexer.Le
 			*out = (*out)[:0]
 		}
 		for !in.IsDelim(']') {
-			var v22 Entity
-			(v22).UnmarshalEasyJSON(in)
-			*out = append(*out, v22)
+			var v40 Entity
+			(v40).UnmarshalEasyJSON(in)
+			*out = append(*out, v40)
 			in.WantComma()
 		}
 		in.Delim(']')
@@ -1158,11 +1412,11 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalSearch7(out *jwriter.
 		out.RawString("null")
 	} else {
 		out.RawByte('[')
-		for v23, v24 := range in {
-			if v23 > 0 {
+		for v41, v42 := range in {
+			if v41 > 0 {
 				out.RawByte(',')
 			}
-			(v24).MarshalEasyJSON(out)
+			(v42).MarshalEasyJSON(out)
 		}
 		out.RawByte(']')
 	}
@@ -23,6 +23,9 @@ const (
 	deleteDocURI        = "/collections/%s/documents/%s"
 	collEntities        = "entities"
 	collProjects        = "projects"
+
+	// Max page size of Typesense searches.
+	indexedPerPage = 250
 )
 
 type Opt struct {
@@ -80,6 +83,9 @@ func (o *Search) SearchEntities(q EntityQuery) (Entities, int, error) {
 	if q.Role != "" {
 		p.Set("filter_by", "role:="+q.Type)
 	}
+	if q.Tenant != "" {
+		p.Set("filter_by", tenantFilter(p.Get("filter_by"), q.Tenant))
+	}
 
 	p.Set("per_page", o.perPage)
 
@@ -141,8 +147,24 @@ func (o *Search) SearchProjects(q ProjectQuery) (Projects, int, error) {
 		p.Set("query_by", "name,tags,description")
 	}
 
+	var filters []string
 	if len(q.Licenses) > 0 {
-		p.Set("filter_by", "licenses="+strings.Join(q.Licenses, ","))
+		filters = append(filters, "licenses="+strings.Join(q.Licenses, ","))
+	}
+	if len(q.Categories) > 0 {
+		filters = append(filters, "categories:=["+strings.Join(q.Categories, ",")+"]")
+	}
+	if q.Verification != "" {
+		filters = append(filters, "verification:=`"+q.Verification+"`")
+	}
+	if q.MinScorecard > 0 {
+		filters = append(filters, "scorecard:>="+strconv.FormatFloat(q.MinScorecard, 'f', -1, 64))
+	}
+	if q.Tenant != "" {
+		filters = append(filters, tenantFilter("", q.Tenant))
+	}
+	if len(filters) > 0 {
+		p.Set("filter_by", strings.Join(filters, " && "))
 	}
 
 	p.Set("page", fmt.Sprintf("%d", q.Page))
@@ -201,12 +223,16 @@ func (o *Search) GetRecentEntities(limit int) (Entities, error) {
 	return out, nil
 }
 
-// GetRecentProjects retrieves N recently updated entities.
-func (o *Search) GetRecentProjects(limit int) (Projects, error) {
+// GetRecentProjects retrieves N recently updated entities. If tenant is set, only
+// the projects listed on the tenant sub-portal are retrieved.
+func (o *Search) GetRecentProjects(limit int, tenant string) (Projects, error) {
 	p := url.Values{}
 	p.Set("q", "*")
 	p.Set("sort_by", "updated_at:desc")
 	p.Set("limit", fmt.Sprintf("%d", limit))
+	if tenant != "" {
+		p.Set("filter_by", tenantFilter("", tenant))
+	}
 
 	// Search.
 	b, _, err := o.do(http.MethodGet, fmt.Sprintf(searchURI, collProjects), []byte(p.Encode()))
@@ -274,6 +300,78 @@ func (s *Search) Delete(manifestID int) error {
 	return nil
 }
 
+// GetIndexedEntities retrieves all the entities in the index with only their IDs,
+// manifest IDs, and update times, eg: for cross-checking the index against the DB.
+func (o *Search) GetIndexedEntities() (Entities, error) {
+	var out Entities
+	for page := 1; ; page++ {
+		b, err := o.getIndexedPage(collEntities, page)
+		if err != nil {
+			return nil, err
+		}
+
+		var res EntitiesResp
+		if err := res.UnmarshalJSON(b); err != nil {
+			return nil, err
+		}
+		for _, h := range res.Hits {
+			out = append(out, h.Entity)
+		}
+
+		if len(res.Hits) < indexedPerPage {
+			return out, nil
+		}
+	}
+}
+
+// GetIndexedProjects retrieves all the projects in the index with only their IDs,
+// manifest IDs, and update times.
+func (o *Search) GetIndexedProjects() (Projects, error) {
+	var out Projects
+	for page := 1; ; page++ {
+		b, err := o.getIndexedPage(collProjects, page)
+		if err != nil {
+			return nil, err
+		}
+
+		var res ProjectsResp
+		if err := res.UnmarshalJSON(b); err != nil {
+			return nil, err
+		}
+		for _, h := range res.Hits {
+			out = append(out, h.Project)
+		}
+
+		if len(res.Hits) < indexedPerPage {
+			return out, nil
+		}
+	}
+}
+
+// getIndexedPage retrieves a page of all the documents in a collection. Pages are
+// used instead of a full export so that responses stay within the max bytes.
+func (o *Search) getIndexedPage(coll string, page int) ([]byte, error) {
+	p := url.Values{}
+	p.Set("q", "*")
+	p.Set("include_fields", "id,manifest_id,updated_at")
+	p.Set("sort_by", "manifest_id:asc")
+	p.Set("page", strconv.Itoa(page))
+	p.Set("per_page", strconv.Itoa(indexedPerPage))
+
+	b, _, err := o.do(http.MethodGet, fmt.Sprintf(searchURI, coll), []byte(p.Encode()))
+	return b, err
+}
+
+// tenantFilter adds a filter on the tenant sub-portal (slug) to a filter_by expression.
+func tenantFilter(filter, tenant string) string {
+	f := "tenants:=[`" + tenant + "`]"
+	if filter == "" {
+		return f
+	}
+
+	return filter + " && " + f
+}
+
 // InitSchema deletes and recreates the empty collection afresh.
 // If `typ` is given, only entries with type=$typ are deleted.
 func (o *Search) InitSchema() error {
@@ -1,6 +1,6 @@
 -- name: upsert-manifest
 WITH man AS (
-    INSERT INTO manifests (version, url, guid, funding, meta, status, status_message)
+    INSERT INTO manifests (version, url, guid, funding, meta, status, status_message, hash, verification)
     VALUES (
         $1::JSONB->>'version',
         $2,
@@ -8,17 +8,28 @@ WITH man AS (
         $1::JSONB->'funding',
         $4,
         $5,
-        $6
+        $6,
+        $7,
+        $8
     )
     ON CONFLICT (url) DO UPDATE
     SET version = $1->>'version',
         funding = $1::JSONB->'funding',
         meta = $4,
         status = $5,
-        status_message = $6,
+        -- Notes on the status (eg: moderation notes and payment alerts) are retained until the status changes.
+        status_message = (CASE WHEN manifests.status = $5 THEN manifests.status_message ELSE $6 END),
+        hash = $7,
+        -- Manual verification by an admin and delegated verification by fiscal hosts are retained across crawls.
+        verification = (CASE
+            WHEN manifests.verification = 'admin-verified' THEN manifests.verification
+            WHEN EXISTS (SELECT 1 FROM fiscal_host_members WHERE url = manifests.url) THEN 'signed'
+            ELSE $8 END
+        ),
         updated_at = NOW(),
         crawl_errors = 0,
-        crawl_message = ''
+        crawl_message = '',
+        gone_streak = 0
     RETURNING id
 ),
 entity AS (
@@ -78,15 +89,17 @@ prj AS (
 SELECT (SELECT id FROM man) AS manifest_id;
 
 -- name: get-manifests
+-- Manifests in preview are only returned by their preview token hash ($5).
 WITH man AS (
     SELECT * FROM manifests 
     WHERE 
     (CASE
+        WHEN $5 != '' THEN preview_token_hash = $5 AND status = 'preview'
         WHEN $1 > 0 THEN id = $1
         WHEN $2 != '' THEN guid = $2
         ELSE TRUE
     END)
-    AND status = 'active'
+    AND (status = 'active' OR $5 != '')
 ),
 entity AS (
     SELECT m.id, TO_JSON(e) AS entity_raw 
@@ -99,8 +112,8 @@ prj AS (
     JOIN man m ON p.manifest_id = m.id
     GROUP BY m.id
 )
-SELECT m.id, m.guid, m.version, m.url, m.funding AS funding_raw, 
-       m.status, m.status_message, m.crawl_errors, 
+SELECT m.id, m.guid, m.version, m.url, m.funding AS funding_raw, m.meta,
+       m.status, m.status_message, m.verification, m.hash, m.crawl_errors, 
        m.crawl_message, m.created_at, m.updated_at, 
        COALESCE(e.entity_raw, '[]'::json) AS entity_raw, 
        COALESCE(p.projects_raw, '[]'::json) AS projects_raw
@@ -111,19 +124,216 @@ FROM man m
 
 
 -- name: get-manifest-status
-SELECT status FROM manifests WHERE url = $1;
+-- Resolves aliases of moved manifests.
+SELECT id, guid, url, status, crawl_errors, crawl_message, updated_at FROM manifests
+    WHERE url = $1 OR id = (SELECT manifest_id FROM manifest_aliases WHERE url = $1)
+    ORDER BY (url = $1) DESC LIMIT 1;
+
+-- name: get-submission-velocity
+-- Number of listings created in the last day whose URLs match the given LIKE pattern
+-- (domain), and that share any of the given payment addresses, excluding the given URL.
+SELECT
+    (SELECT COUNT(*) FROM manifests
+        WHERE created_at > NOW() - INTERVAL '1 day' AND url LIKE $1 AND url != $3) AS domain,
+    (SELECT COUNT(DISTINCT m.id) FROM manifests m, JSONB_ARRAY_ELEMENTS(m.funding->'channels') ch
+        WHERE m.created_at > NOW() - INTERVAL '1 day' AND m.url != $3
+        AND ch->>'address' != '' AND ch->>'address' = ANY($2::TEXT[])) AS address;
+
+-- name: update-manifest-status-message
+UPDATE manifests SET status_message=$2 WHERE url=$1;
+
+-- name: update-manifest-preview
+UPDATE manifests SET preview_token_hash=$2 WHERE url=$1;
+
+-- name: update-manifest-relay
+UPDATE manifests SET relay_optout=$2, relay_source=$3 WHERE url=$1;
+
+-- name: get-manifest-relay
+SELECT relay_optout, relay_source FROM manifests WHERE id=$1;
+
+-- name: update-manifest-intake
+-- Records the submission channel of a manifest. Only the first channel a manifest
+-- entered the system through is kept.
+UPDATE manifests SET intake=$2, intake_ref=$3 WHERE url=$1 AND intake = '';
+
+-- name: get-manifest-intake
+SELECT intake, intake_ref, created_at FROM manifests WHERE id=$1;
+
+-- name: get-intake-stats
+-- Number of manifests submitted within the interval per channel, reference, and status.
+SELECT intake, intake_ref, status, COUNT(*) AS manifests
+    FROM manifests WHERE created_at > NOW() - $1::INTERVAL
+    GROUP BY intake, intake_ref, status ORDER BY intake, intake_ref, status;
+
+-- name: update-manifest-verification
+UPDATE manifests SET verification=$2 WHERE id=$1;
 
 -- name: get-for-crawling
-SELECT id, url, updated_at FROM manifests
+-- Manifests with an armed trace are always picked up.
+WITH traces AS (
+    SELECT manifest_id FROM manifest_traces WHERE armed = true
+)
+SELECT id, url, updated_at, etag, pinned_hash, hash, body_hash, request_id, (id IN (SELECT manifest_id FROM traces)) AS trace,
+    fetch_max_bytes, fetch_timeout_ms, fetch_attempts FROM manifests
     WHERE id > $1
-    AND updated_at > NOW() - $2::INTERVAL
+    AND (updated_at > NOW() - $2::INTERVAL OR id IN (SELECT manifest_id FROM traces))
     AND status != 'disabled'
     AND status != 'blocked'
     ORDER BY id LIMIT $3;
 
+-- name: get-due-crawls
+-- Manifests due for a scheduled crawl, including ones that haven't been scheduled yet.
+SELECT m.id, m.url, m.updated_at, m.etag, m.pinned_hash, m.hash, m.body_hash, m.request_id,
+    EXISTS (SELECT 1 FROM manifest_traces t WHERE t.manifest_id = m.id AND t.armed = true) AS trace,
+    m.fetch_max_bytes, m.fetch_timeout_ms, m.fetch_attempts,
+    COALESCE(s.interval_secs, 0) AS interval_secs
+    FROM manifests m LEFT JOIN crawl_schedule s ON (s.manifest_id = m.id)
+    WHERE m.status != 'disabled' AND m.status != 'blocked'
+    AND (s.next_at IS NULL OR s.next_at <= $1)
+    -- Recrawls of manifests on hosts that are down are deferred.
+    AND NOT EXISTS (SELECT 1 FROM host_downtime h WHERE h.host = LOWER(SUBSTRING(m.url FROM '://([^/:?#]+)')))
+    ORDER BY s.next_at NULLS FIRST, m.id LIMIT $2;
+
+-- name: upsert-crawl-schedule
+INSERT INTO crawl_schedule (manifest_id, interval_secs, next_at) VALUES ($1, $2, $3)
+    ON CONFLICT (manifest_id) DO UPDATE SET interval_secs = EXCLUDED.interval_secs, next_at = EXCLUDED.next_at, updated_at = NOW();
+
+-- name: enqueue-crawl-jobs
+-- Adds manifests to the durable crawl queue. Manifests already in it are left as they are,
+-- unless they're enqueued as interactive (0), which makes them available right away at the top priority.
+INSERT INTO crawl_queue (manifest_id, priority) SELECT UNNEST($1::INT[]), $2
+    ON CONFLICT (manifest_id) DO UPDATE SET priority = EXCLUDED.priority, available_at = NOW()
+    WHERE EXCLUDED.priority = 0;
+
+-- name: lease-crawl-jobs
+-- Leases up to $1 available jobs for $2 seconds in the order of priority. Jobs whose
+-- leases have expired (eg: the crawl was killed) are available again.
+WITH q AS (
+    SELECT c.manifest_id FROM crawl_queue c JOIN manifests m ON (m.id = c.manifest_id)
+        WHERE c.available_at <= NOW() AND (c.leased_until IS NULL OR c.leased_until < NOW())
+        AND m.status != 'disabled' AND m.status != 'blocked'
+        ORDER BY c.priority, c.available_at, c.manifest_id
+        LIMIT $1 FOR UPDATE OF c SKIP LOCKED
+),
+l AS (
+    UPDATE crawl_queue c SET leased_until = NOW() + $2 * INTERVAL '1 second'
+        FROM q WHERE c.manifest_id = q.manifest_id
+        RETURNING c.manifest_id, c.priority, c.attempts
+)
+SELECT m.id, m.url, m.updated_at, m.etag, m.pinned_hash, m.hash, m.body_hash, m.request_id,
+    EXISTS (SELECT 1 FROM manifest_traces t WHERE t.manifest_id = m.id AND t.armed = true) AS trace,
+    m.fetch_max_bytes, m.fetch_timeout_ms, m.fetch_attempts, l.attempts, l.priority
+    FROM l JOIN manifests m ON (m.id = l.manifest_id)
+    ORDER BY l.priority, m.id;
+
+-- name: ack-crawl-job
+DELETE FROM crawl_queue WHERE manifest_id = $1;
+
+-- name: retry-crawl-job
+UPDATE crawl_queue SET leased_until = NULL, attempts = attempts + 1, available_at = NOW() + $2 * INTERVAL '1 second',
+    priority = GREATEST(priority, $3) WHERE manifest_id = $1;
+
+-- name: release-crawl-job
+UPDATE crawl_queue SET leased_until = NULL WHERE manifest_id = $1;
+
+-- name: count-crawl-jobs
+-- Jobs in the durable crawl queue that are available or leased, excluding retries scheduled for later.
+SELECT COUNT(*) FROM crawl_queue WHERE available_at <= NOW();
+
+-- name: update-manifest-fetch-limits
+UPDATE manifests SET fetch_max_bytes = $2, fetch_timeout_ms = $3, fetch_attempts = $4 WHERE id = $1;
+
+-- name: get-manifest-fetch-limits
+SELECT fetch_max_bytes, fetch_timeout_ms, fetch_attempts FROM manifests WHERE id = $1;
+
+-- name: update-manifest-etag
+UPDATE manifests SET etag = $2 WHERE id = $1;
+
+-- name: update-manifest-body-hash
+UPDATE manifests SET body_hash = $2 WHERE id = $1;
+
+-- name: update-manifest-pin
+UPDATE manifests SET pinned_hash = $3 WHERE (CASE WHEN $1 > 0 THEN id = $1 ELSE url = $2 END);
+
+-- name: update-manifest-request-id
+UPDATE manifests SET request_id = $2 WHERE url = $1;
+
+-- name: get-for-sweep
+SELECT id, url, updated_at, fetch_max_bytes, fetch_timeout_ms, fetch_attempts FROM manifests
+    WHERE id > $1
+    AND status != 'disabled'
+    AND status != 'blocked'
+    ORDER BY id LIMIT $2;
+
+-- name: update-manifest-liveness
+INSERT INTO manifest_liveness (manifest_id, available, status_code, message, checked_at, down_since)
+    VALUES ($1, $2, $3, $4, NOW(), (CASE WHEN $2 THEN NULL ELSE NOW() END))
+    ON CONFLICT (manifest_id) DO UPDATE SET
+        available = EXCLUDED.available,
+        status_code = EXCLUDED.status_code,
+        message = EXCLUDED.message,
+        checked_at = NOW(),
+        down_since = (CASE WHEN $2 THEN NULL ELSE COALESCE(manifest_liveness.down_since, NOW()) END);
+
+-- name: get-manifest-liveness
+SELECT * FROM manifest_liveness WHERE manifest_id = $1;
+
+-- name: update-host-downtime
+-- Records the hosts that none of the manifests of were reachable on the last liveness
+-- sweep (connection errors, timeouts, 5xx) as down. Hosts that are up again are removed,
+-- which makes the recrawls of their manifests that were deferred while they were down due
+-- (get-due-crawls), once per manifest however many of its recrawls were deferred.
+WITH hosts AS (
+    SELECT LOWER(SUBSTRING(m.url FROM '://([^/:?#]+)')) AS host,
+        BOOL_AND(NOT l.available AND (l.status_code = 0 OR l.status_code >= 500)) AS down,
+        MIN(l.down_since) AS down_since
+    FROM manifest_liveness l JOIN manifests m ON (m.id = l.manifest_id)
+    WHERE m.status != 'disabled' AND m.status != 'blocked'
+    GROUP BY 1
+),
+down AS (
+    INSERT INTO host_downtime (host, down_since)
+        SELECT host, COALESCE(down_since, NOW()) FROM hosts WHERE down AND host IS NOT NULL
+    ON CONFLICT (host) DO UPDATE SET checked_at = NOW()
+    RETURNING host
+),
+up AS (
+    DELETE FROM host_downtime WHERE host NOT IN (SELECT host FROM hosts WHERE down AND host IS NOT NULL)
+    RETURNING host
+)
+SELECT (SELECT COUNT(*) FROM down) AS down, (SELECT COUNT(*) FROM up) AS up;
+
+-- name: get-host-downtime
+SELECT d.host, d.down_since, d.checked_at,
+    (SELECT COUNT(*) FROM manifests m WHERE LOWER(SUBSTRING(m.url FROM '://([^/:?#]+)')) = d.host) AS manifests
+    FROM host_downtime d ORDER BY d.down_since;
+
 -- name: update-manifest-status
 UPDATE manifests SET status=$2 WHERE id=$1;
 
+-- name: get-moderation-targets
+-- Manifests matching the filters of a bulk moderation action: the host of the URL (or
+-- its parent domain), the submission window, the instance it was relayed from, the
+-- class of its latest crawl error, status, and submission channel. Empty filters match everything.
+SELECT m.id, m.url, m.status, m.intake, m.intake_ref, m.created_at FROM manifests m
+    WHERE ($1 = '' OR LOWER(SUBSTRING(m.url FROM '://([^/:?#]+)')) = $1 OR LOWER(SUBSTRING(m.url FROM '://([^/:?#]+)')) LIKE '%.' || $1)
+    AND ($2::TIMESTAMP WITH TIME ZONE IS NULL OR m.created_at >= $2)
+    AND ($3::TIMESTAMP WITH TIME ZONE IS NULL OR m.created_at < $3)
+    AND ($4 = '' OR m.relay_source = $4)
+    AND ($5 = '' OR (SELECT class FROM crawl_errors e WHERE e.manifest_id = m.id ORDER BY e.id DESC LIMIT 1) = $5)
+    AND ($6 = '' OR m.status::TEXT = $6)
+    AND ($7 = '' OR m.intake = $7)
+    ORDER BY m.id LIMIT $8;
+
+-- name: recrawl-manifests
+-- Adds manifests to the durable crawl queue with the priority $2 for a crawl that
+-- revalidates them (skipping conditional fetches and unchanged contents).
+WITH m AS (
+    UPDATE manifests SET etag = '', body_hash = '' WHERE id = ANY($1::INT[]) RETURNING id
+)
+INSERT INTO crawl_queue (manifest_id, priority) SELECT id, $2 FROM m
+    ON CONFLICT (manifest_id) DO UPDATE SET priority = EXCLUDED.priority, available_at = NOW(), leased_until = NULL;
+
 -- name: get-top-tags
 SELECT tag FROM top_tags LIMIT $1;
 
@@ -135,6 +345,13 @@ UPDATE manifests SET
     WHERE id = $1
     RETURNING status;
 
+-- name: update-manifest-gone-streak
+-- Extends the streak of crawls on which the manifest URL responded with 404 or 410 ($2),
+-- or resets it on any other response.
+UPDATE manifests SET gone_streak = (CASE WHEN $2 THEN gone_streak + 1 ELSE 0 END)
+    WHERE id = $1
+    RETURNING gone_streak;
+
 -- name: delete-manifest
 DELETE FROM manifests WHERE
     CASE
@@ -147,4 +364,566 @@ INSERT INTO reports (manifest_id, reason)
 VALUES (
     $1,
     $2
-);
\ No newline at end of file
+);
+
+-- name: upsert-favicon
+INSERT INTO favicons (manifest_id, body, content_type, opengraph)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT (manifest_id) DO UPDATE SET
+        body = EXCLUDED.body,
+        content_type = EXCLUDED.content_type,
+        opengraph = EXCLUDED.opengraph,
+        updated_at = NOW();
+
+-- name: get-favicon
+SELECT body, content_type, opengraph, updated_at FROM favicons WHERE manifest_id = $1;
+
+-- name: upsert-manifest-mirror
+INSERT INTO manifest_mirrors (manifest_id, body, hash, fetched_at)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT (manifest_id) DO UPDATE SET
+        body = EXCLUDED.body,
+        hash = EXCLUDED.hash,
+        fetched_at = EXCLUDED.fetched_at;
+
+-- name: get-manifest-mirror
+-- Only active manifests are mirrored publicly.
+SELECT m.url, mm.body, mm.hash, mm.fetched_at FROM manifest_mirrors mm
+    JOIN manifests m ON (m.id = mm.manifest_id)
+    WHERE m.guid = $1 AND m.status = 'active';
+
+-- name: insert-crawl-run
+INSERT INTO crawl_runs (started_at, finished_at, total, success, failed, skipped, latency_p50, latency_p95)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: get-crawl-runs
+SELECT * FROM crawl_runs ORDER BY started_at DESC LIMIT $1;
+
+-- name: arm-manifest-trace
+INSERT INTO manifest_traces (manifest_id, armed) VALUES ($1, true)
+    ON CONFLICT (manifest_id) DO UPDATE SET armed = true, requested_at = NOW();
+
+-- name: save-manifest-trace
+UPDATE manifest_traces SET armed = false, trace = $2, captured_at = NOW() WHERE manifest_id = $1;
+
+-- name: get-manifest-trace
+SELECT * FROM manifest_traces WHERE manifest_id = $1;
+
+-- name: insert-crawl-error
+INSERT INTO crawl_errors (manifest_id, host, class, message, request_id) VALUES ($1, $2, $3, $4, $5);
+
+-- name: get-crawl-error-domains
+-- Hosts with the most crawl errors within the interval.
+SELECT host, COUNT(*) AS errors, COUNT(DISTINCT manifest_id) AS manifests,
+    ARRAY_AGG(DISTINCT class) AS classes, MAX(created_at) AS last_error_at,
+    (ARRAY_AGG(message ORDER BY created_at DESC))[1] AS last_message
+    FROM crawl_errors WHERE created_at > NOW() - $1::INTERVAL
+    GROUP BY host ORDER BY errors DESC LIMIT $2;
+
+-- name: get-crawl-error-trends
+-- Daily number of crawl errors per class within the interval.
+SELECT DATE_TRUNC('day', created_at) AS day, class, COUNT(*) AS errors
+    FROM crawl_errors WHERE created_at > NOW() - $1::INTERVAL
+    GROUP BY day, class ORDER BY day, class;
+
+-- name: delete-crawl-errors
+DELETE FROM crawl_errors WHERE created_at < NOW() - $1::INTERVAL;
+
+-- name: move-manifest
+-- Changes a manifest's URL, recording the old URL as an alias. The ID, GUID, and
+-- everything linked to the manifest are retained.
+WITH old AS (
+    SELECT id, url FROM manifests WHERE id = $1
+),
+del AS (
+    DELETE FROM manifest_aliases WHERE url = $2
+),
+alias AS (
+    INSERT INTO manifest_aliases (manifest_id, url, reason)
+        SELECT id, url, $3 FROM old WHERE url != $2
+        ON CONFLICT (url) DO UPDATE SET manifest_id = EXCLUDED.manifest_id, reason = EXCLUDED.reason
+)
+UPDATE manifests SET url = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: insert-manifest-alias
+-- Records an alternate URL of a manifest (by its URL), unless it's already an alias.
+INSERT INTO manifest_aliases (manifest_id, url, reason)
+    SELECT id, $2, $3 FROM manifests WHERE url = $1 AND url != $2
+    ON CONFLICT (url) DO NOTHING;
+
+-- name: get-manifest-aliases
+SELECT * FROM manifest_aliases WHERE manifest_id = $1 ORDER BY created_at;
+
+-- name: link-manifest
+-- Links a manifest to a parent manifest. Links are one level deep, that is, a parent
+-- can't itself be linked to another manifest and a child can't have children.
+INSERT INTO entity_links (manifest_id, parent_id)
+    SELECT $1, $2 WHERE NOT EXISTS (
+        SELECT 1 FROM entity_links WHERE manifest_id = $2 OR parent_id = $1
+    )
+    ON CONFLICT (manifest_id) DO UPDATE SET parent_id = EXCLUDED.parent_id, created_at = NOW();
+
+-- name: unlink-manifest
+DELETE FROM entity_links WHERE manifest_id = $1;
+
+-- name: get-linked-manifests
+SELECT manifest_id FROM entity_links WHERE parent_id = $1 ORDER BY manifest_id;
+
+-- name: get-manifest-parent
+SELECT parent_id FROM entity_links WHERE manifest_id = $1;
+
+-- name: get-changes
+-- Changes after the cursor $1. With a tenant ($3), only the changes of the manifests listed on it.
+SELECT * FROM manifest_changes WHERE id > $1
+    AND ($3 = '' OR manifest_id IN (SELECT manifest_id FROM tenant_manifests WHERE tenant = $3))
+    ORDER BY id LIMIT $2;
+
+-- name: get-last-change-id
+SELECT COALESCE(MAX(id), 0) FROM manifest_changes;
+
+-- name: get-funding-candidates
+-- Active manifests with at least one project matching the licenses and tags (if any),
+-- and entities matching the types (if any), listed on the tenant $5 (if any).
+SELECT m.id, m.guid, e.name, m.funding AS funding_raw, m.meta FROM manifests m
+    JOIN entities e ON e.manifest_id = m.id
+    WHERE m.status = 'active'
+    AND ($5 = '' OR EXISTS (SELECT 1 FROM tenant_manifests t WHERE t.manifest_id = m.id AND t.tenant = $5))
+    AND (CARDINALITY($3::TEXT[]) = 0 OR e.type::TEXT = ANY($3::TEXT[]))
+    AND EXISTS (
+        SELECT 1 FROM projects p WHERE p.manifest_id = m.id
+        AND (CARDINALITY($1::TEXT[]) = 0 OR p.licenses && $1::TEXT[])
+        AND (CARDINALITY($2::TEXT[]) = 0 OR p.tags && $2::TEXT[])
+    )
+    ORDER BY m.id LIMIT $4;
+
+-- name: insert-funding-snapshot
+-- Records a snapshot only if the figures differ from the last one in the currency.
+INSERT INTO funding_snapshots (manifest_id, currency, goal, received)
+    SELECT $1, $2, $3, $4 WHERE NOT EXISTS (
+        SELECT 1 FROM (
+            SELECT goal, received FROM funding_snapshots
+            WHERE manifest_id = $1 AND currency = $2 ORDER BY id DESC LIMIT 1
+        ) s WHERE s.goal = $3 AND s.received = $4
+    );
+
+-- name: insert-manifest-version
+-- Records a version only if the contents differ from the last one.
+INSERT INTO manifest_versions (manifest_id, hash, body)
+    SELECT $1, $2, $3 WHERE NOT EXISTS (
+        SELECT 1 FROM (
+            SELECT hash FROM manifest_versions WHERE manifest_id = $1 ORDER BY id DESC LIMIT 1
+        ) v WHERE v.hash = $2
+    );
+
+-- name: get-manifest-version
+-- The last version of a manifest recorded before $2, if the manifest was listed
+-- (its last listing change before $2 wasn't a delisting) at the time. Manifests
+-- listed before the change feed existed have no changes before $2. They were listed
+-- if their first change after $2 isn't a listing, or without any, if they're active.
+SELECT v.manifest_id, v.hash, v.body, v.created_at FROM manifest_versions v
+    WHERE v.manifest_id = $1 AND v.created_at < $2
+    AND COALESCE(
+        (SELECT event FROM manifest_changes WHERE manifest_id = $1 AND created_at < $2 ORDER BY id DESC LIMIT 1),
+        (SELECT (CASE WHEN event = 'create' THEN 'delete' ELSE 'update' END) FROM manifest_changes WHERE manifest_id = $1 ORDER BY id LIMIT 1),
+        (SELECT (CASE WHEN status = 'active' THEN 'create' END) FROM manifests WHERE id = $1)
+    ) IN ('create', 'update')
+    ORDER BY v.id DESC LIMIT 1;
+
+-- name: get-funding-snapshots
+SELECT manifest_id, currency, goal, received, created_at FROM funding_snapshots
+    WHERE manifest_id = $1 ORDER BY currency, id;
+
+-- name: get-funding-gaps
+-- Yearly goals vs. received amounts (of the latest funding snapshots) of active manifests
+-- per category ($1 = 'category') or tag ($1 = 'tag') of their projects, and currency.
+-- A manifest is counted once in every group that its projects are in. Only the received
+-- amounts of verified manifests count as verified income.
+WITH snaps AS (
+    SELECT DISTINCT ON (manifest_id, currency) manifest_id, currency, goal, received
+    FROM funding_snapshots ORDER BY manifest_id, currency, id DESC
+),
+groups AS (
+    SELECT DISTINCT p.manifest_id, (CASE WHEN $1 = 'tag' THEN t.tag ELSE COALESCE(tc.category, '') END) AS name
+    FROM projects p CROSS JOIN UNNEST(p.tags) AS t(tag)
+    LEFT JOIN tag_categories tc ON tc.tag = t.tag
+)
+SELECT g.name, s.currency, COUNT(*) AS num_manifests,
+    SUM(s.goal) AS goal, SUM(s.received) AS received,
+    SUM(CASE WHEN m.verification != 'unverified' THEN s.received ELSE 0 END) AS verified_received,
+    GREATEST(SUM(s.goal) - SUM(CASE WHEN m.verification != 'unverified' THEN s.received ELSE 0 END), 0) AS gap
+FROM snaps s
+    JOIN manifests m ON m.id = s.manifest_id AND m.status = 'active'
+    JOIN groups g ON g.manifest_id = s.manifest_id AND g.name != ''
+    WHERE ($2 = '' OR s.currency = $2)
+    GROUP BY g.name, s.currency
+    ORDER BY gap DESC, g.name LIMIT $3;
+
+-- name: get-tag-categories
+SELECT tag, category FROM tag_categories ORDER BY category, tag;
+
+-- name: upsert-tag-category
+INSERT INTO tag_categories (tag, category) VALUES ($1, $2)
+    ON CONFLICT (tag) DO UPDATE SET category = EXCLUDED.category;
+
+-- name: delete-tag-category
+DELETE FROM tag_categories WHERE tag = $1;
+
+-- name: get-projects-for-related
+SELECT p.id, p.manifest_id, p.tags, p.licenses, e.type AS entity_type FROM projects p
+    JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+    JOIN entities e ON e.manifest_id = m.id
+    ORDER BY p.id;
+
+-- name: replace-related-projects
+-- Replaces all related projects atomically.
+WITH del AS (
+    DELETE FROM related_projects
+)
+INSERT INTO related_projects (project_id, related_id, score)
+    SELECT * FROM UNNEST($1::INT[], $2::INT[], $3::REAL[]);
+
+-- name: get-related-projects
+-- With a tenant ($4), both the project and its related projects should be listed on it.
+SELECT p.guid, p.name, p.description, m.guid AS manifest_guid, e.name AS entity_name, r.score
+    FROM related_projects r
+    JOIN projects src ON src.id = r.project_id
+    JOIN manifests sm ON sm.id = src.manifest_id
+    JOIN projects p ON p.id = r.related_id
+    JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+    JOIN entities e ON e.manifest_id = m.id
+    WHERE sm.guid = $1 AND src.guid = $2
+    AND ($4 = '' OR (
+        EXISTS (SELECT 1 FROM tenant_manifests t WHERE t.manifest_id = sm.id AND t.tenant = $4)
+        AND EXISTS (SELECT 1 FROM tenant_manifests t WHERE t.manifest_id = m.id AND t.tenant = $4)
+    ))
+    ORDER BY r.score DESC LIMIT $3;
+
+-- name: get-denylist
+SELECT address, channel_type, reason, source, created_at FROM payment_denylist ORDER BY created_at, address;
+
+-- name: get-denylisted
+SELECT address, channel_type, reason, source, created_at FROM payment_denylist WHERE address = ANY($1::TEXT[]);
+
+-- name: import-denylist
+INSERT INTO payment_denylist (address, channel_type, reason, source)
+    SELECT * FROM UNNEST($1::TEXT[], $2::TEXT[], $3::TEXT[], $4::TEXT[])
+    ON CONFLICT (address) DO UPDATE SET channel_type=EXCLUDED.channel_type, reason=EXCLUDED.reason, source=EXCLUDED.source;
+
+-- name: delete-denylist
+DELETE FROM payment_denylist WHERE address = $1;
+
+-- name: upsert-fiscal-host
+-- Only admin-verified manifests can be fiscal hosts.
+INSERT INTO fiscal_hosts (manifest_id, public_key, members_url)
+    SELECT $1, $2, $3 WHERE EXISTS (SELECT 1 FROM manifests WHERE id = $1 AND verification = 'admin-verified')
+    ON CONFLICT (manifest_id) DO UPDATE SET public_key=$2, members_url=$3
+    RETURNING id;
+
+-- name: get-fiscal-hosts
+SELECT f.*, m.guid, m.verification FROM fiscal_hosts f
+    JOIN manifests m ON m.id = f.manifest_id
+    WHERE ($1 = 0 OR f.id = $1)
+    ORDER BY f.id;
+
+-- name: delete-fiscal-host
+-- Members of the host (not vouched for by other hosts) lose their delegated verification.
+-- They are re-verified on the next crawl.
+WITH rev AS (
+    UPDATE manifests SET verification = 'unverified'
+    WHERE verification = 'signed'
+    AND url IN (SELECT url FROM fiscal_host_members WHERE fiscal_host_id = $1)
+    AND NOT EXISTS (SELECT 1 FROM fiscal_host_members f WHERE f.url = manifests.url AND f.fiscal_host_id != $1)
+)
+DELETE FROM fiscal_hosts WHERE id = $1;
+
+-- name: replace-fiscal-host-members
+-- Replaces the members of a fiscal host and grants them delegated verification.
+-- Members that have been removed (and aren't vouched for by other hosts) lose it.
+WITH old AS (
+    DELETE FROM fiscal_host_members WHERE fiscal_host_id = $1 RETURNING url
+),
+ins AS (
+    INSERT INTO fiscal_host_members (fiscal_host_id, url)
+    SELECT $1, UNNEST($2::TEXT[]) ON CONFLICT DO NOTHING
+),
+rev AS (
+    UPDATE manifests SET verification = 'unverified'
+    WHERE verification = 'signed'
+    AND url IN (SELECT url FROM old) AND url != ALL($2::TEXT[])
+    AND NOT EXISTS (SELECT 1 FROM fiscal_host_members f WHERE f.url = manifests.url AND f.fiscal_host_id != $1)
+),
+upd AS (
+    UPDATE fiscal_hosts SET num_members = CARDINALITY($2::TEXT[]), last_error = '', refreshed_at = NOW() WHERE id = $1
+)
+UPDATE manifests SET verification = 'signed'
+    WHERE url = ANY($2::TEXT[]) AND verification != 'admin-verified';
+
+-- name: update-fiscal-host-error
+UPDATE fiscal_hosts SET last_error = $2, refreshed_at = NOW() WHERE id = $1;
+
+-- name: export-projects
+-- Analytics export of the projects of active manifests. Arrays are ; separated.
+SELECT p.id, m.id AS manifest_id, m.guid AS manifest_guid, e.name AS entity_name, e.type AS entity_type,
+    p.guid, p.name, p.webpage_url, p.repository_url,
+    ARRAY_TO_STRING(p.licenses, ';') AS licenses, ARRAY_TO_STRING(p.tags, ';') AS tags,
+    m.verification, p.created_at, p.updated_at
+    FROM projects p
+    JOIN manifests m ON (m.id = p.manifest_id)
+    LEFT JOIN entities e ON (e.manifest_id = m.id)
+    WHERE m.status = 'active' ORDER BY p.id;
+
+-- name: export-plans
+SELECT m.id AS manifest_id, m.guid AS manifest_guid, pl->>'guid' AS guid, pl->>'status' AS status,
+    pl->>'name' AS name, (pl->>'amount')::FLOAT8 AS amount, pl->>'currency' AS currency, pl->>'frequency' AS frequency,
+    (SELECT STRING_AGG(c, ';') FROM JSONB_ARRAY_ELEMENTS_TEXT(pl->'channels') c) AS channels
+    FROM manifests m, JSONB_ARRAY_ELEMENTS(m.funding->'plans') pl
+    WHERE m.status = 'active' ORDER BY m.id;
+
+-- name: export-channels
+SELECT m.id AS manifest_id, m.guid AS manifest_guid, ch->>'guid' AS guid, ch->>'type' AS type,
+    ch->>'address' AS address
+    FROM manifests m, JSONB_ARRAY_ELEMENTS(m.funding->'channels') ch
+    WHERE m.status = 'active' ORDER BY m.id;
+
+-- name: export-crawl-runs
+SELECT id, started_at, finished_at, total, success, failed, skipped, latency_p50, latency_p95
+    FROM crawl_runs ORDER BY id;
+
+-- name: export-changes
+SELECT id, manifest_id, guid, url, event, hash, created_at FROM manifest_changes ORDER BY id;
+
+-- name: get-repos-for-scorecard
+-- Repositories of active projects on hosts supported by Scorecard that haven't
+-- been scored (or attempted) within the given interval.
+SELECT DISTINCT p.repository_url FROM projects p
+    JOIN manifests m ON (m.id = p.manifest_id)
+    LEFT JOIN scorecards s ON (s.repository_url = p.repository_url)
+    WHERE m.status = 'active'
+    AND (p.repository_url LIKE 'https://github.com/%' OR p.repository_url LIKE 'https://gitlab.com/%')
+    AND (s.updated_at IS NULL OR s.updated_at < NOW() - $1::INTERVAL)
+    LIMIT $2;
+
+-- name: upsert-scorecard
+-- On errors ($5), the last known score is retained.
+INSERT INTO scorecards (repository_url, score, checks, scored_at, last_error)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (repository_url) DO UPDATE SET
+        score = (CASE WHEN $5 = '' THEN EXCLUDED.score ELSE scorecards.score END),
+        checks = (CASE WHEN $5 = '' THEN EXCLUDED.checks ELSE scorecards.checks END),
+        scored_at = (CASE WHEN $5 = '' THEN EXCLUDED.scored_at ELSE scorecards.scored_at END),
+        last_error = EXCLUDED.last_error,
+        updated_at = NOW();
+
+-- name: get-scorecards
+SELECT * FROM scorecards WHERE repository_url = ANY($1::TEXT[]);
+
+-- name: get-repos-for-activity
+-- Repositories of active projects on supported forges whose activity metrics
+-- haven't been refreshed (or attempted) within the given interval.
+SELECT DISTINCT p.repository_url FROM projects p
+    JOIN manifests m ON (m.id = p.manifest_id)
+    LEFT JOIN repo_activity a ON (a.repository_url = p.repository_url)
+    WHERE m.status = 'active'
+    AND (p.repository_url LIKE 'https://github.com/%' OR p.repository_url LIKE 'https://gitlab.com/%')
+    AND (a.updated_at IS NULL OR a.updated_at < NOW() - $1::INTERVAL)
+    LIMIT $2;
+
+-- name: upsert-repo-activity
+-- On errors ($5), the last known metrics are retained.
+INSERT INTO repo_activity (repository_url, last_commit_at, contributors, open_issues, last_error)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (repository_url) DO UPDATE SET
+        last_commit_at = (CASE WHEN $5 = '' THEN EXCLUDED.last_commit_at ELSE repo_activity.last_commit_at END),
+        contributors = (CASE WHEN $5 = '' THEN EXCLUDED.contributors ELSE repo_activity.contributors END),
+        open_issues = (CASE WHEN $5 = '' THEN EXCLUDED.open_issues ELSE repo_activity.open_issues END),
+        last_error = EXCLUDED.last_error,
+        updated_at = NOW();
+
+-- name: get-repo-activity
+SELECT * FROM repo_activity WHERE repository_url = ANY($1::TEXT[]);
+
+-- name: get-for-translation
+-- Descriptions of active manifests (entity and projects) that haven't been translated
+-- to the given locales, or that have changed since they were translated.
+WITH src AS (
+    SELECT e.manifest_id, 'entity' AS kind, '' AS guid, COALESCE(e.description, '') AS description FROM entities e
+        JOIN manifests m ON (m.id = e.manifest_id) WHERE m.status = 'active'
+    UNION ALL
+    SELECT p.manifest_id, 'project' AS kind, p.guid, p.description FROM projects p
+        JOIN manifests m ON (m.id = p.manifest_id) WHERE m.status = 'active'
+)
+SELECT src.manifest_id, src.kind, src.guid, l.locale, src.description FROM src
+    CROSS JOIN UNNEST($1::TEXT[]) AS l(locale)
+    LEFT JOIN translations t ON (t.manifest_id = src.manifest_id AND t.kind = src.kind AND t.guid = src.guid AND t.locale = l.locale)
+    WHERE src.description != '' AND t.source_hash IS DISTINCT FROM MD5(src.description)
+    ORDER BY src.manifest_id LIMIT $2;
+
+-- name: upsert-translation
+INSERT INTO translations (manifest_id, kind, guid, locale, text, source_hash)
+    VALUES ($1, $2, $3, $4, $5, MD5($6::TEXT))
+    ON CONFLICT (manifest_id, kind, guid, locale) DO UPDATE SET
+        text = EXCLUDED.text,
+        source_hash = EXCLUDED.source_hash,
+        updated_at = NOW();
+
+-- name: get-translations
+SELECT manifest_id, kind, guid, locale, text, updated_at FROM translations
+    WHERE manifest_id = $1 AND ($2::TEXT = '' OR locale = $2)
+    ORDER BY kind, guid, locale;
+
+-- name: insert-api-key
+INSERT INTO api_keys (name, key_hash, daily_quota) VALUES ($1, $2, $3)
+    RETURNING id, name, daily_quota, enabled, created_at, 0 AS requests_today;
+
+-- name: get-api-keys
+SELECT k.id, k.name, k.daily_quota, k.enabled, k.created_at,
+    COALESCE((SELECT SUM(requests) FROM api_key_usage u WHERE u.key_id = k.id AND u.day = CURRENT_DATE), 0) AS requests_today
+    FROM api_keys k ORDER BY k.id;
+
+-- name: get-api-key
+SELECT k.id, k.name, k.daily_quota, k.enabled, k.created_at,
+    COALESCE((SELECT SUM(requests) FROM api_key_usage u WHERE u.key_id = k.id AND u.day = CURRENT_DATE), 0) AS requests_today
+    FROM api_keys k WHERE k.key_hash = $1 AND k.enabled = true;
+
+-- name: delete-api-key
+DELETE FROM api_keys WHERE id = $1;
+
+-- name: record-api-key-usage
+INSERT INTO api_key_usage (key_id, endpoint, requests, errors) VALUES ($1, $2, 1, (CASE WHEN $3 THEN 1 ELSE 0 END))
+    ON CONFLICT (key_id, day, endpoint) DO UPDATE SET
+        requests = api_key_usage.requests + 1,
+        errors = api_key_usage.errors + EXCLUDED.errors;
+
+-- name: get-api-key-usage
+SELECT day, endpoint, requests, errors FROM api_key_usage
+    WHERE key_id = $1 AND day > CURRENT_DATE - $2::INT
+    ORDER BY day DESC, requests DESC;
+
+-- name: insert-webhook
+INSERT INTO manifest_webhooks (manifest_id, url, events, secret, token_hash) VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, manifest_id, url, events, confirmed, enabled, failures, last_status, last_delivered_at, created_at;
+
+-- name: count-webhooks
+SELECT COUNT(*) FROM manifest_webhooks WHERE manifest_id = $1;
+
+-- name: get-webhook
+SELECT id, manifest_id, url, events, confirmed, enabled, failures, last_status, last_delivered_at, created_at
+    FROM manifest_webhooks WHERE token_hash = $1;
+
+-- name: confirm-webhook
+UPDATE manifest_webhooks SET confirmed = true, enabled = true, failures = 0 WHERE token_hash = $1
+    RETURNING id, manifest_id, url, events, confirmed, enabled, failures, last_status, last_delivered_at, created_at, secret;
+
+-- name: delete-webhook
+DELETE FROM manifest_webhooks WHERE token_hash = $1;
+
+-- name: get-webhooks-for-event
+-- Confirmed, enabled webhooks of a manifest subscribed to an event.
+SELECT id, manifest_id, url, events, confirmed, enabled, failures, last_status, last_delivered_at, created_at, secret
+    FROM manifest_webhooks WHERE manifest_id = $1 AND $2 = ANY(events) AND confirmed = true AND enabled = true;
+
+-- name: update-webhook-delivery
+-- Records the result of a delivery. Webhooks are disabled after $4 consecutive failures.
+UPDATE manifest_webhooks SET
+    last_status = $2,
+    last_delivered_at = NOW(),
+    failures = (CASE WHEN $3 THEN 0 ELSE failures + 1 END),
+    enabled = (CASE WHEN $3 THEN true ELSE failures + 1 < $4 END)
+    WHERE id = $1;
+
+-- name: get-provenance-errors
+-- Provenance crawl errors of manifests after the given ID.
+SELECT e.id, e.manifest_id, m.guid, m.url, e.message, e.created_at FROM crawl_errors e
+    JOIN manifests m ON (m.id = e.manifest_id)
+    WHERE e.id > $1 AND e.class = 'provenance' ORDER BY e.id LIMIT $2;
+
+-- name: get-last-crawl-error-id
+SELECT COALESCE(MAX(id), 0) FROM crawl_errors;
+
+-- name: get-cursor
+-- Returns the stored cursor ($1), eg: the ID of the last processed change, creating it with $2 if it doesn't exist.
+WITH ins AS (
+    INSERT INTO settings (key, value) VALUES ($1, TO_JSONB($2::BIGINT))
+    ON CONFLICT (key) DO NOTHING RETURNING value
+)
+SELECT (value #>> '{}')::BIGINT FROM ins UNION ALL SELECT (value #>> '{}')::BIGINT FROM settings WHERE key = $1 LIMIT 1;
+
+-- name: claim-cursor
+-- Advances a cursor ($1) from $2 to $3 if it hasn't been advanced (eg: by another instance) in the meantime.
+UPDATE settings SET value = TO_JSONB($3::BIGINT), updated_at = NOW() WHERE key = $1 AND value = TO_JSONB($2::BIGINT);
+
+-- name: get-funding-summaries
+-- Active listings whose projects' repository or webpage URLs, or entities' webpage URLs,
+-- match the given keys (lowercased host and path without the scheme, www., and trailing / or .git).
+-- Repository matches take precedence over project webpages, and then entity webpages.
+WITH q AS (SELECT UNNEST($1::TEXT[]) AS key),
+matches AS (
+    SELECT q.key, p.manifest_id, p.guid AS project_guid, p.name AS project_name, 1 AS rank FROM q
+        JOIN projects p ON LOWER(REGEXP_REPLACE(p.repository_url, '^https?://(www\.)?|/+$|\.git/*$', '', 'g')) = q.key
+    UNION ALL
+    SELECT q.key, p.manifest_id, p.guid, p.name, 2 FROM q
+        JOIN projects p ON LOWER(REGEXP_REPLACE(p.webpage_url, '^https?://(www\.)?|/+$', '', 'g')) = q.key
+    UNION ALL
+    SELECT q.key, e.manifest_id, '', '', 3 FROM q
+        JOIN entities e ON LOWER(REGEXP_REPLACE(e.webpage_url, '^https?://(www\.)?|/+$', '', 'g')) = q.key
+)
+SELECT DISTINCT ON (x.key) x.key, m.guid AS manifest_guid, e.name AS entity_name,
+    x.project_guid, x.project_name, m.verification,
+    ARRAY(SELECT DISTINCT ch->>'type' FROM JSONB_ARRAY_ELEMENTS(m.funding->'channels') ch ORDER BY 1) AS channels
+    FROM matches x
+    JOIN manifests m ON (m.id = x.manifest_id AND m.status = 'active')
+    JOIN entities e ON (e.manifest_id = m.id)
+    ORDER BY x.key, x.rank, m.id;
+
+-- name: claim-payment-changes
+-- Marks up to $1 payment changes that haven't been alerted as alerted and returns them.
+-- Changes that are being claimed by other site instances are skipped.
+WITH c AS (
+    UPDATE payment_changes SET alerted_at = NOW() WHERE id IN (
+        SELECT id FROM payment_changes WHERE alerted_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+    ) RETURNING *
+)
+SELECT c.id, c.manifest_id, m.guid, m.url, c.old_channels, c.new_channels, c.created_at FROM c
+    JOIN manifests m ON (m.id = c.manifest_id) ORDER BY c.id;
+
+-- name: get-instance-id
+-- Returns the instance's random ID, creating it ($1) if it doesn't exist.
+WITH ins AS (
+    INSERT INTO settings (key, value) VALUES ('instance_id', TO_JSONB($1::TEXT))
+    ON CONFLICT (key) DO NOTHING RETURNING value
+)
+SELECT value #>> '{}' FROM ins UNION ALL SELECT value #>> '{}' FROM settings WHERE key = 'instance_id' LIMIT 1;
+
+-- name: get-instance-stats
+SELECT (SELECT COUNT(*) FROM manifests WHERE status = 'active') AS manifests,
+    (SELECT COUNT(*) FROM projects p JOIN manifests m ON (m.id = p.manifest_id AND m.status = 'active')) AS projects;
+
+-- name: upsert-telemetry-report
+INSERT INTO telemetry_reports (instance_id, version, manifests, projects) VALUES ($1, $2, $3, $4)
+    ON CONFLICT (instance_id) DO UPDATE SET version = EXCLUDED.version, manifests = EXCLUDED.manifests,
+    projects = EXCLUDED.projects, reported_at = NOW();
+
+-- name: get-telemetry-versions
+-- Instances that have reported in the given interval, grouped by their versions.
+SELECT version, COUNT(*) AS instances, SUM(manifests) AS manifests, SUM(projects) AS projects
+    FROM telemetry_reports WHERE reported_at > NOW() - $1::INTERVAL
+    GROUP BY version ORDER BY instances DESC, version;
+
+-- name: add-tenant-manifest
+-- Returns false if the manifest doesn't exist.
+WITH ins AS (
+    INSERT INTO tenant_manifests (tenant, manifest_id) SELECT $1, id FROM manifests WHERE id = $2
+    ON CONFLICT (tenant, manifest_id) DO NOTHING
+)
+SELECT EXISTS (SELECT 1 FROM manifests WHERE id = $2);
+
+-- name: delete-tenant-manifest
+DELETE FROM tenant_manifests WHERE tenant = $1 AND manifest_id = $2;
+
+-- name: get-tenant-manifests
+SELECT m.id, m.url, m.status, t.created_at FROM tenant_manifests t
+    JOIN manifests m ON (m.id = t.manifest_id)
+    WHERE t.tenant = $1 AND m.id > $2 ORDER BY m.id LIMIT $3;
+
+-- name: get-manifest-tenants
+SELECT COALESCE(ARRAY_AGG(tenant ORDER BY tenant), '{}') FROM tenant_manifests WHERE manifest_id = $1;
@@ -2,7 +2,7 @@ CREATE EXTENSION IF NOT EXISTS pgcrypto;
 CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
 -- manifests
-DROP TYPE IF EXISTS manifest_status CASCADE; CREATE TYPE manifest_status AS ENUM ('pending', 'active', 'expiring', 'disabled', 'blocked');
+DROP TYPE IF EXISTS manifest_status CASCADE; CREATE TYPE manifest_status AS ENUM ('pending', 'active', 'expiring', 'disabled', 'blocked', 'preview');
 DROP TABLE IF EXISTS manifests CASCADE;
 CREATE TABLE manifests (
     id                   SERIAL PRIMARY KEY,
@@ -17,9 +17,52 @@ CREATE TABLE manifests (
     crawl_errors         INT NOT NULL DEFAULT 0,
     crawl_message        TEXT NULL,
 
+    -- Number of consecutive crawls on which the manifest URL responded with 404 or 410.
+    gone_streak          INT NOT NULL DEFAULT 0,
+
+    -- SHA-256 of the manifest's contents for detecting changes.
+    hash                 TEXT NOT NULL DEFAULT '',
+
+    -- ETag of the last crawled response for conditional re-crawls.
+    etag                 TEXT NOT NULL DEFAULT '',
+
+    -- Hash of the token of the private preview URL of a manifest in preview.
+    preview_token_hash   TEXT NOT NULL DEFAULT '',
+
+    -- SHA-256 of the raw body of the last crawled response. Crawls that fetch
+    -- the same body skip parsing and validating it.
+    body_hash            TEXT NOT NULL DEFAULT '',
+
+    -- SHA-256 of the raw manifest body pinned at submission. Crawls that
+    -- fetch any other content are rejected.
+    pinned_hash          TEXT NOT NULL DEFAULT '',
+
+    -- ID of the submission request for tracing it across logs and crawl errors.
+    request_id           TEXT NOT NULL DEFAULT '',
+
+    -- Per-manifest overrides of the crawler's fetch limits (eg: large fiscal host
+    -- manifests). NULL uses the global limits.
+    fetch_max_bytes      BIGINT NULL,
+    fetch_timeout_ms     INTEGER NULL,
+    fetch_attempts       INTEGER NULL,
+
+    -- Trust level: unverified, provenance-verified, forge-verified, signed, admin-verified.
+    verification         TEXT NOT NULL DEFAULT 'unverified',
+
+    -- The submitter opted out of relaying the manifest to upstream instances, and
+    -- the downstream instance (root URL) the manifest was relayed from, if any.
+    relay_optout         BOOLEAN NOT NULL DEFAULT false,
+    relay_source         TEXT NOT NULL DEFAULT '',
+
+    -- Channel the manifest was first submitted through (web, api, email, relay) and a
+    -- reference within it for attribution (eg: API key name, relaying instance).
+    intake               TEXT NOT NULL DEFAULT '',
+    intake_ref           TEXT NOT NULL DEFAULT '',
+
     created_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
     updated_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 );
+DROP INDEX IF EXISTS idx_manifests_verification; CREATE INDEX idx_manifests_verification ON manifests(verification);
 DROP INDEX IF EXISTS idx_funding_channels; CREATE INDEX idx_funding_channels ON manifests USING GIN ((funding->'channels'));
 DROP INDEX IF EXISTS idx_funding_plans; CREATE INDEX idx_funding_plans ON manifests USING GIN ((funding->'plans'));
 DROP INDEX IF EXISTS idx_funding_history; CREATE INDEX idx_funding_history ON manifests USING GIN ((funding->'history'));
@@ -48,6 +91,7 @@ CREATE TABLE IF NOT EXISTS entities (
 DROP INDEX IF EXISTS idx_entity_manifest; CREATE INDEX idx_entity_manifest ON entities(manifest_id);
 DROP INDEX IF EXISTS idx_entity_name; CREATE INDEX idx_entity_name ON entities USING GIN (LOWER(name) gin_trgm_ops);
 DROP INDEX IF EXISTS idx_entity_email; CREATE INDEX idx_entity_email ON entities(LOWER(email));
+DROP INDEX IF EXISTS idx_entity_webpage_key; CREATE INDEX idx_entity_webpage_key ON entities((LOWER(REGEXP_REPLACE(webpage_url, '^https?://(www\.)?|/+$', '', 'g'))));
 
 -- projects
 DROP TABLE IF EXISTS projects CASCADE;
@@ -74,6 +118,8 @@ DROP INDEX IF EXISTS idx_project_manifest; CREATE INDEX idx_project_manifest ON
 DROP INDEX IF EXISTS idx_project_name; CREATE INDEX idx_project_name ON projects USING GIN (LOWER(name) gin_trgm_ops);
 DROP INDEX IF EXISTS idx_project_licenses; CREATE INDEX idx_project_licenses ON projects USING GIN (licenses);
 DROP INDEX IF EXISTS idx_project_tags; CREATE INDEX idx_project_tags ON projects USING GIN (tags);
+DROP INDEX IF EXISTS idx_project_repository_key; CREATE INDEX idx_project_repository_key ON projects((LOWER(REGEXP_REPLACE(repository_url, '^https?://(www\.)?|/+$|\.git/*$', '', 'g'))));
+DROP INDEX IF EXISTS idx_project_webpage_key; CREATE INDEX idx_project_webpage_key ON projects((LOWER(REGEXP_REPLACE(webpage_url, '^https?://(www\.)?|/+$', '', 'g'))));
 
 -- settings
 DROP TABLE IF EXISTS settings CASCADE;
@@ -97,4 +143,394 @@ CREATE TABLE IF NOT EXISTS reports (
     reason              TEXT NOT NULL,
     created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-);
\ No newline at end of file
+);
+
+-- favicons and link-preview metadata of entity webpages captured during crawls.
+DROP TABLE IF EXISTS favicons CASCADE;
+CREATE TABLE IF NOT EXISTS favicons (
+    manifest_id         INTEGER NOT NULL UNIQUE REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    body                BYTEA NOT NULL,
+    content_type        TEXT NOT NULL,
+    opengraph           JSONB NOT NULL DEFAULT '{}',
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- last validated copies of manifests, served when their origins are down.
+DROP TABLE IF EXISTS manifest_mirrors CASCADE;
+CREATE TABLE IF NOT EXISTS manifest_mirrors (
+    manifest_id         INTEGER NOT NULL UNIQUE REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    body                BYTEA NOT NULL,
+    hash                TEXT NOT NULL DEFAULT '',
+    fetched_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- OpenSSF Scorecard results of project repositories.
+DROP TABLE IF EXISTS scorecards CASCADE;
+CREATE TABLE IF NOT EXISTS scorecards (
+    repository_url      TEXT NOT NULL PRIMARY KEY,
+    score               REAL NULL,
+    checks              JSONB NOT NULL DEFAULT '[]',
+    scored_at           TIMESTAMP WITH TIME ZONE NULL,
+    last_error          TEXT NOT NULL DEFAULT '',
+    updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- activity metrics of project repositories from forge APIs.
+DROP TABLE IF EXISTS repo_activity CASCADE;
+CREATE TABLE IF NOT EXISTS repo_activity (
+    repository_url      TEXT NOT NULL PRIMARY KEY,
+    last_commit_at      TIMESTAMP WITH TIME ZONE NULL,
+    contributors        INT NOT NULL DEFAULT 0,
+    open_issues         INT NOT NULL DEFAULT 0,
+    last_error          TEXT NOT NULL DEFAULT '',
+    updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Machine translations of entity and project descriptions (--mode=translate).
+-- guid is empty for entities. source_hash is the MD5 of the translated description.
+DROP TABLE IF EXISTS translations CASCADE;
+CREATE TABLE IF NOT EXISTS translations (
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    kind                TEXT NOT NULL,
+    guid                TEXT NOT NULL DEFAULT '',
+    locale              TEXT NOT NULL,
+    text                TEXT NOT NULL,
+    source_hash         TEXT NOT NULL,
+    updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (manifest_id, kind, guid, locale)
+);
+
+-- API keys issued to integrators and their daily usage per endpoint.
+DROP TABLE IF EXISTS api_keys CASCADE;
+CREATE TABLE IF NOT EXISTS api_keys (
+    id                  SERIAL PRIMARY KEY,
+    name                TEXT NOT NULL,
+
+    -- SHA-256 of the key. The key itself is not stored.
+    key_hash            TEXT NOT NULL UNIQUE,
+    daily_quota         INT NOT NULL DEFAULT 10000,
+    enabled             BOOLEAN NOT NULL DEFAULT true,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+DROP TABLE IF EXISTS api_key_usage CASCADE;
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    key_id              INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    day                 DATE NOT NULL DEFAULT CURRENT_DATE,
+    endpoint            TEXT NOT NULL,
+    requests            INT NOT NULL DEFAULT 0,
+    errors              INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day, endpoint)
+);
+
+-- crawl runs and their stats for tracking the crawler's health.
+DROP TABLE IF EXISTS crawl_runs CASCADE;
+CREATE TABLE IF NOT EXISTS crawl_runs (
+    id                  SERIAL PRIMARY KEY,
+    started_at          TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    total               INT NOT NULL DEFAULT 0,
+    success             INT NOT NULL DEFAULT 0,
+    failed              INT NOT NULL DEFAULT 0,
+    skipped             INT NOT NULL DEFAULT 0,
+    latency_p50         INT NOT NULL DEFAULT 0,
+    latency_p95         INT NOT NULL DEFAULT 0
+);
+DROP INDEX IF EXISTS idx_crawl_runs_started; CREATE INDEX idx_crawl_runs_started ON crawl_runs(started_at);
+
+-- availability of manifest URLs recorded by liveness sweeps.
+DROP TABLE IF EXISTS manifest_liveness CASCADE;
+CREATE TABLE IF NOT EXISTS manifest_liveness (
+    manifest_id         INTEGER NOT NULL PRIMARY KEY REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    available           BOOLEAN NOT NULL,
+    status_code         INT NOT NULL DEFAULT 0,
+    message             TEXT NOT NULL DEFAULT '',
+    checked_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    down_since          TIMESTAMP WITH TIME ZONE NULL
+);
+
+-- hosts that all the manifests of were unreachable on the last liveness sweep. Their
+-- scheduled recrawls are deferred until a sweep finds them up again.
+DROP TABLE IF EXISTS host_downtime CASCADE;
+CREATE TABLE IF NOT EXISTS host_downtime (
+    host                TEXT NOT NULL PRIMARY KEY,
+    down_since          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    checked_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- admin-requested captures of the full requests and responses of the next crawl of manifests.
+DROP TABLE IF EXISTS manifest_traces CASCADE;
+CREATE TABLE IF NOT EXISTS manifest_traces (
+    manifest_id         INTEGER NOT NULL PRIMARY KEY REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    armed               BOOLEAN NOT NULL DEFAULT true,
+    trace               JSONB NULL,
+    requested_at        TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    captured_at         TIMESTAMP WITH TIME ZONE NULL
+);
+
+-- crawl failures with normalized error classes for analytics.
+DROP TABLE IF EXISTS crawl_errors CASCADE;
+CREATE TABLE IF NOT EXISTS crawl_errors (
+    id                  BIGSERIAL PRIMARY KEY,
+    manifest_id         INTEGER NULL REFERENCES manifests(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    host                TEXT NOT NULL,
+    class               TEXT NOT NULL,
+    message             TEXT NOT NULL DEFAULT '',
+    request_id          TEXT NOT NULL DEFAULT '',
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_crawl_errors_created; CREATE INDEX idx_crawl_errors_created ON crawl_errors(created_at);
+DROP INDEX IF EXISTS idx_crawl_errors_host; CREATE INDEX idx_crawl_errors_host ON crawl_errors(host);
+
+-- previous URLs of manifests that have moved (301 redirects or declared moves).
+DROP TABLE IF EXISTS manifest_aliases CASCADE;
+CREATE TABLE IF NOT EXISTS manifest_aliases (
+    id                  SERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    url                 TEXT NOT NULL UNIQUE,
+    reason              TEXT NOT NULL,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_manifest_aliases_manifest; CREATE INDEX idx_manifest_aliases_manifest ON manifest_aliases(manifest_id);
+
+-- manifests linked to a parent manifest of the same entity (eg: per-project manifests)
+-- that are presented together on the parent entity's pages.
+DROP TABLE IF EXISTS entity_links CASCADE;
+CREATE TABLE IF NOT EXISTS entity_links (
+    manifest_id         INTEGER NOT NULL UNIQUE REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    parent_id           INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CHECK (manifest_id != parent_id)
+);
+DROP INDEX IF EXISTS idx_entity_links_parent; CREATE INDEX idx_entity_links_parent ON entity_links(parent_id);
+
+-- public change feed of listings. Changes are recorded by a trigger on manifests.
+-- Only active manifests are listings, so a manifest becoming active is a 'create'
+-- and a manifest ceasing to be active (or being deleted) is a 'delete'.
+DROP TABLE IF EXISTS manifest_changes CASCADE;
+CREATE TABLE IF NOT EXISTS manifest_changes (
+    id                  BIGSERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL,
+    guid                TEXT NOT NULL,
+    url                 TEXT NOT NULL,
+    event               TEXT NOT NULL,
+    hash                TEXT NOT NULL DEFAULT '',
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE OR REPLACE FUNCTION record_manifest_change() RETURNS TRIGGER AS $$
+DECLARE
+    ev TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.status = 'active' THEN
+            INSERT INTO manifest_changes (manifest_id, guid, url, event, hash) VALUES (OLD.id, OLD.guid, OLD.url, 'delete', OLD.hash);
+        END IF;
+        RETURN OLD;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.status = 'active' THEN
+            ev := 'create';
+        END IF;
+    ELSIF OLD.status != 'active' AND NEW.status = 'active' THEN
+        ev := 'create';
+    ELSIF OLD.status = 'active' AND NEW.status != 'active' THEN
+        ev := 'delete';
+    ELSIF NEW.status = 'active' AND (OLD.hash != NEW.hash OR OLD.url != NEW.url) THEN
+        ev := 'update';
+    END IF;
+
+    IF ev IS NOT NULL THEN
+        INSERT INTO manifest_changes (manifest_id, guid, url, event, hash) VALUES (NEW.id, NEW.guid, NEW.url, ev, NEW.hash);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_manifest_changes ON manifests;
+CREATE TRIGGER trg_manifest_changes AFTER INSERT OR UPDATE OR DELETE ON manifests
+    FOR EACH ROW EXECUTE FUNCTION record_manifest_change();
+
+-- yearly funding goals and received amounts of manifests (per currency) over time.
+-- A snapshot is recorded whenever the figures change.
+DROP TABLE IF EXISTS funding_snapshots CASCADE;
+CREATE TABLE IF NOT EXISTS funding_snapshots (
+    id                  BIGSERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    currency            TEXT NOT NULL,
+    goal                NUMERIC NOT NULL DEFAULT 0,
+    received            NUMERIC NOT NULL DEFAULT 0,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_funding_snapshots_manifest; CREATE INDEX idx_funding_snapshots_manifest ON funding_snapshots(manifest_id, currency, id);
+
+-- contents of manifests over time. A version is recorded whenever the contents change.
+DROP TABLE IF EXISTS manifest_versions CASCADE;
+CREATE TABLE IF NOT EXISTS manifest_versions (
+    id                  BIGSERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    hash                TEXT NOT NULL,
+    body                JSONB NOT NULL,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_manifest_versions_manifest; CREATE INDEX idx_manifest_versions_manifest ON manifest_versions(manifest_id, id);
+
+-- curated mapping of project tags to top-level categories.
+DROP TABLE IF EXISTS tag_categories CASCADE;
+CREATE TABLE IF NOT EXISTS tag_categories (
+    tag                 TEXT NOT NULL PRIMARY KEY,
+    category            TEXT NOT NULL,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_tag_categories_category; CREATE INDEX idx_tag_categories_category ON tag_categories(category);
+
+-- similar projects of every project, computed offline (-mode=related).
+DROP TABLE IF EXISTS related_projects CASCADE;
+CREATE TABLE IF NOT EXISTS related_projects (
+    project_id          INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    related_id          INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    score               REAL NOT NULL DEFAULT 0
+);
+DROP INDEX IF EXISTS idx_related_projects; CREATE INDEX idx_related_projects ON related_projects(project_id);
+
+-- shared denylist of payment addresses known to be fraudulent.
+DROP TABLE IF EXISTS payment_denylist CASCADE;
+CREATE TABLE IF NOT EXISTS payment_denylist (
+    address             TEXT NOT NULL PRIMARY KEY,
+    channel_type        TEXT NOT NULL DEFAULT '',
+    reason              TEXT NOT NULL DEFAULT '',
+    source              TEXT NOT NULL DEFAULT '',
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- admin-verified fiscal hosts (eg: foundations) that vouch for member manifests
+-- with a signed member list.
+DROP TABLE IF EXISTS fiscal_hosts CASCADE;
+CREATE TABLE IF NOT EXISTS fiscal_hosts (
+    id                  SERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL UNIQUE REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    -- base64 ed25519 public key that the member list is signed with.
+    public_key          TEXT NOT NULL,
+
+    -- URL of the member list (one manifest URL per line). The base64 ed25519
+    -- signature of the list is at the same URL suffixed with .sig
+    members_url         TEXT NOT NULL,
+    num_members         INT NOT NULL DEFAULT 0,
+    last_error          TEXT NOT NULL DEFAULT '',
+    refreshed_at        TIMESTAMP WITH TIME ZONE NULL,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+DROP TABLE IF EXISTS fiscal_host_members CASCADE;
+CREATE TABLE IF NOT EXISTS fiscal_host_members (
+    fiscal_host_id      INTEGER NOT NULL REFERENCES fiscal_hosts(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    url                 TEXT NOT NULL,
+    PRIMARY KEY (fiscal_host_id, url)
+);
+DROP INDEX IF EXISTS idx_fiscal_host_members_url; CREATE INDEX idx_fiscal_host_members_url ON fiscal_host_members(url);
+
+-- webhooks registered by maintainers, scoped to their own manifests. A webhook is
+-- delivered only after it's confirmed via the link e-mailed to the manifest's entity.
+DROP TABLE IF EXISTS manifest_webhooks CASCADE;
+CREATE TABLE IF NOT EXISTS manifest_webhooks (
+    id                  SERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    url                 TEXT NOT NULL,
+
+    -- update, delist, provenance-lost
+    events              TEXT[] NOT NULL,
+
+    -- HMAC-SHA256 key that deliveries are signed with.
+    secret              TEXT NOT NULL,
+
+    -- SHA-256 of the management token. The token itself is not stored.
+    token_hash          TEXT NOT NULL UNIQUE,
+    confirmed           BOOLEAN NOT NULL DEFAULT false,
+
+    -- Consecutive failed deliveries. The webhook is disabled after too many.
+    failures            INT NOT NULL DEFAULT 0,
+    enabled             BOOLEAN NOT NULL DEFAULT true,
+    last_status         INT NOT NULL DEFAULT 0,
+    last_delivered_at   TIMESTAMP WITH TIME ZONE NULL,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_manifest_webhooks_manifest; CREATE INDEX idx_manifest_webhooks_manifest ON manifest_webhooks(manifest_id);
+
+-- changes to the funding channels (payment details) of active manifests between
+-- crawls, recorded for alerting as they're the prime target of hijacking.
+DROP TABLE IF EXISTS payment_changes CASCADE;
+CREATE TABLE IF NOT EXISTS payment_changes (
+    id                  BIGSERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    old_channels        JSONB NOT NULL DEFAULT '[]',
+    new_channels        JSONB NOT NULL DEFAULT '[]',
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    -- Set when a site instance claims the change for alerting.
+    alerted_at          TIMESTAMP WITH TIME ZONE NULL
+);
+DROP INDEX IF EXISTS idx_payment_changes_unalerted; CREATE INDEX idx_payment_changes_unalerted ON payment_changes(id) WHERE alerted_at IS NULL;
+
+CREATE OR REPLACE FUNCTION record_payment_change() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO payment_changes (manifest_id, old_channels, new_channels)
+        VALUES (NEW.id, COALESCE(OLD.funding->'channels', '[]'), COALESCE(NEW.funding->'channels', '[]'));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_payment_changes ON manifests;
+CREATE TRIGGER trg_payment_changes AFTER UPDATE OF funding ON manifests
+    FOR EACH ROW WHEN (OLD.status = 'active' AND OLD.funding->'channels' IS DISTINCT FROM NEW.funding->'channels')
+    EXECUTE FUNCTION record_payment_change();
+
+-- anonymous aggregate stats reported by (opted-in) self-hosted instances, on the
+-- central instance. Instances are identified by a random ID.
+DROP TABLE IF EXISTS telemetry_reports CASCADE;
+CREATE TABLE IF NOT EXISTS telemetry_reports (
+    instance_id         TEXT NOT NULL PRIMARY KEY,
+    version             TEXT NOT NULL DEFAULT '',
+    manifests           INTEGER NOT NULL DEFAULT 0,
+    projects            INTEGER NOT NULL DEFAULT 0,
+    reported_at         TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- adaptive re-crawl schedule of manifests (-mode=schedule).
+DROP TABLE IF EXISTS crawl_schedule CASCADE;
+CREATE TABLE IF NOT EXISTS crawl_schedule (
+    manifest_id         INTEGER NOT NULL PRIMARY KEY REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    interval_secs       INTEGER NOT NULL DEFAULT 0,
+    next_at             TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_crawl_schedule_next; CREATE INDEX idx_crawl_schedule_next ON crawl_schedule(next_at);
+
+-- durable queue of crawl jobs (crawl.queue) that interrupted crawls resume from.
+DROP TABLE IF EXISTS crawl_queue CASCADE;
+CREATE TABLE IF NOT EXISTS crawl_queue (
+    manifest_id         INTEGER NOT NULL PRIMARY KEY REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    priority            SMALLINT NOT NULL DEFAULT 0,
+
+    -- Number of failed attempts. Jobs are retried after available_at.
+    attempts            INTEGER NOT NULL DEFAULT 0,
+    available_at        TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    leased_until        TIMESTAMP WITH TIME ZONE NULL,
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_crawl_queue_available; CREATE INDEX idx_crawl_queue_available ON crawl_queue(priority, available_at);
+
+-- listings of tenant sub-portals (tenants in the config), by the tenant's slug.
+DROP TABLE IF EXISTS tenant_manifests CASCADE;
+CREATE TABLE IF NOT EXISTS tenant_manifests (
+    tenant              TEXT NOT NULL,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (tenant, manifest_id)
+);
+DROP INDEX IF EXISTS idx_tenant_manifests_manifest; CREATE INDEX idx_tenant_manifests_manifest ON tenant_manifests(manifest_id);
@@ -39,3 +39,34 @@ document.addEventListener("keydown", function(event) {
     q.select();
   }
 });
+
+// Live ticker of new and updated listings on the homepage.
+const live = document.querySelector("[data-live-feed]");
+if (live && window.EventSource) {
+  const list = live.querySelector("ul");
+  const src = new EventSource("/api/v1/live");
+
+  const onEvent = (e) => {
+    const d = JSON.parse(e.data);
+    if (!d.name) {
+      return;
+    }
+
+    const a = document.createElement("a");
+    a.href = `/view/${d.guid}`;
+    a.textContent = d.name;
+
+    const li = document.createElement("li");
+    li.append(a, ` ${e.type === "create" ? "was listed" : "was updated"}`);
+    list.prepend(li);
+
+    // Keep the last few items.
+    while (list.children.length > 5) {
+      list.lastChild.remove();
+    }
+    live.hidden = false;
+  };
+
+  src.addEventListener("create", onEvent);
+  src.addEventListener("update", onEvent);
+}